@@ -27,6 +27,9 @@ type (
 		Profiling Profiling `mapstructure:"PROFILING"`
 		Swagger   Swagger   `mapstructure:"SWAGGER"`
 		JWT       JWT       `mapstructure:"JWT"`
+		Auth      Auth      `mapstructure:"AUTH"`
+		Mail      Mail      `mapstructure:"MAIL"`
+		VietQR    VietQR    `mapstructure:"VIETQR"`
 	}
 
 	// App -.
@@ -45,6 +48,9 @@ type (
 		ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
 		UsePreforkMode  bool          `mapstructure:"USE_PREFORK_MODE"`
 		ApiTimeout      time.Duration `mapstructure:"API_TIMEOUT"`
+		// TrustedProxies lists the IPs or CIDRs allowed to set X-Forwarded-For;
+		// empty trusts no one and the client IP is the peer address.
+		TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
 	}
 
 	// Log -.
@@ -88,10 +94,74 @@ type (
 
 	// Kafka -.
 	Kafka struct {
-		Brokers []string `mapstructure:"BROKERS"`
-		GroupID string   `mapstructure:"GROUP_ID"`
-		Topics  Topics   `mapstructure:"TOPICS"`
-		Control Control  `mapstructure:"CONTROL"`
+		Brokers  []string      `mapstructure:"BROKERS"`
+		GroupID  string        `mapstructure:"GROUP_ID"`
+		Topics   Topics        `mapstructure:"TOPICS"`
+		Control  Control       `mapstructure:"CONTROL"`
+		Outbox   Outbox        `mapstructure:"OUTBOX"`
+		Producer KafkaProducer `mapstructure:"PRODUCER"`
+		Consumer KafkaConsumer `mapstructure:"CONSUMER"`
+		TLS      KafkaTLS      `mapstructure:"TLS"`
+		SASL     KafkaSASL     `mapstructure:"SASL"`
+
+		SchemaRegistry SchemaRegistry `mapstructure:"SCHEMA_REGISTRY"`
+	}
+
+	// KafkaProducer -.
+	KafkaProducer struct {
+		BatchSize    int           `mapstructure:"BATCH_SIZE"`
+		BatchTimeout time.Duration `mapstructure:"BATCH_TIMEOUT"`
+		// Compression is none, gzip, snappy, lz4 or zstd.
+		Compression string `mapstructure:"COMPRESSION"`
+		// RequiredAcks is none, one or all.
+		RequiredAcks string `mapstructure:"REQUIRED_ACKS"`
+		// Balancer is least_bytes, round_robin, hash, murmur2 or crc32.
+		Balancer string `mapstructure:"BALANCER"`
+		// Async applies to payment events only; the outbox relay and dead
+		// letter writes always wait for the brokers.
+		Async bool `mapstructure:"ASYNC"`
+	}
+
+	// KafkaTLS -.
+	KafkaTLS struct {
+		Enabled            bool   `mapstructure:"ENABLED"`
+		CAFile             string `mapstructure:"CA_FILE"`
+		CertFile           string `mapstructure:"CERT_FILE"`
+		KeyFile            string `mapstructure:"KEY_FILE"`
+		InsecureSkipVerify bool   `mapstructure:"INSECURE_SKIP_VERIFY"`
+	}
+
+	// KafkaSASL -.
+	KafkaSASL struct {
+		// Mechanism is none, plain, scram-sha-256 or scram-sha-512.
+		Mechanism string `mapstructure:"MECHANISM"`
+		Username  string `mapstructure:"USERNAME"`
+		Password  string `mapstructure:"PASSWORD"`
+	}
+
+	// SchemaRegistry -.
+	SchemaRegistry struct {
+		URL      string `mapstructure:"URL"`
+		Username string `mapstructure:"USERNAME"`
+		Password string `mapstructure:"PASSWORD"`
+		// ValueFormat is the codec of payment events: "json" or "avro".
+		ValueFormat string `mapstructure:"VALUE_FORMAT"`
+	}
+
+	// KafkaConsumer -.
+	KafkaConsumer struct {
+		Workers        int           `mapstructure:"WORKERS"`
+		WorkerBuffer   int           `mapstructure:"WORKER_BUFFER"`
+		MinBytes       int           `mapstructure:"MIN_BYTES"`
+		MaxBytes       int           `mapstructure:"MAX_BYTES"`
+		MaxWait        time.Duration `mapstructure:"MAX_WAIT"`
+		CommitInterval time.Duration `mapstructure:"COMMIT_INTERVAL"`
+	}
+
+	// Outbox -.
+	Outbox struct {
+		PollInterval time.Duration `mapstructure:"POLL_INTERVAL"`
+		BatchSize    int           `mapstructure:"BATCH_SIZE"`
 	}
 
 	// Control -.
@@ -133,7 +203,123 @@ type (
 	}
 	// JWTConfig -.
 	JWT struct {
+		// Secret signs HS256 tokens when PrivateKeyFile is empty
+		Secret     string        `mapstructure:"SECRET"`
+		AccessTTL  time.Duration `mapstructure:"ACCESS_TTL"`
+		RefreshTTL time.Duration `mapstructure:"REFRESH_TTL"`
+		// KeyID is the kid of the signing key
+		KeyID string `mapstructure:"KEY_ID"`
+		// PrivateKeyFile is a PEM RSA (RS256) or Ed25519 (EdDSA) private key
+		PrivateKeyFile string `mapstructure:"PRIVATE_KEY_FILE"`
+		// VerificationKeys are still accepted after rotation, e.g. the previous signing key
+		VerificationKeys []JWTVerificationKey `mapstructure:"VERIFICATION_KEYS"`
+	}
+
+	// Auth -.
+	Auth struct {
+		// RequireEmailVerification rejects logins until the email is confirmed
+		RequireEmailVerification bool          `mapstructure:"REQUIRE_EMAIL_VERIFICATION"`
+		PasswordResetTTL         time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+		EmailVerificationTTL     time.Duration `mapstructure:"EMAIL_VERIFICATION_TTL"`
+		// ResetPasswordURL and VerifyEmailURL are the pages linked from the mails; the token is added as ?token=
+		ResetPasswordURL string `mapstructure:"RESET_PASSWORD_URL"`
+		VerifyEmailURL   string `mapstructure:"VERIFY_EMAIL_URL"`
+		// TOTPEncryptionKey is a base64 32 byte key sealing the TOTP secrets; empty disables two-factor authentication
+		TOTPEncryptionKey string        `mapstructure:"TOTP_ENCRYPTION_KEY"`
+		TOTPIssuer        string        `mapstructure:"TOTP_ISSUER"`
+		MFAChallengeTTL   time.Duration `mapstructure:"MFA_CHALLENGE_TTL"`
+		// Login brute-force protection; zero values keep the defaults (5 and 20 failures in 15m, 15m lock, 250ms..4s delay)
+		LoginMaxFailures   int           `mapstructure:"LOGIN_MAX_FAILURES"`
+		LoginMaxIPFailures int           `mapstructure:"LOGIN_MAX_IP_FAILURES"`
+		LoginFailureWindow time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+		LoginLockDuration  time.Duration `mapstructure:"LOGIN_LOCK_DURATION"`
+		LoginBaseDelay     time.Duration `mapstructure:"LOGIN_BASE_DELAY"`
+		LoginMaxDelay      time.Duration `mapstructure:"LOGIN_MAX_DELAY"`
+		// MailMaxRequests limits the password reset and verification mails per email
+		// within LOGIN_FAILURE_WINDOW (default 3); an IP gets LOGIN_MAX_IP_FAILURES
+		MailMaxRequests int       `mapstructure:"MAIL_MAX_REQUESTS"`
+		OIDC            AuthOIDC  `mapstructure:"OIDC"`
+		Admin           AuthAdmin `mapstructure:"ADMIN"`
+	}
+
+	// AuthAdmin bootstraps the first administrator at startup when no user has
+	// Email yet; set it through AUTH_ADMIN_EMAIL and AUTH_ADMIN_PASSWORD.
+	AuthAdmin struct {
+		Email    string `mapstructure:"EMAIL"`
+		Password string `mapstructure:"PASSWORD"`
+	}
+
+	// AuthOIDC configures login through an OpenID Connect provider.
+	AuthOIDC struct {
+		Enabled bool `mapstructure:"ENABLED"`
+		// Issuer is the provider's issuer URL; discovery reads Issuer/.well-known/openid-configuration
+		Issuer       string `mapstructure:"ISSUER"`
+		ClientID     string `mapstructure:"CLIENT_ID"`
+		ClientSecret string `mapstructure:"CLIENT_SECRET"`
+		// RedirectURL must point at /v1/auth/oidc/callback and be registered with the provider
+		RedirectURL string   `mapstructure:"REDIRECT_URL"`
+		Scopes      []string `mapstructure:"SCOPES"`
+		// AllowedDomains restricts logins to these email domains; empty allows any
+		AllowedDomains []string      `mapstructure:"ALLOWED_DOMAINS"`
+		StateTTL       time.Duration `mapstructure:"STATE_TTL"`
+	}
+
+	// Mail -.
+	Mail struct {
+		// Driver is "smtp", or "log" to only write mails to the log (development)
+		Driver string   `mapstructure:"DRIVER"`
+		From   string   `mapstructure:"FROM"`
+		SMTP   MailSMTP `mapstructure:"SMTP"`
+	}
+
+	// MailSMTP -.
+	MailSMTP struct {
+		Host     string        `mapstructure:"HOST"`
+		Port     int           `mapstructure:"PORT"`
+		Username string        `mapstructure:"USERNAME"`
+		Password string        `mapstructure:"PASSWORD"`
+		Timeout  time.Duration `mapstructure:"TIMEOUT"`
+	}
+
+	// JWTVerificationKey -.
+	JWTVerificationKey struct {
+		KeyID         string `mapstructure:"KEY_ID"`
+		PublicKeyFile string `mapstructure:"PUBLIC_KEY_FILE"`
+	}
+
+	// VietQR -.
+	VietQR struct {
+		// TTL is the default validity of a generated code
+		TTL time.Duration `mapstructure:"TTL"`
+		// ExpireInterval is how often overdue codes are moved to timeout
+		ExpireInterval time.Duration `mapstructure:"EXPIRE_INTERVAL"`
+		Image          VietQRImage   `mapstructure:"IMAGE"`
+		Webhook        VietQRWebhook `mapstructure:"WEBHOOK"`
+		// Merchant receives utility payments made with payment_method "vietqr"
+		Merchant VietQRMerchant `mapstructure:"MERCHANT"`
+	}
+
+	// VietQRImage -.
+	VietQRImage struct {
+		// ErrorCorrection is the default level: L, M, Q or H
+		ErrorCorrection string `mapstructure:"ERROR_CORRECTION"`
+		// LogoDir holds <name>.png overlays; empty disables logos
+		LogoDir string `mapstructure:"LOGO_DIR"`
+	}
+
+	// VietQRMerchant -.
+	VietQRMerchant struct {
+		BankCode  string `mapstructure:"BANK_CODE"`
+		AccountNo string `mapstructure:"ACCOUNT_NO"`
+		Name      string `mapstructure:"NAME"`
+	}
+
+	// VietQRWebhook -.
+	VietQRWebhook struct {
+		// Secret is shared with the bank to sign notifications; empty rejects them all
 		Secret string `mapstructure:"SECRET"`
+		// Tolerance is the allowed clock drift of the signed timestamp
+		Tolerance time.Duration `mapstructure:"TOLERANCE"`
 	}
 
 	// NATS -.
@@ -0,0 +1,15 @@
+-- Create vietqr table
+CREATE TABLE IF NOT EXISTS vietqr (
+    id VARCHAR(36) PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'generated',
+    content TEXT NOT NULL
+);
+
+-- Expiry and request details
+ALTER TABLE vietqr ADD COLUMN IF NOT EXISTS amount VARCHAR(13) NOT NULL DEFAULT '';
+ALTER TABLE vietqr ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE vietqr ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE vietqr ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_vietqr_status_expires_at ON vietqr(status, expires_at);
@@ -0,0 +1,22 @@
+-- Payment reference embedded in the transfer description
+ALTER TABLE vietqr ADD COLUMN IF NOT EXISTS account_no VARCHAR(19) NOT NULL DEFAULT '';
+ALTER TABLE vietqr ADD COLUMN IF NOT EXISTS reference VARCHAR(11) NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vietqr_reference ON vietqr(reference) WHERE reference <> '';
+
+-- Create bank_transactions table
+CREATE TABLE IF NOT EXISTS bank_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    bank_transaction_id VARCHAR(64) NOT NULL UNIQUE,
+    account_no VARCHAR(19) NOT NULL,
+    amount VARCHAR(13) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    direction VARCHAR(10) NOT NULL,
+    transacted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    vietqr_id VARCHAR(36) REFERENCES vietqr(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_bank_transactions_status_created_at ON bank_transactions(status, created_at);
@@ -0,0 +1,3 @@
+-- Acquiring bank resolved from the bank directory
+ALTER TABLE vietqr ADD COLUMN IF NOT EXISTS bank_code VARCHAR(16) NOT NULL DEFAULT '';
+ALTER TABLE vietqr ADD COLUMN IF NOT EXISTS bank_bin VARCHAR(6) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Payments made by VietQR reference their code
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS vietqr_id VARCHAR(36) REFERENCES vietqr(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_vietqr_id ON payments(vietqr_id) WHERE vietqr_id IS NOT NULL;
@@ -0,0 +1,18 @@
+-- Email verification gates login. Accounts that existed before verification
+-- was introduced are treated as verified.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE;
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
+
+-- Single-use password reset and email verification tokens, stored as SHA-256 hashes.
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tokens_token_hash ON user_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id_purpose ON user_tokens(user_id, purpose);
@@ -0,0 +1,8 @@
+-- Lets admins lift login lockouts through POST /v1/user/:id/unlock
+INSERT INTO permissions (name, description) VALUES
+    ('users:unlock', 'Unlock accounts locked after failed logins')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'users:unlock' WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,13 @@
+-- Soft delete users. Deleted users keep their rows, so their payments stay.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Keyset pagination and prefix search of GET /v1/user
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_email_prefix ON users(lower(email) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_username_prefix ON users(lower(username) text_pattern_ops);
+
+-- A hard delete must not take payment records with it
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS fk_payments_user_id;
+ALTER TABLE payments ADD CONSTRAINT fk_payments_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+ALTER TABLE payment_history DROP CONSTRAINT IF EXISTS fk_payment_history_user_id;
+ALTER TABLE payment_history ADD CONSTRAINT fk_payment_history_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
@@ -0,0 +1,2 @@
+-- Kafka headers of the event, e.g. the trace context and request ID of the request that caused it
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS headers JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,8 @@
+-- Lets admins review unmatched bank credits through GET /v1/vietqr/notifications/unmatched
+INSERT INTO permissions (name, description) VALUES
+    ('vietqr:review', 'List bank credits that matched no VietQR code')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p ON p.name = 'vietqr:review' WHERE r.name = 'admin'
+ON CONFLICT DO NOTHING;
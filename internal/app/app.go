@@ -6,16 +6,20 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"sync"
 	"syscall"
 
 	"github.com/ducnpdev/godev-kit/config"
 	"github.com/ducnpdev/godev-kit/internal/controller/http"
+	"github.com/ducnpdev/godev-kit/internal/repo"
 	"github.com/ducnpdev/godev-kit/internal/repo/externalapi"
 	vietqrrepo "github.com/ducnpdev/godev-kit/internal/repo/externalapi/vietqr"
 	"github.com/ducnpdev/godev-kit/internal/repo/persistent"
 	"github.com/ducnpdev/godev-kit/internal/usecase"
 	"github.com/ducnpdev/godev-kit/internal/usecase/billing"
 	natuc "github.com/ducnpdev/godev-kit/internal/usecase/nat"
+	"github.com/ducnpdev/godev-kit/internal/usecase/outbox"
 	"github.com/ducnpdev/godev-kit/internal/usecase/payment"
 	redisuc "github.com/ducnpdev/godev-kit/internal/usecase/redis"
 	"github.com/ducnpdev/godev-kit/internal/usecase/translation"
@@ -55,11 +59,17 @@ func Run(cfg *config.Config) {
 	defer redisClient.Close()
 
 	// Kafka Repository
+	kafkaProducerOpts, kafkaConsumerOpts, err := newKafkaOptions(cfg.Kafka)
+	if err != nil {
+		l.Fatal(fmt.Errorf("app - Run - newKafkaOptions: %w", err))
+	}
 	kafkaRepo := persistent.NewKafkaRepoWithConfig(
 		cfg.Kafka.Brokers,
 		l.Zerolog(),
 		cfg.Kafka.Control.ProducerEnabled,
 		cfg.Kafka.Control.ConsumerEnabled,
+		kafka.ProducerOptions(kafkaProducerOpts...),
+		kafka.ConsumerOptions(kafkaConsumerOpts...),
 	)
 	defer func() {
 		if err := kafkaRepo.Close(); err != nil {
@@ -90,18 +100,110 @@ func Run(cfg *config.Config) {
 		externalapi.New(),
 	)
 
+	jwtKeys, err := newKeySet(cfg.JWT)
+	if err != nil {
+		l.Fatal(fmt.Errorf("app - Run - newKeySet: %w", err))
+	}
+
+	accountMailer, err := newMailer(cfg.Mail, l)
+	if err != nil {
+		l.Fatal(fmt.Errorf("app - Run - newMailer: %w", err))
+	}
+
+	tokenDenylist := persistent.NewTokenDenylistRepo(redisClient)
+	kafkaEventUseCase := usecase.NewKafkaEventUseCase(kafkaRepo, l.Zerolog(),
+		usecase.EventTopics(cfg.Kafka.Topics.UserEvents, cfg.Kafka.Topics.TranslationEvents),
+		usecase.ConsumerGroup(cfg.Kafka.GroupID),
+	)
+	userMFA, err := newUserMFA(cfg.Auth, pg)
+	if err != nil {
+		l.Fatal(fmt.Errorf("app - Run - newUserMFA: %w", err))
+	}
+	userOIDC, err := newUserOIDC(cfg.Auth.OIDC, pg, redisClient)
+	if err != nil {
+		l.Fatal(fmt.Errorf("app - Run - newUserOIDC: %w", err))
+	}
+
+	// User lifecycle events are written with the change and sent by the outbox relay
+	transactor := persistent.NewTransactor(pg)
+	outboxRepo := persistent.NewOutboxRepo(pg)
+	userEventsTopic := cfg.Kafka.Topics.UserEvents
+	if userEventsTopic == "" {
+		userEventsTopic = usecase.DefaultUserEventsTopic
+	}
+
 	userUseCase := user.New(
 		persistent.NewUserRepo(pg),
-		cfg.JWT.Secret,
+		jwtKeys,
+		user.Roles(persistent.NewRoleRepo(pg)),
+		user.Tokens(persistent.NewRefreshTokenRepo(pg), tokenDenylist),
+		user.TokenTTL(cfg.JWT.AccessTTL, cfg.JWT.RefreshTTL),
+		user.Mail(persistent.NewUserTokenRepo(pg), accountMailer, user.MailLinks{
+			ResetPassword: cfg.Auth.ResetPasswordURL,
+			VerifyEmail:   cfg.Auth.VerifyEmailURL,
+		}),
+		user.MailTokenTTL(cfg.Auth.PasswordResetTTL, cfg.Auth.EmailVerificationTTL),
+		user.RequireEmailVerification(cfg.Auth.RequireEmailVerification),
+		userMFA,
+		user.MFAChallengeTTL(cfg.Auth.MFAChallengeTTL),
+		user.LoginProtection(persistent.NewLoginAttemptRepo(redisClient), user.LoginPolicy{
+			MaxFailures:   cfg.Auth.LoginMaxFailures,
+			MaxIPFailures: cfg.Auth.LoginMaxIPFailures,
+			FailureWindow: cfg.Auth.LoginFailureWindow,
+			LockDuration:  cfg.Auth.LoginLockDuration,
+			BaseDelay:     cfg.Auth.LoginBaseDelay,
+			MaxDelay:      cfg.Auth.LoginMaxDelay,
+
+			MaxMailRequests: cfg.Auth.MailMaxRequests,
+		}),
+		user.Events(kafkaEventUseCase),
+		user.Logger(l),
+		user.Outbox(transactor, outboxRepo, userEventsTopic),
+		user.APIKeys(persistent.NewAPIKeyRepo(pg)),
+		userOIDC,
 	)
+	if err := userUseCase.BootstrapAdmin(context.Background(), cfg.Auth.Admin.Email, cfg.Auth.Admin.Password); err != nil {
+		l.Fatal(fmt.Errorf("app - Run - userUseCase.BootstrapAdmin: %w", err))
+	}
 	kafkaUseCase := usecase.NewKafkaUseCase(kafkaRepo)
 	redisUseCase := redisuc.NewRedisUseCase(
 		persistent.NewRedisRepo(redisClient),
 	)
-	natsUseCase := natuc.NewNatsUseCase(persistent.NewNatsRepo(natsClient))
+	natsRepo := persistent.NewNatsRepo(natsClient)
+	natsUseCase := natuc.NewNatsUseCase(natsRepo)
+
+	// VietQR status changes go to Kafka and NATS; with NATS every instance's SSE watchers follow the subject
+	vietqrBroker := vietqruc.NewBroker()
+	var vietqrNats repo.NatsRepo
+	if cfg.NATS.Enable {
+		vietqrNats = natsRepo
+		unsubscribe, err := vietqrBroker.Follow(natsRepo, l)
+		if err != nil {
+			l.Fatal(fmt.Errorf("app - Run - vietqrBroker.Follow: %w", err))
+		}
+		defer unsubscribe()
+	}
+	vietqrPublisher := vietqruc.NewEventPublisher(kafkaRepo, vietqrNats, vietqrBroker, l)
+
+	// Payments paid by VietQR advance when their code does
+	paymentRepo := persistent.NewPaymentRepo(pg)
+	paymentVietQRLink := payment.NewVietQRLink(paymentRepo, l.ZerologPtr())
+
+	vietqrPersistentRepo := persistent.NewVietQRRepo(pg)
 	vietqrUseCase := vietqruc.NewVietQRUseCase(
 		vietqrrepo.NewVietQRRepo(),
-		persistent.NewVietQRRepo(pg),
+		vietqrPersistentRepo,
+		vietqruc.DefaultExpiry(cfg.VietQR.TTL),
+		vietqruc.ImageErrorCorrection(cfg.VietQR.Image.ErrorCorrection),
+		vietqruc.LogoDir(cfg.VietQR.Image.LogoDir),
+		vietqruc.Events(vietqrPublisher, vietqrBroker),
+		vietqruc.Hooks(transactor, paymentVietQRLink),
+	)
+	bankNotificationUseCase := vietqruc.NewBankNotificationUseCase(
+		vietqrUseCase,
+		vietqrPersistentRepo,
+		persistent.NewBankTransactionRepo(pg),
+		transactor,
 	)
 	billingUseCase := billing.New()
 
@@ -110,25 +212,78 @@ func Run(cfg *config.Config) {
 	shipperLocationUsecase := redisuc.NewShipperLocationUseCase(redisRepo, shipperLocationRepo)
 
 	// Payment Use Case
-	paymentRepo := persistent.NewPaymentRepo(pg)
-	
 	// Only create Kafka producer if enabled
-	var kafkaProducer *kafka.Producer
+	var kafkaProducer, deadLetterProducer *kafka.Producer
 	if cfg.Kafka.Control.ProducerEnabled {
-		kafkaProducer = kafka.NewProducer(cfg.Kafka.Brokers, l.Zerolog())
+		if cfg.Kafka.Producer.Async {
+			kafkaProducer = kafka.NewProducer(cfg.Kafka.Brokers, l.Zerolog(), append(slices.Clone(kafkaProducerOpts), kafka.Async(nil))...)
+			// Dead letters must not be lost, so they are written synchronously
+			deadLetterProducer = kafka.NewProducer(cfg.Kafka.Brokers, l.Zerolog(), kafkaProducerOpts...)
+		} else {
+			kafkaProducer = kafka.NewProducer(cfg.Kafka.Brokers, l.Zerolog(), kafkaProducerOpts...)
+			deadLetterProducer = kafkaProducer
+		}
+		defer func() {
+			if err := kafkaProducer.Close(); err != nil {
+				l.Error(fmt.Errorf("app - Run - kafkaProducer.Close: %w", err))
+			}
+			if deadLetterProducer != kafkaProducer {
+				if err := deadLetterProducer.Close(); err != nil {
+					l.Error(fmt.Errorf("app - Run - deadLetterProducer.Close: %w", err))
+				}
+			}
+		}()
 	}
-	paymentUseCase := payment.NewPaymentUseCase(paymentRepo, kafkaProducer, l.ZerologPtr())
+	paymentSerializer, err := newKafkaSerializer(cfg.Kafka.SchemaRegistry)
+	if err != nil {
+		l.Fatal(fmt.Errorf("app - Run - newKafkaSerializer: %w", err))
+	}
+	paymentUseCase := payment.NewPaymentUseCase(paymentRepo, kafkaProducer, l.ZerologPtr(),
+		payment.Transactor(transactor),
+		payment.Serializer(paymentSerializer),
+		payment.VietQR(vietqrUseCase, payment.Merchant{
+			BankCode:  cfg.VietQR.Merchant.BankCode,
+			AccountNo: cfg.VietQR.Merchant.AccountNo,
+			Name:      cfg.VietQR.Merchant.Name,
+		}),
+	)
+
+	// Setup context for Kafka operations and background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// Setup context for Kafka operations
-	ctx := context.Background()
+	// Background workers are awaited on shutdown, before the producers they
+	// write to are closed by the deferred calls above
+	var workers sync.WaitGroup
+
+	// Move unpaid VietQR codes to timeout once they expire
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		vietqruc.NewExpirer(vietqrUseCase, cfg.VietQR.ExpireInterval, l).Start(ctx)
+	}()
+
+	// Send the events written to the outbox
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		outbox.NewRelay(transactor, outboxRepo, kafkaRepo, cfg.Kafka.Outbox.PollInterval, cfg.Kafka.Outbox.BatchSize, l).Start(ctx)
+	}()
 
 	// Only create and start payment consumer if Kafka consumer is enabled
 	var paymentConsumer *payment.PaymentConsumer
 	if cfg.Kafka.Control.ConsumerEnabled {
-		paymentConsumer = payment.NewPaymentConsumer(cfg.Kafka.Brokers, "payment-processor", paymentUseCase, l.ZerologPtr())
+		consumerOpts := append(slices.Clone(kafkaConsumerOpts), kafka.Workers(cfg.Kafka.Consumer.Workers, cfg.Kafka.Consumer.WorkerBuffer))
+		// Events that keep failing are parked when there is a producer, and retried otherwise
+		if deadLetterProducer != nil {
+			consumerOpts = append(consumerOpts, kafka.DeadLetter(deadLetterProducer, payment.DeadLetterTopic))
+		}
+		paymentConsumer = payment.NewPaymentConsumer(cfg.Kafka.Brokers, "payment-processor", paymentUseCase, l.ZerologPtr(), consumerOpts...)
 		
 		// Start Payment Consumer
+		workers.Add(1)
 		go func() {
+			defer workers.Done()
 			if err := paymentConsumer.Start(ctx); err != nil {
 				l.Error(fmt.Errorf("app - Run - paymentConsumer.Start: %w", err))
 			}
@@ -137,17 +292,19 @@ func Run(cfg *config.Config) {
 		l.Info("Kafka consumer is disabled, skipping payment consumer initialization")
 	}
 
-	// Kafka Event Use Case
-	// kafkaEventUseCase := usecase.NewKafkaEventUseCase(kafkaRepo, l.Zerolog())
-
 	// Setup Kafka consumers
-	// if err := kafkaEventUseCase.ConsumeUserEvents(ctx); err != nil {
-	// 	l.Error(fmt.Errorf("app - Run - ConsumeUserEvents: %w", err))
-	// }
+	if cfg.Kafka.Control.ConsumerEnabled {
+		if err := kafkaEventUseCase.ConsumeUserEvents(ctx); err != nil {
+			l.Error(fmt.Errorf("app - Run - ConsumeUserEvents: %w", err))
+		}
 
-	// if err := kafkaEventUseCase.ConsumeTranslationEvents(ctx); err != nil {
-	// 	l.Error(fmt.Errorf("app - Run - ConsumeTranslationEvents: %w", err))
-	// }
+		if err := kafkaEventUseCase.ConsumeTranslationEvents(ctx); err != nil {
+			l.Error(fmt.Errorf("app - Run - ConsumeTranslationEvents: %w", err))
+		}
+
+		// Start Kafka consumers
+		kafkaRepo.StartAllConsumers(ctx)
+	}
 
 	// Start Payment Consumer
 	// go func() {
@@ -156,9 +313,6 @@ func Run(cfg *config.Config) {
 	// 	}
 	// }()
 
-	// Start Kafka consumers
-	// kafkaRepo.StartAllConsumers(ctx)
-
 	// RabbitMQ RPC Server
 	// rmqRouter := amqprpc.NewRouter(translationUseCase, l)
 
@@ -168,12 +322,15 @@ func Run(cfg *config.Config) {
 	// }
 
 	// gRPC Server
-	// grpcServer := grpcserver.New(grpcserver.Port(cfg.GRPC.Port))
+	// grpcServer := grpcserver.New(
+	// 	grpcserver.Port(cfg.GRPC.Port),
+	// 	grpcserver.UnaryInterceptors(grpc.AuthInterceptor(user.AccessTokenParser(jwtKeys.Keyfunc), tokenDenylist, l)),
+	// )
 	// grpc.NewRouter(grpcServer.App, translationUseCase, l)
 
 	// HTTP Server
 	httpServer := httpserver.New(cfg, httpserver.Port(cfg.HTTP.Port))
-	http.NewRouter(httpServer.App, cfg, translationUseCase, userUseCase, kafkaUseCase, redisUseCase, natsUseCase, vietqrUseCase, billingUseCase, l, shipperLocationUsecase, paymentUseCase, billingUseCase)
+	http.NewRouter(httpServer.App, cfg, translationUseCase, userUseCase, userUseCase, userUseCase, kafkaUseCase, redisUseCase, natsUseCase, vietqrUseCase, bankNotificationUseCase, billingUseCase, l, shipperLocationUsecase, paymentUseCase, billingUseCase, jwtKeys, user.AccessTokenParser(jwtKeys.Keyfunc), tokenDenylist)
 
 	// Start servers
 	// rmqServer.Start()
@@ -203,6 +360,11 @@ func Run(cfg *config.Config) {
 		l.Error(fmt.Errorf("app - Run - httpServer.Shutdown: %w", err))
 	}
 
+	// Stop the consumers and workers; the payment consumer may still be
+	// writing dead letters, so the producers stay open until it returns
+	cancel()
+	workers.Wait()
+
 	// err = grpcServer.Shutdown()
 	// if err != nil {
 	// 	l.Error(fmt.Errorf("app - Run - grpcServer.Shutdown: %w", err))
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/ducnpdev/godev-kit/config"
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+)
+
+// newKeySet signs with the configured private key, falling back to HS256 with
+// the shared secret when none is set.
+func newKeySet(cfg config.JWT) (*jwtkeys.KeySet, error) {
+	if cfg.PrivateKeyFile == "" {
+		return jwtkeys.NewHMAC(cfg.Secret), nil
+	}
+
+	signing, err := jwtkeys.LoadPrivateKey(cfg.KeyID, cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+
+	verification := make([]jwtkeys.Key, 0, len(cfg.VerificationKeys))
+	for _, k := range cfg.VerificationKeys {
+		key, err := jwtkeys.LoadPublicKey(k.KeyID, k.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %q: %w", k.KeyID, err)
+		}
+		verification = append(verification, key)
+	}
+
+	return jwtkeys.New(signing, verification...)
+}
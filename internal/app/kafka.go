@@ -0,0 +1,137 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/ducnpdev/godev-kit/config"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// newKafkaSerializer returns the serializer of payment events in
+// SCHEMA_REGISTRY.VALUE_FORMAT. Without a registry URL values are written
+// without schema IDs, as before the registry existed.
+func newKafkaSerializer(cfg config.SchemaRegistry) (*kafka.Serializer, error) {
+	var codec kafka.Codec
+	switch cfg.ValueFormat {
+	case "", "json":
+		codec = kafka.JSON{}
+	case "avro":
+		codec = kafka.Avro{}
+	default:
+		return nil, fmt.Errorf("unknown kafka value format %q", cfg.ValueFormat)
+	}
+
+	var opts []kafka.SerializerOption
+	if cfg.URL != "" {
+		opts = append(opts, kafka.SchemaRegistry(kafka.NewRegistry(cfg.URL, kafka.RegistryAuth(cfg.Username, cfg.Password))))
+	}
+
+	return kafka.NewSerializer(codec, opts...), nil
+}
+
+// newKafkaOptions returns the producer and consumer settings of cfg, shared
+// by all Kafka clients. PRODUCER.ASYNC is left to the caller, as only some
+// producers may lose messages.
+func newKafkaOptions(cfg config.Kafka) ([]kafka.ProducerOption, []kafka.ConsumerOption, error) {
+	security, err := newKafkaSecurity(cfg.TLS, cfg.SASL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	producerOpts := []kafka.ProducerOption{
+		kafka.Batch(cfg.Producer.BatchSize, cfg.Producer.BatchTimeout),
+		kafka.ProducerSecurity(security),
+	}
+	if cfg.Producer.Compression != "" {
+		var codec kafkago.Compression
+		if err := codec.UnmarshalText([]byte(cfg.Producer.Compression)); err != nil {
+			return nil, nil, fmt.Errorf("kafka producer compression: %w", err)
+		}
+		producerOpts = append(producerOpts, kafka.Compression(codec))
+	}
+	if cfg.Producer.RequiredAcks != "" {
+		var acks kafkago.RequiredAcks
+		if err := acks.UnmarshalText([]byte(cfg.Producer.RequiredAcks)); err != nil {
+			return nil, nil, fmt.Errorf("kafka producer required acks: %w", err)
+		}
+		producerOpts = append(producerOpts, kafka.RequiredAcks(acks))
+	}
+	if cfg.Producer.Balancer != "" {
+		balancer, err := newKafkaBalancer(cfg.Producer.Balancer)
+		if err != nil {
+			return nil, nil, err
+		}
+		producerOpts = append(producerOpts, kafka.Balancer(balancer))
+	}
+
+	consumerOpts := []kafka.ConsumerOption{
+		kafka.FetchBytes(cfg.Consumer.MinBytes, cfg.Consumer.MaxBytes),
+		kafka.MaxWait(cfg.Consumer.MaxWait),
+		kafka.CommitInterval(cfg.Consumer.CommitInterval),
+		kafka.ConsumerSecurity(security),
+	}
+
+	return producerOpts, consumerOpts, nil
+}
+
+func newKafkaBalancer(name string) (kafkago.Balancer, error) {
+	switch name {
+	case "least_bytes":
+		return &kafkago.LeastBytes{}, nil
+	case "round_robin":
+		return &kafkago.RoundRobin{}, nil
+	case "hash":
+		return &kafkago.Hash{}, nil
+	case "murmur2":
+		return kafkago.Murmur2Balancer{}, nil
+	case "crc32":
+		return kafkago.CRC32Balancer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown kafka balancer %q", name)
+	}
+}
+
+func newKafkaSecurity(tlsCfg config.KafkaTLS, saslCfg config.KafkaSASL) (kafka.Security, error) {
+	var (
+		security kafka.Security
+		err      error
+	)
+
+	security.SASL, err = kafka.SASLMechanism(saslCfg.Mechanism, saslCfg.Username, saslCfg.Password)
+	if err != nil {
+		return kafka.Security{}, err
+	}
+
+	if !tlsCfg.Enabled {
+		return security, nil
+	}
+
+	security.TLS = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: tlsCfg.InsecureSkipVerify, //nolint:gosec // opt-in for test clusters
+	}
+	if tlsCfg.CAFile != "" {
+		pem, err := os.ReadFile(tlsCfg.CAFile)
+		if err != nil {
+			return kafka.Security{}, fmt.Errorf("kafka tls ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return kafka.Security{}, fmt.Errorf("kafka tls ca: no certificates in %s", tlsCfg.CAFile)
+		}
+		security.TLS.RootCAs = pool
+	}
+	if tlsCfg.CertFile != "" || tlsCfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsCfg.CertFile, tlsCfg.KeyFile)
+		if err != nil {
+			return kafka.Security{}, fmt.Errorf("kafka tls client certificate: %w", err)
+		}
+		security.TLS.Certificates = []tls.Certificate{cert}
+	}
+
+	return security, nil
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/ducnpdev/godev-kit/config"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+	"github.com/ducnpdev/godev-kit/pkg/mailer"
+)
+
+// newMailer returns the mailer selected by MAIL.DRIVER; anything but "smtp"
+// only logs the mails.
+func newMailer(cfg config.Mail, l logger.Interface) (mailer.Mailer, error) {
+	if cfg.Driver != "smtp" {
+		return mailer.NewLog(l), nil
+	}
+
+	m, err := mailer.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.From,
+		mailer.Auth(cfg.SMTP.Username, cfg.SMTP.Password),
+		mailer.Timeout(cfg.SMTP.Timeout),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("mailer.NewSMTP: %w", err)
+	}
+
+	return m, nil
+}
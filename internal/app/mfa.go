@@ -0,0 +1,25 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/ducnpdev/godev-kit/config"
+	"github.com/ducnpdev/godev-kit/internal/repo/persistent"
+	"github.com/ducnpdev/godev-kit/internal/usecase/user"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+	"github.com/ducnpdev/godev-kit/pkg/secretbox"
+)
+
+// newUserMFA enables TOTP two-factor authentication when an encryption key is configured.
+func newUserMFA(cfg config.Auth, pg *postgres.Postgres) (user.Option, error) {
+	if cfg.TOTPEncryptionKey == "" {
+		return func(*user.UseCase) {}, nil
+	}
+
+	box, err := secretbox.NewFromBase64(cfg.TOTPEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("TOTP_ENCRYPTION_KEY: %w", err)
+	}
+
+	return user.MFA(persistent.NewMFARepo(pg), box, cfg.TOTPIssuer), nil
+}
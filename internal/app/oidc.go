@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/config"
+	"github.com/ducnpdev/godev-kit/internal/repo/persistent"
+	"github.com/ducnpdev/godev-kit/internal/usecase/user"
+	"github.com/ducnpdev/godev-kit/pkg/oidc"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+	"github.com/ducnpdev/godev-kit/pkg/redis"
+)
+
+const _oidcDiscoveryTimeout = 15 * time.Second
+
+// newUserOIDC enables login through an OpenID Connect provider. Discovery
+// runs at startup, so a misconfigured provider stops the service early.
+func newUserOIDC(cfg config.AuthOIDC, pg *postgres.Postgres, r *redis.Redis) (user.Option, error) {
+	if !cfg.Enabled {
+		return func(*user.UseCase) {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), _oidcDiscoveryTimeout)
+	defer cancel()
+
+	provider, err := oidc.New(ctx, cfg.Issuer, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURL, oidc.Scopes(cfg.Scopes...))
+	if err != nil {
+		return nil, fmt.Errorf("oidc.New: %w", err)
+	}
+
+	return user.OIDC(provider, persistent.NewOIDCStateRepo(r), persistent.NewUserIdentityRepo(pg), user.OIDCConfig{
+		AllowedDomains: cfg.AllowedDomains,
+		StateTTL:       cfg.StateTTL,
+	}), nil
+}
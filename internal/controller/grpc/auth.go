@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/usecase"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+	pbgrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenDenylist reports access tokens revoked before their expiry.
+type TokenDenylist interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// AuthInterceptor authenticates unary calls with the bearer token in the
+// "authorization" metadata, verified like the HTTP AuthMiddleware. The caller
+// is available to handlers through entity.PrincipalFromContext. Methods listed
+// in public (full names, e.g. "/grpc.v1.TranslationService/GetHistory") skip
+// authentication; a nil denylist skips the revocation check.
+func AuthInterceptor(tokens usecase.TokenParser, denylist TokenDenylist, l logger.Interface, public ...string) pbgrpc.UnaryServerInterceptor {
+	skip := make(map[string]struct{}, len(public))
+	for _, m := range public {
+		skip[m] = struct{}{}
+	}
+
+	return func(ctx context.Context, req interface{}, info *pbgrpc.UnaryServerInfo, handler pbgrpc.UnaryHandler) (interface{}, error) {
+		if _, ok := skip[info.FullMethod]; ok {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+		}
+
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "authorization metadata format must be Bearer {token}")
+		}
+
+		principal, err := tokens.ParseAccessToken(token)
+		if err != nil {
+			l.Error(err, "grpc - AuthInterceptor - tokens.ParseAccessToken")
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+
+		if denylist != nil && principal.TokenID != "" {
+			revoked, err := denylist.IsRevoked(ctx, principal.TokenID)
+			if err != nil {
+				l.Error(err, "grpc - AuthInterceptor - denylist.IsRevoked")
+				return nil, status.Error(codes.Unavailable, "authentication unavailable")
+			}
+			if revoked {
+				return nil, status.Error(codes.Unauthenticated, "token revoked")
+			}
+		}
+
+		return handler(entity.ContextWithPrincipal(ctx, principal), req)
+	}
+}
@@ -0,0 +1,78 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/usecase/user"
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pbgrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type denylistFunc func(jti string) (bool, error)
+
+func (f denylistFunc) IsRevoked(_ context.Context, jti string) (bool, error) {
+	return f(jti)
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	keys := jwtkeys.NewHMAC("secret")
+	sign := func(jti string) string {
+		token, err := keys.Sign(user.JWTClaims{
+			Email: "user@example.com",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        jti,
+				Subject:   "42",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		})
+		require.NoError(t, err)
+		return token
+	}
+
+	interceptor := AuthInterceptor(user.AccessTokenParser(keys.Keyfunc),
+		denylistFunc(func(jti string) (bool, error) { return jti == "revoked", nil }),
+		logger.New("error"), "/svc/Public")
+
+	call := func(method, authorization string) (entity.Principal, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+
+		var principal entity.Principal
+		_, err := interceptor(ctx, nil, &pbgrpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			principal, _ = entity.PrincipalFromContext(ctx)
+			return nil, nil
+		})
+		return principal, err
+	}
+
+	principal, err := call("/svc/Private", "Bearer "+sign("ok"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), principal.UserID)
+
+	_, err = call("/svc/Public", "")
+	assert.NoError(t, err)
+
+	for name, authorization := range map[string]string{
+		"missing":   "",
+		"no scheme": sign("ok"),
+		"invalid":   "Bearer not-a-token",
+		"revoked":   "Bearer " + sign("revoked"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := call("/svc/Private", authorization)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	}
+}
@@ -1,17 +1,47 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/usecase"
 	"github.com/ducnpdev/godev-kit/pkg/logger"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-// AuthMiddleware creates a middleware for JWT authentication
-func AuthMiddleware(jwtSecret string, l logger.Interface) gin.HandlerFunc {
+// PrincipalKey is the gin context key holding the authenticated entity.Principal.
+const PrincipalKey = "principal"
+
+// TokenDenylist reports access tokens revoked before their expiry.
+type TokenDenylist interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// APIKeyHeader carries API keys of machine clients.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator resolves API keys to the principal they act as.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (entity.Principal, error)
+}
+
+// AuthMiddleware creates a middleware for JWT authentication with tokens,
+// typically a user.AccessTokenParser.
+// On success the caller is available through Principal. Tokens whose ID is on
+// denylist are rejected; a nil denylist skips the check.
+// Requests with an X-API-Key header are authenticated by apiKeys instead; a nil
+// apiKeys rejects them.
+func AuthMiddleware(tokens usecase.TokenParser, denylist TokenDenylist, apiKeys APIKeyAuthenticator, l logger.Interface) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			authenticateAPIKey(c, apiKeys, key, l)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header is required"})
@@ -26,30 +56,108 @@ func AuthMiddleware(jwtSecret string, l logger.Interface) gin.HandlerFunc {
 		}
 
 		// Parse and validate the token
-		token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(jwtSecret), nil
-		})
-
+		principal, err := tokens.ParseAccessToken(parts[1])
 		if err != nil {
-			l.Error(err, "middleware - AuthMiddleware - jwt.Parse")
+			l.Error(err, "middleware - AuthMiddleware - tokens.ParseAccessToken")
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
 
-		// Check if the token is valid
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// Set user ID in context
-			if userID, ok := claims["sub"].(float64); ok {
-				c.Set("user_id", int64(userID))
-				c.Next()
+		if denylist != nil && principal.TokenID != "" {
+			revoked, err := denylist.IsRevoked(c.Request.Context(), principal.TokenID)
+			if err != nil {
+				// Fail closed: a revoked token must not slip through while Redis is down
+				l.Error(err, "middleware - AuthMiddleware - denylist.IsRevoked")
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication unavailable"})
+				return
+			}
+			if revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
 				return
 			}
 		}
 
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, key string, l logger.Interface) {
+	if apiKeys == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "api keys are not accepted"})
+		return
+	}
+
+	principal, err := apiKeys.AuthenticateAPIKey(c.Request.Context(), key)
+	if err != nil {
+		l.Error(err, "middleware - AuthMiddleware - apiKeys.AuthenticateAPIKey")
+		switch {
+		case errors.Is(err, usecase.ErrInvalidAPIKey):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+		case errors.Is(err, usecase.ErrAPIKeysNotConfigured):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "api keys are not accepted"})
+		default:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication unavailable"})
+		}
+		return
+	}
+
+	SetPrincipal(c, principal)
+	c.Next()
+}
+
+// SetPrincipal stores p on both the gin context and the request context,
+// so use cases can read it with entity.PrincipalFromContext.
+func SetPrincipal(c *gin.Context, p entity.Principal) {
+	c.Set(PrincipalKey, p)
+	c.Request = c.Request.WithContext(entity.ContextWithPrincipal(c.Request.Context(), p))
+}
+
+// Principal returns the authenticated caller, if the request went through AuthMiddleware.
+func Principal(c *gin.Context) (entity.Principal, bool) {
+	if v, ok := c.Get(PrincipalKey); ok {
+		if p, ok := v.(entity.Principal); ok {
+			return p, true
+		}
+	}
+	return entity.PrincipalFromContext(c.Request.Context())
+}
+
+// RequirePermission rejects callers whose roles do not grant permission.
+// Mount it after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := Principal(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		if !p.HasPermission(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing permission " + permission})
+			return
+		}
+		c.Next()
+	}
+}
+
+// OwnerOrAdmin only lets a request through when the user ID in the named path
+// parameter belongs to the caller, or the caller is an admin.
+func OwnerOrAdmin(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := Principal(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		userID, err := strconv.ParseInt(c.Param(param), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+			return
+		}
+		if !p.CanActFor(userID) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed to access this resource"})
+			return
+		}
+		c.Next()
 	}
 }
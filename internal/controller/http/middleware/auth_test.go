@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/usecase/user"
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+func signToken(t *testing.T, secret string, subject string, role string, ttl time.Duration, permissions ...string) string {
+	t.Helper()
+
+	claims := user.JWTClaims{
+		Email:       "user@example.com",
+		Roles:       []string{role},
+		Permissions: permissions,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}
+	token, err := jwtkeys.NewHMAC(secret).Sign(claims)
+	require.NoError(t, err)
+
+	return token
+}
+
+func newAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	protected := router.Group("", AuthMiddleware(user.AccessTokenParser(jwtkeys.NewHMAC(testSecret).Keyfunc), nil, fakeAPIKeys{}, logger.New("error")))
+	protected.GET("/me", func(c *gin.Context) {
+		p, ok := entity.PrincipalFromContext(c.Request.Context())
+		if !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	})
+	protected.GET("/users/:user_id", OwnerOrAdmin("user_id"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	protected.POST("/refunds", RequirePermission(entity.PermissionPaymentsRefund), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+func serve(router *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	return serveMethod(router, http.MethodGet, path, token)
+}
+
+func serveMethod(router *gin.Engine, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	router := newAuthRouter()
+
+	t.Run("string subject becomes the principal", func(t *testing.T) {
+		w := serve(router, "/me", signToken(t, testSecret, "42", entity.RoleUser, time.Hour, entity.PermissionUsersRead))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"user_id":42,"email":"user@example.com","roles":["user"],"permissions":["users:read"]}`, w.Body.String())
+	})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "missing header", token: ""},
+		{name: "wrong secret", token: signToken(t, "other", "42", "", time.Hour)},
+		{name: "expired", token: signToken(t, testSecret, "42", "", -time.Minute)},
+		{name: "non numeric subject", token: signToken(t, testSecret, "alice", "", time.Hour)},
+		{name: "empty subject", token: signToken(t, testSecret, "", "", time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, "/me", tt.token)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
+
+func TestOwnerOrAdmin(t *testing.T) {
+	router := newAuthRouter()
+
+	tests := []struct {
+		name   string
+		role   string
+		target int64
+		want   int
+	}{
+		{name: "owner", role: entity.RoleUser, target: 7, want: http.StatusOK},
+		{name: "other user", role: entity.RoleUser, target: 8, want: http.StatusForbidden},
+		{name: "admin", role: entity.RoleAdmin, target: 8, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signToken(t, testSecret, "7", tt.role, time.Hour)
+			w := serve(router, "/users/"+strconv.FormatInt(tt.target, 10), token)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	t.Run("invalid id", func(t *testing.T) {
+		w := serve(router, "/users/abc", signToken(t, testSecret, "7", entity.RoleUser, time.Hour))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestRequirePermission(t *testing.T) {
+	router := newAuthRouter()
+
+	// Roles alone do not grant anything; only the permissions in the token count
+	w := serveMethod(router, http.MethodPost, "/refunds", signToken(t, testSecret, "7", entity.RoleAdmin, time.Hour))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serveMethod(router, http.MethodPost, "/refunds", signToken(t, testSecret, "7", entity.RoleUser, time.Hour, entity.PermissionPaymentsRefund))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+type denylistFunc func(jti string) (bool, error)
+
+func (f denylistFunc) IsRevoked(_ context.Context, jti string) (bool, error) {
+	return f(jti)
+}
+
+func TestAuthMiddleware_Denylist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(denylist TokenDenylist) *gin.Engine {
+		router := gin.New()
+		router.GET("/me", AuthMiddleware(user.AccessTokenParser(jwtkeys.NewHMAC(testSecret).Keyfunc), denylist, nil, logger.New("error")), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return router
+	}
+
+	claims := user.JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "revoked-jti",
+			Subject:   "42",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, err := jwtkeys.NewHMAC(testSecret).Sign(claims)
+	require.NoError(t, err)
+
+	revoked := newRouter(denylistFunc(func(jti string) (bool, error) { return jti == "revoked-jti", nil }))
+	assert.Equal(t, http.StatusUnauthorized, serve(revoked, "/me", token).Code)
+	assert.Equal(t, http.StatusOK, serve(revoked, "/me", signToken(t, testSecret, "42", "", time.Hour)).Code)
+
+	unavailable := newRouter(denylistFunc(func(string) (bool, error) { return false, errors.New("redis down") }))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(unavailable, "/me", token).Code)
+}
+
+type fakeAPIKeys struct{}
+
+func (fakeAPIKeys) AuthenticateAPIKey(_ context.Context, key string) (entity.Principal, error) {
+	switch key {
+	case "gdk_good_secret":
+		return entity.Principal{UserID: 7, Roles: []string{entity.RoleUser}, Permissions: []string{entity.PermissionPaymentsRefund}, APIKeyID: 3}, nil
+	case "gdk_admin_secret":
+		// The key of an admin user, scoped to refunds: key principals have no roles
+		return entity.Principal{UserID: 1, Permissions: []string{entity.PermissionPaymentsRefund}, APIKeyID: 4}, nil
+	case "gdk_down_secret":
+		return entity.Principal{}, errors.New("postgres down")
+	}
+	return entity.Principal{}, user.ErrInvalidAPIKey
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	router := newAuthRouter()
+
+	withKey := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := withKey(http.MethodGet, "/me", "gdk_good_secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":7,"email":"","roles":["user"],"permissions":["payments:refund"],"api_key_id":3}`, w.Body.String())
+
+	// API key principals go through the same permission and ownership checks
+	assert.Equal(t, http.StatusOK, withKey(http.MethodPost, "/refunds", "gdk_good_secret").Code)
+	assert.Equal(t, http.StatusForbidden, withKey(http.MethodGet, "/users/8", "gdk_good_secret").Code)
+
+	// An admin's narrowly scoped key only grants its scopes
+	assert.Equal(t, http.StatusOK, withKey(http.MethodPost, "/refunds", "gdk_admin_secret").Code)
+	assert.Equal(t, http.StatusForbidden, withKey(http.MethodGet, "/users/8", "gdk_admin_secret").Code)
+
+	assert.Equal(t, http.StatusUnauthorized, withKey(http.MethodGet, "/me", "gdk_bad_secret").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, withKey(http.MethodGet, "/me", "gdk_down_secret").Code)
+}
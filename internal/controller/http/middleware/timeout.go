@@ -16,6 +16,8 @@ type TimeoutConfig struct {
 	TimeoutResponse gin.H
 	// Optional: Skip timeout for specific paths
 	SkipPaths []string
+	// Optional: Skip timeout for requests matching the predicate, e.g. long-lived streams
+	Skip func(c *gin.Context) bool
 }
 
 // DefaultTimeoutConfig returns a default timeout configuration
@@ -40,6 +42,10 @@ func TimeoutMiddleware(config ...TimeoutConfig) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		// Check if this path should skip timeout
+		if cfg.Skip != nil && cfg.Skip(c) {
+			c.Next()
+			return
+		}
 		for _, path := range cfg.SkipPaths {
 			if c.Request.URL.Path == path {
 				c.Next()
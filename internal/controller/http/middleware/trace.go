@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
+	"github.com/gin-gonic/gin"
+)
+
+// Trace continues the caller's W3C trace (traceparent, tracestate) in a new
+// span, or starts one, and takes the caller's X-Request-ID or generates one.
+// Both ride on the request context, so Kafka messages produced while handling
+// the request carry them as headers; the request ID is echoed in the response.
+func Trace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracectx.Extract(c.Request.Context(), c.GetHeader)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Header(tracectx.HeaderRequestID, tracectx.RequestID(ctx))
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var (
+		span      tracectx.SpanContext
+		requestID string
+	)
+	r := gin.New()
+	r.Use(Trace())
+	r.GET("/", func(c *gin.Context) {
+		span, _ = tracectx.Span(c.Request.Context())
+		requestID = tracectx.RequestID(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span.TraceIDString())
+	assert.Equal(t, "req-1", requestID)
+	assert.Equal(t, "req-1", w.Header().Get("X-Request-ID"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, span.IsValid(), "a trace is started")
+	assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+}
@@ -3,16 +3,19 @@ package http
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/ducnpdev/godev-kit/config"
 	_ "github.com/ducnpdev/godev-kit/docs" // Swagger docs
 	"github.com/ducnpdev/godev-kit/internal/controller/http/middleware"
 	v1 "github.com/ducnpdev/godev-kit/internal/controller/http/v1"
+	"github.com/ducnpdev/godev-kit/internal/entity"
 
 	"github.com/ducnpdev/godev-kit/internal/usecase"
 	"github.com/ducnpdev/godev-kit/internal/usecase/billing"
 	"github.com/ducnpdev/godev-kit/internal/usecase/payment"
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
 	"github.com/ducnpdev/godev-kit/pkg/logger"
 	"github.com/ducnpdev/godev-kit/pkg/profiling"
 
@@ -33,11 +36,12 @@ import (
 // @in header
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
-func NewRouter(app *gin.Engine, cfg *config.Config, t usecase.Translation, u usecase.User, k usecase.Kafka, r usecase.Redis, n usecase.Nats, v usecase.VietQR, billing usecase.Billing, l logger.Interface, shipperLocation usecase.ShipperLocation, paymentUseCase *payment.PaymentUseCase, billingUseCase *billing.UseCase) {
+func NewRouter(app *gin.Engine, cfg *config.Config, t usecase.Translation, u usecase.User, roles usecase.Roles, apiKeys usecase.APIKeys, k usecase.Kafka, r usecase.Redis, n usecase.Nats, v usecase.VietQR, bn usecase.BankNotification, billing usecase.Billing, l logger.Interface, shipperLocation usecase.ShipperLocation, paymentUseCase *payment.PaymentUseCase, billingUseCase *billing.UseCase, keys *jwtkeys.KeySet, tokens usecase.TokenParser, denylist middleware.TokenDenylist) {
 	// Initialize profiler
 	profiler := profiling.NewProfiler(l.Zerolog(), cfg.Profiling.Enabled, cfg.Profiling.Path)
 
 	// Middleware
+	app.Use(middleware.Trace())
 	app.Use(middleware.Logger(l))
 	// app.Use(middleware.Recovery(l))
 
@@ -55,6 +59,10 @@ func NewRouter(app *gin.Engine, cfg *config.Config, t usecase.Translation, u use
 			"timestamp": time.Now().Format(time.RFC3339),
 		},
 		SkipPaths: []string{"/healthz", "/metrics", "/swagger", "/debug"},
+		// Server-sent event streams stay open far longer than any API call
+		Skip: func(c *gin.Context) bool {
+			return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+		},
 	}
 	app.Use(middleware.TimeoutMiddleware(timeoutConfig))
 
@@ -70,9 +78,11 @@ func NewRouter(app *gin.Engine, cfg *config.Config, t usecase.Translation, u use
 		app.GET(registerAt(), gin.WrapH(promhttp.Handler()))
 	}
 
+	authenticate := middleware.AuthMiddleware(tokens, denylist, apiKeys, l)
+
 	// Profiling routes
 	if cfg.Profiling.Enabled {
-		profiler.SetupRoutes(app)
+		profiler.SetupRoutes(app, authenticate, middleware.RequirePermission(entity.PermissionDebugGC))
 	}
 
 	// Swagger
@@ -80,6 +90,12 @@ func NewRouter(app *gin.Engine, cfg *config.Config, t usecase.Translation, u use
 		app.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	}
 
+	// Public keys for verifying access tokens in other services
+	app.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keys.JWKS())
+	})
+
 	// K8s probe
 	app.GET("/healthz", func(c *gin.Context) {
 		c.Status(http.StatusOK)
@@ -90,18 +106,23 @@ func NewRouter(app *gin.Engine, cfg *config.Config, t usecase.Translation, u use
 
 	// Routers
 	apiV1Group := app.Group("/v1")
+	// Everything outside the public group requires a bearer token
+	public := apiV1Group
+	protected := apiV1Group.Group("", authenticate)
 	{
-		v1.NewTranslationRoutes(apiV1Group, t, l)
-		v1.NewUserRoutes(apiV1Group, u, l)
-		v1.NewKafkaRoutes(apiV1Group, k, l)
-		v1.NewRedisRoutes(apiV1Group, r, l, shipperLocation)
-		v1.NewNatsRoutes(apiV1Group, n, l)
-		v1.NewVietQRRoutes(apiV1Group, v, l)
+		v1.NewTranslationRoutes(protected, t, l)
+		v1.NewUserRoutes(public, protected, u, l)
+		v1.NewAdminRoutes(protected, roles, l)
+		v1.NewAPIKeyRoutes(protected, apiKeys, l)
+		v1.NewKafkaRoutes(protected, k, l)
+		v1.NewRedisRoutes(protected, r, l, shipperLocation)
+		v1.NewNatsRoutes(protected, n, l)
+		v1.NewVietQRRoutes(public, protected, v, bn, middleware.HMACSignature(cfg.VietQR.Webhook.Secret, cfg.VietQR.Webhook.Tolerance, l), l)
 
 		// Payment routes
-		v1Controller.RegisterPaymentRoutes(apiV1Group)
+		v1Controller.RegisterPaymentRoutes(protected)
 
 		// Billing routes
-		v1Controller.RegisterBillingRoutes(apiV1Group)
+		v1Controller.RegisterBillingRoutes(protected)
 	}
 }
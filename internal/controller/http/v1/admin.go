@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/request"
+	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/response"
+	useruc "github.com/ducnpdev/godev-kit/internal/usecase/user"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary     List roles
+// @Description List every role with the permissions it grants
+// @ID          list-roles
+// @Tags  	    admin
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {array} entity.Role
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/admin/roles [get]
+func (r *V1) listRoles(c *gin.Context) {
+	roles, err := r.roles.ListRoles(c.Request.Context())
+	if err != nil {
+		r.l.Error(err, "http - v1 - listRoles")
+		errorResponse(c, http.StatusInternalServerError, "role service problems")
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// @Summary     Get user roles
+// @Description Get the roles of a user and the permissions they grant
+// @ID          get-user-roles
+// @Tags  	    admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path int true "User ID"
+// @Success     200 {object} response.UserRoles
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/admin/users/{id}/roles [get]
+func (r *V1) getUserRoles(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		r.l.Error(err, "http - v1 - getUserRoles")
+		errorResponse(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	roles, permissions, err := r.roles.UserRoles(c.Request.Context(), id)
+	if err != nil {
+		r.l.Error(err, "http - v1 - getUserRoles")
+		r.roleErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.UserRoles{UserID: id, Roles: roles, Permissions: permissions})
+}
+
+// @Summary     Assign role
+// @Description Grant a role to a user. It applies from the user's next login or token refresh.
+// @ID          assign-role
+// @Tags  	    admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path int true "User ID"
+// @Param       request body request.AssignRole true "Role"
+// @Success     204
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/admin/users/{id}/roles [post]
+func (r *V1) assignRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		r.l.Error(err, "http - v1 - assignRole")
+		errorResponse(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var body request.AssignRole
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, "http - v1 - assignRole")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, "http - v1 - assignRole")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.roles.AssignRole(c.Request.Context(), id, body.Role); err != nil {
+		r.l.Error(err, "http - v1 - assignRole")
+		r.roleErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary     Revoke role
+// @Description Remove a role from a user. It applies from the user's next login or token refresh.
+// @ID          revoke-role
+// @Tags  	    admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path int true "User ID"
+// @Param       role path string true "Role name"
+// @Success     204
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/admin/users/{id}/roles/{role} [delete]
+func (r *V1) revokeRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		r.l.Error(err, "http - v1 - revokeRole")
+		errorResponse(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := r.roles.RevokeRole(c.Request.Context(), id, c.Param("role")); err != nil {
+		r.l.Error(err, "http - v1 - revokeRole")
+		r.roleErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (r *V1) roleErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, useruc.ErrUserNotFound):
+		errorResponse(c, http.StatusNotFound, "user not found")
+	case errors.Is(err, useruc.ErrUnknownRole):
+		errorResponse(c, http.StatusBadRequest, "unknown role")
+	case errors.Is(err, useruc.ErrRoleNotAssigned):
+		errorResponse(c, http.StatusNotFound, "role not assigned")
+	case errors.Is(err, useruc.ErrRolesNotConfigured):
+		errorResponse(c, http.StatusNotImplemented, "roles are not enabled")
+	default:
+		errorResponse(c, http.StatusInternalServerError, "role service problems")
+	}
+}
@@ -0,0 +1,142 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/request"
+	useruc "github.com/ducnpdev/godev-kit/internal/usecase/user"
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary     Create API key
+// @Description Create an API key acting as a user with permissions both the caller and that user hold.
+// @Description Only admins create keys for other users.
+// @Description The key is only returned in this response; send it in the X-API-Key header.
+// @ID          create-api-key
+// @Tags  	    admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body request.CreateAPIKey true "API key"
+// @Success     201 {object} entity.CreatedAPIKey
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/admin/api-keys [post]
+func (r *V1) createAPIKey(c *gin.Context) {
+	var body request.CreateAPIKey
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, "http - v1 - createAPIKey")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, "http - v1 - createAPIKey")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	key, err := r.apiKeys.CreateAPIKey(c.Request.Context(), body.UserID, body.Name, body.Scopes, body.ExpiresAt)
+	if err != nil {
+		r.l.Error(err, "http - v1 - createAPIKey")
+		apiKeyErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// @Summary     List API keys
+// @Description List API keys, newest first, optionally only those of one user. Keys themselves are never returned.
+// @ID          list-api-keys
+// @Tags  	    admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       user_id query int false "User ID"
+// @Success     200 {array} entity.APIKey
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/admin/api-keys [get]
+func (r *V1) listAPIKeys(c *gin.Context) {
+	var userID int64
+	if v := c.Query("user_id"); v != "" {
+		var err error
+		userID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			r.l.Error(err, "http - v1 - listAPIKeys")
+			errorResponse(c, http.StatusBadRequest, "invalid user_id")
+			return
+		}
+	}
+
+	keys, err := r.apiKeys.ListAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		r.l.Error(err, "http - v1 - listAPIKeys")
+		apiKeyErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// @Summary     Revoke API key
+// @Description Revoke an API key. Requests using it fail from then on.
+// @ID          revoke-api-key
+// @Tags  	    admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path int true "API key ID"
+// @Success     204
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/admin/api-keys/{id} [delete]
+func (r *V1) revokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		r.l.Error(err, "http - v1 - revokeAPIKey")
+		errorResponse(c, http.StatusBadRequest, "invalid api key id")
+		return
+	}
+
+	if err := r.apiKeys.RevokeAPIKey(c.Request.Context(), id); err != nil {
+		r.l.Error(err, "http - v1 - revokeAPIKey")
+		apiKeyErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func apiKeyErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, useruc.ErrNotAuthenticated):
+		errorResponse(c, http.StatusUnauthorized, "authentication required")
+	case errors.Is(err, useruc.ErrInvalidScope):
+		errorResponse(c, http.StatusBadRequest, "scopes must be permissions of both the caller and the user")
+	case errors.Is(err, useruc.ErrAPIKeyNotAllowed):
+		errorResponse(c, http.StatusForbidden, "only admins create api keys for other users")
+	case errors.Is(err, useruc.ErrInvalidExpiry):
+		errorResponse(c, http.StatusBadRequest, "expires_at must be in the future")
+	case errors.Is(err, useruc.ErrUserNotFound):
+		errorResponse(c, http.StatusNotFound, "user not found")
+	case errors.Is(err, useruc.ErrAPIKeyNotFound):
+		errorResponse(c, http.StatusNotFound, "api key not found")
+	case errors.Is(err, useruc.ErrAPIKeysNotConfigured):
+		errorResponse(c, http.StatusNotImplemented, "api keys are not enabled")
+	default:
+		errorResponse(c, http.StatusInternalServerError, "api key service problems")
+	}
+}
@@ -1,8 +1,11 @@
 package v1
 
 import (
+	"bytes"
+	"mime"
 	"net/http"
 	"path/filepath"
+	"strconv"
 
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/request"
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/response"
@@ -31,7 +34,9 @@ func NewBillingController(billingUseCase *billing.UseCase, logger *zerolog.Logge
 // @Tags billing
 // @Accept json
 // @Produce json
+// @Produce application/pdf
 // @Param request body request.GenerateInvoicePDFRequest true "Invoice data"
+// @Param inline query bool false "Return the PDF bytes in the response instead of writing a file"
 // @Success 200 {object} response.GenerateInvoicePDFResponse
 // @Failure 400 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
@@ -73,6 +78,12 @@ func (c *BillingController) GenerateInvoicePDF(ctx *gin.Context) {
 
 	// Generate file path (could use a UUID or timestamp for uniqueness)
 	fileName := "invoice_" + ucData.Number + ".pdf"
+
+	if inline, _ := strconv.ParseBool(ctx.Query("inline")); inline {
+		c.streamInvoicePDF(ctx, ucData, fileName)
+		return
+	}
+
 	outputPath := filepath.Join("./", fileName)
 
 	if err := c.billingUseCase.GenerateInvoicePDF(ucData, outputPath); err != nil {
@@ -87,4 +98,21 @@ func (c *BillingController) GenerateInvoicePDF(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response.GenerateInvoicePDFResponse{
 		FilePath: outputPath,
 	})
-} 
\ No newline at end of file
+}
+
+// streamInvoicePDF writes the invoice into the response body so clients can preview it
+func (c *BillingController) streamInvoicePDF(ctx *gin.Context, data billing.InvoiceData, fileName string) {
+	// Render into a buffer first so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	if err := c.billingUseCase.WriteInvoicePDF(data, &buf); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to generate PDF")
+		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{
+			Error:   "Internal server error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+	ctx.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
@@ -15,10 +15,13 @@ type V1 struct {
 	//
 	t                 usecase.Translation
 	user              usecase.User
+	roles             usecase.Roles
+	apiKeys           usecase.APIKeys
 	kafka             usecase.Kafka
 	redis             usecase.Redis
 	nats              usecase.Nats
 	vietqr            usecase.VietQR
+	bankNotification  usecase.BankNotification
 	billing           usecase.Billing
 	shipperLocation   usecase.ShipperLocation
 	paymentController *PaymentController
@@ -2,10 +2,12 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/request"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,7 +29,7 @@ func (h *V1) ProducerRequest(c *gin.Context) {
 		return
 	}
 
-	err := h.kafka.ProduceMessage(c.Copy(), req.Topic, req.Key, req.Value)
+	err := h.kafka.ProduceMessage(c.Request.Context(), req.Topic, req.Key, req.Value)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -107,7 +109,7 @@ func (h *V1) DisableProducer(c *gin.Context) {
 
 // EnableConsumer godoc
 // @Summary      Enable Kafka consumer
-// @Description  Enable the Kafka consumer to receive messages
+// @Description  Enable the Kafka consumer and restart the consumers disabling stopped
 // @Tags         kafka
 // @Accept       json
 // @Produce      json
@@ -120,7 +122,7 @@ func (h *V1) EnableConsumer(c *gin.Context) {
 
 // DisableConsumer godoc
 // @Summary      Disable Kafka consumer
-// @Description  Disable the Kafka consumer from receiving messages
+// @Description  Stop all running consumers until the Kafka consumer is enabled again
 // @Tags         kafka
 // @Accept       json
 // @Produce      json
@@ -185,6 +187,95 @@ func (h *V1) CheckProducerStatus(c *gin.Context) {
 	})
 }
 
+// ListConsumers godoc
+// @Summary      List Kafka consumers
+// @Description  State, last message time and error counts of every consumer, by topic and group
+// @Tags         kafka
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Router       /v1/kafka/consumers [get]
+func (h *V1) ListConsumers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   h.kafka.ConsumerStatuses(),
+	})
+}
+
+// PauseConsumer godoc
+// @Summary      Pause a Kafka consumer
+// @Description  Stop handling messages of a topic and group; the consumer keeps its partitions
+// @Tags         kafka
+// @Produce      json
+// @Param        topic path string true "Kafka topic"
+// @Param        group path string true "Kafka group"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /v1/kafka/consumers/{topic}/{group}/pause [post]
+func (h *V1) PauseConsumer(c *gin.Context) {
+	h.controlConsumer(c, h.kafka.PauseConsumer, "consumer paused")
+}
+
+// ResumeConsumer godoc
+// @Summary      Resume a Kafka consumer
+// @Description  Handle messages of a paused topic and group again
+// @Tags         kafka
+// @Produce      json
+// @Param        topic path string true "Kafka topic"
+// @Param        group path string true "Kafka group"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /v1/kafka/consumers/{topic}/{group}/resume [post]
+func (h *V1) ResumeConsumer(c *gin.Context) {
+	h.controlConsumer(c, h.kafka.ResumeConsumer, "consumer resumed")
+}
+
+// StopConsumer godoc
+// @Summary      Stop a Kafka consumer
+// @Description  Commit and leave the group, handing the partitions to the other members
+// @Tags         kafka
+// @Produce      json
+// @Param        topic path string true "Kafka topic"
+// @Param        group path string true "Kafka group"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /v1/kafka/consumers/{topic}/{group}/stop [post]
+func (h *V1) StopConsumer(c *gin.Context) {
+	h.controlConsumer(c, h.kafka.StopConsumer, "consumer stopped")
+}
+
+// RestartConsumer godoc
+// @Summary      Restart a Kafka consumer
+// @Description  Stop the consumer of a topic and group if it runs, and start it again
+// @Tags         kafka
+// @Produce      json
+// @Param        topic path string true "Kafka topic"
+// @Param        group path string true "Kafka group"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /v1/kafka/consumers/{topic}/{group}/restart [post]
+func (h *V1) RestartConsumer(c *gin.Context) {
+	h.controlConsumer(c, h.kafka.RestartConsumer, "consumer restarted")
+}
+
+func (h *V1) controlConsumer(c *gin.Context, control func(topic, group string) error, status string) {
+	if err := control(c.Param("topic"), c.Param("group")); err != nil {
+		switch {
+		case errors.Is(err, kafka.ErrConsumerNotFound):
+			errorResponse(c, http.StatusNotFound, "consumer not found")
+		case errors.Is(err, kafka.ErrConsumerDisabled):
+			errorResponse(c, http.StatusConflict, "kafka consumer is disabled")
+		case errors.Is(err, kafka.ErrConsumerNotStarted), errors.Is(err, kafka.ErrConsumerRunning):
+			errorResponse(c, http.StatusConflict, err.Error())
+		default:
+			errorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": status})
+}
+
 // getConsumerStatusMessage returns a human-readable message for consumer status
 func getConsumerStatusMessage(enabled bool) string {
 	if enabled {
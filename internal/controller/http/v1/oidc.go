@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"time"
+
+	useruc "github.com/ducnpdev/godev-kit/internal/usecase/user"
+	"github.com/gin-gonic/gin"
+)
+
+// _oidcStateCookie binds a login's state to the browser that started it, so a
+// callback URL from someone else's login is rejected (login CSRF).
+const _oidcStateCookie = "oidc_state"
+
+// @Summary     Start OIDC login
+// @Description Redirect to the company identity provider. After the login there, the provider
+// @Description redirects the browser to the callback, which returns the token pair.
+// @ID          start-oidc-login
+// @Tags  	    auth
+// @Success     302
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/oidc/login [get]
+func (r *V1) StartOIDCLogin(c *gin.Context) {
+	redirect, err := r.user.StartOIDCLogin(c.Request.Context())
+	if err != nil {
+		r.l.Error(err, "http - v1 - startOIDCLogin")
+		oidcErrorResponse(c, err)
+		return
+	}
+
+	maxAge := int(math.Ceil(time.Until(redirect.ExpiresAt).Seconds()))
+	setOIDCStateCookie(c, redirect.State, max(maxAge, 1))
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, redirect.URL)
+}
+
+// @Summary     OIDC login callback
+// @Description Complete a login at the identity provider. The user is found by the linked identity,
+// @Description linked by verified email or created on the first login.
+// @ID          oidc-callback
+// @Tags  	    auth
+// @Produce     json
+// @Param       state query string true "State from the login redirect; must match the oidc_state cookie"
+// @Param       code  query string false "Authorization code"
+// @Param       error query string false "Error from the identity provider"
+// @Success     200 {object} response.LoginResponse
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/oidc/callback [get]
+func (r *V1) OIDCCallback(c *gin.Context) {
+	if e := c.Query("error"); e != "" {
+		r.l.Error(fmt.Errorf("%s: %s", e, c.Query("error_description")), "http - v1 - oidcCallback")
+		errorResponse(c, http.StatusUnauthorized, "login was not completed at the identity provider")
+		return
+	}
+
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		errorResponse(c, http.StatusBadRequest, "state and code are required")
+		return
+	}
+
+	// The cookie is single use like the state
+	browserState, _ := c.Cookie(_oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		r.l.Warn("http - v1 - oidcCallback - state does not match the browser's")
+		oidcErrorResponse(c, useruc.ErrInvalidOIDCState)
+		return
+	}
+
+	tokens, user, err := r.user.CompleteOIDCLogin(c.Request.Context(), state, code)
+	if err != nil {
+		r.l.Error(err, "http - v1 - oidcCallback")
+		oidcErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, loginResponse(tokens, user))
+}
+
+// setOIDCStateCookie sets the state cookie for the OIDC routes only; a negative
+// maxAge deletes it. SameSite=Lax lets it ride along the provider's redirect.
+func setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(_oidcStateCookie, state, maxAge, path.Dir(c.FullPath()), "", true, true)
+}
+
+func oidcErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, useruc.ErrInvalidOIDCState):
+		errorResponse(c, http.StatusBadRequest, "invalid or expired login, start again")
+	case errors.Is(err, useruc.ErrOIDCLoginFailed):
+		errorResponse(c, http.StatusUnauthorized, "login with the identity provider failed")
+	case errors.Is(err, useruc.ErrOIDCEmailNotVerified):
+		errorResponse(c, http.StatusForbidden, "the identity provider has not verified the email address")
+	case errors.Is(err, useruc.ErrOIDCDomainNotAllowed):
+		errorResponse(c, http.StatusForbidden, "email domain is not allowed")
+	case errors.Is(err, useruc.ErrOIDCAccountUnverified):
+		errorResponse(c, http.StatusForbidden, "verify the email address of the local account first")
+	case errors.Is(err, useruc.ErrOIDCUserDeleted):
+		errorResponse(c, http.StatusForbidden, "the account is deleted")
+	case errors.Is(err, useruc.ErrOIDCNotConfigured):
+		errorResponse(c, http.StatusNotImplemented, "oidc login is not enabled")
+	default:
+		errorResponse(c, http.StatusInternalServerError, "user service problems")
+	}
+}
@@ -1,9 +1,11 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/ducnpdev/godev-kit/internal/controller/http/middleware"
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/request"
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/response"
 	"github.com/ducnpdev/godev-kit/internal/entity"
@@ -28,14 +30,18 @@ func NewPaymentController(paymentUseCase *payment.PaymentUseCase, logger *zerolo
 
 // RegisterPayment registers a new payment
 // @Summary Register a new payment
-// @Description Register a new payment for electric bill and send to Kafka for processing
+// @Description Register a new payment for electric bill and send to Kafka for processing.
+// @Description With payment_method "vietqr" a VietQR code is generated instead, and the payment completes once it is paid.
 // @Tags payments
 // @Accept json
 // @Produce json
 // @Param payment body request.PaymentRequest true "Payment request"
 // @Success 201 {object} response.PaymentResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
+// @Security BearerAuth
 // @Router /v1/payments [post]
 func (c *PaymentController) RegisterPayment(ctx *gin.Context) {
 	var req request.PaymentRequest
@@ -48,6 +54,18 @@ func (c *PaymentController) RegisterPayment(ctx *gin.Context) {
 		return
 	}
 
+	principal, _ := middleware.Principal(ctx)
+	if req.UserID == 0 {
+		req.UserID = principal.UserID
+	}
+	if !principal.CanActFor(req.UserID) {
+		ctx.JSON(http.StatusForbidden, response.ErrorResponse{
+			Error:   "Forbidden",
+			Message: "payments can only be registered for the authenticated user",
+		})
+		return
+	}
+
 	// Convert request to entity
 	paymentReq := &entity.PaymentRequest{
 		UserID:        req.UserID,
@@ -61,9 +79,16 @@ func (c *PaymentController) RegisterPayment(ctx *gin.Context) {
 	}
 
 	// Register payment
-	paymentResp, err := c.paymentUseCase.RegisterPayment(ctx, paymentReq)
+	paymentResp, err := c.paymentUseCase.RegisterPayment(ctx.Request.Context(), paymentReq)
 	if err != nil {
 		c.logger.Error().Err(err).Msg("Failed to register payment")
+		if errors.Is(err, payment.ErrVietQRAmount) || errors.Is(err, payment.ErrVietQRNotConfigured) {
+			ctx.JSON(http.StatusBadRequest, response.ErrorResponse{
+				Error:   "Invalid request",
+				Message: err.Error(),
+			})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{
 			Error:   "Internal server error",
 			Message: err.Error(),
@@ -85,6 +110,8 @@ func (c *PaymentController) RegisterPayment(ctx *gin.Context) {
 		TransactionID: paymentResp.TransactionID,
 		PaymentMethod: paymentResp.PaymentMethod,
 		CreatedAt:     paymentResp.CreatedAt,
+		VietQRID:      paymentResp.VietQRID,
+		VietQR:        paymentResp.VietQR,
 	}
 
 	ctx.JSON(http.StatusCreated, resp)
@@ -99,8 +126,10 @@ func (c *PaymentController) RegisterPayment(ctx *gin.Context) {
 // @Param id path int true "Payment ID"
 // @Success 200 {object} response.PaymentResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
 // @Failure 404 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
+// @Security BearerAuth
 // @Router /v1/payments/{id} [get]
 func (c *PaymentController) GetPaymentByID(ctx *gin.Context) {
 	idStr := ctx.Param("id")
@@ -131,6 +160,15 @@ func (c *PaymentController) GetPaymentByID(ctx *gin.Context) {
 		return
 	}
 
+	// Other users' payments are reported as missing rather than forbidden
+	if principal, _ := middleware.Principal(ctx); !principal.CanActFor(paymentResp.UserID) {
+		ctx.JSON(http.StatusNotFound, response.ErrorResponse{
+			Error:   "Payment not found",
+			Message: "Payment with the specified ID was not found",
+		})
+		return
+	}
+
 	// Convert to response
 	resp := response.PaymentResponse{
 		ID:            paymentResp.ID,
@@ -145,6 +183,8 @@ func (c *PaymentController) GetPaymentByID(ctx *gin.Context) {
 		TransactionID: paymentResp.TransactionID,
 		PaymentMethod: paymentResp.PaymentMethod,
 		CreatedAt:     paymentResp.CreatedAt,
+		VietQRID:      paymentResp.VietQRID,
+		VietQRStatus:  string(paymentResp.VietQRStatus),
 	}
 
 	ctx.JSON(http.StatusOK, resp)
@@ -159,7 +199,10 @@ func (c *PaymentController) GetPaymentByID(ctx *gin.Context) {
 // @Param user_id path int true "User ID"
 // @Success 200 {array} response.PaymentResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
+// @Security BearerAuth
 // @Router /v1/users/{user_id}/payments [get]
 func (c *PaymentController) GetPaymentsByUserID(ctx *gin.Context) {
 	userIDStr := ctx.Param("user_id")
@@ -199,8 +242,79 @@ func (c *PaymentController) GetPaymentsByUserID(ctx *gin.Context) {
 			TransactionID: payment.TransactionID,
 			PaymentMethod: payment.PaymentMethod,
 			CreatedAt:     payment.CreatedAt,
+			VietQRID:      payment.VietQRID,
+			VietQRStatus:  string(payment.VietQRStatus),
 		}
 	}
 
 	ctx.JSON(http.StatusOK, responses)
 }
+
+// RefundPayment refunds a completed payment
+// @Summary Refund a payment
+// @Description Mark a completed payment as refunded. Requires the payments:refund permission.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param id path int true "Payment ID"
+// @Success 200 {object} response.PaymentResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Security BearerAuth
+// @Router /v1/payments/{id}/refund [post]
+func (c *PaymentController) RefundPayment(ctx *gin.Context) {
+	idStr := ctx.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.logger.Error().Err(err).Str("id", idStr).Msg("Invalid payment ID")
+		ctx.JSON(http.StatusBadRequest, response.ErrorResponse{
+			Error:   "Invalid payment ID",
+			Message: "Payment ID must be a valid integer",
+		})
+		return
+	}
+
+	paymentResp, err := c.paymentUseCase.RefundPayment(ctx, id)
+	if err != nil {
+		c.logger.Error().Err(err).Int64("payment_id", id).Msg("Failed to refund payment")
+		switch {
+		case errors.Is(err, payment.ErrPaymentNotFound):
+			ctx.JSON(http.StatusNotFound, response.ErrorResponse{
+				Error:   "Payment not found",
+				Message: "Payment with the specified ID was not found",
+			})
+		case errors.Is(err, payment.ErrPaymentNotRefundable):
+			ctx.JSON(http.StatusConflict, response.ErrorResponse{
+				Error:   "Payment not refundable",
+				Message: err.Error(),
+			})
+		default:
+			ctx.JSON(http.StatusInternalServerError, response.ErrorResponse{
+				Error:   "Internal server error",
+				Message: err.Error(),
+			})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.PaymentResponse{
+		ID:            paymentResp.ID,
+		UserID:        paymentResp.UserID,
+		Amount:        paymentResp.Amount,
+		Currency:      paymentResp.Currency,
+		PaymentType:   string(paymentResp.PaymentType),
+		Status:        string(paymentResp.Status),
+		MeterNumber:   paymentResp.MeterNumber,
+		CustomerCode:  paymentResp.CustomerCode,
+		Description:   paymentResp.Description,
+		TransactionID: paymentResp.TransactionID,
+		PaymentMethod: paymentResp.PaymentMethod,
+		CreatedAt:     paymentResp.CreatedAt,
+		VietQRID:      paymentResp.VietQRID,
+		VietQRStatus:  string(paymentResp.VietQRStatus),
+	})
+}
@@ -3,7 +3,8 @@ package request
 // PaymentRequest represents payment request
 // @Description Payment request for electric bill
 type PaymentRequest struct {
-	UserID        int64   `json:"user_id" binding:"required" example:"1"`
+	// UserID defaults to the authenticated user; only admins may pay for someone else.
+	UserID        int64   `json:"user_id" binding:"omitempty,gt=0" example:"1"`
 	Amount        float64 `json:"amount" binding:"required" example:"500000"`
 	Currency      string  `json:"currency" binding:"required" example:"VND"`
 	PaymentType   string  `json:"payment_type" binding:"required" example:"electric"`
@@ -1,5 +1,7 @@
 package request
 
+import "time"
+
 // CreateUser represents create user request
 type CreateUser struct {
 	Email    string `json:"email"     validate:"required,email" example:"user@example.com"`
@@ -14,8 +16,78 @@ type UpdateUser struct {
 	Password string `json:"password"  validate:"omitempty,min=6"  example:"password123"`
 }
 
+// ListUsers represents the query parameters for listing users
+type ListUsers struct {
+	// Search matches the start of the email or the username, ignoring case
+	Search  string `form:"q"       binding:"max=100"`
+	Sort    string `form:"sort"    binding:"omitempty,oneof=id created_at email username"`
+	Order   string `form:"order"   binding:"omitempty,oneof=asc desc"`
+	Limit   uint64 `form:"limit"   binding:"max=100"`
+	Cursor  string `form:"cursor"`
+	Deleted bool   `form:"deleted"`
+}
+
 // LoginUser represents login user request
 type LoginUser struct {
 	Email    string `json:"email"     validate:"required,email" example:"user@example.com"`
 	Password string `json:"password"  validate:"required,min=6"  example:"password123"`
 }
+
+// RefreshToken represents refresh token request
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Logout represents logout request. The refresh token is optional; when given
+// every token issued from the same login is revoked.
+type Logout struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ForgotPassword represents forgot password request
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email" example:"user@example.com"`
+}
+
+// ResetPassword represents reset password request
+type ResetPassword struct {
+	Token    string `json:"token"    validate:"required"`
+	Password string `json:"password" validate:"required,min=6" example:"new-password123"`
+}
+
+// VerifyEmail represents verify email request
+type VerifyEmail struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerification represents resend verification email request
+type ResendVerification struct {
+	Email string `json:"email" validate:"required,email" example:"user@example.com"`
+}
+
+// CompleteMFALogin represents the second login step
+type CompleteMFALogin struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	// Code is a 6 digit code from the authenticator app or a recovery code
+	Code string `json:"code" validate:"required" example:"123456"`
+}
+
+// MFACode represents a request confirmed with a two-factor code
+type MFACode struct {
+	Code string `json:"code" validate:"required" example:"123456"`
+}
+
+// AssignRole represents assign role request
+type AssignRole struct {
+	Role string `json:"role" validate:"required" example:"admin"`
+}
+
+// CreateAPIKey represents create API key request
+type CreateAPIKey struct {
+	Name string `json:"name" validate:"required,max=100" example:"nightly billing export"`
+	// UserID is the user the key acts as; the caller when omitted
+	UserID int64 `json:"user_id" validate:"gte=0" example:"12"`
+	// Scopes are permissions of that user the key may use
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required" example:"payments:refund"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
@@ -1,15 +1,58 @@
 package request
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // GenerateQR represents the request body for generating a VietQR code.
 type GenerateQR struct {
+	// BankCode is a code (e.g. VCB) or BIN from GET /v1/vietqr/banks.
+	BankCode     string `json:"bankCode" binding:"required"`
 	AccountNo    string `json:"accountNo" binding:"required"`
 	Amount       string `json:"amount" binding:"required"`
 	Description  string `json:"description"`
 	MCC          string `json:"mcc"`
 	ReceiverName string `json:"receiverName"`
+	// TTLSeconds is how long the code stays payable; zero uses the server default.
+	TTLSeconds int `json:"ttlSeconds" binding:"min=0"`
+}
+
+// ListBanks represents the query parameters for searching the bank directory.
+type ListBanks struct {
+	Query string `form:"q"`
+}
+
+// VietQRImage represents the query parameters for rendering a VietQR code.
+type VietQRImage struct {
+	Format string `form:"format,default=png" binding:"oneof=png svg"`
+	Size   int    `form:"size" binding:"min=0,max=1024"`
+	Level  string `form:"level" binding:"omitempty,oneof=L M Q H"`
+	Logo   string `form:"logo"`
+}
+
+// DecodeQR represents the request body for decoding a VietQR string.
+type DecodeQR struct {
+	Content string `json:"content" binding:"required"`
 }
 
 // UpdateVietQRStatus represents the request body for updating a VietQR status.
 type UpdateVietQRStatus struct {
 	Status string `json:"status" binding:"required"`
 }
+
+// BankNotification represents a balance-change webhook sent by a bank.
+type BankNotification struct {
+	TransactionID string `json:"transactionId" binding:"required,max=64"`
+	AccountNo     string `json:"accountNo" binding:"required"`
+	// Amount accepts both JSON numbers and numeric strings.
+	Amount       json.Number `json:"amount" binding:"required"`
+	Description  string      `json:"description"`
+	Type         string      `json:"type" binding:"required,oneof=credit debit"`
+	TransactedAt time.Time   `json:"transactedAt" binding:"required"`
+}
+
+// ListUnmatchedTransactions represents the query parameters for listing unmatched bank credits.
+type ListUnmatchedTransactions struct {
+	Limit uint64 `form:"limit" binding:"max=100"`
+}
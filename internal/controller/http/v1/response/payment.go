@@ -1,6 +1,10 @@
 package response
 
-import "time"
+import (
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+)
 
 // PaymentResponse represents payment response
 // @Description Payment response with all payment details
@@ -17,4 +21,9 @@ type PaymentResponse struct {
 	TransactionID string    `json:"transaction_id" example:"uuid-here"`
 	PaymentMethod string    `json:"payment_method" example:"bank_transfer"`
 	CreatedAt     time.Time `json:"created_at" example:"2024-12-20T10:30:00Z"`
+	// VietQRID and VietQRStatus are set for payment_method "vietqr"
+	VietQRID     string `json:"vietqr_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	VietQRStatus string `json:"vietqr_status,omitempty" example:"generated"`
+	// VietQR is the code to show the customer, returned on registration only
+	VietQR *entity.VietQR `json:"vietqr,omitempty"`
 }
@@ -1,11 +1,45 @@
 package response
 
+import "time"
+
 // LoginResponse represents login response
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  struct {
+	// Token is the access token, kept under its original name
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at,omitempty"`
+	User             struct {
 		ID       int64  `json:"id"`
 		Email    string `json:"email"`
 		Username string `json:"username"`
 	} `json:"user"`
 }
+
+// MFAChallengeResponse is returned by login instead of LoginResponse when the
+// user has two-factor authentication enabled
+type MFAChallengeResponse struct {
+	MFARequired  bool      `json:"mfa_required"`
+	MFAToken     string    `json:"mfa_token"`
+	MFAExpiresAt time.Time `json:"mfa_expires_at"`
+}
+
+// RecoveryCodes represents newly generated recovery codes, shown once
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TokenResponse represents a refreshed token pair
+type TokenResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// UserRoles represents the roles of a user
+type UserRoles struct {
+	UserID      int64    `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,9 @@
+package response
+
+// VietQRDecodeError describes why a VietQR string could not be decoded.
+type VietQRDecodeError struct {
+	Error  string `json:"error" example:"checksum mismatch"`
+	Tag    string `json:"tag,omitempty" example:"63"`
+	Offset int    `json:"offset" example:"120"`
+	Detail string `json:"detail,omitempty" example:"got 1A2B, want 3C4D"`
+}
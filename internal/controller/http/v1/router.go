@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"github.com/ducnpdev/godev-kit/internal/controller/http/middleware"
+	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/godev-kit/internal/usecase"
 	"github.com/ducnpdev/godev-kit/pkg/logger"
 	"github.com/gin-gonic/gin"
@@ -19,20 +21,62 @@ func NewTranslationRoutes(apiV1Group *gin.RouterGroup, t usecase.Translation, l
 	}
 }
 
-// NewUserRoutes -.
-func NewUserRoutes(apiV1Group *gin.RouterGroup, u usecase.User, l logger.Interface) {
+// NewUserRoutes registers sign-up and login on the public group and the
+// rest of the user endpoints on the protected group.
+func NewUserRoutes(public, protected *gin.RouterGroup, u usecase.User, l logger.Interface) {
 	r := &V1{user: u, l: l, v: validator.New(validator.WithRequiredStructEnabled())}
 
-	userGroup := apiV1Group.Group("/user")
+	public.POST("/user", r.CreateUser)
+	public.POST("auth/login", r.LoginUser)
+	public.POST("auth/refresh", r.RefreshToken)
+	public.POST("auth/password/forgot", r.ForgotPassword)
+	public.POST("auth/password/reset", r.ResetPassword)
+	public.POST("auth/email/verify", r.VerifyEmail)
+	public.POST("auth/email/resend", r.ResendVerification)
+	public.POST("auth/login/mfa", r.CompleteMFALogin)
+	public.GET("auth/oidc/login", r.StartOIDCLogin)
+	public.GET("auth/oidc/callback", r.OIDCCallback)
+	protected.POST("auth/logout", r.Logout)
+	protected.POST("auth/mfa/totp/enroll", r.EnrollTOTP)
+	protected.POST("auth/mfa/totp/confirm", r.ConfirmTOTP)
+	protected.POST("auth/mfa/totp/disable", r.DisableTOTP)
+	protected.POST("auth/mfa/recovery-codes", r.RegenerateRecoveryCodes)
+
+	userGroup := protected.Group("/user")
 
 	{
-		userGroup.POST("", r.CreateUser)
-		userGroup.GET("", r.ListUsers)
-		userGroup.GET("/:id", r.GetUser)
-		userGroup.PUT("/:id", r.UpdateUser)
-		userGroup.DELETE("/:id", r.DeleteUser)
+		userGroup.GET("", middleware.RequirePermission(entity.PermissionUsersRead), r.ListUsers)
+		userGroup.GET("/:id", middleware.OwnerOrAdmin("id"), r.GetUser)
+		userGroup.PUT("/:id", middleware.OwnerOrAdmin("id"), r.UpdateUser)
+		userGroup.DELETE("/:id", middleware.RequirePermission(entity.PermissionUsersDelete), r.DeleteUser)
+		userGroup.POST("/:id/restore", middleware.RequirePermission(entity.PermissionUsersDelete), r.RestoreUser)
+		userGroup.POST("/:id/unlock", middleware.RequirePermission(entity.PermissionUsersUnlock), r.UnlockUser)
+	}
+}
+
+// NewAdminRoutes registers role management, which requires the roles:manage permission.
+func NewAdminRoutes(protected *gin.RouterGroup, roles usecase.Roles, l logger.Interface) {
+	r := &V1{roles: roles, l: l, v: validator.New(validator.WithRequiredStructEnabled())}
+
+	adminGroup := protected.Group("/admin", middleware.RequirePermission(entity.PermissionRolesManage))
+	{
+		adminGroup.GET("/roles", r.listRoles)
+		adminGroup.GET("/users/:id/roles", r.getUserRoles)
+		adminGroup.POST("/users/:id/roles", r.assignRole)
+		adminGroup.DELETE("/users/:id/roles/:role", r.revokeRole)
+	}
+}
+
+// NewAPIKeyRoutes registers API key management, which requires the api_keys:manage permission.
+func NewAPIKeyRoutes(protected *gin.RouterGroup, keys usecase.APIKeys, l logger.Interface) {
+	r := &V1{apiKeys: keys, l: l, v: validator.New(validator.WithRequiredStructEnabled())}
+
+	apiKeyGroup := protected.Group("/admin/api-keys", middleware.RequirePermission(entity.PermissionAPIKeysManage))
+	{
+		apiKeyGroup.POST("", r.createAPIKey)
+		apiKeyGroup.GET("", r.listAPIKeys)
+		apiKeyGroup.DELETE("/:id", r.revokeAPIKey)
 	}
-	apiV1Group.POST("auth/login", r.LoginUser)
 }
 
 // // NewUserRoutes -.
@@ -60,11 +104,17 @@ func NewKafkaRoutes(apiV1Group *gin.RouterGroup, kafka usecase.Kafka, l logger.I
 		kafkaGroup.GET("/consumer/receiver", r.ConsumerReceiver)
 
 		// Control endpoints
-		kafkaGroup.POST("/producer/enable", r.EnableProducer)
-		kafkaGroup.POST("/producer/disable", r.DisableProducer)
-		kafkaGroup.POST("/consumer/enable", r.EnableConsumer)
-		kafkaGroup.POST("/consumer/disable", r.DisableConsumer)
+		manage := middleware.RequirePermission(entity.PermissionKafkaManage)
+		kafkaGroup.POST("/producer/enable", manage, r.EnableProducer)
+		kafkaGroup.POST("/producer/disable", manage, r.DisableProducer)
+		kafkaGroup.POST("/consumer/enable", manage, r.EnableConsumer)
+		kafkaGroup.POST("/consumer/disable", manage, r.DisableConsumer)
 		kafkaGroup.GET("/status", r.GetKafkaStatus)
+		kafkaGroup.GET("/consumers", r.ListConsumers)
+		kafkaGroup.POST("/consumers/:topic/:group/pause", manage, r.PauseConsumer)
+		kafkaGroup.POST("/consumers/:topic/:group/resume", manage, r.ResumeConsumer)
+		kafkaGroup.POST("/consumers/:topic/:group/stop", manage, r.StopConsumer)
+		kafkaGroup.POST("/consumers/:topic/:group/restart", manage, r.RestartConsumer)
 
 		// Status check endpoints
 		kafkaGroup.GET("/producer/status", r.CheckProducerStatus)
@@ -139,14 +189,27 @@ func NewNatsRoutes(apiV1Group *gin.RouterGroup, nats usecase.Nats, l logger.Inte
 }
 
 // NewVietQRRoutes -.
-func NewVietQRRoutes(apiV1Group *gin.RouterGroup, vietqr usecase.VietQR, l logger.Interface) {
-	v1 := &V1{vietqr: vietqr, l: l, v: validator.New(validator.WithRequiredStructEnabled())}
+// signed guards the bank webhook, which banks call without a user token.
+func NewVietQRRoutes(public, protected *gin.RouterGroup, vietqr usecase.VietQR, bankNotification usecase.BankNotification, signed gin.HandlerFunc, l logger.Interface) {
+	v1 := &V1{vietqr: vietqr, bankNotification: bankNotification, l: l, v: validator.New(validator.WithRequiredStructEnabled())}
+
+	// The QR ID is an unguessable UUID handed to the payer, so the image and
+	// the event stream are reachable from a checkout page without a token.
+	publicGroup := public.Group("/vietqr")
+	{
+		publicGroup.GET("/banks", v1.listBanks)
+		publicGroup.GET("/:id/image", v1.renderQR)
+		publicGroup.GET("/:id/events", v1.streamQREvents)
+		publicGroup.POST("/notifications/bank", signed, v1.ingestBankNotification)
+	}
 
-	vietqrGroup := apiV1Group.Group("/vietqr")
+	vietqrGroup := protected.Group("/vietqr")
 	{
 		vietqrGroup.POST("/gen", v1.generateQR)
+		vietqrGroup.POST("/decode", v1.decodeQR)
 		vietqrGroup.GET("/inquiry/:id", v1.inquiryQR)
-		vietqrGroup.PUT("/update/:id", v1.updateStatus)
+		vietqrGroup.PUT("/update/:id", middleware.RequirePermission(entity.PermissionVietQRUpdate), v1.updateStatus)
+		vietqrGroup.GET("/notifications/unmatched", middleware.RequirePermission(entity.PermissionVietQRReview), v1.listUnmatchedTransactions)
 	}
 }
 
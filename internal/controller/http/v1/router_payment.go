@@ -1,19 +1,22 @@
 package v1
 
 import (
+	"github.com/ducnpdev/godev-kit/internal/controller/http/middleware"
+	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/gin-gonic/gin"
 )
 
-// RegisterPaymentRoutes registers payment routes
+// RegisterPaymentRoutes registers payment routes. api must be an authenticated group.
 func (v *V1) RegisterPaymentRoutes(api *gin.RouterGroup) {
 	payments := api.Group("/payments")
 	{
 		payments.POST("", v.paymentController.RegisterPayment)
 		payments.GET("/:id", v.paymentController.GetPaymentByID)
+		payments.POST("/:id/refund", middleware.RequirePermission(entity.PermissionPaymentsRefund), v.paymentController.RefundPayment)
 	}
 
 	users := api.Group("/users")
 	{
-		users.GET("/:user_id/payments", v.paymentController.GetPaymentsByUserID)
+		users.GET("/:user_id/payments", middleware.OwnerOrAdmin("user_id"), v.paymentController.GetPaymentsByUserID)
 	}
 }
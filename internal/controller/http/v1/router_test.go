@@ -5,16 +5,24 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/ducnpdev/godev-kit/internal/controller/http/middleware"
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/request"
 	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/usecase"
+	useruc "github.com/ducnpdev/godev-kit/internal/usecase/user"
+	vietqruc "github.com/ducnpdev/godev-kit/internal/usecase/vietqr"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockUserUseCase is a mock implementation of usecase.User
@@ -48,55 +56,128 @@ func (m *MockUserUseCase) Delete(ctx context.Context, id int64) error {
 	return args.Error(0)
 }
 
-func (m *MockUserUseCase) List(ctx context.Context) (entity.UserHistory, error) {
-	args := m.Called(ctx)
+func (m *MockUserUseCase) Restore(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserUseCase) List(ctx context.Context, query entity.UserQuery, cursor string) (entity.UserHistory, error) {
+	args := m.Called(ctx, query, cursor)
 	if args.Get(0) == nil {
 		return entity.UserHistory{}, args.Error(1)
 	}
 	return args.Get(0).(entity.UserHistory), args.Error(1)
 }
 
-func (m *MockUserUseCase) Login(ctx context.Context, email, password string) (string, entity.User, error) {
-	args := m.Called(ctx, email, password)
-	return args.String(0), args.Get(1).(entity.User), args.Error(2)
+func (m *MockUserUseCase) Login(ctx context.Context, email, password, clientIP string) (entity.AuthTokens, entity.User, error) {
+	args := m.Called(ctx, email, password, clientIP)
+	return args.Get(0).(entity.AuthTokens), args.Get(1).(entity.User), args.Error(2)
 }
 
-// MockLogger is a mock implementation of logger.Interface
-type MockLogger struct {
-	mock.Mock
+func (m *MockUserUseCase) Refresh(ctx context.Context, refreshToken string) (entity.AuthTokens, error) {
+	args := m.Called(ctx, refreshToken)
+	return args.Get(0).(entity.AuthTokens), args.Error(1)
+}
+
+func (m *MockUserUseCase) Logout(ctx context.Context, refreshToken string) error {
+	args := m.Called(ctx, refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockUserUseCase) ForgotPassword(ctx context.Context, email, clientIP string) error {
+	args := m.Called(ctx, email, clientIP)
+	return args.Error(0)
+}
+
+func (m *MockUserUseCase) ResetPassword(ctx context.Context, token, password string) error {
+	args := m.Called(ctx, token, password)
+	return args.Error(0)
+}
+
+func (m *MockUserUseCase) VerifyEmail(ctx context.Context, token string) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
 }
 
-func (m *MockLogger) Debug(message interface{}, args ...interface{}) {
-	m.Called(message, args)
+func (m *MockUserUseCase) ResendVerification(ctx context.Context, email, clientIP string) error {
+	args := m.Called(ctx, email, clientIP)
+	return args.Error(0)
 }
 
-func (m *MockLogger) Info(message string, args ...interface{}) {
-	m.Called(message, args)
+func (m *MockUserUseCase) CompleteMFALogin(ctx context.Context, mfaToken, code, clientIP string) (entity.AuthTokens, entity.User, error) {
+	args := m.Called(ctx, mfaToken, code, clientIP)
+	return args.Get(0).(entity.AuthTokens), args.Get(1).(entity.User), args.Error(2)
 }
 
-func (m *MockLogger) Warn(message string, args ...interface{}) {
-	m.Called(message, args)
+func (m *MockUserUseCase) EnrollTOTP(ctx context.Context) (entity.TOTPEnrollment, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(entity.TOTPEnrollment), args.Error(1)
 }
 
-func (m *MockLogger) Error(message interface{}, args ...interface{}) {
-	m.Called(message, args)
+func (m *MockUserUseCase) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	args := m.Called(ctx, code)
+	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *MockLogger) Fatal(message interface{}, args ...interface{}) {
-	m.Called(message, args)
+func (m *MockUserUseCase) DisableTOTP(ctx context.Context, code string) error {
+	args := m.Called(ctx, code)
+	return args.Error(0)
+}
+
+func (m *MockUserUseCase) UnlockUser(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
 }
 
-func (m *MockLogger) Zerolog() interface{} {
-	return nil
+func (m *MockUserUseCase) StartOIDCLogin(ctx context.Context) (entity.OIDCLoginRedirect, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(entity.OIDCLoginRedirect), args.Error(1)
+}
+
+func (m *MockUserUseCase) CompleteOIDCLogin(ctx context.Context, state, code string) (entity.AuthTokens, entity.User, error) {
+	args := m.Called(ctx, state, code)
+	return args.Get(0).(entity.AuthTokens), args.Get(1).(entity.User), args.Error(2)
+}
+
+func (m *MockUserUseCase) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	args := m.Called(ctx, code)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockLogger is a mock implementation of logger.Interface. It discards every
+// call, so handlers may log whatever they need without expectations set up.
+type MockLogger struct {
+	mock.Mock
 }
 
-func (m *MockLogger) ZerologPtr() interface{} {
-	return nil
+func (m *MockLogger) Debug(message interface{}, args ...interface{}) {}
+
+func (m *MockLogger) Info(message string, args ...interface{}) {}
+
+func (m *MockLogger) Warn(message string, args ...interface{}) {}
+
+func (m *MockLogger) Error(message interface{}, args ...interface{}) {}
+
+func (m *MockLogger) Fatal(message interface{}, args ...interface{}) {}
+
+func (m *MockLogger) Zerolog() zerolog.Logger {
+	return zerolog.Nop()
 }
 
+var _ logger.Interface = (*MockLogger)(nil)
+
 func setupRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
-	return gin.New()
+	router := gin.New()
+	// Handlers are exercised as an authenticated admin; the auth layer has its own tests
+	router.Use(func(c *gin.Context) {
+		middleware.SetPrincipal(c, entity.Principal{
+			UserID:      1,
+			Roles:       []string{entity.RoleAdmin},
+			Permissions: []string{entity.PermissionUsersRead, entity.PermissionUsersDelete},
+		})
+	})
+	return router
 }
 
 func TestNewUserRoutes_CreateUser(t *testing.T) {
@@ -197,7 +278,7 @@ func TestNewUserRoutes_CreateUser(t *testing.T) {
 			tt.mockSetup(mockUserUseCase)
 
 			apiV1Group := router.Group("/v1")
-			NewUserRoutes(apiV1Group, mockUserUseCase, mockLogger)
+			NewUserRoutes(apiV1Group, apiV1Group, mockUserUseCase, mockLogger)
 
 			// Create request
 			body, _ := json.Marshal(tt.requestBody)
@@ -302,7 +383,7 @@ func TestNewUserRoutes_GetUser(t *testing.T) {
 			tt.mockSetup(mockUserUseCase)
 
 			apiV1Group := router.Group("/v1")
-			NewUserRoutes(apiV1Group, mockUserUseCase, mockLogger)
+			NewUserRoutes(apiV1Group, apiV1Group, mockUserUseCase, mockLogger)
 
 			// Create request
 			req := httptest.NewRequest(http.MethodGet, "/v1/user/"+tt.userID, nil)
@@ -331,7 +412,7 @@ func TestNewUserRoutes_ListUsers(t *testing.T) {
 		{
 			name: "success - list users",
 			mockSetup: func(m *MockUserUseCase) {
-				m.On("List", mock.Anything).Return(entity.UserHistory{
+				m.On("List", mock.Anything, mock.Anything, "").Return(entity.UserHistory{
 					Users: []entity.User{
 						{
 							ID:        1,
@@ -362,7 +443,7 @@ func TestNewUserRoutes_ListUsers(t *testing.T) {
 		{
 			name: "error - service error",
 			mockSetup: func(m *MockUserUseCase) {
-				m.On("List", mock.Anything).Return(entity.UserHistory{}, errors.New("database error"))
+				m.On("List", mock.Anything, mock.Anything, "").Return(entity.UserHistory{}, errors.New("database error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
 			validateResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -387,7 +468,7 @@ func TestNewUserRoutes_ListUsers(t *testing.T) {
 			tt.mockSetup(mockUserUseCase)
 
 			apiV1Group := router.Group("/v1")
-			NewUserRoutes(apiV1Group, mockUserUseCase, mockLogger)
+			NewUserRoutes(apiV1Group, apiV1Group, mockUserUseCase, mockLogger)
 
 			// Create request
 			req := httptest.NewRequest(http.MethodGet, "/v1/user", nil)
@@ -496,7 +577,7 @@ func TestNewUserRoutes_UpdateUser(t *testing.T) {
 			tt.mockSetup(mockUserUseCase)
 
 			apiV1Group := router.Group("/v1")
-			NewUserRoutes(apiV1Group, mockUserUseCase, mockLogger)
+			NewUserRoutes(apiV1Group, apiV1Group, mockUserUseCase, mockLogger)
 
 			// Create request
 			body, _ := json.Marshal(tt.requestBody)
@@ -580,7 +661,7 @@ func TestNewUserRoutes_DeleteUser(t *testing.T) {
 			tt.mockSetup(mockUserUseCase)
 
 			apiV1Group := router.Group("/v1")
-			NewUserRoutes(apiV1Group, mockUserUseCase, mockLogger)
+			NewUserRoutes(apiV1Group, apiV1Group, mockUserUseCase, mockLogger)
 
 			// Create request
 			req := httptest.NewRequest(http.MethodDelete, "/v1/user/"+tt.userID, nil)
@@ -614,8 +695,8 @@ func TestNewUserRoutes_LoginUser(t *testing.T) {
 				Password: "password123",
 			},
 			mockSetup: func(m *MockUserUseCase) {
-				m.On("Login", mock.Anything, "test@example.com", "password123").Return(
-					"mock-jwt-token",
+				m.On("Login", mock.Anything, "test@example.com", "password123", mock.Anything).Return(
+					entity.AuthTokens{AccessToken: "mock-jwt-token"},
 					entity.User{
 						ID:       1,
 						Email:    "test@example.com",
@@ -669,10 +750,10 @@ func TestNewUserRoutes_LoginUser(t *testing.T) {
 				Password: "wrongpassword",
 			},
 			mockSetup: func(m *MockUserUseCase) {
-				m.On("Login", mock.Anything, "test@example.com", "wrongpassword").Return(
-					"",
+				m.On("Login", mock.Anything, "test@example.com", "wrongpassword", mock.Anything).Return(
+					entity.AuthTokens{},
 					entity.User{},
-					errors.New("UserUseCase - Login - bcrypt.CompareHashAndPassword: crypto/bcrypt: hashedPassword is not the hash of the given password"),
+					fmt.Errorf("UserUseCase - Login: %w", useruc.ErrInvalidCredentials),
 				)
 			},
 			expectedStatus: http.StatusUnauthorized,
@@ -690,8 +771,8 @@ func TestNewUserRoutes_LoginUser(t *testing.T) {
 				Password: "password123",
 			},
 			mockSetup: func(m *MockUserUseCase) {
-				m.On("Login", mock.Anything, "test@example.com", "password123").Return(
-					"",
+				m.On("Login", mock.Anything, "test@example.com", "password123", mock.Anything).Return(
+					entity.AuthTokens{},
 					entity.User{},
 					errors.New("database error"),
 				)
@@ -719,7 +800,7 @@ func TestNewUserRoutes_LoginUser(t *testing.T) {
 			tt.mockSetup(mockUserUseCase)
 
 			apiV1Group := router.Group("/v1")
-			NewUserRoutes(apiV1Group, mockUserUseCase, mockLogger)
+			NewUserRoutes(apiV1Group, apiV1Group, mockUserUseCase, mockLogger)
 
 			// Create request
 			body, _ := json.Marshal(tt.requestBody)
@@ -739,3 +820,209 @@ func TestNewUserRoutes_LoginUser(t *testing.T) {
 		})
 	}
 }
+
+func TestNewUserRoutes_ForgotPassword(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "success - accepted", expectedStatus: http.StatusAccepted},
+		{name: "error - rate limited", err: useruc.ErrTooManyMailRequests, expectedStatus: http.StatusTooManyRequests},
+		{name: "error - mail not configured", err: useruc.ErrMailNotConfigured, expectedStatus: http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			mockUserUseCase := new(MockUserUseCase)
+			mockUserUseCase.On("ForgotPassword", mock.Anything, "test@example.com", "192.0.2.1").Return(tt.err)
+
+			apiV1Group := router.Group("/v1")
+			NewUserRoutes(apiV1Group, apiV1Group, mockUserUseCase, new(MockLogger))
+
+			body, _ := json.Marshal(request.ForgotPassword{Email: "test@example.com"})
+			req := httptest.NewRequest(http.MethodPost, "/v1/auth/password/forgot", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "192.0.2.1:1234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockUserUseCase.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewUserRoutes_OIDCStateCookie(t *testing.T) {
+	setup := func() (*gin.Engine, *MockUserUseCase) {
+		router := setupRouter()
+		mockUserUseCase := new(MockUserUseCase)
+		apiV1Group := router.Group("/v1")
+		NewUserRoutes(apiV1Group, apiV1Group, mockUserUseCase, new(MockLogger))
+		return router, mockUserUseCase
+	}
+
+	t.Run("login sets a short-lived HttpOnly state cookie", func(t *testing.T) {
+		router, m := setup()
+		m.On("StartOIDCLogin", mock.Anything).Return(entity.OIDCLoginRedirect{
+			URL:       "https://login.example.com/authorize?state=s1",
+			State:     "s1",
+			ExpiresAt: time.Now().Add(10 * time.Minute),
+		}, nil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/login", nil))
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://login.example.com/authorize?state=s1", w.Header().Get("Location"))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "oidc_state", cookies[0].Name)
+		assert.Equal(t, "s1", cookies[0].Value)
+		assert.Equal(t, "/v1/auth/oidc", cookies[0].Path)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.True(t, cookies[0].Secure)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+		assert.InDelta(t, 600, cookies[0].MaxAge, 1)
+	})
+
+	tests := []struct {
+		name           string
+		cookie         string
+		expectedStatus int
+	}{
+		{name: "success - cookie matches the state", cookie: "s1", expectedStatus: http.StatusOK},
+		{name: "error - no cookie", expectedStatus: http.StatusBadRequest},
+		{name: "error - cookie of another login", cookie: "s2", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, m := setup()
+			if tt.expectedStatus == http.StatusOK {
+				m.On("CompleteOIDCLogin", mock.Anything, "s1", "code").Return(
+					entity.AuthTokens{AccessToken: "access"}, entity.User{ID: 1}, nil)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/callback?state=s1&code=code", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "oidc_state", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			cookies := w.Result().Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, "oidc_state", cookies[0].Name)
+			assert.Negative(t, cookies[0].MaxAge, "the cookie is cleared")
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+// MockVietQRUseCase mocks the usecase.VietQR methods the image route uses.
+type MockVietQRUseCase struct {
+	usecase.VietQR
+	mock.Mock
+}
+
+func (m *MockVietQRUseCase) RenderQR(ctx context.Context, req entity.VietQRImageRequest) (*entity.VietQRImage, error) {
+	args := m.Called(ctx, req)
+	img, _ := args.Get(0).(*entity.VietQRImage)
+	return img, args.Error(1)
+}
+
+func TestNewVietQRRoutes_RenderQR(t *testing.T) {
+	img := &entity.VietQRImage{ContentType: "image/svg+xml", ETag: `"abc"`, Data: []byte("<svg></svg>")}
+
+	tests := []struct {
+		name           string
+		query          string
+		ifNoneMatch    string
+		mockSetup      func(*MockVietQRUseCase)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:  "success - rendered image",
+			query: "?format=svg&size=128",
+			mockSetup: func(m *MockVietQRUseCase) {
+				m.On("RenderQR", mock.Anything, entity.VietQRImageRequest{ID: "qr-1", Format: "svg", Size: 128}).Return(img, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<svg></svg>",
+		},
+		{
+			name:        "success - not modified",
+			query:       "?format=svg",
+			ifNoneMatch: `"abc"`,
+			mockSetup: func(m *MockVietQRUseCase) {
+				m.On("RenderQR", mock.Anything, mock.Anything).Return(img, nil)
+			},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:        "success - stale validator",
+			ifNoneMatch: `"old"`,
+			mockSetup: func(m *MockVietQRUseCase) {
+				m.On("RenderQR", mock.Anything, mock.Anything).Return(img, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<svg></svg>",
+		},
+		{
+			name:           "error - unsupported format",
+			query:          "?format=gif",
+			mockSetup:      func(m *MockVietQRUseCase) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "error - unknown logo",
+			query: "?logo=nope",
+			mockSetup: func(m *MockVietQRUseCase) {
+				m.On("RenderQR", mock.Anything, mock.Anything).Return(nil, vietqruc.ErrInvalidImageRequest)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "error - not found",
+			mockSetup: func(m *MockVietQRUseCase) {
+				m.On("RenderQR", mock.Anything, mock.Anything).Return(nil, vietqruc.ErrQRNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupRouter()
+			mockVietQR := new(MockVietQRUseCase)
+			tt.mockSetup(mockVietQR)
+
+			apiV1Group := router.Group("/v1")
+			NewVietQRRoutes(apiV1Group, apiV1Group, mockVietQR, nil, func(c *gin.Context) {}, new(MockLogger))
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/vietqr/qr-1/image"+tt.query, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK || tt.expectedStatus == http.StatusNotModified {
+				assert.Equal(t, img.ETag, w.Header().Get("ETag"))
+			}
+			if tt.expectedBody != "" {
+				assert.Equal(t, img.ContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+			if tt.expectedStatus == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+			mockVietQR.AssertExpectations(t)
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package v1
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/request"
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/response"
 	"github.com/ducnpdev/godev-kit/internal/entity"
+	useruc "github.com/ducnpdev/godev-kit/internal/usecase/user"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,11 +20,9 @@ import (
 // @Tags  	    user
 // @Accept      json
 // @Produce     json
-// @Security    BearerAuth
 // @Param       request body request.CreateUser true "Create user"
 // @Success     201 {object} entity.User
 // @Failure     400 {object} response.Error
-// @Failure     401 {object} response.Error
 // @Failure     500 {object} response.Error
 // @Router      /v1/user [post]
 func (r *V1) CreateUser(c *gin.Context) {
@@ -47,8 +49,11 @@ func (r *V1) CreateUser(c *gin.Context) {
 	)
 	if err != nil {
 		r.l.Error(err, "http - v1 - createUser")
-		errorResponse(c, http.StatusInternalServerError, "user service problems")
-		return
+		// The account exists; the verification mail can be requested again
+		if !errors.Is(err, useruc.ErrVerificationNotSent) {
+			errorResponse(c, http.StatusInternalServerError, "user service problems")
+			return
+		}
 	}
 
 	c.JSON(http.StatusCreated, user)
@@ -65,6 +70,7 @@ func (r *V1) CreateUser(c *gin.Context) {
 // @Success     200 {object} entity.User
 // @Failure     400 {object} response.Error
 // @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
 // @Failure     404 {object} response.Error
 // @Failure     500 {object} response.Error
 // @Router      /v1/user/{id} [get]
@@ -79,6 +85,10 @@ func (r *V1) GetUser(c *gin.Context) {
 	user, err := r.user.GetByID(c.Request.Context(), id)
 	if err != nil {
 		r.l.Error(err, "http - v1 - getUser")
+		if errors.Is(err, useruc.ErrUserNotFound) {
+			errorResponse(c, http.StatusNotFound, "user not found")
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "user service problems")
 		return
 	}
@@ -92,14 +102,24 @@ func (r *V1) GetUser(c *gin.Context) {
 }
 
 // @Summary     List users
-// @Description Get all users
+// @Description List users a page at a time. Pass next_cursor from the response as cursor, with the
+// @Description same q, sort, order and deleted, to get the next page. Deleted users are only listed
+// @Description with deleted=true.
 // @ID          list-users
 // @Tags  	    user
 // @Accept      json
 // @Produce     json
 // @Security    BearerAuth
+// @Param       q       query string false "Email or username prefix"
+// @Param       sort    query string false "Sort key" Enums(id, created_at, email, username)
+// @Param       order   query string false "Sort order" Enums(asc, desc)
+// @Param       limit   query int    false "Page size, 20 by default, at most 100"
+// @Param       cursor  query string false "next_cursor of the previous page"
+// @Param       deleted query bool   false "List soft deleted users instead"
 // @Success     200 {object} entity.UserHistory
+// @Failure     400 {object} response.Error
 // @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
 // @Failure     500 {object} response.Error
 // @Router      /v1/user [get]
 func (r *V1) ListUsers(c *gin.Context) {
@@ -122,10 +142,30 @@ func (r *V1) ListUsers(c *gin.Context) {
 	// 	}
 	// }
 
-	userHistory, err := r.user.List(c.Request.Context())
+	var query request.ListUsers
+	if err := c.ShouldBindQuery(&query); err != nil {
+		r.l.Error(err, "http - v1 - listUsers")
+		errorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	userHistory, err := r.user.List(c.Request.Context(), entity.UserQuery{
+		Search:  query.Search,
+		Sort:    query.Sort,
+		Desc:    query.Order == "desc",
+		Deleted: query.Deleted,
+		Limit:   query.Limit,
+	}, query.Cursor)
 	if err != nil {
 		r.l.Error(err, "http - v1 - listUsers")
-		errorResponse(c, http.StatusInternalServerError, "user service problems")
+		switch {
+		case errors.Is(err, useruc.ErrInvalidCursor):
+			errorResponse(c, http.StatusBadRequest, "invalid cursor")
+		case errors.Is(err, useruc.ErrInvalidUserQuery):
+			errorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		default:
+			errorResponse(c, http.StatusInternalServerError, "user service problems")
+		}
 		return
 	}
 
@@ -145,6 +185,7 @@ func (r *V1) ListUsers(c *gin.Context) {
 // @Success     200 {object} response.Success
 // @Failure     400 {object} response.Error
 // @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
 // @Failure     404 {object} response.Error
 // @Failure     500 {object} response.Error
 // @Router      /v1/user/{id} [put]
@@ -180,6 +221,10 @@ func (r *V1) UpdateUser(c *gin.Context) {
 	)
 	if err != nil {
 		r.l.Error(err, "http - v1 - updateUser")
+		if errors.Is(err, useruc.ErrUserNotFound) {
+			errorResponse(c, http.StatusNotFound, "user not found")
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "user service problems")
 		return
 	}
@@ -188,7 +233,8 @@ func (r *V1) UpdateUser(c *gin.Context) {
 }
 
 // @Summary     Delete user
-// @Description Delete user by ID
+// @Description Soft delete user by ID. The user can no longer log in and their tokens are revoked;
+// @Description payments and other records are kept. Undo with /v1/user/{id}/restore.
 // @ID          delete-user
 // @Tags  	    user
 // @Accept      json
@@ -198,6 +244,7 @@ func (r *V1) UpdateUser(c *gin.Context) {
 // @Success     200 {object} response.Success
 // @Failure     400 {object} response.Error
 // @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
 // @Failure     404 {object} response.Error
 // @Failure     500 {object} response.Error
 // @Router      /v1/user/{id} [delete]
@@ -212,6 +259,10 @@ func (r *V1) DeleteUser(c *gin.Context) {
 	err = r.user.Delete(c.Request.Context(), id)
 	if err != nil {
 		r.l.Error(err, "http - v1 - deleteUser")
+		if errors.Is(err, useruc.ErrUserNotFound) {
+			errorResponse(c, http.StatusNotFound, "user not found")
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "user service problems")
 		return
 	}
@@ -219,8 +270,44 @@ func (r *V1) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
 }
 
+// @Summary     Restore user
+// @Description Undo the soft delete of a user. The user can log in again with the old password.
+// @ID          restore-user
+// @Tags  	    user
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path int true "User ID"
+// @Success     200 {object} response.Success
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/user/{id}/restore [post]
+func (r *V1) RestoreUser(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		r.l.Error(err, "http - v1 - restoreUser")
+		errorResponse(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := r.user.Restore(c.Request.Context(), id); err != nil {
+		r.l.Error(err, "http - v1 - restoreUser")
+		if errors.Is(err, useruc.ErrUserNotFound) {
+			errorResponse(c, http.StatusNotFound, "deleted user not found")
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "user service problems")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user restored successfully"})
+}
+
 // @Summary     Login user
-// @Description Login user with email and password
+// @Description Login user with email and password. Users with two-factor authentication get a
+// @Description response.MFAChallengeResponse instead, to complete at /v1/auth/login/mfa.
 // @ID          login-user
 // @Tags  	    auth
 // @Accept      json
@@ -229,6 +316,8 @@ func (r *V1) DeleteUser(c *gin.Context) {
 // @Success     200 {object} response.LoginResponse
 // @Failure     400 {object} response.Error
 // @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     429 {object} response.Error
 // @Failure     500 {object} response.Error
 // @Router      /v1/auth/login [post]
 func (r *V1) LoginUser(c *gin.Context) {
@@ -245,27 +334,539 @@ func (r *V1) LoginUser(c *gin.Context) {
 		return
 	}
 
-	token, user, err := r.user.Login(c.Request.Context(), body.Email, body.Password)
+	tokens, user, err := r.user.Login(c.Request.Context(), body.Email, body.Password, c.ClientIP())
 	if err != nil {
 		r.l.Error(err, "http - v1 - loginUser")
-		if err.Error() == "UserUseCase - Login - bcrypt.CompareHashAndPassword: crypto/bcrypt: hashedPassword is not the hash of the given password" {
+		switch {
+		case errors.Is(err, useruc.ErrInvalidCredentials):
 			errorResponse(c, http.StatusUnauthorized, "invalid credentials")
+		case errors.Is(err, useruc.ErrEmailNotVerified):
+			errorResponse(c, http.StatusForbidden, "email address is not verified")
+		case errors.Is(err, useruc.ErrAccountLocked):
+			setRetryAfter(c, useruc.RetryAfter(err))
+			errorResponse(c, http.StatusTooManyRequests, "account is temporarily locked")
+		case errors.Is(err, useruc.ErrTooManyLoginAttempts):
+			setRetryAfter(c, useruc.RetryAfter(err))
+			errorResponse(c, http.StatusTooManyRequests, "too many failed login attempts")
+		default:
+			errorResponse(c, http.StatusInternalServerError, "user service problems")
+		}
+		return
+	}
+
+	if tokens.MFAToken != "" {
+		c.JSON(http.StatusOK, response.MFAChallengeResponse{
+			MFARequired:  true,
+			MFAToken:     tokens.MFAToken,
+			MFAExpiresAt: tokens.MFAExpiresAt,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(tokens, user))
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounded up.
+func setRetryAfter(c *gin.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10))
+}
+
+func loginResponse(tokens entity.AuthTokens, user entity.User) response.LoginResponse {
+	resp := response.LoginResponse{
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.AccessExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}
+	resp.User.ID = user.ID
+	resp.User.Email = user.Email
+	resp.User.Username = user.Username
+
+	return resp
+}
+
+// @Summary     Refresh token
+// @Description Exchange a refresh token for a new token pair. The refresh token is single use;
+// @Description presenting it again revokes every token issued from the same login.
+// @ID          refresh-token
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Param       request body request.RefreshToken true "Refresh token"
+// @Success     200 {object} response.TokenResponse
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/auth/refresh [post]
+func (r *V1) RefreshToken(c *gin.Context) {
+	var body request.RefreshToken
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, "http - v1 - refreshToken")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, "http - v1 - refreshToken")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tokens, err := r.user.Refresh(c.Request.Context(), body.RefreshToken)
+	if err != nil {
+		r.l.Error(err, "http - v1 - refreshToken")
+		switch {
+		case errors.Is(err, useruc.ErrInvalidRefreshToken), errors.Is(err, useruc.ErrRefreshTokenReused):
+			errorResponse(c, http.StatusUnauthorized, "invalid refresh token")
+		case errors.Is(err, useruc.ErrRefreshNotConfigured):
+			errorResponse(c, http.StatusNotImplemented, "refresh tokens are not enabled")
+		default:
+			errorResponse(c, http.StatusInternalServerError, "user service problems")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TokenResponse{
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.AccessExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	})
+}
+
+// @Summary     Logout
+// @Description Revoke the current access token and, when given, the refresh token's family
+// @ID          logout
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body request.Logout false "Logout"
+// @Success     204
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/auth/logout [post]
+func (r *V1) Logout(c *gin.Context) {
+	var body request.Logout
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			r.l.Error(err, "http - v1 - logout")
+			errorResponse(c, http.StatusBadRequest, "invalid request body")
 			return
 		}
+	}
+
+	err := r.user.Logout(c.Request.Context(), body.RefreshToken)
+	if err != nil {
+		r.l.Error(err, "http - v1 - logout")
+		switch {
+		case errors.Is(err, useruc.ErrInvalidRefreshToken):
+			errorResponse(c, http.StatusBadRequest, "invalid refresh token")
+		case errors.Is(err, useruc.ErrNotAuthenticated):
+			errorResponse(c, http.StatusUnauthorized, "authentication required")
+		default:
+			errorResponse(c, http.StatusInternalServerError, "user service problems")
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary     Forgot password
+// @Description Mail a single-use password reset link. Answers 202 whether or not the email belongs to an account.
+// @ID          forgot-password
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Param       request body request.ForgotPassword true "Forgot password"
+// @Success     202
+// @Failure     400 {object} response.Error
+// @Failure     429 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/password/forgot [post]
+func (r *V1) ForgotPassword(c *gin.Context) {
+	var body request.ForgotPassword
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, "http - v1 - forgotPassword")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, "http - v1 - forgotPassword")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.user.ForgotPassword(c.Request.Context(), body.Email, c.ClientIP()); err != nil {
+		r.l.Error(err, "http - v1 - forgotPassword")
+		accountErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// @Summary     Reset password
+// @Description Set a new password with the token from the reset link. Every session of the user is revoked.
+// @ID          reset-password
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Param       request body request.ResetPassword true "Reset password"
+// @Success     204
+// @Failure     400 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/password/reset [post]
+func (r *V1) ResetPassword(c *gin.Context) {
+	var body request.ResetPassword
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, "http - v1 - resetPassword")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, "http - v1 - resetPassword")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.user.ResetPassword(c.Request.Context(), body.Token, body.Password); err != nil {
+		r.l.Error(err, "http - v1 - resetPassword")
+		accountErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary     Verify email
+// @Description Confirm the email address with the token from the verification link
+// @ID          verify-email
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Param       request body request.VerifyEmail true "Verify email"
+// @Success     204
+// @Failure     400 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/email/verify [post]
+func (r *V1) VerifyEmail(c *gin.Context) {
+	var body request.VerifyEmail
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, "http - v1 - verifyEmail")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, "http - v1 - verifyEmail")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.user.VerifyEmail(c.Request.Context(), body.Token); err != nil {
+		r.l.Error(err, "http - v1 - verifyEmail")
+		accountErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary     Resend verification email
+// @Description Mail a new verification link. Answers 202 for unknown and already verified emails too.
+// @ID          resend-verification
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Param       request body request.ResendVerification true "Resend verification"
+// @Success     202
+// @Failure     400 {object} response.Error
+// @Failure     429 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/email/resend [post]
+func (r *V1) ResendVerification(c *gin.Context) {
+	var body request.ResendVerification
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, "http - v1 - resendVerification")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, "http - v1 - resendVerification")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := r.user.ResendVerification(c.Request.Context(), body.Email, c.ClientIP()); err != nil {
+		r.l.Error(err, "http - v1 - resendVerification")
+		accountErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func accountErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, useruc.ErrInvalidUserToken):
+		errorResponse(c, http.StatusBadRequest, "invalid or expired token")
+	case errors.Is(err, useruc.ErrMailNotConfigured):
+		errorResponse(c, http.StatusNotImplemented, "account emails are not enabled")
+	case errors.Is(err, useruc.ErrTooManyMailRequests):
+		setRetryAfter(c, useruc.RetryAfter(err))
+		errorResponse(c, http.StatusTooManyRequests, "too many email requests")
+	default:
 		errorResponse(c, http.StatusInternalServerError, "user service problems")
+	}
+}
+
+// @Summary     Complete MFA login
+// @Description Exchange the login MFA challenge and an authenticator or recovery code for the token pair.
+// @Description The challenge is single use; a wrong code means logging in again.
+// @ID          complete-mfa-login
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Param       request body request.CompleteMFALogin true "MFA challenge"
+// @Success     200 {object} response.LoginResponse
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     429 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/auth/login/mfa [post]
+func (r *V1) CompleteMFALogin(c *gin.Context) {
+	var body request.CompleteMFALogin
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, "http - v1 - completeMFALogin")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	c.JSON(http.StatusOK, response.LoginResponse{
-		Token: token,
-		User: struct {
-			ID       int64  `json:"id"`
-			Email    string `json:"email"`
-			Username string `json:"username"`
-		}{
-			ID:       user.ID,
-			Email:    user.Email,
-			Username: user.Username,
-		},
-	})
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, "http - v1 - completeMFALogin")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tokens, user, err := r.user.CompleteMFALogin(c.Request.Context(), body.MFAToken, body.Code, c.ClientIP())
+	if err != nil {
+		r.l.Error(err, "http - v1 - completeMFALogin")
+		switch {
+		case errors.Is(err, useruc.ErrInvalidMFAToken), errors.Is(err, useruc.ErrInvalidMFACode):
+			errorResponse(c, http.StatusUnauthorized, "invalid mfa token or code")
+		case errors.Is(err, useruc.ErrAccountLocked):
+			setRetryAfter(c, useruc.RetryAfter(err))
+			errorResponse(c, http.StatusTooManyRequests, "account is temporarily locked")
+		case errors.Is(err, useruc.ErrTooManyLoginAttempts):
+			setRetryAfter(c, useruc.RetryAfter(err))
+			errorResponse(c, http.StatusTooManyRequests, "too many failed login attempts")
+		default:
+			mfaErrorResponse(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(tokens, user))
+}
+
+// @Summary     Enroll TOTP
+// @Description Generate an authenticator app secret for the caller. Two-factor authentication is
+// @Description only enabled once a code is confirmed.
+// @ID          enroll-totp
+// @Tags  	    auth
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} entity.TOTPEnrollment
+// @Failure     401 {object} response.Error
+// @Failure     409 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/mfa/totp/enroll [post]
+func (r *V1) EnrollTOTP(c *gin.Context) {
+	enrollment, err := r.user.EnrollTOTP(c.Request.Context())
+	if err != nil {
+		r.l.Error(err, "http - v1 - enrollTOTP")
+		mfaErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary     Confirm TOTP
+// @Description Enable two-factor authentication with a code from the enrolled app. The recovery codes are only shown once.
+// @ID          confirm-totp
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body request.MFACode true "Authenticator code"
+// @Success     200 {object} response.RecoveryCodes
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     409 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/mfa/totp/confirm [post]
+func (r *V1) ConfirmTOTP(c *gin.Context) {
+	body, ok := r.bindMFACode(c, "http - v1 - confirmTOTP")
+	if !ok {
+		return
+	}
+
+	codes, err := r.user.ConfirmTOTP(c.Request.Context(), body.Code)
+	if err != nil {
+		r.l.Error(err, "http - v1 - confirmTOTP")
+		mfaErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// @Summary     Disable TOTP
+// @Description Turn two-factor authentication off. Takes an authenticator or recovery code.
+// @ID          disable-totp
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body request.MFACode true "Authenticator or recovery code"
+// @Success     204
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     409 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/mfa/totp/disable [post]
+func (r *V1) DisableTOTP(c *gin.Context) {
+	body, ok := r.bindMFACode(c, "http - v1 - disableTOTP")
+	if !ok {
+		return
+	}
+
+	if err := r.user.DisableTOTP(c.Request.Context(), body.Code); err != nil {
+		r.l.Error(err, "http - v1 - disableTOTP")
+		mfaErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary     Regenerate recovery codes
+// @Description Replace every recovery code of the caller. The new codes are only shown once.
+// @ID          regenerate-recovery-codes
+// @Tags  	    auth
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body request.MFACode true "Authenticator code"
+// @Success     200 {object} response.RecoveryCodes
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     409 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/auth/mfa/recovery-codes [post]
+func (r *V1) RegenerateRecoveryCodes(c *gin.Context) {
+	body, ok := r.bindMFACode(c, "http - v1 - regenerateRecoveryCodes")
+	if !ok {
+		return
+	}
+
+	codes, err := r.user.RegenerateRecoveryCodes(c.Request.Context(), body.Code)
+	if err != nil {
+		r.l.Error(err, "http - v1 - regenerateRecoveryCodes")
+		mfaErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.RecoveryCodes{RecoveryCodes: codes})
+}
+
+func (r *V1) bindMFACode(c *gin.Context, op string) (request.MFACode, bool) {
+	var body request.MFACode
+	if err := c.ShouldBindJSON(&body); err != nil {
+		r.l.Error(err, op)
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return body, false
+	}
+
+	if err := r.v.Struct(body); err != nil {
+		r.l.Error(err, op)
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return body, false
+	}
+
+	return body, true
+}
+
+func mfaErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, useruc.ErrNotAuthenticated):
+		errorResponse(c, http.StatusUnauthorized, "authentication required")
+	case errors.Is(err, useruc.ErrInvalidMFACode):
+		errorResponse(c, http.StatusBadRequest, "invalid code")
+	case errors.Is(err, useruc.ErrMFAAlreadyEnabled):
+		errorResponse(c, http.StatusConflict, "two-factor authentication is already enabled")
+	case errors.Is(err, useruc.ErrMFANotEnrolled):
+		errorResponse(c, http.StatusConflict, "two-factor authentication is not enrolled")
+	case errors.Is(err, useruc.ErrMFANotConfigured):
+		errorResponse(c, http.StatusNotImplemented, "two-factor authentication is not enabled")
+	default:
+		errorResponse(c, http.StatusInternalServerError, "user service problems")
+	}
+}
+
+// @Summary     Unlock user
+// @Description Lift the login lockout of a user's account and forget its failed logins
+// @ID          unlock-user
+// @Tags  	    user
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path int true "User ID"
+// @Success     204
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     403 {object} response.Error
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Failure     501 {object} response.Error
+// @Router      /v1/user/{id}/unlock [post]
+func (r *V1) UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		r.l.Error(err, "http - v1 - unlockUser")
+		errorResponse(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := r.user.UnlockUser(c.Request.Context(), id); err != nil {
+		r.l.Error(err, "http - v1 - unlockUser")
+		switch {
+		case errors.Is(err, useruc.ErrUserNotFound):
+			errorResponse(c, http.StatusNotFound, "user not found")
+		case errors.Is(err, useruc.ErrLockoutNotConfigured):
+			errorResponse(c, http.StatusNotImplemented, "login protection is not enabled")
+		default:
+			errorResponse(c, http.StatusInternalServerError, "user service problems")
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
@@ -1,12 +1,17 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/request"
+	"github.com/ducnpdev/godev-kit/internal/controller/http/v1/response"
 	"github.com/ducnpdev/godev-kit/internal/entity"
+	vietqrrepo "github.com/ducnpdev/godev-kit/internal/repo/externalapi/vietqr"
+	vietqruc "github.com/ducnpdev/godev-kit/internal/usecase/vietqr"
 )
 
 // @Summary     Generate QR Code
@@ -16,7 +21,9 @@ import (
 // @Accept      json
 // @Produce     json
 // @Security    BearerAuth
+// @Param       request body request.GenerateQR true "Generate request"
 // @Success     200 {object} entity.VietQR
+// @Failure     400 {object} response.Error
 // @Failure     500 {object} response.Error
 // @Router      /v1/vietqr/gen [post]
 func (v1 *V1) generateQR(c *gin.Context) {
@@ -28,14 +35,20 @@ func (v1 *V1) generateQR(c *gin.Context) {
 	}
 
 	qr, err := v1.vietqr.GenerateQR(c.Request.Context(), entity.VietQRGenerateRequest{
+		BankCode:     req.BankCode,
 		AccountNo:    req.AccountNo,
 		Amount:       req.Amount,
 		Description:  req.Description,
 		MCC:          req.MCC,
 		ReceiverName: req.ReceiverName,
+		TTL:          time.Duration(req.TTLSeconds) * time.Second,
 	})
 	if err != nil {
 		v1.l.Error(err, "http - v1 - generateQR - v1.vietqr.GenerateQR")
+		if errors.Is(err, vietqruc.ErrUnknownBank) {
+			errorResponse(c, http.StatusBadRequest, "unknown bank code")
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "internal server error")
 		return
 	}
@@ -43,7 +56,7 @@ func (v1 *V1) generateQR(c *gin.Context) {
 }
 
 // @Summary     Inquiry QR Status
-// @Description Get the status of a VietQR code
+// @Description Get the status and remaining validity of a VietQR code
 // @ID          inquiry-qr
 // @Tags  	    vietqr
 // @Accept      json
@@ -76,6 +89,7 @@ func (v1 *V1) inquiryQR(c *gin.Context) {
 // @Param       request body request.UpdateVietQRStatus true "Update status"
 // @Success     200 {object} response.Success
 // @Failure     400 {object} response.Error
+// @Failure     404 {object} response.Error
 // @Failure     500 {object} response.Error
 // @Router      /v1/vietqr/update/{id} [put]
 func (v1 *V1) updateStatus(c *gin.Context) {
@@ -99,9 +113,288 @@ func (v1 *V1) updateStatus(c *gin.Context) {
 	err := v1.vietqr.UpdateStatus(c.Request.Context(), id, status)
 	if err != nil {
 		v1.l.Error(err, "http - v1 - updateStatus - v1.vietqr.UpdateStatus")
+		if errors.Is(err, vietqruc.ErrQRNotFound) {
+			errorResponse(c, http.StatusNotFound, "qr not found")
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
+
+// sseHeartbeat keeps idle event streams alive through proxies that drop silent connections.
+const sseHeartbeat = 15 * time.Second
+
+// @Summary     Stream QR Events
+// @Description Server-sent events for a VietQR code. The first "status" event carries the current state;
+// @Description each change follows as a "vietqr.status_changed" event. The stream ends once the code is paid, failed or timed out.
+// @ID          stream-qr-events
+// @Tags  	    vietqr
+// @Produce     text/event-stream
+// @Security    BearerAuth
+// @Param       id path string true "QR ID"
+// @Success     200 {object} entity.VietQRStatusChangedEvent
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/vietqr/{id}/events [get]
+func (v1 *V1) streamQREvents(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+
+	events, cancel, err := v1.vietqr.WatchStatus(ctx, id)
+	if err != nil {
+		v1.l.Error(err, "http - v1 - streamQREvents - v1.vietqr.WatchStatus")
+		if errors.Is(err, vietqruc.ErrQRNotFound) {
+			errorResponse(c, http.StatusNotFound, "qr not found")
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	defer cancel()
+
+	// Read the snapshot after subscribing so no change falls in between
+	qr, err := v1.vietqr.InquiryQR(ctx, id)
+	if err != nil {
+		v1.l.Error(err, "http - v1 - streamQREvents - v1.vietqr.InquiryQR")
+		errorResponse(c, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	// The server write timeout is meant for regular responses, not streams
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		v1.l.Warn("http - v1 - streamQREvents - SetWriteDeadline: %v", err)
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("status", qr)
+	c.Writer.Flush()
+	if !qr.Status.IsOpen() {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+			if !event.NewStatus.IsOpen() {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// @Summary     Render QR Image
+// @Description Render a stored VietQR code as a PNG or SVG image
+// @ID          render-qr
+// @Tags  	    vietqr
+// @Produce     image/png
+// @Produce     image/svg+xml
+// @Security    BearerAuth
+// @Param       id path string true "QR ID"
+// @Param       format query string false "Image format" Enums(png, svg) default(png)
+// @Param       size query int false "Width and height in pixels"
+// @Param       level query string false "Error-correction level" Enums(L, M, Q, H)
+// @Param       logo query string false "Logo overlay name, e.g. napas"
+// @Success     200 {file} binary
+// @Success     304
+// @Failure     400 {object} response.Error
+// @Failure     404 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/vietqr/{id}/image [get]
+func (v1 *V1) renderQR(c *gin.Context) {
+	var req request.VietQRImage
+	if err := c.ShouldBindQuery(&req); err != nil {
+		v1.l.Error(err, "http - v1 - renderQR - c.ShouldBindQuery")
+		errorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	img, err := v1.vietqr.RenderQR(c.Request.Context(), entity.VietQRImageRequest{
+		ID:     c.Param("id"),
+		Format: req.Format,
+		Size:   req.Size,
+		Level:  req.Level,
+		Logo:   req.Logo,
+	})
+	if err != nil {
+		v1.l.Error(err, "http - v1 - renderQR - v1.vietqr.RenderQR")
+		switch {
+		case errors.Is(err, vietqruc.ErrInvalidImageRequest):
+			errorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, vietqruc.ErrQRNotFound):
+			errorResponse(c, http.StatusNotFound, "qr not found")
+		default:
+			errorResponse(c, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	c.Header("ETag", img.ETag)
+	c.Header("Cache-Control", "private, max-age=300")
+	if c.GetHeader("If-None-Match") == img.ETag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, img.ContentType, img.Data)
+}
+
+// @Summary     Decode QR Code
+// @Description Parse and validate an EMVCo VietQR string, including its CRC16
+// @ID          decode-qr
+// @Tags  	    vietqr
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body request.DecodeQR true "VietQR string"
+// @Success     200 {object} entity.VietQRPayload
+// @Failure     400 {object} response.Error
+// @Failure     422 {object} response.VietQRDecodeError
+// @Router      /v1/vietqr/decode [post]
+func (v1 *V1) decodeQR(c *gin.Context) {
+	var req request.DecodeQR
+	if err := c.ShouldBindJSON(&req); err != nil {
+		v1.l.Error(err, "http - v1 - decodeQR - c.ShouldBindJSON")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	payload, err := v1.vietqr.DecodeQR(c.Request.Context(), req.Content)
+	if err != nil {
+		var decodeErr *vietqrrepo.DecodeError
+		if errors.As(err, &decodeErr) {
+			c.JSON(http.StatusUnprocessableEntity, response.VietQRDecodeError{
+				Error:  decodeErr.Err.Error(),
+				Tag:    decodeErr.Tag,
+				Offset: decodeErr.Offset,
+				Detail: decodeErr.Detail,
+			})
+			return
+		}
+		v1.l.Error(err, "http - v1 - decodeQR - v1.vietqr.DecodeQR")
+		errorResponse(c, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	c.JSON(http.StatusOK, payload)
+}
+
+// @Summary     Bank Notification
+// @Description Receive a bank balance-change webhook. Credits carrying the reference of an open code with the same account and amount mark it paid; other credits are stored for manual review.
+// @ID          bank-notification
+// @Tags  	    vietqr
+// @Accept      json
+// @Produce     json
+// @Param       X-Signature header string true "Hex HMAC-SHA256 of '<X-Timestamp>.<body>'"
+// @Param       X-Timestamp header string true "Unix seconds"
+// @Param       request body request.BankNotification true "Bank transaction"
+// @Success     200 {object} entity.BankTransaction
+// @Failure     400 {object} response.Error
+// @Failure     401 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/vietqr/notifications/bank [post]
+func (v1 *V1) ingestBankNotification(c *gin.Context) {
+	var req request.BankNotification
+	if err := c.ShouldBindJSON(&req); err != nil {
+		v1.l.Error(err, "http - v1 - ingestBankNotification - c.ShouldBindJSON")
+		errorResponse(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if _, err := req.Amount.Float64(); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid amount")
+		return
+	}
+
+	txn, err := v1.bankNotification.Ingest(c.Request.Context(), entity.BankTransaction{
+		BankTransactionID: req.TransactionID,
+		AccountNo:         req.AccountNo,
+		Amount:            req.Amount.String(),
+		Description:       req.Description,
+		Direction:         entity.BankTransactionDirection(req.Type),
+		TransactedAt:      req.TransactedAt,
+	})
+	if err != nil {
+		v1.l.Error(err, "http - v1 - ingestBankNotification - v1.bankNotification.Ingest")
+		errorResponse(c, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	c.JSON(http.StatusOK, txn)
+}
+
+// @Summary     List Unmatched Credits
+// @Description List bank credits that did not match an open VietQR code, newest first
+// @ID          list-unmatched-transactions
+// @Tags  	    vietqr
+// @Produce     json
+// @Security    BearerAuth
+// @Param       limit query int false "Maximum rows, up to 100"
+// @Success     200 {array} entity.BankTransaction
+// @Failure     400 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/vietqr/notifications/unmatched [get]
+func (v1 *V1) listUnmatchedTransactions(c *gin.Context) {
+	var req request.ListUnmatchedTransactions
+	if err := c.ShouldBindQuery(&req); err != nil {
+		v1.l.Error(err, "http - v1 - listUnmatchedTransactions - c.ShouldBindQuery")
+		errorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	txns, err := v1.bankNotification.ListUnmatched(c.Request.Context(), req.Limit)
+	if err != nil {
+		v1.l.Error(err, "http - v1 - listUnmatchedTransactions - v1.bankNotification.ListUnmatched")
+		errorResponse(c, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	c.JSON(http.StatusOK, txns)
+}
+
+// @Summary     List Banks
+// @Description Search the NAPAS bank directory by code, name or BIN prefix
+// @ID          list-banks
+// @Tags  	    vietqr
+// @Produce     json
+// @Param       q query string false "Search text"
+// @Success     200 {array} entity.Bank
+// @Failure     400 {object} response.Error
+// @Failure     500 {object} response.Error
+// @Router      /v1/vietqr/banks [get]
+func (v1 *V1) listBanks(c *gin.Context) {
+	var req request.ListBanks
+	if err := c.ShouldBindQuery(&req); err != nil {
+		v1.l.Error(err, "http - v1 - listBanks - c.ShouldBindQuery")
+		errorResponse(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	banks, err := v1.vietqr.ListBanks(c.Request.Context(), req.Query)
+	if err != nil {
+		v1.l.Error(err, "http - v1 - listBanks - v1.vietqr.ListBanks")
+		errorResponse(c, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, banks)
+}
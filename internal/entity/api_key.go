@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to spot in logs and by secret scanners.
+const APIKeyPrefix = "gdk_"
+
+// APIKey lets a machine client act as a user, limited to Scopes. Only the
+// SHA-256 of the key is stored; Prefix, the first part of the key, finds it.
+type APIKey struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	// KeyHash is the hex SHA-256 of the whole key.
+	KeyHash string `json:"-"`
+	// UserID is the user the key acts as.
+	UserID int64 `json:"user_id"`
+	// Scopes are the permissions the key may use, out of those of the user.
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  int64      `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active reports whether the key is neither revoked nor expired at now.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// CreatedAPIKey is a new API key with its secret, which is never shown again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+// AuthTokens is the token pair returned by login and refresh. When the user
+// has two-factor authentication enabled, login returns only an MFA challenge
+// token, which is exchanged for the pair together with a code.
+type AuthTokens struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at,omitempty"`
+	MFAToken         string    `json:"mfa_token,omitempty"`
+	MFAExpiresAt     time.Time `json:"mfa_expires_at,omitempty"`
+}
+
+// RefreshToken is a stored refresh token. Only the SHA-256 of the token is kept.
+// Every rotation adds a row to the same family, so reusing a rotated token can
+// revoke everything issued since the original login.
+type RefreshToken struct {
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"user_id"`
+	FamilyID        string     `json:"family_id"`
+	TokenHash       string     `json:"-"`
+	AccessJTI       string     `json:"access_jti"`
+	AccessExpiresAt time.Time  `json:"access_expires_at"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	RotatedAt       *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package entity
+
+// Bank is a NAPAS member that can receive VietQR transfers.
+type Bank struct {
+	// Code is the short identifier clients send, e.g. VCB.
+	Code string `json:"code"`
+	// BIN is the 6-digit NAPAS acquirer ID written into tag 38.01.00.
+	BIN       string `json:"bin"`
+	ShortName string `json:"short_name"`
+	Name      string `json:"name"`
+	// SWIFTCode is empty for banks without one, such as digital-only brands.
+	SWIFTCode string `json:"swift_code"`
+	Logo      string `json:"logo"`
+}
@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// BankTransactionDirection tells whether money entered or left the account.
+type BankTransactionDirection string
+
+const (
+	BankTransactionCredit BankTransactionDirection = "credit"
+	BankTransactionDebit  BankTransactionDirection = "debit"
+)
+
+// BankTransactionStatus is the reconciliation outcome of a bank notification.
+type BankTransactionStatus string
+
+const (
+	// BankTransactionMatched means the credit paid an open VietQR code.
+	BankTransactionMatched BankTransactionStatus = "matched"
+	// BankTransactionUnmatched means no open code fits and the credit needs manual review.
+	BankTransactionUnmatched BankTransactionStatus = "unmatched"
+	// BankTransactionIgnored means the notification was not a credit and was not stored.
+	BankTransactionIgnored BankTransactionStatus = "ignored"
+)
+
+// BankTransaction is a balance-change notification received from a bank.
+type BankTransaction struct {
+	ID int64 `json:"id"`
+	// BankTransactionID is the bank's own reference and makes notifications idempotent.
+	BankTransactionID string                   `json:"bank_transaction_id"`
+	AccountNo         string                   `json:"account_no"`
+	Amount            string                   `json:"amount"`
+	Description       string                   `json:"description"`
+	Direction         BankTransactionDirection `json:"direction"`
+	TransactedAt      time.Time                `json:"transacted_at"`
+	Status            BankTransactionStatus    `json:"status"`
+	// VietQRID is the code this credit paid, empty when unmatched.
+	VietQRID  string    `json:"vietqr_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -31,6 +31,9 @@ const (
 	UserCreatedEvent          = "user.created"
 	UserUpdatedEvent          = "user.updated"
 	UserDeletedEvent          = "user.deleted"
+	UserRestoredEvent         = "user.restored"
+	UserLoginFailedEvent      = "user.login_failed"
+	UserLockedEvent           = "user.locked"
 	TranslationRequestEvent   = "translation.requested"
 	TranslationCompletedEvent = "translation.completed"
 )
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// TOTP is a user's authenticator app enrollment. Secret is sealed with the
+// MFA encryption key; EnabledAt is nil until the first code was verified.
+type TOTP struct {
+	UserID    int64      `json:"user_id"`
+	Secret    string     `json:"-"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty"`
+	// LastStep is the time step of the last accepted code, so a code works once
+	LastStep int64 `json:"-"`
+}
+
+// Enabled reports whether logins require a code.
+func (t TOTP) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TOTPEnrollment is shown once when a user starts enrolling an authenticator app.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	// QRCode is a PNG of URI
+	QRCode []byte `json:"qr_code_png"`
+}
@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// UserIdentity links a user to an account at an OpenID Connect provider,
+// identified by the provider's issuer and the subject it gave the user.
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCLoginState is kept between the redirect to the provider and the
+// callback, under the state parameter sent along.
+type OIDCLoginState struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// OIDCLoginRedirect starts a login at the provider. State must come back from
+// the same browser with the callback, before ExpiresAt.
+type OIDCLoginRedirect struct {
+	URL       string
+	State     string
+	ExpiresAt time.Time
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is a message written in the transaction of the change it
+// reports and sent to Kafka afterwards by the outbox relay.
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+	// Headers are sent along as Kafka headers.
+	Headers     map[string]string `json:"headers,omitempty"`
+	Attempts    int               `json:"attempts"`
+	LastError   string            `json:"last_error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	PublishedAt *time.Time        `json:"published_at,omitempty"`
+}
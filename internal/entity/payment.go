@@ -13,6 +13,7 @@ const (
 	PaymentStatusCompleted  PaymentStatus = "completed"
 	PaymentStatusFailed     PaymentStatus = "failed"
 	PaymentStatusCancelled  PaymentStatus = "cancelled"
+	PaymentStatusRefunded   PaymentStatus = "refunded"
 )
 
 // PaymentType represents payment type
@@ -39,8 +40,14 @@ type Payment struct {
 	PaymentMethod string        `json:"payment_method"`
 	CreatedAt     time.Time     `json:"created_at"`
 	UpdatedAt     time.Time     `json:"updated_at"`
+	// VietQRID links a payment made by VietQR to its code.
+	VietQRID     string       `json:"vietqr_id,omitempty"`
+	VietQRStatus VietQRStatus `json:"vietqr_status,omitempty"`
 }
 
+// PaymentMethodVietQR is paid by scanning a generated VietQR code instead of being processed by the gateway.
+const PaymentMethodVietQR = "vietqr"
+
 // PaymentEvent represents payment event for Kafka
 type PaymentEvent struct {
 	ID            int64         `json:"id"`
@@ -85,6 +92,10 @@ type PaymentResponse struct {
 	TransactionID string        `json:"transaction_id"`
 	PaymentMethod string        `json:"payment_method"`
 	CreatedAt     time.Time     `json:"created_at"`
+	VietQRID      string        `json:"vietqr_id,omitempty"`
+	VietQRStatus  VietQRStatus  `json:"vietqr_status,omitempty"`
+	// VietQR is the generated code, only returned when the payment is registered.
+	VietQR *VietQR `json:"vietqr,omitempty"`
 }
 
 // Event types for payment
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID      int64    `json:"user_id"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+
+	// TokenID and TokenExpiresAt identify the access token the principal came from.
+	TokenID        string    `json:"-"`
+	TokenExpiresAt time.Time `json:"-"`
+	// APIKeyID is set instead when the caller authenticated with an API key.
+	APIKeyID int64 `json:"api_key_id,omitempty"`
+}
+
+// HasRole reports whether the principal holds role.
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// HasPermission reports whether any of the principal's roles grants permission.
+func (p Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+// IsAdmin reports whether the principal has the admin role.
+func (p Principal) IsAdmin() bool {
+	return p.HasRole(RoleAdmin)
+}
+
+// CanActFor reports whether the principal may act on resources owned by userID.
+// The zero Principal can act for nobody.
+func (p Principal) CanActFor(userID int64) bool {
+	return p.IsAdmin() || (p.UserID != 0 && p.UserID == userID)
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying p.
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored in ctx, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
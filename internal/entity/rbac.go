@@ -0,0 +1,30 @@
+package entity
+
+// Built-in roles seeded by the RBAC migration.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permissions checked by the HTTP layer. They are seeded by the migrations
+// and granted to roles through role_permissions.
+const (
+	PermissionUsersRead      = "users:read"
+	PermissionUsersDelete    = "users:delete"
+	PermissionUsersUnlock    = "users:unlock"
+	PermissionRolesManage    = "roles:manage"
+	PermissionPaymentsRefund = "payments:refund"
+	PermissionKafkaManage    = "kafka:manage"
+	PermissionVietQRUpdate   = "vietqr:update"
+	PermissionVietQRReview   = "vietqr:review"
+	PermissionDebugGC        = "debug:gc"
+	PermissionAPIKeysManage  = "api_keys:manage"
+)
+
+// Role is a named set of permissions.
+type Role struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
@@ -4,15 +4,43 @@ import "time"
 
 // User represents user entity
 type User struct {
-	ID        int64     `json:"id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // Password is not exposed in JSON
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID       int64    `json:"id"`
+	Email    string   `json:"email"`
+	Username string   `json:"username"`
+	Password string   `json:"-"` // Password is not exposed in JSON
+	Roles    []string `json:"roles,omitempty"`
+	// EmailVerifiedAt is nil until the user confirms the address
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	// DeletedAt is set while the user is soft deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // UserHistory represents user history entity
 type UserHistory struct {
 	Users []User `json:"users"`
-} 
\ No newline at end of file
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// User list sort keys.
+const (
+	UserSortID        = "id"
+	UserSortCreatedAt = "created_at"
+	UserSortEmail     = "email"
+	UserSortUsername  = "username"
+)
+
+// UserQuery selects a page of users, ordered by Sort and then by ID.
+type UserQuery struct {
+	// Search matches a prefix of the email or the username, ignoring case
+	Search string
+	Sort   string
+	Desc   bool
+	// Deleted lists soft deleted users instead of active ones
+	Deleted bool
+	Limit   uint64
+	// AfterID is the last user of the previous page; 0 starts at the first page
+	AfterID int64
+}
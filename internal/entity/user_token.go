@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// User token purposes.
+const (
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenEmailVerification = "email_verification"
+)
+
+// UserToken is a single-use token mailed to a user, e.g. to reset the password.
+// Only the SHA-256 of the token is stored.
+type UserToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 // VietQRStatus represents the status of a VietQR code.
 type VietQRStatus string
 
@@ -11,18 +13,121 @@ const (
 	VietQRStatusTimeout   VietQRStatus = "timeout"
 )
 
+// IsOpen reports whether a code with this status can still be paid.
+func (s VietQRStatus) IsOpen() bool {
+	return s == VietQRStatusGenerated || s == VietQRStatusInProcess
+}
+
 // VietQR represents the vietqr entity.
 type VietQR struct {
-	ID      string       `json:"id"`
-	Status  VietQRStatus `json:"status"`
-	Content string       `json:"content"`
+	ID          string       `json:"id"`
+	Status      VietQRStatus `json:"status"`
+	Content     string       `json:"content"`
+	BankCode    string       `json:"bank_code"`
+	BankBIN     string       `json:"bank_bin"`
+	AccountNo   string       `json:"account_no"`
+	Amount      string       `json:"amount"`
+	Description string       `json:"description"`
+	// Reference is embedded in the transfer description so bank notifications can be matched back to the code.
+	Reference string    `json:"reference"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is zero for codes that never expire.
+	ExpiresAt time.Time `json:"expires_at"`
+	// RemainingSeconds is computed on inquiry and is not persisted.
+	RemainingSeconds int64 `json:"remaining_seconds"`
+	// PaymentID and PaymentStatus are set when the code pays a utility payment.
+	PaymentID     int64         `json:"payment_id,omitempty"`
+	PaymentStatus PaymentStatus `json:"payment_status,omitempty"`
+}
+
+// IsExpired reports whether the code has passed its expiry time at now.
+func (qr VietQR) IsExpired(now time.Time) bool {
+	return !qr.ExpiresAt.IsZero() && !now.Before(qr.ExpiresAt)
 }
 
 // VietQRGenerateRequest represents the data needed to generate a VietQR code.
 type VietQRGenerateRequest struct {
+	// BankCode is a bank code or BIN from the bank directory.
+	BankCode string
+	// BankBIN is resolved from BankCode by the use case.
+	BankBIN      string
 	AccountNo    string
 	Amount       string
 	Description  string
 	MCC          string
 	ReceiverName string
+	// TTL is how long the code stays payable. Zero falls back to the use case default.
+	TTL time.Duration
+}
+
+// VietQRImageRequest describes how a stored code should be rendered.
+type VietQRImageRequest struct {
+	ID string
+	// Format is png or svg.
+	Format string
+	// Size is the width and height in pixels; zero uses the renderer default.
+	Size int
+	// Level is the error-correction level (L, M, Q, H); empty uses the configured default.
+	Level string
+	// Logo names an overlay image such as a bank short name; empty renders no logo.
+	Logo string
+}
+
+// VietQRImage is a rendered VietQR code.
+type VietQRImage struct {
+	ContentType string
+	ETag        string
+	Data        []byte
+}
+
+// VietQRMerchantAccount is the merchant account information template (tag 38) of a VietQR payload.
+type VietQRMerchantAccount struct {
+	GUID        string `json:"guid"`
+	BankBIN     string `json:"bank_bin"`
+	AccountNo   string `json:"account_no"`
+	ServiceCode string `json:"service_code"`
+}
+
+// VietQRAdditionalData is the additional data field template (tag 62) of a VietQR payload.
+type VietQRAdditionalData struct {
+	BillNumber     string `json:"bill_number,omitempty"`
+	MobileNumber   string `json:"mobile_number,omitempty"`
+	StoreLabel     string `json:"store_label,omitempty"`
+	LoyaltyNumber  string `json:"loyalty_number,omitempty"`
+	ReferenceLabel string `json:"reference_label,omitempty"`
+	CustomerLabel  string `json:"customer_label,omitempty"`
+	TerminalLabel  string `json:"terminal_label,omitempty"`
+	Purpose        string `json:"purpose,omitempty"`
+}
+
+// VietQRPayload is a decoded EMVCo VietQR string.
+type VietQRPayload struct {
+	PayloadFormat    string                `json:"payload_format"`
+	InitiationMethod string                `json:"initiation_method"`
+	MerchantAccount  VietQRMerchantAccount `json:"merchant_account"`
+	MCC              string                `json:"mcc,omitempty"`
+	Currency         string                `json:"currency"`
+	Amount           string                `json:"amount,omitempty"`
+	CountryCode      string                `json:"country_code"`
+	MerchantName     string                `json:"merchant_name,omitempty"`
+	MerchantCity     string                `json:"merchant_city,omitempty"`
+	AdditionalData   VietQRAdditionalData  `json:"additional_data"`
+	CRC              string                `json:"crc"`
+}
+
+// VietQREventStatusChanged is the event type, Kafka topic and NATS subject prefix for status changes.
+const VietQREventStatusChanged = "vietqr.status_changed"
+
+// VietQRStatusChangedEvent is published whenever a code moves to a different status.
+type VietQRStatusChangedEvent struct {
+	EventID    string       `json:"event_id"`
+	Type       string       `json:"type"`
+	QRID       string       `json:"qr_id"`
+	OldStatus  VietQRStatus `json:"old_status"`
+	NewStatus  VietQRStatus `json:"new_status"`
+	BankCode   string       `json:"bank_code"`
+	AccountNo  string       `json:"account_no"`
+	Amount     string       `json:"amount"`
+	Reference  string       `json:"reference"`
+	OccurredAt time.Time    `json:"occurred_at"`
 }
@@ -3,10 +3,12 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/godev-kit/internal/repo/persistent/models"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -30,13 +32,138 @@ type (
 		GetByID(context.Context, int64) (entity.User, error)
 		GetByEmail(context.Context, string) (entity.User, error)
 		Update(context.Context, models.UserModel) error
-		Delete(context.Context, int64) error
-		List(context.Context) ([]entity.User, error)
+		// MarkEmailVerified records that the user confirmed their email address.
+		MarkEmailVerified(ctx context.Context, id int64) error
+		// Delete soft deletes the user and reports whether it was active. GetByID,
+		// GetByEmail and Update ignore deleted users.
+		Delete(ctx context.Context, id int64) (bool, error)
+		// Restore undoes a soft delete and reports whether the user was deleted.
+		Restore(ctx context.Context, id int64) (bool, error)
+		List(context.Context, entity.UserQuery) ([]entity.User, error)
 		// Database access methods
 		GetBuilder() squirrel.StatementBuilderType
 		GetPool() *pgxpool.Pool
 	}
 
+	// RoleRepo stores roles, their permissions and role assignments.
+	RoleRepo interface {
+		List(context.Context) ([]entity.Role, error)
+		// UserAccess returns the user's roles and the union of their permissions.
+		UserAccess(ctx context.Context, userID int64) (roles, permissions []string, err error)
+		// Assign grants role to the user; an unknown role yields pgx.ErrNoRows.
+		Assign(ctx context.Context, userID int64, role string) error
+		// Revoke removes role from the user and reports whether it was held.
+		Revoke(ctx context.Context, userID int64, role string) (bool, error)
+	}
+
+	// RefreshTokenRepo stores hashed refresh tokens.
+	RefreshTokenRepo interface {
+		Create(context.Context, entity.RefreshToken) (entity.RefreshToken, error)
+		GetByHash(ctx context.Context, tokenHash string) (entity.RefreshToken, error)
+		// Rotate marks an active token as used; false means it was already rotated or revoked.
+		Rotate(ctx context.Context, id int64) (bool, error)
+		// RevokeFamily revokes every active token of the family and returns them.
+		RevokeFamily(ctx context.Context, familyID string) ([]entity.RefreshToken, error)
+		// RevokeUser revokes every active token of the user and returns them.
+		RevokeUser(ctx context.Context, userID int64) ([]entity.RefreshToken, error)
+	}
+
+	// UserTokenRepo stores hashed single-use tokens mailed to users.
+	UserTokenRepo interface {
+		Create(context.Context, entity.UserToken) (entity.UserToken, error)
+		// Consume marks an unused, unexpired token as used and returns it; any
+		// other token yields pgx.ErrNoRows.
+		Consume(ctx context.Context, purpose, tokenHash string) (entity.UserToken, error)
+		// Invalidate marks the user's outstanding tokens for purpose as used.
+		Invalidate(ctx context.Context, userID int64, purpose string) error
+	}
+
+	// MFARepo stores TOTP enrollments in the users table and recovery codes.
+	MFARepo interface {
+		// GetTOTP returns the user's enrollment; Secret is empty if there is none.
+		GetTOTP(ctx context.Context, userID int64) (entity.TOTP, error)
+		// SetTOTPSecret starts a new, not yet enabled enrollment.
+		SetTOTPSecret(ctx context.Context, userID int64, sealedSecret string) error
+		EnableTOTP(ctx context.Context, userID int64) error
+		// DisableTOTP removes the enrollment and the recovery codes.
+		DisableTOTP(ctx context.Context, userID int64) error
+		// UseTOTPStep records step as used; false means it or a later step was used already.
+		UseTOTPStep(ctx context.Context, userID, step int64) (bool, error)
+		// ReplaceRecoveryCodes swaps the user's recovery codes for codeHashes.
+		ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+		// UseRecoveryCode marks an unused code as used; false means no such code.
+		UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+	}
+
+	// TokenDenylist holds revoked access token IDs until they expire.
+	TokenDenylist interface {
+		Revoke(ctx context.Context, jti string, ttl time.Duration) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+	}
+
+	// APIKeyRepo stores hashed API keys.
+	APIKeyRepo interface {
+		Create(context.Context, entity.APIKey) (entity.APIKey, error)
+		// GetByPrefix returns the key with prefix, revoked or not; pgx.ErrNoRows when there is none.
+		GetByPrefix(ctx context.Context, prefix string) (entity.APIKey, error)
+		// List returns the keys of userID, or every key when userID is 0, newest first.
+		List(ctx context.Context, userID int64) ([]entity.APIKey, error)
+		// Revoke revokes the key and reports whether it was active.
+		Revoke(ctx context.Context, id int64) (bool, error)
+		TouchLastUsed(ctx context.Context, id int64, at time.Time) error
+	}
+
+	// UserIdentityRepo stores the OpenID Connect accounts linked to users.
+	UserIdentityRepo interface {
+		// GetBySubject returns the identity of subject at issuer; pgx.ErrNoRows when there is none.
+		GetBySubject(ctx context.Context, issuer, subject string) (entity.UserIdentity, error)
+		Create(context.Context, entity.UserIdentity) (entity.UserIdentity, error)
+	}
+
+	// OIDCStateRepo keeps OpenID Connect logins between the redirect to the
+	// provider and the callback.
+	OIDCStateRepo interface {
+		Save(ctx context.Context, state string, login entity.OIDCLoginState, ttl time.Duration) error
+		// Take returns and deletes the login of state; false when it is unknown or expired.
+		Take(ctx context.Context, state string) (entity.OIDCLoginState, bool, error)
+	}
+
+	// Transactor runs fn in a database transaction. Repos called with the ctx
+	// given to fn take part in it.
+	Transactor interface {
+		InTx(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+
+	// OutboxRepo stores events until the outbox relay has sent them.
+	OutboxRepo interface {
+		// NextID reserves a row ID, so a payload can carry the ID of its event.
+		NextID(ctx context.Context) (int64, error)
+		// Add stores event, under its ID when it is one from NextID.
+		Add(context.Context, entity.OutboxEvent) error
+		// TryLock takes the relay lock until the current transaction ends;
+		// false when another relay holds it. Only the holder reads Pending.
+		TryLock(ctx context.Context) (bool, error)
+		// Pending returns up to limit unsent events, oldest first.
+		Pending(ctx context.Context, limit uint64) ([]entity.OutboxEvent, error)
+		MarkPublished(ctx context.Context, ids []int64) error
+		MarkFailed(ctx context.Context, id int64, reason string) error
+	}
+
+	// LoginAttemptRepo counts failed logins and keeps lockouts. Keys name what is
+	// tracked, such as an email or a client IP.
+	LoginAttemptRepo interface {
+		// AddFailure counts a failure for key and returns the failures since the
+		// first one, which are forgotten window after it.
+		AddFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+		// Failures returns the failures counted for key and how long until they are forgotten.
+		Failures(ctx context.Context, key string) (int64, time.Duration, error)
+		Lock(ctx context.Context, key string, ttl time.Duration) error
+		// LockedFor returns the time left on the lock of key, zero when it is not locked.
+		LockedFor(ctx context.Context, key string) (time.Duration, error)
+		// Reset forgets the failures and the lock of key.
+		Reset(ctx context.Context, key string) error
+	}
+
 	// RedisRepo -.
 	RedisRepo interface {
 		SetValue(context.Context, entity.RedisValue) error
@@ -45,12 +172,19 @@ type (
 
 	// KafkaRepo -.
 	KafkaRepo interface {
-		SendMessage(ctx context.Context, topic string, key []byte, value interface{}) error
-		AddConsumer(topic, groupID string, handler func(ctx context.Context, key, value []byte) error) error
-		StartConsumer(ctx context.Context, topic string) error
+		SendMessage(ctx context.Context, topic string, key []byte, value interface{}, opts ...kafka.MessageOption) error
+		AddConsumer(topic, groupID string, handler kafka.MessageHandler) error
+		StartConsumer(ctx context.Context, topic, groupID string) error
 		StartAllConsumers(ctx context.Context)
 		Close() error
 
+		// Consumer lifecycle, by topic and group
+		PauseConsumer(topic, groupID string) error
+		ResumeConsumer(topic, groupID string) error
+		StopConsumer(topic, groupID string) error
+		RestartConsumer(topic, groupID string) error
+		ConsumerStatuses() []kafka.ConsumerStatus
+
 		// Control methods
 		EnableProducer()
 		DisableProducer()
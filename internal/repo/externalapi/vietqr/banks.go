@@ -0,0 +1,84 @@
+package vietqr
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+)
+
+//go:embed banks.json
+var banksJSON []byte
+
+// BankDirectory looks up NAPAS member banks.
+type BankDirectory interface {
+	// Lookup finds a bank by code or BIN, ignoring case.
+	Lookup(codeOrBIN string) (entity.Bank, bool)
+	// Search returns banks whose code, short name or name contains query, or whose BIN starts with it.
+	// An empty query returns every bank.
+	Search(query string) []entity.Bank
+}
+
+type bankDirectory struct {
+	banks  []entity.Bank
+	byCode map[string]entity.Bank
+	byBIN  map[string]entity.Bank
+}
+
+// NewBankDirectory creates a directory over the given banks, sorted by code.
+func NewBankDirectory(banks []entity.Bank) BankDirectory {
+	d := &bankDirectory{
+		banks:  make([]entity.Bank, len(banks)),
+		byCode: make(map[string]entity.Bank, len(banks)),
+		byBIN:  make(map[string]entity.Bank, len(banks)),
+	}
+	copy(d.banks, banks)
+	sort.Slice(d.banks, func(i, j int) bool { return d.banks[i].Code < d.banks[j].Code })
+
+	for _, b := range d.banks {
+		d.byCode[strings.ToUpper(b.Code)] = b
+		d.byBIN[b.BIN] = b
+	}
+	return d
+}
+
+var defaultBanks = sync.OnceValue(func() BankDirectory {
+	var banks []entity.Bank
+	if err := json.Unmarshal(banksJSON, &banks); err != nil {
+		// The dataset is compiled in; a parse failure is a build defect
+		panic("vietqr - banks.json: " + err.Error())
+	}
+	return NewBankDirectory(banks)
+})
+
+// DefaultBankDirectory returns the directory built from the embedded NAPAS dataset.
+func DefaultBankDirectory() BankDirectory {
+	return defaultBanks()
+}
+
+func (d *bankDirectory) Lookup(codeOrBIN string) (entity.Bank, bool) {
+	key := strings.ToUpper(strings.TrimSpace(codeOrBIN))
+	if b, ok := d.byCode[key]; ok {
+		return b, true
+	}
+	b, ok := d.byBIN[key]
+	return b, ok
+}
+
+func (d *bankDirectory) Search(query string) []entity.Bank {
+	query = strings.ToLower(strings.TrimSpace(query))
+	result := make([]entity.Bank, 0, len(d.banks))
+	for _, b := range d.banks {
+		if query == "" ||
+			strings.HasPrefix(b.BIN, query) ||
+			strings.Contains(strings.ToLower(b.Code), query) ||
+			strings.Contains(strings.ToLower(b.ShortName), query) ||
+			strings.Contains(strings.ToLower(b.Name), query) {
+			result = append(result, b)
+		}
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package vietqr
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultBankDirectory(t *testing.T) {
+	dir := DefaultBankDirectory()
+	all := dir.Search("")
+	require.NotEmpty(t, all)
+
+	bin := regexp.MustCompile(`^\d{6}$`)
+	seen := map[string]bool{}
+	for _, b := range all {
+		assert.Regexp(t, bin, b.BIN, b.Code)
+		assert.NotEmpty(t, b.ShortName, b.Code)
+		assert.False(t, seen[b.BIN], "duplicate BIN %s", b.BIN)
+		seen[b.BIN] = true
+	}
+}
+
+func TestBankDirectory_Lookup(t *testing.T) {
+	dir := DefaultBankDirectory()
+
+	for _, key := range []string{"VCB", "vcb", " VCB ", "970436"} {
+		b, ok := dir.Lookup(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, "970436", b.BIN, key)
+		assert.Equal(t, "BFTVVNVX", b.SWIFTCode, key)
+	}
+
+	_, ok := dir.Lookup("NOPE")
+	assert.False(t, ok)
+	_, ok = dir.Lookup("")
+	assert.False(t, ok)
+}
+
+func TestBankDirectory_Search(t *testing.T) {
+	dir := DefaultBankDirectory()
+
+	byName := dir.Search("techcom")
+	require.Len(t, byName, 1)
+	assert.Equal(t, "TCB", byName[0].Code)
+
+	byBIN := dir.Search("97043")
+	assert.NotEmpty(t, byBIN)
+	for _, b := range byBIN {
+		assert.Contains(t, b.BIN, "97043")
+	}
+
+	assert.Empty(t, dir.Search("no such bank"))
+}
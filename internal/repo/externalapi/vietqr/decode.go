@@ -0,0 +1,287 @@
+package vietqr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/vietqr"
+)
+
+// napasGUID identifies the NAPAS VietQR merchant account template.
+const napasGUID = "A000000727"
+
+var (
+	// ErrMalformed is returned when the TLV structure cannot be read.
+	ErrMalformed = errors.New("malformed payload")
+	// ErrChecksum is returned when the CRC16 does not match the payload.
+	ErrChecksum = errors.New("checksum mismatch")
+	// ErrMissingField is returned when a mandatory tag is absent.
+	ErrMissingField = errors.New("missing required field")
+	// ErrInvalidField is returned when a tag holds a value outside its allowed format.
+	ErrInvalidField = errors.New("invalid field value")
+)
+
+// DecodeError describes where and why a payload failed to decode.
+type DecodeError struct {
+	// Tag is the EMVCo ID path, e.g. "38.01.00"; empty for whole-payload errors.
+	Tag string
+	// Offset is the byte position in the payload where the problem was found.
+	Offset int
+	Err    error
+	Detail string
+}
+
+func (e *DecodeError) Error() string {
+	msg := "vietqr - decode"
+	if e.Tag != "" {
+		msg += fmt.Sprintf(" - tag %s", e.Tag)
+	}
+	msg += fmt.Sprintf(" at offset %d: %v", e.Offset, e.Err)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// field is a single TLV element.
+type field struct {
+	id     string
+	value  string
+	offset int
+}
+
+// Decode parses and validates an EMVCo VietQR string, including its CRC16.
+func Decode(payload string) (entity.VietQRPayload, error) {
+	fields, err := parseTLV(payload, "", 0)
+	if err != nil {
+		return entity.VietQRPayload{}, err
+	}
+
+	if err := verifyChecksum(payload, fields); err != nil {
+		return entity.VietQRPayload{}, err
+	}
+
+	var p entity.VietQRPayload
+	seen := make(map[string]bool, len(fields))
+	for i, f := range fields {
+		if seen[f.id] {
+			return entity.VietQRPayload{}, &DecodeError{Tag: f.id, Offset: f.offset, Err: ErrMalformed, Detail: "duplicate tag"}
+		}
+		seen[f.id] = true
+
+		switch f.id {
+		case "00":
+			if i != 0 || f.value != "01" {
+				return entity.VietQRPayload{}, &DecodeError{Tag: f.id, Offset: f.offset, Err: ErrInvalidField, Detail: "payload format indicator must be the first tag with value 01"}
+			}
+			p.PayloadFormat = f.value
+		case "01":
+			if f.value != "11" && f.value != "12" {
+				return entity.VietQRPayload{}, &DecodeError{Tag: f.id, Offset: f.offset, Err: ErrInvalidField, Detail: "initiation method must be 11 or 12"}
+			}
+			p.InitiationMethod = f.value
+		case "38":
+			if p.MerchantAccount, err = decodeMerchantAccount(f); err != nil {
+				return entity.VietQRPayload{}, err
+			}
+		case "52":
+			if !isDigits(f.value) || len(f.value) != 4 {
+				return entity.VietQRPayload{}, &DecodeError{Tag: f.id, Offset: f.offset, Err: ErrInvalidField, Detail: "merchant category code must be 4 digits"}
+			}
+			p.MCC = f.value
+		case "53":
+			if !isDigits(f.value) || len(f.value) != 3 {
+				return entity.VietQRPayload{}, &DecodeError{Tag: f.id, Offset: f.offset, Err: ErrInvalidField, Detail: "currency must be a 3-digit ISO 4217 code"}
+			}
+			p.Currency = f.value
+		case "54":
+			if !isAmount(f.value) {
+				return entity.VietQRPayload{}, &DecodeError{Tag: f.id, Offset: f.offset, Err: ErrInvalidField, Detail: "amount must be a positive decimal of at most 13 characters"}
+			}
+			p.Amount = f.value
+		case "58":
+			if len(f.value) != 2 {
+				return entity.VietQRPayload{}, &DecodeError{Tag: f.id, Offset: f.offset, Err: ErrInvalidField, Detail: "country code must be 2 letters"}
+			}
+			p.CountryCode = f.value
+		case "59":
+			p.MerchantName = f.value
+		case "60":
+			p.MerchantCity = f.value
+		case "62":
+			if p.AdditionalData, err = decodeAdditionalData(f); err != nil {
+				return entity.VietQRPayload{}, err
+			}
+		case "63":
+			p.CRC = f.value
+		}
+	}
+
+	for _, required := range []string{"00", "38", "53", "58", "63"} {
+		if !seen[required] {
+			return entity.VietQRPayload{}, &DecodeError{Tag: required, Offset: len(payload), Err: ErrMissingField}
+		}
+	}
+
+	return p, nil
+}
+
+// parseTLV splits s into ID/length/value triplets. base is the offset of s in the full payload.
+func parseTLV(s, parent string, base int) ([]field, error) {
+	var fields []field
+	for pos := 0; pos < len(s); {
+		if len(s)-pos < 4 {
+			return nil, &DecodeError{Tag: parent, Offset: base + pos, Err: ErrMalformed, Detail: "truncated tag header"}
+		}
+
+		id := s[pos : pos+2]
+		tag := joinTag(parent, id)
+		if !isDigits(id) {
+			return nil, &DecodeError{Tag: tag, Offset: base + pos, Err: ErrMalformed, Detail: "tag ID must be 2 digits"}
+		}
+		n, err := strconv.Atoi(s[pos+2 : pos+4])
+		if err != nil || !isDigits(s[pos+2:pos+4]) {
+			return nil, &DecodeError{Tag: tag, Offset: base + pos + 2, Err: ErrMalformed, Detail: "length must be 2 digits"}
+		}
+		if pos+4+n > len(s) {
+			return nil, &DecodeError{Tag: tag, Offset: base + pos + 2, Err: ErrMalformed, Detail: fmt.Sprintf("length %d exceeds remaining payload", n)}
+		}
+
+		fields = append(fields, field{id: id, value: s[pos+4 : pos+4+n], offset: base + pos})
+		pos += 4 + n
+	}
+	return fields, nil
+}
+
+func verifyChecksum(payload string, fields []field) error {
+	if len(fields) == 0 {
+		return &DecodeError{Offset: 0, Err: ErrMalformed, Detail: "empty payload"}
+	}
+	last := fields[len(fields)-1]
+	if last.id != "63" {
+		return &DecodeError{Tag: "63", Offset: len(payload), Err: ErrMissingField, Detail: "CRC must be the last tag"}
+	}
+	if len(last.value) != 4 {
+		return &DecodeError{Tag: "63", Offset: last.offset, Err: ErrInvalidField, Detail: "CRC must be 4 hex characters"}
+	}
+
+	want := Checksum(payload[:last.offset+4])
+	if !strings.EqualFold(last.value, want) {
+		return &DecodeError{Tag: "63", Offset: last.offset, Err: ErrChecksum, Detail: fmt.Sprintf("got %s, want %s", last.value, want)}
+	}
+	return nil
+}
+
+// Checksum returns the CRC16/CCITT-FALSE of data as 4 upper-case hex characters.
+func Checksum(data string) string {
+	return fmt.Sprintf("%04X", vietqr.Checksum([]byte(data), vietqr.MakeTable(vietqr.CRC16_CCITT_FALSE)))
+}
+
+func decodeMerchantAccount(f field) (entity.VietQRMerchantAccount, error) {
+	subs, err := parseTLV(f.value, f.id, f.offset+4)
+	if err != nil {
+		return entity.VietQRMerchantAccount{}, err
+	}
+
+	var acc entity.VietQRMerchantAccount
+	for _, sub := range subs {
+		switch sub.id {
+		case "00":
+			acc.GUID = sub.value
+		case "01":
+			beneficiary, err := parseTLV(sub.value, joinTag(f.id, sub.id), sub.offset+4)
+			if err != nil {
+				return entity.VietQRMerchantAccount{}, err
+			}
+			for _, b := range beneficiary {
+				switch b.id {
+				case "00":
+					acc.BankBIN = b.value
+				case "01":
+					acc.AccountNo = b.value
+				}
+			}
+		case "02":
+			acc.ServiceCode = sub.value
+		}
+	}
+
+	if acc.GUID != napasGUID {
+		return entity.VietQRMerchantAccount{}, &DecodeError{Tag: "38.00", Offset: f.offset, Err: ErrInvalidField, Detail: "GUID must be " + napasGUID}
+	}
+	if len(acc.BankBIN) != 6 || !isDigits(acc.BankBIN) {
+		return entity.VietQRMerchantAccount{}, &DecodeError{Tag: "38.01.00", Offset: f.offset, Err: ErrInvalidField, Detail: "bank BIN must be 6 digits"}
+	}
+	if acc.AccountNo == "" {
+		return entity.VietQRMerchantAccount{}, &DecodeError{Tag: "38.01.01", Offset: f.offset, Err: ErrMissingField}
+	}
+	return acc, nil
+}
+
+func decodeAdditionalData(f field) (entity.VietQRAdditionalData, error) {
+	subs, err := parseTLV(f.value, f.id, f.offset+4)
+	if err != nil {
+		return entity.VietQRAdditionalData{}, err
+	}
+
+	var data entity.VietQRAdditionalData
+	for _, sub := range subs {
+		switch sub.id {
+		case "01":
+			data.BillNumber = sub.value
+		case "02":
+			data.MobileNumber = sub.value
+		case "03":
+			data.StoreLabel = sub.value
+		case "04":
+			data.LoyaltyNumber = sub.value
+		case "05":
+			data.ReferenceLabel = sub.value
+		case "06":
+			data.CustomerLabel = sub.value
+		case "07":
+			data.TerminalLabel = sub.value
+		case "08":
+			data.Purpose = sub.value
+		}
+	}
+	return data, nil
+}
+
+func joinTag(parent, id string) string {
+	if parent == "" {
+		return id
+	}
+	return parent + "." + id
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAmount(s string) bool {
+	if s == "" || len(s) > 13 {
+		return false
+	}
+	whole, frac, hasDot := strings.Cut(s, ".")
+	if !isDigits(whole) || (hasDot && !isDigits(frac)) {
+		return false
+	}
+	// Positive: some digit other than zero
+	return strings.Trim(whole+frac, "0") != ""
+}
@@ -0,0 +1,126 @@
+package vietqr
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validPayload(t *testing.T) string {
+	t.Helper()
+
+	content, err := NewVietQRRepo().GenerateQR(context.Background(), entity.VietQRGenerateRequest{
+		AccountNo:    "1234567890",
+		Amount:       "100000",
+		Description:  "Thanh toan hoa don",
+		MCC:          "5812",
+		ReceiverName: "NGUYEN VAN A",
+	})
+	require.NoError(t, err)
+	return content
+}
+
+func TestDecode(t *testing.T) {
+	payload, err := Decode(validPayload(t))
+
+	require.NoError(t, err)
+	assert.Equal(t, "01", payload.PayloadFormat)
+	assert.Equal(t, "12", payload.InitiationMethod)
+	assert.Equal(t, "A000000727", payload.MerchantAccount.GUID)
+	assert.Equal(t, "970437", payload.MerchantAccount.BankBIN)
+	assert.Equal(t, "1234567890", payload.MerchantAccount.AccountNo)
+	assert.Equal(t, "QRIBFTTA", payload.MerchantAccount.ServiceCode)
+	assert.Equal(t, "5812", payload.MCC)
+	assert.Equal(t, "704", payload.Currency)
+	assert.Equal(t, "100000", payload.Amount)
+	assert.Equal(t, "VN", payload.CountryCode)
+	assert.Equal(t, "NGUYEN VAN A", payload.MerchantName)
+	assert.Equal(t, "Thanh toan hoa don", payload.AdditionalData.Purpose)
+	assert.Len(t, payload.CRC, 4)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	valid := validPayload(t)
+	body := valid[:len(valid)-4]
+
+	tests := []struct {
+		name        string
+		payload     string
+		expectedErr error
+		expectedTag string
+	}{
+		{
+			name:        "tampered amount",
+			payload:     strings.Replace(valid, "5406100000", "5406900000", 1),
+			expectedErr: ErrChecksum,
+			expectedTag: "63",
+		},
+		{
+			name:        "truncated payload",
+			payload:     valid[:len(valid)-10],
+			expectedErr: ErrMalformed,
+		},
+		{
+			name:        "non numeric length",
+			payload:     "00AB01",
+			expectedErr: ErrMalformed,
+			expectedTag: "00",
+		},
+		{
+			name:        "missing CRC",
+			payload:     body[:len(body)-4],
+			expectedErr: ErrMissingField,
+			expectedTag: "63",
+		},
+		{
+			name:        "empty payload",
+			payload:     "",
+			expectedErr: ErrMalformed,
+		},
+		{
+			name:        "garbage",
+			payload:     "not a vietqr code",
+			expectedErr: ErrMalformed,
+		},
+		{
+			name:        "zero amount",
+			payload:     withChecksum(strings.Replace(body, "5406100000", "54010", 1)),
+			expectedErr: ErrInvalidField,
+			expectedTag: "54",
+		},
+		{
+			name:        "zero decimal amount",
+			payload:     withChecksum(strings.Replace(body, "5406100000", "54060.0000", 1)),
+			expectedErr: ErrInvalidField,
+			expectedTag: "54",
+		},
+		{
+			name:        "missing merchant account",
+			payload:     withChecksum("000201010212530370458" + "02VN6304"),
+			expectedErr: ErrMissingField,
+			expectedTag: "38",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.payload)
+
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			var decodeErr *DecodeError
+			require.ErrorAs(t, err, &decodeErr)
+			if tt.expectedTag != "" {
+				assert.Equal(t, tt.expectedTag, decodeErr.Tag)
+			}
+		})
+	}
+}
+
+func withChecksum(s string) string {
+	return s + Checksum(s)
+}
@@ -2,6 +2,7 @@ package vietqr
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/vietqr"
@@ -24,6 +25,7 @@ func NewVietQRRepo() VietQRRepo {
 func (r *vietQRRepo) GenerateQR(ctx context.Context, req entity.VietQRGenerateRequest) (string, error) {
 	qrRequest := vietqr.RequestGenerateViQR{
 		MerchantAccountInformation: vietqr.MerchantAccountInformation{
+			AcqID:     req.BankBIN,
 			AccountNo: req.AccountNo,
 		},
 		TransactionAmount: req.Amount,
@@ -34,5 +36,12 @@ func (r *vietQRRepo) GenerateQR(ctx context.Context, req entity.VietQRGenerateRe
 		ReceiverName: req.ReceiverName,
 	}
 
-	return vietqr.GenerateViQR(qrRequest), nil
+	content := vietqr.GenerateViQR(qrRequest)
+
+	// Never hand out a code that partners would fail to parse
+	if _, err := Decode(content); err != nil {
+		return "", fmt.Errorf("vietqr - GenerateQR - generated payload is invalid: %w", err)
+	}
+
+	return content, nil
 }
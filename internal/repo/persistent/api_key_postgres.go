@@ -0,0 +1,156 @@
+package persistent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+)
+
+var apiKeyColumns = []string{
+	"id", "name", "prefix", "key_hash", "user_id", "scopes", "COALESCE(created_by, 0)",
+	"expires_at", "last_used_at", "revoked_at", "created_at",
+}
+
+// APIKeyRepo -.
+type APIKeyRepo struct {
+	pg *postgres.Postgres
+}
+
+// NewAPIKeyRepo -.
+func NewAPIKeyRepo(pg *postgres.Postgres) *APIKeyRepo {
+	return &APIKeyRepo{pg}
+}
+
+// Create -.
+func (r *APIKeyRepo) Create(ctx context.Context, key entity.APIKey) (entity.APIKey, error) {
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+
+	var createdBy *int64
+	if key.CreatedBy != 0 {
+		createdBy = &key.CreatedBy
+	}
+
+	sql, args, err := r.pg.Builder.
+		Insert("api_keys").
+		Columns("name", "prefix", "key_hash", "user_id", "scopes", "created_by", "expires_at", "created_at").
+		Values(key.Name, key.Prefix, key.KeyHash, key.UserID, key.Scopes, createdBy, key.ExpiresAt, key.CreatedAt).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return entity.APIKey{}, fmt.Errorf("APIKeyRepo - Create - r.Builder: %w", err)
+	}
+
+	if err := conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(&key.ID); err != nil {
+		return entity.APIKey{}, fmt.Errorf("APIKeyRepo - Create - r.Pool.QueryRow: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetByPrefix -.
+func (r *APIKeyRepo) GetByPrefix(ctx context.Context, prefix string) (entity.APIKey, error) {
+	sql, args, err := r.pg.Builder.
+		Select(apiKeyColumns...).
+		From("api_keys").
+		Where(squirrel.Eq{"prefix": prefix}).
+		ToSql()
+	if err != nil {
+		return entity.APIKey{}, fmt.Errorf("APIKeyRepo - GetByPrefix - r.Builder: %w", err)
+	}
+
+	key, err := scanAPIKey(conn(ctx, r.pg).QueryRow(ctx, sql, args...))
+	if err != nil {
+		return entity.APIKey{}, fmt.Errorf("APIKeyRepo - GetByPrefix - r.Pool.QueryRow: %w", err)
+	}
+
+	return key, nil
+}
+
+// List -.
+func (r *APIKeyRepo) List(ctx context.Context, userID int64) ([]entity.APIKey, error) {
+	builder := r.pg.Builder.
+		Select(apiKeyColumns...).
+		From("api_keys").
+		OrderBy("id DESC")
+	if userID != 0 {
+		builder = builder.Where(squirrel.Eq{"user_id": userID})
+	}
+
+	sql, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyRepo - List - r.Builder: %w", err)
+	}
+
+	rows, err := conn(ctx, r.pg).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyRepo - List - r.Pool.Query: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []entity.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("APIKeyRepo - List - rows.Scan: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("APIKeyRepo - List - rows.Err: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Revoke -.
+func (r *APIKeyRepo) Revoke(ctx context.Context, id int64) (bool, error) {
+	sql, args, err := r.pg.Builder.
+		Update("api_keys").
+		Set("revoked_at", time.Now()).
+		Where(squirrel.Eq{"id": id, "revoked_at": nil}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("APIKeyRepo - Revoke - r.Builder: %w", err)
+	}
+
+	tag, err := conn(ctx, r.pg).Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("APIKeyRepo - Revoke - r.Pool.Exec: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// TouchLastUsed -.
+func (r *APIKeyRepo) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	sql, args, err := r.pg.Builder.
+		Update("api_keys").
+		Set("last_used_at", at).
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("APIKeyRepo - TouchLastUsed - r.Builder: %w", err)
+	}
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("APIKeyRepo - TouchLastUsed - r.Pool.Exec: %w", err)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (entity.APIKey, error) {
+	var k entity.APIKey
+	err := row.Scan(
+		&k.ID, &k.Name, &k.Prefix, &k.KeyHash, &k.UserID, &k.Scopes, &k.CreatedBy,
+		&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt,
+	)
+	return k, err
+}
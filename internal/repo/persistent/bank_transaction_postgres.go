@@ -0,0 +1,134 @@
+package persistent
+
+import (
+	"context"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+)
+
+var bankTransactionColumns = []string{
+	"id", "bank_transaction_id", "account_no", "amount", "description",
+	"direction", "transacted_at", "status", "vietqr_id", "created_at",
+}
+
+type BankTransactionRepo struct {
+	pg *postgres.Postgres
+}
+
+func NewBankTransactionRepo(pg *postgres.Postgres) *BankTransactionRepo {
+	return &BankTransactionRepo{pg}
+}
+
+// Store inserts the transaction and fills in its ID. A notification the bank already
+// delivered keeps its original row and is returned unchanged with stored set to false.
+func (r *BankTransactionRepo) Store(ctx context.Context, txn *entity.BankTransaction) (stored bool, err error) {
+	var vietqrID *string
+	if txn.VietQRID != "" {
+		vietqrID = &txn.VietQRID
+	}
+	if txn.CreatedAt.IsZero() {
+		txn.CreatedAt = time.Now()
+	}
+
+	sql, args, err := r.pg.Builder.
+		Insert("bank_transactions").
+		Columns("bank_transaction_id", "account_no", "amount", "description", "direction", "transacted_at", "status", "vietqr_id", "created_at").
+		Values(txn.BankTransactionID, txn.AccountNo, txn.Amount, txn.Description, txn.Direction, txn.TransactedAt, txn.Status, vietqrID, txn.CreatedAt).
+		Suffix("ON CONFLICT (bank_transaction_id) DO NOTHING RETURNING id").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := conn(ctx, r.pg).Query(ctx, sql, args...)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err := rows.Scan(&txn.ID); err != nil {
+			return false, err
+		}
+		return true, rows.Err()
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	existing, err := r.FindByBankTransactionID(ctx, txn.BankTransactionID)
+	if err != nil {
+		return false, err
+	}
+	*txn = existing
+
+	return false, nil
+}
+
+func (r *BankTransactionRepo) FindByBankTransactionID(ctx context.Context, bankTransactionID string) (entity.BankTransaction, error) {
+	sql, args, err := r.pg.Builder.
+		Select(bankTransactionColumns...).
+		From("bank_transactions").
+		Where(squirrel.Eq{"bank_transaction_id": bankTransactionID}).
+		ToSql()
+	if err != nil {
+		return entity.BankTransaction{}, err
+	}
+
+	return scanBankTransaction(conn(ctx, r.pg).QueryRow(ctx, sql, args...))
+}
+
+// ListByStatus returns the newest transactions in the given status first.
+func (r *BankTransactionRepo) ListByStatus(ctx context.Context, status entity.BankTransactionStatus, limit uint64) ([]entity.BankTransaction, error) {
+	sql, args, err := r.pg.Builder.
+		Select(bankTransactionColumns...).
+		From("bank_transactions").
+		Where(squirrel.Eq{"status": status}).
+		OrderBy("created_at DESC").
+		Limit(limit).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, r.pg).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	txns := make([]entity.BankTransaction, 0, limit)
+	for rows.Next() {
+		txn, err := scanBankTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, txn)
+	}
+
+	return txns, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBankTransaction(row rowScanner) (entity.BankTransaction, error) {
+	var (
+		txn      entity.BankTransaction
+		vietqrID *string
+	)
+	err := row.Scan(&txn.ID, &txn.BankTransactionID, &txn.AccountNo, &txn.Amount, &txn.Description,
+		&txn.Direction, &txn.TransactedAt, &txn.Status, &vietqrID, &txn.CreatedAt)
+	if err != nil {
+		return entity.BankTransaction{}, err
+	}
+	if vietqrID != nil {
+		txn.VietQRID = *vietqrID
+	}
+
+	return txn, nil
+}
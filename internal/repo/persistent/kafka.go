@@ -14,32 +14,32 @@ type KafkaRepo struct {
 }
 
 // NewKafkaRepo -.
-func NewKafkaRepo(brokers []string, logger zerolog.Logger) repo.KafkaRepo {
+func NewKafkaRepo(brokers []string, logger zerolog.Logger, opts ...kafka.ManagerOption) repo.KafkaRepo {
 	return &KafkaRepo{
-		manager: kafka.NewManager(brokers, logger),
+		manager: kafka.NewManager(brokers, logger, opts...),
 	}
 }
 
 // NewKafkaRepoWithConfig creates a new Kafka repository with configuration
-func NewKafkaRepoWithConfig(brokers []string, logger zerolog.Logger, producerEnabled, consumerEnabled bool) repo.KafkaRepo {
+func NewKafkaRepoWithConfig(brokers []string, logger zerolog.Logger, producerEnabled, consumerEnabled bool, opts ...kafka.ManagerOption) repo.KafkaRepo {
 	return &KafkaRepo{
-		manager: kafka.NewManagerWithConfig(brokers, logger, producerEnabled, consumerEnabled),
+		manager: kafka.NewManagerWithConfig(brokers, logger, producerEnabled, consumerEnabled, opts...),
 	}
 }
 
 // SendMessage -.
-func (k *KafkaRepo) SendMessage(ctx context.Context, topic string, key []byte, value interface{}) error {
-	return k.manager.SendMessage(ctx, topic, key, value)
+func (k *KafkaRepo) SendMessage(ctx context.Context, topic string, key []byte, value interface{}, opts ...kafka.MessageOption) error {
+	return k.manager.SendMessage(ctx, topic, key, value, opts...)
 }
 
 // AddConsumer -.
-func (k *KafkaRepo) AddConsumer(topic, groupID string, handler func(ctx context.Context, key, value []byte) error) error {
+func (k *KafkaRepo) AddConsumer(topic, groupID string, handler kafka.MessageHandler) error {
 	return k.manager.AddConsumer(topic, groupID, handler)
 }
 
 // StartConsumer -.
-func (k *KafkaRepo) StartConsumer(ctx context.Context, topic string) error {
-	return k.manager.StartConsumer(ctx, topic)
+func (k *KafkaRepo) StartConsumer(ctx context.Context, topic, groupID string) error {
+	return k.manager.StartConsumer(ctx, topic, groupID)
 }
 
 // StartAllConsumers -.
@@ -52,6 +52,31 @@ func (k *KafkaRepo) Close() error {
 	return k.manager.Close()
 }
 
+// PauseConsumer -.
+func (k *KafkaRepo) PauseConsumer(topic, groupID string) error {
+	return k.manager.PauseConsumer(topic, groupID)
+}
+
+// ResumeConsumer -.
+func (k *KafkaRepo) ResumeConsumer(topic, groupID string) error {
+	return k.manager.ResumeConsumer(topic, groupID)
+}
+
+// StopConsumer -.
+func (k *KafkaRepo) StopConsumer(topic, groupID string) error {
+	return k.manager.StopConsumer(topic, groupID)
+}
+
+// RestartConsumer -.
+func (k *KafkaRepo) RestartConsumer(topic, groupID string) error {
+	return k.manager.RestartConsumer(topic, groupID)
+}
+
+// ConsumerStatuses -.
+func (k *KafkaRepo) ConsumerStatuses() []kafka.ConsumerStatus {
+	return k.manager.ConsumerStatuses()
+}
+
 // Control methods for Kafka producer and consumer
 
 // EnableProducer enables the Kafka producer
@@ -0,0 +1,104 @@
+package persistent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/pkg/redis"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+const (
+	loginFailurePrefix = "auth:login:failures:"
+	loginLockPrefix    = "auth:login:lock:"
+)
+
+// addFailureScript starts the window with the first failure only, so further
+// failures do not keep extending it.
+var addFailureScript = goredis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n`)
+
+// LoginAttemptRepo keeps failed login counters and lockouts in Redis. Both
+// expire on their own.
+type LoginAttemptRepo struct {
+	r *redis.Redis
+}
+
+// NewLoginAttemptRepo -.
+func NewLoginAttemptRepo(r *redis.Redis) *LoginAttemptRepo {
+	return &LoginAttemptRepo{r: r}
+}
+
+// AddFailure -.
+func (r *LoginAttemptRepo) AddFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	return addFailureScript.Run(ctx, r.r.Client(), []string{loginFailurePrefix + key}, window.Milliseconds()).Int64()
+}
+
+// Failures -.
+func (r *LoginAttemptRepo) Failures(ctx context.Context, key string) (int64, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	pipe := r.r.Client().Pipeline()
+	count := pipe.Get(ctx, loginFailurePrefix+key)
+	ttl := pipe.PTTL(ctx, loginFailurePrefix+key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return 0, 0, err
+	}
+
+	n, err := count.Int64()
+	if errors.Is(err, goredis.Nil) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return n, positive(ttl.Val()), nil
+}
+
+// Lock -.
+func (r *LoginAttemptRepo) Lock(ctx context.Context, key string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	return r.r.Client().Set(ctx, loginLockPrefix+key, 1, ttl).Err()
+}
+
+// LockedFor -.
+func (r *LoginAttemptRepo) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	ttl, err := r.r.Client().PTTL(ctx, loginLockPrefix+key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	return positive(ttl), nil
+}
+
+// Reset -.
+func (r *LoginAttemptRepo) Reset(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	return r.r.Client().Del(ctx, loginFailurePrefix+key, loginLockPrefix+key).Err()
+}
+
+// positive maps the negative PTTL answers for missing keys or keys without
+// an expiry to zero.
+func positive(ttl time.Duration) time.Duration {
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
@@ -0,0 +1,163 @@
+package persistent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+)
+
+// MFARepo -.
+type MFARepo struct {
+	pg *postgres.Postgres
+}
+
+// NewMFARepo -.
+func NewMFARepo(pg *postgres.Postgres) *MFARepo {
+	return &MFARepo{pg}
+}
+
+// GetTOTP -.
+func (r *MFARepo) GetTOTP(ctx context.Context, userID int64) (entity.TOTP, error) {
+	sql, args, err := r.pg.Builder.
+		Select("totp_secret", "totp_enabled_at", "totp_last_step").
+		From("users").
+		Where(squirrel.Eq{"id": userID}).
+		ToSql()
+	if err != nil {
+		return entity.TOTP{}, fmt.Errorf("MFARepo - GetTOTP - r.Builder: %w", err)
+	}
+
+	var (
+		totp     = entity.TOTP{UserID: userID}
+		secret   *string
+		lastStep *int64
+	)
+	if err := conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(&secret, &totp.EnabledAt, &lastStep); err != nil {
+		return entity.TOTP{}, fmt.Errorf("MFARepo - GetTOTP - r.Pool.QueryRow: %w", err)
+	}
+	if secret != nil {
+		totp.Secret = *secret
+	}
+	if lastStep != nil {
+		totp.LastStep = *lastStep
+	}
+
+	return totp, nil
+}
+
+// SetTOTPSecret -.
+func (r *MFARepo) SetTOTPSecret(ctx context.Context, userID int64, sealedSecret string) error {
+	return r.updateUser(ctx, "SetTOTPSecret", userID, map[string]interface{}{
+		"totp_secret":     sealedSecret,
+		"totp_enabled_at": nil,
+		"totp_last_step":  nil,
+	})
+}
+
+// EnableTOTP -.
+func (r *MFARepo) EnableTOTP(ctx context.Context, userID int64) error {
+	return r.updateUser(ctx, "EnableTOTP", userID, map[string]interface{}{
+		"totp_enabled_at": time.Now(),
+	})
+}
+
+// DisableTOTP -.
+func (r *MFARepo) DisableTOTP(ctx context.Context, userID int64) error {
+	if err := r.updateUser(ctx, "DisableTOTP", userID, map[string]interface{}{
+		"totp_secret":     nil,
+		"totp_enabled_at": nil,
+		"totp_last_step":  nil,
+	}); err != nil {
+		return err
+	}
+
+	sql, args, err := r.pg.Builder.
+		Delete("user_recovery_codes").
+		Where(squirrel.Eq{"user_id": userID}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("MFARepo - DisableTOTP - r.Builder: %w", err)
+	}
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("MFARepo - DisableTOTP - r.Pool.Exec: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep only moves totp_last_step forward, so of two requests with the
+// same code only one succeeds.
+func (r *MFARepo) UseTOTPStep(ctx context.Context, userID, step int64) (bool, error) {
+	sql, args, err := r.pg.Builder.
+		Update("users").
+		Set("totp_last_step", step).
+		Where(squirrel.Eq{"id": userID}).
+		Where(squirrel.Or{squirrel.Eq{"totp_last_step": nil}, squirrel.Lt{"totp_last_step": step}}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("MFARepo - UseTOTPStep - r.Builder: %w", err)
+	}
+
+	tag, err := conn(ctx, r.pg).Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("MFARepo - UseTOTPStep - r.Pool.Exec: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReplaceRecoveryCodes deletes and inserts in one statement, so the user never
+// ends up without codes.
+func (r *MFARepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	const sql = `WITH removed AS (DELETE FROM user_recovery_codes WHERE user_id = $1)
+INSERT INTO user_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, userID, codeHashes); err != nil {
+		return fmt.Errorf("MFARepo - ReplaceRecoveryCodes - r.Pool.Exec: %w", err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode -.
+func (r *MFARepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	sql, args, err := r.pg.Builder.
+		Update("user_recovery_codes").
+		Set("used_at", time.Now()).
+		Where(squirrel.Eq{"user_id": userID, "code_hash": codeHash, "used_at": nil}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("MFARepo - UseRecoveryCode - r.Builder: %w", err)
+	}
+
+	tag, err := conn(ctx, r.pg).Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("MFARepo - UseRecoveryCode - r.Pool.Exec: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *MFARepo) updateUser(ctx context.Context, method string, userID int64, values map[string]interface{}) error {
+	values["updated_at"] = time.Now()
+
+	sql, args, err := r.pg.Builder.
+		Update("users").
+		SetMap(values).
+		Where(squirrel.Eq{"id": userID}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("MFARepo - %s - r.Builder: %w", method, err)
+	}
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("MFARepo - %s - r.Pool.Exec: %w", method, err)
+	}
+
+	return nil
+}
@@ -19,6 +19,9 @@ type Payment struct {
 	PaymentMethod string    `db:"payment_method" json:"payment_method"`
 	CreatedAt     time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+	// VietQRID and VietQRStatus are NULL unless the payment is paid by VietQR
+	VietQRID     *string `db:"vietqr_id" json:"vietqr_id"`
+	VietQRStatus *string `db:"vietqr_status" json:"vietqr_status"`
 }
 
 // PaymentHistory represents payment history database model
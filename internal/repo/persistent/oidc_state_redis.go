@@ -0,0 +1,60 @@
+package persistent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/redis"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+const oidcStatePrefix = "auth:oidc:state:"
+
+// OIDCStateRepo keeps pending OpenID Connect logins in Redis until the
+// callback takes them or they expire.
+type OIDCStateRepo struct {
+	r *redis.Redis
+}
+
+// NewOIDCStateRepo -.
+func NewOIDCStateRepo(r *redis.Redis) *OIDCStateRepo {
+	return &OIDCStateRepo{r: r}
+}
+
+// Save -.
+func (r *OIDCStateRepo) Save(ctx context.Context, state string, login entity.OIDCLoginState, ttl time.Duration) error {
+	value, err := json.Marshal(login)
+	if err != nil {
+		return fmt.Errorf("OIDCStateRepo - Save - json.Marshal: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	return r.r.Client().Set(ctx, oidcStatePrefix+state, value, ttl).Err()
+}
+
+// Take deletes the state as it reads it, so a callback can only be used once.
+func (r *OIDCStateRepo) Take(ctx context.Context, state string) (entity.OIDCLoginState, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	value, err := r.r.Client().GetDel(ctx, oidcStatePrefix+state).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return entity.OIDCLoginState{}, false, nil
+	}
+	if err != nil {
+		return entity.OIDCLoginState{}, false, fmt.Errorf("OIDCStateRepo - Take - GetDel: %w", err)
+	}
+
+	var login entity.OIDCLoginState
+	if err := json.Unmarshal(value, &login); err != nil {
+		return entity.OIDCLoginState{}, false, fmt.Errorf("OIDCStateRepo - Take - json.Unmarshal: %w", err)
+	}
+
+	return login, true, nil
+}
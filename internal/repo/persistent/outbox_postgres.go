@@ -0,0 +1,182 @@
+package persistent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+)
+
+// _outboxRelayLock is the advisory lock key of the outbox relay ("outbox" in ASCII).
+const _outboxRelayLock = 0x6f7574626f78
+
+// OutboxRepo -.
+type OutboxRepo struct {
+	pg *postgres.Postgres
+}
+
+// NewOutboxRepo -.
+func NewOutboxRepo(pg *postgres.Postgres) *OutboxRepo {
+	return &OutboxRepo{pg}
+}
+
+// NextID takes the next value of the id sequence; Add given that ID never
+// collides with a row numbered by the default.
+func (r *OutboxRepo) NextID(ctx context.Context) (int64, error) {
+	const sql = `SELECT nextval(pg_get_serial_sequence('outbox_events', 'id'))`
+
+	var id int64
+	if err := conn(ctx, r.pg).QueryRow(ctx, sql).Scan(&id); err != nil {
+		return 0, fmt.Errorf("OutboxRepo - NextID - r.Pool.QueryRow: %w", err)
+	}
+
+	return id, nil
+}
+
+// Add -.
+func (r *OutboxRepo) Add(ctx context.Context, event entity.OutboxEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	if event.Headers == nil {
+		event.Headers = map[string]string{}
+	}
+
+	headers, err := json.Marshal(event.Headers)
+	if err != nil {
+		return fmt.Errorf("OutboxRepo - Add - json.Marshal: %w", err)
+	}
+
+	columns := []string{"topic", "key", "event_type", "payload", "headers", "created_at"}
+	values := []interface{}{event.Topic, event.Key, event.EventType, []byte(event.Payload), headers, event.CreatedAt}
+	if event.ID != 0 {
+		columns = append(columns, "id")
+		values = append(values, event.ID)
+	}
+
+	sql, args, err := r.pg.Builder.
+		Insert("outbox_events").
+		Columns(columns...).
+		Values(values...).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("OutboxRepo - Add - r.Builder: %w", err)
+	}
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("OutboxRepo - Add - r.Pool.Exec: %w", err)
+	}
+
+	return nil
+}
+
+// TryLock takes a transaction-level advisory lock, so only one relay sends at a
+// time and events of a key keep their order. Outside a transaction the lock is
+// released as soon as it is taken.
+func (r *OutboxRepo) TryLock(ctx context.Context) (bool, error) {
+	var locked bool
+	if err := conn(ctx, r.pg).QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", _outboxRelayLock).Scan(&locked); err != nil {
+		return false, fmt.Errorf("OutboxRepo - TryLock - r.Pool.QueryRow: %w", err)
+	}
+
+	return locked, nil
+}
+
+// Pending -.
+func (r *OutboxRepo) Pending(ctx context.Context, limit uint64) ([]entity.OutboxEvent, error) {
+	sql, args, err := r.pg.Builder.
+		Select("id", "topic", "key", "event_type", "payload", "headers", "attempts", "COALESCE(last_error, '')", "created_at").
+		From("outbox_events").
+		Where("published_at IS NULL").
+		OrderBy("id").
+		Limit(limit).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("OutboxRepo - Pending - r.Builder: %w", err)
+	}
+
+	rows, err := conn(ctx, r.pg).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("OutboxRepo - Pending - r.Pool.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var events []entity.OutboxEvent
+	for rows.Next() {
+		var (
+			event   entity.OutboxEvent
+			payload []byte
+			headers []byte
+		)
+		err := rows.Scan(
+			&event.ID,
+			&event.Topic,
+			&event.Key,
+			&event.EventType,
+			&payload,
+			&headers,
+			&event.Attempts,
+			&event.LastError,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("OutboxRepo - Pending - rows.Scan: %w", err)
+		}
+		event.Payload = payload
+		if err := json.Unmarshal(headers, &event.Headers); err != nil {
+			return nil, fmt.Errorf("OutboxRepo - Pending - json.Unmarshal: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("OutboxRepo - Pending - rows.Err: %w", err)
+	}
+
+	return events, nil
+}
+
+// MarkPublished -.
+func (r *OutboxRepo) MarkPublished(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	sql, args, err := r.pg.Builder.
+		Update("outbox_events").
+		Set("published_at", time.Now()).
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Where(squirrel.Eq{"id": ids}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("OutboxRepo - MarkPublished - r.Builder: %w", err)
+	}
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("OutboxRepo - MarkPublished - r.Pool.Exec: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed -.
+func (r *OutboxRepo) MarkFailed(ctx context.Context, id int64, reason string) error {
+	sql, args, err := r.pg.Builder.
+		Update("outbox_events").
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("last_error", reason).
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("OutboxRepo - MarkFailed - r.Builder: %w", err)
+	}
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("OutboxRepo - MarkFailed - r.Pool.Exec: %w", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Masterminds/squirrel"
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/godev-kit/internal/repo/persistent/models"
 	"github.com/ducnpdev/godev-kit/pkg/postgres"
@@ -33,15 +34,15 @@ func (r *PaymentRepo) Create(ctx context.Context, payment *entity.Payment) error
 
 	sql, args, err := r.Builder.
 		Insert("payments").
-		Columns("user_id, amount, currency, payment_type, status, meter_number, customer_code, description, transaction_id, payment_method, created_at, updated_at").
-		Values(payment.UserID, payment.Amount, payment.Currency, payment.PaymentType, payment.Status, payment.MeterNumber, payment.CustomerCode, payment.Description, transactionID, payment.PaymentMethod, now, now).
+		Columns("user_id, amount, currency, payment_type, status, meter_number, customer_code, description, transaction_id, payment_method, created_at, updated_at, vietqr_id").
+		Values(payment.UserID, payment.Amount, payment.Currency, payment.PaymentType, payment.Status, payment.MeterNumber, payment.CustomerCode, payment.Description, transactionID, payment.PaymentMethod, now, now, nullString(payment.VietQRID)).
 		Suffix("RETURNING id").
 		ToSql()
 	if err != nil {
 		return fmt.Errorf("PaymentRepo - Create - r.Builder: %w", err)
 	}
 
-	err = r.Pool.QueryRow(ctx, sql, args...).Scan(&payment.ID)
+	err = conn(ctx, r.Postgres).QueryRow(ctx, sql, args...).Scan(&payment.ID)
 	if err != nil {
 		return fmt.Errorf("PaymentRepo - Create - r.Pool.QueryRow: %w", err)
 	}
@@ -52,16 +53,17 @@ func (r *PaymentRepo) Create(ctx context.Context, payment *entity.Payment) error
 // GetByID gets payment by ID
 func (r *PaymentRepo) GetByID(ctx context.Context, id int64) (*entity.Payment, error) {
 	sql, args, err := r.Builder.
-		Select("id, user_id, amount, currency, payment_type, status, meter_number, customer_code, description, transaction_id, payment_method, created_at, updated_at").
-		From("payments").
-		Where("id = ?", id).
+		Select(paymentColumns).
+		From("payments AS p").
+		LeftJoin("vietqr AS v ON v.id = p.vietqr_id").
+		Where("p.id = ?", id).
 		ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("PaymentRepo - GetByID - r.Builder: %w", err)
 	}
 
 	var payment models.Payment
-	err = r.Pool.QueryRow(ctx, sql, args...).Scan(
+	err = conn(ctx, r.Postgres).QueryRow(ctx, sql, args...).Scan(
 		&payment.ID,
 		&payment.UserID,
 		&payment.Amount,
@@ -75,6 +77,8 @@ func (r *PaymentRepo) GetByID(ctx context.Context, id int64) (*entity.Payment, e
 		&payment.PaymentMethod,
 		&payment.CreatedAt,
 		&payment.UpdatedAt,
+		&payment.VietQRID,
+		&payment.VietQRStatus,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -89,16 +93,17 @@ func (r *PaymentRepo) GetByID(ctx context.Context, id int64) (*entity.Payment, e
 // GetByUserID gets payments by user ID
 func (r *PaymentRepo) GetByUserID(ctx context.Context, userID int64) ([]*entity.Payment, error) {
 	sql, args, err := r.Builder.
-		Select("id, user_id, amount, currency, payment_type, status, meter_number, customer_code, description, transaction_id, payment_method, created_at, updated_at").
-		From("payments").
-		Where("user_id = ?", userID).
-		OrderBy("created_at DESC").
+		Select(paymentColumns).
+		From("payments AS p").
+		LeftJoin("vietqr AS v ON v.id = p.vietqr_id").
+		Where("p.user_id = ?", userID).
+		OrderBy("p.created_at DESC").
 		ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("PaymentRepo - GetByUserID - r.Builder: %w", err)
 	}
 
-	rows, err := r.Pool.Query(ctx, sql, args...)
+	rows, err := conn(ctx, r.Postgres).Query(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("PaymentRepo - GetByUserID - r.Pool.Query: %w", err)
 	}
@@ -121,6 +126,8 @@ func (r *PaymentRepo) GetByUserID(ctx context.Context, userID int64) ([]*entity.
 			&payment.PaymentMethod,
 			&payment.CreatedAt,
 			&payment.UpdatedAt,
+			&payment.VietQRID,
+			&payment.VietQRStatus,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("PaymentRepo - GetByUserID - rows.Scan: %w", err)
@@ -143,7 +150,7 @@ func (r *PaymentRepo) UpdateStatus(ctx context.Context, id int64, status entity.
 		return fmt.Errorf("PaymentRepo - UpdateStatus - r.Builder: %w", err)
 	}
 
-	result, err := r.Pool.Exec(ctx, sql, args...)
+	result, err := conn(ctx, r.Postgres).Exec(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("PaymentRepo - UpdateStatus - r.Pool.Exec: %w", err)
 	}
@@ -166,7 +173,7 @@ func (r *PaymentRepo) CreateHistory(ctx context.Context, payment *entity.Payment
 		return fmt.Errorf("PaymentRepo - CreateHistory - r.Builder: %w", err)
 	}
 
-	_, err = r.Pool.Exec(ctx, sql, args...)
+	_, err = conn(ctx, r.Postgres).Exec(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("PaymentRepo - CreateHistory - r.Pool.Exec: %w", err)
 	}
@@ -174,8 +181,67 @@ func (r *PaymentRepo) CreateHistory(ctx context.Context, payment *entity.Payment
 	return nil
 }
 
+// AdvanceByVietQR moves the payment linked to a VietQR code to status, but only from one of the
+// given statuses so a late or repeated QR event cannot reopen a finished payment. It returns nil
+// when no payment qualified.
+func (r *PaymentRepo) AdvanceByVietQR(ctx context.Context, vietqrID string, from []entity.PaymentStatus, status entity.PaymentStatus) (*entity.Payment, error) {
+	sql, args, err := r.Builder.
+		Update("payments").
+		Set("status", status).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"vietqr_id": vietqrID, "status": from}).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("PaymentRepo - AdvanceByVietQR - r.Builder: %w", err)
+	}
+
+	var id int64
+	err = conn(ctx, r.Postgres).QueryRow(ctx, sql, args...).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("PaymentRepo - AdvanceByVietQR - r.Pool.QueryRow: %w", err)
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+// Transition moves a payment to status, but only from one of the given statuses. It returns
+// nil when the payment does not exist or is in another status.
+func (r *PaymentRepo) Transition(ctx context.Context, id int64, from []entity.PaymentStatus, status entity.PaymentStatus) (*entity.Payment, error) {
+	sql, args, err := r.Builder.
+		Update("payments").
+		Set("status", status).
+		Set("updated_at", time.Now()).
+		Where(squirrel.Eq{"id": id, "status": from}).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("PaymentRepo - Transition - r.Builder: %w", err)
+	}
+
+	result, err := conn(ctx, r.Postgres).Exec(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("PaymentRepo - Transition - r.Pool.Exec: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return nil, nil
+	}
+
+	return r.GetByID(ctx, id)
+}
+
 // toEntity converts database model to entity
 func (r *PaymentRepo) toEntity(payment *models.Payment) *entity.Payment {
+	var vietqrID, vietqrStatus string
+	if payment.VietQRID != nil {
+		vietqrID = *payment.VietQRID
+	}
+	if payment.VietQRStatus != nil {
+		vietqrStatus = *payment.VietQRStatus
+	}
+
 	return &entity.Payment{
 		ID:            payment.ID,
 		UserID:        payment.UserID,
@@ -190,5 +256,17 @@ func (r *PaymentRepo) toEntity(payment *models.Payment) *entity.Payment {
 		PaymentMethod: payment.PaymentMethod,
 		CreatedAt:     payment.CreatedAt,
 		UpdatedAt:     payment.UpdatedAt,
+		VietQRID:      vietqrID,
+		VietQRStatus:  entity.VietQRStatus(vietqrStatus),
+	}
+}
+
+// paymentColumns selects a payment joined with its VietQR code as "payments AS p" and "vietqr AS v".
+const paymentColumns = "p.id, p.user_id, p.amount, p.currency, p.payment_type, p.status, p.meter_number, p.customer_code, p.description, p.transaction_id, p.payment_method, p.created_at, p.updated_at, p.vietqr_id, v.status"
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
 	}
+	return &s
 }
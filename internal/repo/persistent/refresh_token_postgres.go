@@ -0,0 +1,147 @@
+package persistent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+)
+
+var refreshTokenColumns = []string{
+	"id", "user_id", "family_id", "token_hash", "access_jti", "access_expires_at",
+	"expires_at", "rotated_at", "revoked_at", "created_at",
+}
+
+// RefreshTokenRepo -.
+type RefreshTokenRepo struct {
+	pg *postgres.Postgres
+}
+
+// NewRefreshTokenRepo -.
+func NewRefreshTokenRepo(pg *postgres.Postgres) *RefreshTokenRepo {
+	return &RefreshTokenRepo{pg}
+}
+
+// Create -.
+func (r *RefreshTokenRepo) Create(ctx context.Context, token entity.RefreshToken) (entity.RefreshToken, error) {
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+
+	sql, args, err := r.pg.Builder.
+		Insert("refresh_tokens").
+		Columns("user_id", "family_id", "token_hash", "access_jti", "access_expires_at", "expires_at", "created_at").
+		Values(token.UserID, token.FamilyID, token.TokenHash, token.AccessJTI, token.AccessExpiresAt, token.ExpiresAt, token.CreatedAt).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo - Create - r.Builder: %w", err)
+	}
+
+	if err := conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(&token.ID); err != nil {
+		return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo - Create - r.Pool.QueryRow: %w", err)
+	}
+
+	return token, nil
+}
+
+// GetByHash -.
+func (r *RefreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (entity.RefreshToken, error) {
+	sql, args, err := r.pg.Builder.
+		Select(refreshTokenColumns...).
+		From("refresh_tokens").
+		Where(squirrel.Eq{"token_hash": tokenHash}).
+		ToSql()
+	if err != nil {
+		return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo - GetByHash - r.Builder: %w", err)
+	}
+
+	token, err := scanRefreshToken(conn(ctx, r.pg).QueryRow(ctx, sql, args...))
+	if err != nil {
+		return entity.RefreshToken{}, fmt.Errorf("RefreshTokenRepo - GetByHash - r.Pool.QueryRow: %w", err)
+	}
+
+	return token, nil
+}
+
+// Rotate marks the token as used. Only one of several concurrent calls for
+// the same token sees true.
+func (r *RefreshTokenRepo) Rotate(ctx context.Context, id int64) (bool, error) {
+	sql, args, err := r.pg.Builder.
+		Update("refresh_tokens").
+		Set("rotated_at", time.Now()).
+		Where(squirrel.Eq{"id": id, "rotated_at": nil, "revoked_at": nil}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("RefreshTokenRepo - Rotate - r.Builder: %w", err)
+	}
+
+	tag, err := conn(ctx, r.pg).Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("RefreshTokenRepo - Rotate - r.Pool.Exec: %w", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// RevokeFamily -.
+func (r *RefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) ([]entity.RefreshToken, error) {
+	tokens, err := r.revoke(ctx, squirrel.Eq{"family_id": familyID, "revoked_at": nil})
+	if err != nil {
+		return nil, fmt.Errorf("RefreshTokenRepo - RevokeFamily - %w", err)
+	}
+
+	return tokens, nil
+}
+
+// RevokeUser -.
+func (r *RefreshTokenRepo) RevokeUser(ctx context.Context, userID int64) ([]entity.RefreshToken, error) {
+	tokens, err := r.revoke(ctx, squirrel.Eq{"user_id": userID, "revoked_at": nil})
+	if err != nil {
+		return nil, fmt.Errorf("RefreshTokenRepo - RevokeUser - %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (r *RefreshTokenRepo) revoke(ctx context.Context, where squirrel.Eq) ([]entity.RefreshToken, error) {
+	sql, args, err := r.pg.Builder.
+		Update("refresh_tokens").
+		Set("revoked_at", time.Now()).
+		Where(where).
+		Suffix("RETURNING " + strings.Join(refreshTokenColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("r.Builder: %w", err)
+	}
+
+	rows, err := conn(ctx, r.pg).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("r.Pool.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []entity.RefreshToken
+	for rows.Next() {
+		token, err := scanRefreshToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("rows.Scan: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func scanRefreshToken(row rowScanner) (entity.RefreshToken, error) {
+	var t entity.RefreshToken
+	err := row.Scan(
+		&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.AccessJTI, &t.AccessExpiresAt,
+		&t.ExpiresAt, &t.RotatedAt, &t.RevokedAt, &t.CreatedAt,
+	)
+	return t, err
+}
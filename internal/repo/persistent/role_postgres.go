@@ -0,0 +1,143 @@
+package persistent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+	"github.com/jackc/pgx/v5"
+)
+
+// RoleRepo -.
+type RoleRepo struct {
+	pg *postgres.Postgres
+}
+
+// NewRoleRepo -.
+func NewRoleRepo(pg *postgres.Postgres) *RoleRepo {
+	return &RoleRepo{pg}
+}
+
+// List returns every role with its permissions, ordered by name.
+func (r *RoleRepo) List(ctx context.Context) ([]entity.Role, error) {
+	sql, args, err := r.pg.Builder.
+		Select("r.id", "r.name", "r.description", "COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')").
+		From("roles AS r").
+		LeftJoin("role_permissions AS rp ON rp.role_id = r.id").
+		LeftJoin("permissions AS p ON p.id = rp.permission_id").
+		GroupBy("r.id", "r.name", "r.description").
+		OrderBy("r.name").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("RoleRepo - List - r.Builder: %w", err)
+	}
+
+	rows, err := conn(ctx, r.pg).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("RoleRepo - List - r.Pool.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []entity.Role
+	for rows.Next() {
+		var role entity.Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.Permissions); err != nil {
+			return nil, fmt.Errorf("RoleRepo - List - rows.Scan: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// UserAccess -.
+func (r *RoleRepo) UserAccess(ctx context.Context, userID int64) (roles, permissions []string, err error) {
+	sql, args, err := r.pg.Builder.
+		Select(
+			"COALESCE(array_agg(DISTINCT r.name) FILTER (WHERE r.name IS NOT NULL), '{}')",
+			"COALESCE(array_agg(DISTINCT p.name) FILTER (WHERE p.name IS NOT NULL), '{}')",
+		).
+		From("user_roles AS ur").
+		Join("roles AS r ON r.id = ur.role_id").
+		LeftJoin("role_permissions AS rp ON rp.role_id = r.id").
+		LeftJoin("permissions AS p ON p.id = rp.permission_id").
+		Where(squirrel.Eq{"ur.user_id": userID}).
+		ToSql()
+	if err != nil {
+		return nil, nil, fmt.Errorf("RoleRepo - UserAccess - r.Builder: %w", err)
+	}
+
+	if err := conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(&roles, &permissions); err != nil {
+		return nil, nil, fmt.Errorf("RoleRepo - UserAccess - r.Pool.QueryRow: %w", err)
+	}
+
+	return roles, permissions, nil
+}
+
+// Assign -.
+func (r *RoleRepo) Assign(ctx context.Context, userID int64, role string) error {
+	exists, err := r.roleExists(ctx, role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("RoleRepo - Assign - role %q: %w", role, pgx.ErrNoRows)
+	}
+
+	sql, args, err := r.pg.Builder.
+		Insert("user_roles").
+		Columns("user_id", "role_id").
+		Values(userID, squirrel.Expr("(SELECT id FROM roles WHERE name = ?)", role)).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("RoleRepo - Assign - r.Builder: %w", err)
+	}
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("RoleRepo - Assign - r.Pool.Exec: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke -.
+func (r *RoleRepo) Revoke(ctx context.Context, userID int64, role string) (bool, error) {
+	sql, args, err := r.pg.Builder.
+		Delete("user_roles").
+		Where(squirrel.Eq{"user_id": userID}).
+		Where("role_id = (SELECT id FROM roles WHERE name = ?)", role).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("RoleRepo - Revoke - r.Builder: %w", err)
+	}
+
+	tag, err := conn(ctx, r.pg).Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("RoleRepo - Revoke - r.Pool.Exec: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *RoleRepo) roleExists(ctx context.Context, role string) (bool, error) {
+	sql, args, err := r.pg.Builder.
+		Select("1").
+		From("roles").
+		Where(squirrel.Eq{"name": role}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("RoleRepo - roleExists - r.Builder: %w", err)
+	}
+
+	var exists bool
+	if err := conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(&exists); err != nil {
+		return false, fmt.Errorf("RoleRepo - roleExists - r.Pool.QueryRow: %w", err)
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,47 @@
+package persistent
+
+import (
+	"context"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/pkg/redis"
+)
+
+const tokenDenylistPrefix = "auth:denylist:"
+
+// TokenDenylistRepo keeps revoked access token IDs in Redis. Keys expire with
+// the token, so the list never grows past the tokens that are still valid.
+type TokenDenylistRepo struct {
+	r *redis.Redis
+}
+
+// NewTokenDenylistRepo -.
+func NewTokenDenylistRepo(r *redis.Redis) *TokenDenylistRepo {
+	return &TokenDenylistRepo{r: r}
+}
+
+// Revoke -.
+func (r *TokenDenylistRepo) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		// Already expired, nothing left to deny
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	return r.r.Client().Set(ctx, tokenDenylistPrefix+jti, 1, ttl).Err()
+}
+
+// IsRevoked -.
+func (r *TokenDenylistRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, _defaultTimeout)
+	defer cancel()
+
+	n, err := r.r.Client().Exists(ctx, tokenDenylistPrefix+jti).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
@@ -0,0 +1,65 @@
+package persistent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type txKey struct{}
+
+// querier is what repos run statements on: the pool, or the transaction in ctx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conn returns the transaction started by Transactor.InTx, or the pool outside one.
+func conn(ctx context.Context, pg *postgres.Postgres) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return pg.Pool
+}
+
+// Transactor runs functions in a database transaction.
+type Transactor struct {
+	pg *postgres.Postgres
+}
+
+// NewTransactor -.
+func NewTransactor(pg *postgres.Postgres) *Transactor {
+	return &Transactor{pg}
+}
+
+// InTx runs fn in a transaction, committed when fn returns nil and rolled back
+// otherwise. Repos given the ctx passed to fn join the transaction; a nested
+// InTx joins the outer one.
+func (t *Transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.pg.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("Transactor - InTx - t.pg.Pool.Begin: %w", err)
+	}
+	defer func() {
+		// A no-op after Commit
+		_ = tx.Rollback(ctx)
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("Transactor - InTx - tx.Commit: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package persistent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+)
+
+// UserIdentityRepo -.
+type UserIdentityRepo struct {
+	pg *postgres.Postgres
+}
+
+// NewUserIdentityRepo -.
+func NewUserIdentityRepo(pg *postgres.Postgres) *UserIdentityRepo {
+	return &UserIdentityRepo{pg}
+}
+
+// GetBySubject -.
+func (r *UserIdentityRepo) GetBySubject(ctx context.Context, issuer, subject string) (entity.UserIdentity, error) {
+	sql, args, err := r.pg.Builder.
+		Select("id", "user_id", "issuer", "subject", "email", "created_at").
+		From("user_identities").
+		Where(squirrel.Eq{"issuer": issuer, "subject": subject}).
+		ToSql()
+	if err != nil {
+		return entity.UserIdentity{}, fmt.Errorf("UserIdentityRepo - GetBySubject - r.Builder: %w", err)
+	}
+
+	var identity entity.UserIdentity
+	err = conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Issuer,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return entity.UserIdentity{}, fmt.Errorf("UserIdentityRepo - GetBySubject - r.Pool.QueryRow: %w", err)
+	}
+
+	return identity, nil
+}
+
+// Create -.
+func (r *UserIdentityRepo) Create(ctx context.Context, identity entity.UserIdentity) (entity.UserIdentity, error) {
+	if identity.CreatedAt.IsZero() {
+		identity.CreatedAt = time.Now()
+	}
+
+	sql, args, err := r.pg.Builder.
+		Insert("user_identities").
+		Columns("user_id", "issuer", "subject", "email", "created_at").
+		Values(identity.UserID, identity.Issuer, identity.Subject, identity.Email, identity.CreatedAt).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return entity.UserIdentity{}, fmt.Errorf("UserIdentityRepo - Create - r.Builder: %w", err)
+	}
+
+	if err := conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(&identity.ID); err != nil {
+		return entity.UserIdentity{}, fmt.Errorf("UserIdentityRepo - Create - r.Pool.QueryRow: %w", err)
+	}
+
+	return identity, nil
+}
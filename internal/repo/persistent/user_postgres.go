@@ -2,15 +2,14 @@ package persistent
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/godev-kit/internal/repo/persistent/models"
 	"github.com/ducnpdev/godev-kit/pkg/postgres"
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -44,7 +43,7 @@ func (r *UserRepo) Create(ctx context.Context, user models.UserModel) (entity.Us
 	}
 
 	var id int64
-	err = r.Pool.QueryRow(ctx, sql, args...).Scan(&id)
+	err = conn(ctx, r.Postgres).QueryRow(ctx, sql, args...).Scan(&id)
 	if err != nil {
 		return entity.User{}, fmt.Errorf("UserRepo - Create - r.Pool.QueryRow: %w", err)
 	}
@@ -61,19 +60,20 @@ func (r *UserRepo) Create(ctx context.Context, user models.UserModel) (entity.Us
 // GetByID -.
 func (r *UserRepo) GetByID(ctx context.Context, id int64) (entity.User, error) {
 	sql, args, err := r.Builder.
-		Select("id, email, username, created_at, updated_at").
+		Select("id, email, username, email_verified_at, created_at, updated_at").
 		From("users").
-		Where("id = ?", id).
+		Where("id = ? AND deleted_at IS NULL", id).
 		ToSql()
 	if err != nil {
 		return entity.User{}, fmt.Errorf("UserRepo - GetByID - r.Builder: %w", err)
 	}
 
 	var user entity.User
-	err = r.Pool.QueryRow(ctx, sql, args...).Scan(
+	err = conn(ctx, r.Postgres).QueryRow(ctx, sql, args...).Scan(
 		&user.ID,
 		&user.Email,
 		&user.Username,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -93,7 +93,10 @@ func (r *UserRepo) Update(ctx context.Context, user models.UserModel) error {
 		Set("updated_at", user.UpdatedAt)
 
 	if user.Email != "" {
-		builder = builder.Set("email", user.Email)
+		// A new address is unverified; the right side sees the old email
+		builder = builder.
+			Set("email", user.Email).
+			Set("email_verified_at", squirrel.Expr("CASE WHEN email = ? THEN email_verified_at END", user.Email))
 	}
 	if user.Username != "" {
 		builder = builder.Set("username", user.Username)
@@ -103,13 +106,13 @@ func (r *UserRepo) Update(ctx context.Context, user models.UserModel) error {
 	}
 
 	sql, args, err := builder.
-		Where("id = ?", user.ID).
+		Where("id = ? AND deleted_at IS NULL", user.ID).
 		ToSql()
 	if err != nil {
 		return fmt.Errorf("UserRepo - Update - r.Builder: %w", err)
 	}
 
-	_, err = r.Pool.Exec(ctx, sql, args...)
+	_, err = conn(ctx, r.Postgres).Exec(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("UserRepo - Update - r.Pool.Exec: %w", err)
 	}
@@ -117,41 +120,122 @@ func (r *UserRepo) Update(ctx context.Context, user models.UserModel) error {
 	return nil
 }
 
-// Delete -.
-func (r *UserRepo) Delete(ctx context.Context, id int64) error {
+// MarkEmailVerified sets email_verified_at unless it is already set.
+func (r *UserRepo) MarkEmailVerified(ctx context.Context, id int64) error {
+	now := time.Now()
+
 	sql, args, err := r.Builder.
-		Delete("users").
-		Where("id = ?", id).
+		Update("users").
+		Set("email_verified_at", now).
+		Set("updated_at", now).
+		Where(squirrel.Eq{"id": id, "email_verified_at": nil}).
 		ToSql()
 	if err != nil {
-		return fmt.Errorf("UserRepo - Delete - r.Builder: %w", err)
+		return fmt.Errorf("UserRepo - MarkEmailVerified - r.Builder: %w", err)
 	}
 
-	_, err = r.Pool.Exec(ctx, sql, args...)
-	if err != nil {
-		return fmt.Errorf("UserRepo - Delete - r.Pool.Exec: %w", err)
+	if _, err := conn(ctx, r.Postgres).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("UserRepo - MarkEmailVerified - r.Pool.Exec: %w", err)
 	}
 
 	return nil
 }
 
-// List -.
-func (r *UserRepo) List(ctx context.Context) ([]entity.User, error) {
-	sql, _, err := r.Builder.
-		Select("id, email, username, created_at, updated_at").
-		From("users").
+// Delete soft deletes the user and reports whether it was active.
+func (r *UserRepo) Delete(ctx context.Context, id int64) (bool, error) {
+	now := time.Now()
+
+	sql, args, err := r.Builder.
+		Update("users").
+		Set("deleted_at", now).
+		Set("updated_at", now).
+		Where(squirrel.Eq{"id": id, "deleted_at": nil}).
 		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("UserRepo - Delete - r.Builder: %w", err)
+	}
+
+	tag, err := conn(ctx, r.Postgres).Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("UserRepo - Delete - r.Pool.Exec: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// Restore undoes a soft delete and reports whether the user was deleted.
+func (r *UserRepo) Restore(ctx context.Context, id int64) (bool, error) {
+	sql, args, err := r.Builder.
+		Update("users").
+		Set("deleted_at", nil).
+		Set("updated_at", time.Now()).
+		Where(squirrel.And{squirrel.Eq{"id": id}, squirrel.NotEq{"deleted_at": nil}}).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("UserRepo - Restore - r.Builder: %w", err)
+	}
+
+	tag, err := conn(ctx, r.Postgres).Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("UserRepo - Restore - r.Pool.Exec: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// List returns a page of users in keyset order: rows after the AfterID user
+// in (sort column, id) order, so pages stay stable while users are added.
+func (r *UserRepo) List(ctx context.Context, q entity.UserQuery) ([]entity.User, error) {
+	column, ok := userSortColumns[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("UserRepo - List: unknown sort %q", q.Sort)
+	}
+
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	builder := r.Builder.
+		Select("id, email, username, email_verified_at, created_at, updated_at, deleted_at").
+		From("users").
+		OrderBy(column+" "+dir, "id "+dir).
+		Limit(q.Limit)
+
+	if q.Deleted {
+		builder = builder.Where(squirrel.NotEq{"deleted_at": nil})
+	} else {
+		builder = builder.Where(squirrel.Eq{"deleted_at": nil})
+	}
+
+	if q.Search != "" {
+		pattern := escapeLike(strings.ToLower(q.Search)) + "%"
+		builder = builder.Where(squirrel.Or{
+			squirrel.Like{"lower(email)": pattern},
+			squirrel.Like{"lower(username)": pattern},
+		})
+	}
+
+	if q.AfterID != 0 {
+		// The cursor row is read without the deleted_at filter, it may have been deleted since
+		builder = builder.Where(
+			fmt.Sprintf("(%[1]s, id) %[2]s (SELECT %[1]s, id FROM users WHERE id = ?)", column, cmp),
+			q.AfterID,
+		)
+	}
+
+	sql, args, err := builder.ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("UserRepo - List - r.Builder: %w", err)
 	}
 
-	rows, err := r.Pool.Query(ctx, sql)
+	rows, err := conn(ctx, r.Postgres).Query(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("UserRepo - List - r.Pool.Query: %w", err)
 	}
 	defer rows.Close()
 
-	users := make([]entity.User, 0, _defaultEntityCap)
+	users := make([]entity.User, 0, q.Limit)
 
 	for rows.Next() {
 		var user entity.User
@@ -159,8 +243,10 @@ func (r *UserRepo) List(ctx context.Context) ([]entity.User, error) {
 			&user.ID,
 			&user.Email,
 			&user.Username,
+			&user.EmailVerifiedAt,
 			&user.CreatedAt,
 			&user.UpdatedAt,
+			&user.DeletedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("UserRepo - List - rows.Scan: %w", err)
@@ -169,33 +255,48 @@ func (r *UserRepo) List(ctx context.Context) ([]entity.User, error) {
 		users = append(users, user)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UserRepo - List - rows.Err: %w", err)
+	}
+
 	return users, nil
 }
 
+// userSortColumns maps entity sort keys to columns; only these reach the SQL.
+var userSortColumns = map[string]string{
+	entity.UserSortID:        "id",
+	entity.UserSortCreatedAt: "created_at",
+	entity.UserSortEmail:     "email",
+	entity.UserSortUsername:  "username",
+}
+
+// escapeLike escapes the LIKE wildcards in s, with backslash as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // GetByEmail -.
 func (r *UserRepo) GetByEmail(ctx context.Context, email string) (entity.User, error) {
 	sql, args, err := r.Builder.
-		Select("id, email, username, password, created_at, updated_at").
+		Select("id, email, username, password, email_verified_at, created_at, updated_at").
 		From("users").
-		Where("email = ?", email).
+		Where("email = ? AND deleted_at IS NULL", email).
 		ToSql()
 	if err != nil {
 		return entity.User{}, fmt.Errorf("UserRepo - GetByEmail - r.Builder: %w", err)
 	}
 
 	var user entity.User
-	err = r.Pool.QueryRow(ctx, sql, args...).Scan(
+	err = conn(ctx, r.Postgres).QueryRow(ctx, sql, args...).Scan(
 		&user.ID,
 		&user.Email,
 		&user.Username,
 		&user.Password,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return entity.User{}, errors.New("user not found")
-		}
 		return entity.User{}, fmt.Errorf("UserRepo - GetByEmail - r.Pool.QueryRow: %w", err)
 	}
 
@@ -0,0 +1,92 @@
+package persistent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/postgres"
+)
+
+var userTokenColumns = []string{"id", "user_id", "purpose", "token_hash", "expires_at", "used_at", "created_at"}
+
+// UserTokenRepo -.
+type UserTokenRepo struct {
+	pg *postgres.Postgres
+}
+
+// NewUserTokenRepo -.
+func NewUserTokenRepo(pg *postgres.Postgres) *UserTokenRepo {
+	return &UserTokenRepo{pg}
+}
+
+// Create -.
+func (r *UserTokenRepo) Create(ctx context.Context, token entity.UserToken) (entity.UserToken, error) {
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+
+	sql, args, err := r.pg.Builder.
+		Insert("user_tokens").
+		Columns("user_id", "purpose", "token_hash", "expires_at", "created_at").
+		Values(token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt, token.CreatedAt).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("UserTokenRepo - Create - r.Builder: %w", err)
+	}
+
+	if err := conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(&token.ID); err != nil {
+		return entity.UserToken{}, fmt.Errorf("UserTokenRepo - Create - r.Pool.QueryRow: %w", err)
+	}
+
+	return token, nil
+}
+
+// Consume uses the token in a single statement, so two concurrent calls with
+// the same token cannot both succeed.
+func (r *UserTokenRepo) Consume(ctx context.Context, purpose, tokenHash string) (entity.UserToken, error) {
+	now := time.Now()
+
+	sql, args, err := r.pg.Builder.
+		Update("user_tokens").
+		Set("used_at", now).
+		Where(squirrel.Eq{"token_hash": tokenHash, "purpose": purpose, "used_at": nil}).
+		Where(squirrel.Gt{"expires_at": now}).
+		Suffix("RETURNING " + strings.Join(userTokenColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("UserTokenRepo - Consume - r.Builder: %w", err)
+	}
+
+	var t entity.UserToken
+	err = conn(ctx, r.pg).QueryRow(ctx, sql, args...).Scan(
+		&t.ID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt,
+	)
+	if err != nil {
+		return entity.UserToken{}, fmt.Errorf("UserTokenRepo - Consume - r.Pool.QueryRow: %w", err)
+	}
+
+	return t, nil
+}
+
+// Invalidate -.
+func (r *UserTokenRepo) Invalidate(ctx context.Context, userID int64, purpose string) error {
+	sql, args, err := r.pg.Builder.
+		Update("user_tokens").
+		Set("used_at", time.Now()).
+		Where(squirrel.Eq{"user_id": userID, "purpose": purpose, "used_at": nil}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("UserTokenRepo - Invalidate - r.Builder: %w", err)
+	}
+
+	if _, err := conn(ctx, r.pg).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("UserTokenRepo - Invalidate - r.Pool.Exec: %w", err)
+	}
+
+	return nil
+}
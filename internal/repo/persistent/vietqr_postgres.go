@@ -2,6 +2,8 @@ package persistent
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/ducnpdev/godev-kit/internal/entity"
@@ -17,48 +19,173 @@ func NewVietQRRepo(pg *postgres.Postgres) *VietQRRepo {
 }
 
 func (r *VietQRRepo) Store(ctx context.Context, qr entity.VietQR) error {
+	var expiresAt *time.Time
+	if !qr.ExpiresAt.IsZero() {
+		expiresAt = &qr.ExpiresAt
+	}
+
 	sql, args, err := r.pg.Builder.
 		Insert("vietqr").
-		Columns("id", "status", "content").
-		Values(qr.ID, qr.Status, qr.Content).
+		Columns("id", "status", "content", "bank_code", "bank_bin", "account_no", "amount", "description", "reference", "created_at", "expires_at").
+		Values(qr.ID, qr.Status, qr.Content, qr.BankCode, qr.BankBIN, qr.AccountNo, qr.Amount, qr.Description, qr.Reference, qr.CreatedAt, expiresAt).
 		ToSql()
 	if err != nil {
 		return err
 	}
 
-	_, err = r.pg.Pool.Exec(ctx, sql, args...)
+	_, err = conn(ctx, r.pg).Exec(ctx, sql, args...)
 	return err
 }
 
+// FindByID returns the code together with the payment it pays, if any.
 func (r *VietQRRepo) FindByID(ctx context.Context, id string) (entity.VietQR, error) {
 	sql, args, err := r.pg.Builder.
-		Select("id", "status", "content").
-		From("vietqr").
-		Where(squirrel.Eq{"id": id}).
+		Select(append(vietqrColumns("v."), "p.id", "p.status")...).
+		From("vietqr AS v").
+		LeftJoin("payments AS p ON p.vietqr_id = v.id").
+		Where(squirrel.Eq{"v.id": id}).
 		ToSql()
 	if err != nil {
 		return entity.VietQR{}, err
 	}
 
-	var qr entity.VietQR
-	err = r.pg.Pool.QueryRow(ctx, sql, args...).Scan(&qr.ID, &qr.Status, &qr.Content)
+	var (
+		paymentID     *int64
+		paymentStatus *string
+	)
+	qr, err := scanVietQR(conn(ctx, r.pg).QueryRow(ctx, sql, args...), &paymentID, &paymentStatus)
 	if err != nil {
 		return entity.VietQR{}, err
 	}
+	if paymentID != nil {
+		qr.PaymentID = *paymentID
+	}
+	if paymentStatus != nil {
+		qr.PaymentStatus = entity.PaymentStatus(*paymentStatus)
+	}
 
 	return qr, nil
 }
 
-func (r *VietQRRepo) UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) error {
+// FindOpenByReference returns the generated or in-process code carrying the reference.
+func (r *VietQRRepo) FindOpenByReference(ctx context.Context, reference string) (entity.VietQR, error) {
+	return r.findOne(ctx, squirrel.Eq{
+		"reference": reference,
+		"status":    []entity.VietQRStatus{entity.VietQRStatusGenerated, entity.VietQRStatusInProcess},
+	})
+}
+
+func (r *VietQRRepo) findOne(ctx context.Context, where squirrel.Sqlizer) (entity.VietQR, error) {
 	sql, args, err := r.pg.Builder.
-		Update("vietqr").
+		Select(vietqrColumns("")...).
+		From("vietqr").
+		Where(where).
+		ToSql()
+	if err != nil {
+		return entity.VietQR{}, err
+	}
+
+	return scanVietQR(conn(ctx, r.pg).QueryRow(ctx, sql, args...))
+}
+
+// UpdateStatus sets the status and returns the code as it was before the update,
+// so callers can tell which transition happened without a racy read.
+func (r *VietQRRepo) UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) (entity.VietQR, error) {
+	sql, args, err := r.transition(status, squirrel.Eq{"id": id})
+	if err != nil {
+		return entity.VietQR{}, err
+	}
+
+	return scanVietQR(conn(ctx, r.pg).QueryRow(ctx, sql, args...))
+}
+
+// PayOpen moves the code to paid only while it is open and not expired at now, and returns
+// it as it was before the update. Concurrent calls for one code pay it once: the row lock
+// makes the later update re-check the status and match nothing, which is pgx.ErrNoRows.
+func (r *VietQRRepo) PayOpen(ctx context.Context, id string, now time.Time) (entity.VietQR, error) {
+	sql, args, err := r.transition(entity.VietQRStatusPaid, squirrel.And{
+		squirrel.Eq{"id": id},
+		squirrel.Eq{"status": []entity.VietQRStatus{entity.VietQRStatusGenerated, entity.VietQRStatusInProcess}},
+		squirrel.Or{squirrel.Eq{"expires_at": nil}, squirrel.Gt{"expires_at": now}},
+	})
+	if err != nil {
+		return entity.VietQR{}, err
+	}
+
+	return scanVietQR(conn(ctx, r.pg).QueryRow(ctx, sql, args...))
+}
+
+// ExpireBefore moves open codes whose expiry is at or before now to timeout and returns them
+// as they were before the update.
+func (r *VietQRRepo) ExpireBefore(ctx context.Context, now time.Time) ([]entity.VietQR, error) {
+	sql, args, err := r.transition(entity.VietQRStatusTimeout, squirrel.And{
+		squirrel.Eq{"status": []entity.VietQRStatus{entity.VietQRStatusGenerated, entity.VietQRStatusInProcess}},
+		squirrel.LtOrEq{"expires_at": now},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, r.pg).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expired []entity.VietQR
+	for rows.Next() {
+		qr, err := scanVietQR(rows)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, qr)
+	}
+
+	return expired, rows.Err()
+}
+
+// transition builds an UPDATE that locks the matching rows first and returns their previous status.
+func (r *VietQRRepo) transition(status entity.VietQRStatus, where squirrel.Sqlizer) (string, []interface{}, error) {
+	old := squirrel.Select("id", "status").
+		From("vietqr").
+		Where(where).
+		Suffix("FOR UPDATE")
+
+	cols := vietqrColumns("v.")
+	cols[1] = "old.status"
+
+	return r.pg.Builder.
+		Update("vietqr AS v").
 		Set("status", status).
-		Where(squirrel.Eq{"id": id}).
+		FromSelect(old, "old").
+		Where("v.id = old.id").
+		Suffix("RETURNING " + strings.Join(cols, ", ")).
 		ToSql()
+}
+
+// vietqrColumns lists the columns scanVietQR reads, in order.
+func vietqrColumns(prefix string) []string {
+	cols := []string{"id", "status", "content", "bank_code", "bank_bin", "account_no", "amount", "description", "reference", "created_at", "expires_at"}
+	for i, col := range cols {
+		cols[i] = prefix + col
+	}
+	return cols
+}
+
+// scanVietQR reads the vietqrColumns, followed by any extra destinations.
+func scanVietQR(row rowScanner, extra ...any) (entity.VietQR, error) {
+	var (
+		qr        entity.VietQR
+		expiresAt *time.Time
+	)
+	dest := append([]any{&qr.ID, &qr.Status, &qr.Content, &qr.BankCode, &qr.BankBIN, &qr.AccountNo, &qr.Amount, &qr.Description, &qr.Reference, &qr.CreatedAt, &expiresAt}, extra...)
+	err := row.Scan(dest...)
 	if err != nil {
-		return err
+		return entity.VietQR{}, err
+	}
+	if expiresAt != nil {
+		qr.ExpiresAt = *expiresAt
 	}
 
-	_, err = r.pg.Pool.Exec(ctx, sql, args...)
-	return err
+	return qr, nil
 }
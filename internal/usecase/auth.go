@@ -0,0 +1,11 @@
+package usecase
+
+import "errors"
+
+// Authentication errors. The user use case returns them and the transports
+// map them to responses without depending on it.
+var (
+	ErrInvalidAccessToken   = errors.New("invalid access token")
+	ErrAPIKeysNotConfigured = errors.New("api keys are not configured")
+	ErrInvalidAPIKey        = errors.New("invalid api key")
+)
@@ -1,6 +1,8 @@
 package billing
 
 import (
+	"io"
+
 	"github.com/signintech/gopdf"
 )
 
@@ -43,7 +45,33 @@ func New() *UseCase {
 
 // GenerateInvoicePDF generates a PDF invoice and returns the file path
 func (uc *UseCase) GenerateInvoicePDF(data InvoiceData, outputPath string) error {
-	pdf := gopdf.GoPdf{}
+	pdf, err := buildInvoicePDF(data)
+	if err != nil {
+		return err
+	}
+
+	if err := pdf.WritePdf(outputPath); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteInvoicePDF renders a PDF invoice into w without touching the disk
+func (uc *UseCase) WriteInvoicePDF(data InvoiceData, w io.Writer) error {
+	pdf, err := buildInvoicePDF(data)
+	if err != nil {
+		return err
+	}
+
+	if err := pdf.Write(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+// buildInvoicePDF lays out the invoice pages for data
+func buildInvoicePDF(data InvoiceData) (*gopdf.GoPdf, error) {
+	pdf := &gopdf.GoPdf{}
 	mm6ToPx := 22.68
 
 	pdf.Start(gopdf.Config{
@@ -57,21 +85,18 @@ func (uc *UseCase) GenerateInvoicePDF(data InvoiceData, outputPath string) error
 	pdf.AddPageWithOption(opt)
 
 	if err := pdf.AddTTFFont("roboto", "./docs/front/Roboto-Regular.ttf"); err != nil {
-		return err
+		return nil, err
 	}
 	if err := pdf.AddTTFFont("roboto-bold", "./docs/front/Roboto-Regular.ttf"); err != nil {
-		return err
+		return nil, err
 	}
 
-	headerBottomY := drawHeader(&pdf, data)
-	tableBottomY := drawTable(&pdf, data.Items, headerBottomY)
-	summaryBottomY := drawSummary(&pdf, data, tableBottomY)
-	drawFooter(&pdf, data, summaryBottomY)
+	headerBottomY := drawHeader(pdf, data)
+	tableBottomY := drawTable(pdf, data.Items, headerBottomY)
+	summaryBottomY := drawSummary(pdf, data, tableBottomY)
+	drawFooter(pdf, data, summaryBottomY)
 
-	if err := pdf.WritePdf(outputPath); err != nil {
-		return err
-	}
-	return nil
+	return pdf, nil
 }
 
 // --- PDF Drawing Functions (copied from main.go, made unexported) ---
@@ -3,6 +3,8 @@ package usecase
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/godev-kit/internal/usecase/billing"
@@ -27,12 +29,73 @@ type (
 		GetByID(ctx context.Context, id int64) (entity.User, error)
 		// Update updates user
 		Update(ctx context.Context, user entity.User) error
-		// Delete deletes user
+		// Delete soft deletes user and ends their sessions
 		Delete(ctx context.Context, id int64) error
-		// List gets all users
-		List(ctx context.Context) (entity.UserHistory, error)
-		// Login authenticates a user and returns a JWT token
-		Login(ctx context.Context, email, password string) (string, entity.User, error)
+		// Restore undoes a soft delete
+		Restore(ctx context.Context, id int64) error
+		// List gets a page of users; cursor is the previous page's NextCursor
+		List(ctx context.Context, query entity.UserQuery, cursor string) (entity.UserHistory, error)
+		// Login authenticates a user and returns an access and refresh token
+		Login(ctx context.Context, email, password, clientIP string) (entity.AuthTokens, entity.User, error)
+		// Refresh rotates a refresh token into a new token pair
+		Refresh(ctx context.Context, refreshToken string) (entity.AuthTokens, error)
+		// Logout revokes the caller's access token and the refresh token family
+		Logout(ctx context.Context, refreshToken string) error
+		// ForgotPassword mails a password reset link
+		ForgotPassword(ctx context.Context, email, clientIP string) error
+		// ResetPassword sets a new password with a mailed reset token
+		ResetPassword(ctx context.Context, token, password string) error
+		// VerifyEmail confirms the email address with a mailed token
+		VerifyEmail(ctx context.Context, token string) error
+		// ResendVerification mails a new email verification link
+		ResendVerification(ctx context.Context, email, clientIP string) error
+		// CompleteMFALogin exchanges a login MFA challenge and a code for the token pair
+		CompleteMFALogin(ctx context.Context, mfaToken, code, clientIP string) (entity.AuthTokens, entity.User, error)
+		// EnrollTOTP starts enrolling an authenticator app for the caller
+		EnrollTOTP(ctx context.Context) (entity.TOTPEnrollment, error)
+		// ConfirmTOTP enables the enrollment and returns recovery codes
+		ConfirmTOTP(ctx context.Context, code string) ([]string, error)
+		// DisableTOTP turns two-factor authentication off
+		DisableTOTP(ctx context.Context, code string) error
+		// RegenerateRecoveryCodes replaces the caller's recovery codes
+		RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error)
+		// UnlockUser lifts a login lockout of the user's account
+		UnlockUser(ctx context.Context, id int64) error
+		// StartOIDCLogin returns the identity provider URL that starts a login and its state
+		StartOIDCLogin(ctx context.Context) (entity.OIDCLoginRedirect, error)
+		// CompleteOIDCLogin handles the identity provider callback and returns the token pair
+		CompleteOIDCLogin(ctx context.Context, state, code string) (entity.AuthTokens, entity.User, error)
+	}
+
+	// Roles -.
+	Roles interface {
+		// ListRoles returns every role with its permissions
+		ListRoles(ctx context.Context) ([]entity.Role, error)
+		// UserRoles returns a user's roles and the permissions they grant
+		UserRoles(ctx context.Context, userID int64) (roles, permissions []string, err error)
+		// AssignRole grants a role to a user
+		AssignRole(ctx context.Context, userID int64, role string) error
+		// RevokeRole removes a role from a user
+		RevokeRole(ctx context.Context, userID int64, role string) error
+	}
+
+	// APIKeys manages API keys for machine clients.
+	APIKeys interface {
+		// CreateAPIKey creates a key acting as userID; the key is only returned here
+		CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string, expiresAt *time.Time) (entity.CreatedAPIKey, error)
+		// ListAPIKeys returns the keys of userID, or every key when userID is 0
+		ListAPIKeys(ctx context.Context, userID int64) ([]entity.APIKey, error)
+		// RevokeAPIKey revokes a key
+		RevokeAPIKey(ctx context.Context, id int64) error
+		// AuthenticateAPIKey resolves a key to the principal it acts as
+		AuthenticateAPIKey(ctx context.Context, key string) (entity.Principal, error)
+	}
+
+	// TokenParser verifies access tokens for the transports.
+	TokenParser interface {
+		// ParseAccessToken returns the principal of a valid access token, or an
+		// error wrapping ErrInvalidAccessToken
+		ParseAccessToken(token string) (entity.Principal, error)
 	}
 
 	// Redis -.
@@ -54,11 +117,22 @@ type (
 		GenerateQR(ctx context.Context, req entity.VietQRGenerateRequest) (*entity.VietQR, error)
 		InquiryQR(ctx context.Context, id string) (*entity.VietQR, error)
 		UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) error
+		WatchStatus(ctx context.Context, id string) (events <-chan entity.VietQRStatusChangedEvent, cancel func(), err error)
+		RenderQR(ctx context.Context, req entity.VietQRImageRequest) (*entity.VietQRImage, error)
+		DecodeQR(ctx context.Context, content string) (*entity.VietQRPayload, error)
+		ListBanks(ctx context.Context, query string) ([]entity.Bank, error)
+	}
+
+	// BankNotification -.
+	BankNotification interface {
+		Ingest(ctx context.Context, txn entity.BankTransaction) (entity.BankTransaction, error)
+		ListUnmatched(ctx context.Context, limit uint64) ([]entity.BankTransaction, error)
 	}
 
 	// Billing -.
 	Billing interface {
 		GenerateInvoicePDF(data billing.InvoiceData, outputPath string) error
+		WriteInvoicePDF(data billing.InvoiceData, w io.Writer) error
 	}
 
 	// ShipperLocation -.
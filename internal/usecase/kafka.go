@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
 )
 
 type Kafka interface {
@@ -18,6 +19,13 @@ type Kafka interface {
 	DisableConsumer()
 	IsConsumerEnabled() bool
 	GetStatus() map[string]interface{}
+
+	// Consumer lifecycle, by topic and group
+	PauseConsumer(topic, group string) error
+	ResumeConsumer(topic, group string) error
+	StopConsumer(topic, group string) error
+	RestartConsumer(topic, group string) error
+	ConsumerStatuses() []kafka.ConsumerStatus
 }
 
 type kafkaUseCase struct {
@@ -78,12 +86,12 @@ func (u *kafkaUseCase) ConsumeMessage(ctx context.Context, topic, group string)
 	defer cancel()
 
 	// Add a temporary consumer
-	err := u.kafkaRepo.AddConsumer(topic, group, func(ctx context.Context, key, value []byte) error {
+	err := u.kafkaRepo.AddConsumer(topic, group, func(ctx context.Context, msg kafka.Message) error {
 		select {
 		case msgCh <- struct {
 			key   string
 			value []byte
-		}{key: string(key), value: value}:
+		}{key: string(msg.Key), value: msg.Value}:
 			cancel() // Stop the consumer after the first message
 		default:
 		}
@@ -95,7 +103,7 @@ func (u *kafkaUseCase) ConsumeMessage(ctx context.Context, topic, group string)
 
 	// Start the consumer in a goroutine
 	go func() {
-		if err := u.kafkaRepo.StartConsumer(ctx, topic); err != nil {
+		if err := u.kafkaRepo.StartConsumer(ctx, topic, group); err != nil {
 			errCh <- err
 		}
 	}()
@@ -146,3 +154,28 @@ func (u *kafkaUseCase) IsConsumerEnabled() bool {
 func (u *kafkaUseCase) GetStatus() map[string]interface{} {
 	return u.kafkaRepo.GetStatus()
 }
+
+// PauseConsumer -.
+func (u *kafkaUseCase) PauseConsumer(topic, group string) error {
+	return u.kafkaRepo.PauseConsumer(topic, group)
+}
+
+// ResumeConsumer -.
+func (u *kafkaUseCase) ResumeConsumer(topic, group string) error {
+	return u.kafkaRepo.ResumeConsumer(topic, group)
+}
+
+// StopConsumer -.
+func (u *kafkaUseCase) StopConsumer(topic, group string) error {
+	return u.kafkaRepo.StopConsumer(topic, group)
+}
+
+// RestartConsumer -.
+func (u *kafkaUseCase) RestartConsumer(topic, group string) error {
+	return u.kafkaRepo.RestartConsumer(topic, group)
+}
+
+// ConsumerStatuses -.
+func (u *kafkaUseCase) ConsumerStatuses() []kafka.ConsumerStatus {
+	return u.kafkaRepo.ConsumerStatuses()
+}
@@ -2,28 +2,80 @@ package usecase
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
 	"github.com/rs/zerolog"
 )
 
+// Default event topics.
+const (
+	DefaultUserEventsTopic        = "user-events"
+	DefaultTranslationEventsTopic = "translation-events"
+)
+
 // KafkaEventUseCase -.
 type KafkaEventUseCase struct {
 	kafkaRepo repo.KafkaRepo
 	logger    zerolog.Logger
+
+	userTopic        string
+	translationTopic string
+	userGroup        string
+	translationGroup string
+	// events decodes the JSON the events are produced as
+	events *kafka.Serializer
+}
+
+// KafkaEventOption configures the Kafka event use case.
+type KafkaEventOption func(*KafkaEventUseCase)
+
+// EventTopics sets the topics user and translation events are produced to
+// and consumed from. Empty keeps the default.
+func EventTopics(userEvents, translationEvents string) KafkaEventOption {
+	return func(k *KafkaEventUseCase) {
+		if userEvents != "" {
+			k.userTopic = userEvents
+		}
+		if translationEvents != "" {
+			k.translationTopic = translationEvents
+		}
+	}
+}
+
+// ConsumerGroup names the consumer groups after groupID, one per topic:
+// "<groupID>-user-events" and "<groupID>-translation-events".
+func ConsumerGroup(groupID string) KafkaEventOption {
+	return func(k *KafkaEventUseCase) {
+		if groupID != "" {
+			k.userGroup = groupID + "-user-events"
+			k.translationGroup = groupID + "-translation-events"
+		}
+	}
 }
 
 // NewKafkaEventUseCase -.
-func NewKafkaEventUseCase(kafkaRepo repo.KafkaRepo, logger zerolog.Logger) *KafkaEventUseCase {
-	return &KafkaEventUseCase{
-		kafkaRepo: kafkaRepo,
-		logger:    logger,
+func NewKafkaEventUseCase(kafkaRepo repo.KafkaRepo, logger zerolog.Logger, opts ...KafkaEventOption) *KafkaEventUseCase {
+	k := &KafkaEventUseCase{
+		kafkaRepo:        kafkaRepo,
+		logger:           logger,
+		userTopic:        DefaultUserEventsTopic,
+		translationTopic: DefaultTranslationEventsTopic,
+		userGroup:        "user-events-consumer",
+		translationGroup: "translation-events-consumer",
+		events:           kafka.NewSerializer(kafka.JSON{}),
 	}
+
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	return k
 }
 
 // ProduceUserEvent -.
@@ -39,7 +91,7 @@ func (k *KafkaEventUseCase) ProduceUserEvent(ctx context.Context, eventType stri
 
 	key := []byte(strconv.FormatInt(userID, 10))
 
-	err := k.kafkaRepo.SendMessage(ctx, "user-events", key, event)
+	err := k.kafkaRepo.SendMessage(ctx, k.userTopic, key, event, kafka.MessageHeader(kafka.HeaderEventType, eventType))
 	if err != nil {
 		return fmt.Errorf("failed to send user event: %w", err)
 	}
@@ -52,6 +104,21 @@ func (k *KafkaEventUseCase) ProduceUserEvent(ctx context.Context, eventType stri
 	return nil
 }
 
+// PublishUserEvent sends a user event when the producer is enabled. Delivery is
+// best effort: failures are logged, not returned.
+func (k *KafkaEventUseCase) PublishUserEvent(ctx context.Context, eventType string, userID int64, email string, data any) {
+	if !k.kafkaRepo.IsProducerEnabled() {
+		return
+	}
+
+	if err := k.ProduceUserEvent(ctx, eventType, userID, email, data); err != nil {
+		k.logger.Error().Err(err).
+			Str("event_type", eventType).
+			Int64("user_id", userID).
+			Msg("user event not produced")
+	}
+}
+
 // ProduceTranslationEvent -.
 func (k *KafkaEventUseCase) ProduceTranslationEvent(ctx context.Context, eventType string, userID int64, source, target, original, translated string) error {
 	event := entity.TranslationEvent{
@@ -67,7 +134,7 @@ func (k *KafkaEventUseCase) ProduceTranslationEvent(ctx context.Context, eventTy
 
 	key := []byte(fmt.Sprintf("%d-%s", userID, eventType))
 
-	err := k.kafkaRepo.SendMessage(ctx, "translation-events", key, event)
+	err := k.kafkaRepo.SendMessage(ctx, k.translationTopic, key, event, kafka.MessageHeader(kafka.HeaderEventType, eventType))
 	if err != nil {
 		return fmt.Errorf("failed to send translation event: %w", err)
 	}
@@ -84,16 +151,12 @@ func (k *KafkaEventUseCase) ProduceTranslationEvent(ctx context.Context, eventTy
 
 // ConsumeUserEvents -.
 func (k *KafkaEventUseCase) ConsumeUserEvents(ctx context.Context) error {
-	handler := func(ctx context.Context, key, value []byte) error {
-		var event entity.UserEvent
-		if err := json.Unmarshal(value, &event); err != nil {
-			return fmt.Errorf("failed to unmarshal user event: %w", err)
-		}
-
+	handler := kafka.Subscribe(k.events, func(ctx context.Context, _ kafka.Message, event entity.UserEvent) error {
 		k.logger.Info().
 			Str("event_type", event.EventType).
 			Int64("user_id", event.UserID).
 			Str("email", event.Email).
+			Str("request_id", tracectx.RequestID(ctx)).
 			Msg("user event consumed")
 
 		// Here you can add business logic to handle different event types
@@ -104,27 +167,27 @@ func (k *KafkaEventUseCase) ConsumeUserEvents(ctx context.Context) error {
 			k.handleUserUpdated(ctx, event)
 		case entity.UserDeletedEvent:
 			k.handleUserDeleted(ctx, event)
+		case entity.UserRestoredEvent:
+			// Already logged above
+		case entity.UserLoginFailedEvent, entity.UserLockedEvent:
+			// Already logged above; security tooling consumes these
 		default:
 			k.logger.Warn().Str("event_type", event.EventType).Msg("unknown user event type")
 		}
 
 		return nil
-	}
+	})
 
-	return k.kafkaRepo.AddConsumer("user-events", "user-events-consumer", handler)
+	return k.kafkaRepo.AddConsumer(k.userTopic, k.userGroup, handler)
 }
 
 // ConsumeTranslationEvents -.
 func (k *KafkaEventUseCase) ConsumeTranslationEvents(ctx context.Context) error {
-	handler := func(ctx context.Context, key, value []byte) error {
-		var event entity.TranslationEvent
-		if err := json.Unmarshal(value, &event); err != nil {
-			return fmt.Errorf("failed to unmarshal translation event: %w", err)
-		}
-
+	handler := kafka.Subscribe(k.events, func(ctx context.Context, _ kafka.Message, event entity.TranslationEvent) error {
 		k.logger.Info().
 			Str("event_type", event.EventType).
 			Int64("user_id", event.UserID).
+			Str("request_id", tracectx.RequestID(ctx)).
 			Str("source", event.Source).
 			Str("target", event.Target).
 			Msg("translation event consumed")
@@ -140,9 +203,9 @@ func (k *KafkaEventUseCase) ConsumeTranslationEvents(ctx context.Context) error
 		}
 
 		return nil
-	}
+	})
 
-	return k.kafkaRepo.AddConsumer("translation-events", "translation-events-consumer", handler)
+	return k.kafkaRepo.AddConsumer(k.translationTopic, k.translationGroup, handler)
 }
 
 // handleUserCreated -.
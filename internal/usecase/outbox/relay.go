@@ -0,0 +1,149 @@
+// Package outbox sends the events use cases write to the outbox table.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+)
+
+// Relay defaults.
+const (
+	DefaultPollInterval = time.Second
+	DefaultBatchSize    = 100
+)
+
+// Sender delivers messages to Kafka, e.g. a repo.KafkaRepo.
+type Sender interface {
+	SendMessage(ctx context.Context, topic string, key []byte, value interface{}, opts ...kafka.MessageOption) error
+	IsProducerEnabled() bool
+}
+
+// Relay moves events from the outbox to Kafka. Delivery is at least once: an
+// event sent just before a failed commit is sent again, so consumers should
+// ignore event IDs they have seen.
+//
+// Only one relay sends at a time: each batch first takes the outbox lock and
+// is skipped when another instance holds it. Events of a key are then sent in
+// order, as no other relay can send a later one while an earlier one waits.
+type Relay struct {
+	tx        repo.Transactor
+	outbox    repo.OutboxRepo
+	sender    Sender
+	interval  time.Duration
+	batchSize uint64
+	l         logger.Interface
+}
+
+// NewRelay creates a new relay. Non-positive values use DefaultPollInterval and DefaultBatchSize.
+func NewRelay(tx repo.Transactor, outbox repo.OutboxRepo, sender Sender, interval time.Duration, batchSize int, l logger.Interface) *Relay {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Relay{
+		tx:        tx,
+		outbox:    outbox,
+		sender:    sender,
+		interval:  interval,
+		batchSize: uint64(batchSize),
+		l:         l,
+	}
+}
+
+// Start polls the outbox on every tick until ctx is cancelled. Events wait in
+// the outbox while the producer is disabled.
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.drain(ctx)
+		}
+	}
+}
+
+// drain sends batches until the outbox is empty or a send fails.
+func (r *Relay) drain(ctx context.Context) {
+	for r.sender.IsProducerEnabled() && ctx.Err() == nil {
+		sent, err := r.relay(ctx)
+		if err != nil {
+			r.l.Error(fmt.Errorf("outbox - Relay - %w", err))
+			return
+		}
+		if sent < r.batchSize {
+			return
+		}
+	}
+}
+
+// relay sends one batch and returns how many events were sent. The batch stops
+// at the first failure so later events of the same key are not sent before it.
+func (r *Relay) relay(ctx context.Context) (uint64, error) {
+	var (
+		sent    uint64
+		sendErr error
+	)
+
+	err := r.tx.InTx(ctx, func(ctx context.Context) error {
+		locked, err := r.outbox.TryLock(ctx)
+		if err != nil {
+			return fmt.Errorf("r.outbox.TryLock: %w", err)
+		}
+		if !locked {
+			// Another relay is sending
+			return nil
+		}
+
+		events, err := r.outbox.Pending(ctx, r.batchSize)
+		if err != nil {
+			return fmt.Errorf("r.outbox.Pending: %w", err)
+		}
+
+		ids := make([]int64, 0, len(events))
+		for _, event := range events {
+			if err := r.sender.SendMessage(ctx, event.Topic, []byte(event.Key), event.Payload, headers(event)...); err != nil {
+				sendErr = fmt.Errorf("r.sender.SendMessage event %d: %w", event.ID, err)
+				if err := r.outbox.MarkFailed(ctx, event.ID, err.Error()); err != nil {
+					return fmt.Errorf("r.outbox.MarkFailed: %w", err)
+				}
+				break
+			}
+			ids = append(ids, event.ID)
+		}
+
+		if err := r.outbox.MarkPublished(ctx, ids); err != nil {
+			return fmt.Errorf("r.outbox.MarkPublished: %w", err)
+		}
+		sent = uint64(len(ids))
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return sent, sendErr
+}
+
+// headers are the Kafka headers of event, in a stable order.
+func headers(event entity.OutboxEvent) []kafka.MessageOption {
+	opts := make([]kafka.MessageOption, 0, len(event.Headers))
+	for _, key := range slices.Sorted(maps.Keys(event.Headers)) {
+		opts = append(opts, kafka.MessageHeader(key, event.Headers[key]))
+	}
+	return opts
+}
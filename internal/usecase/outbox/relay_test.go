@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type fakeOutboxRepo struct {
+	events []entity.OutboxEvent
+	// heldElsewhere simulates another relay holding the lock
+	heldElsewhere bool
+}
+
+func (f *fakeOutboxRepo) TryLock(context.Context) (bool, error) {
+	return !f.heldElsewhere, nil
+}
+
+func (f *fakeOutboxRepo) NextID(context.Context) (int64, error) {
+	return int64(len(f.events) + 1), nil
+}
+
+func (f *fakeOutboxRepo) Add(_ context.Context, event entity.OutboxEvent) error {
+	event.ID = int64(len(f.events) + 1)
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeOutboxRepo) Pending(_ context.Context, limit uint64) ([]entity.OutboxEvent, error) {
+	var pending []entity.OutboxEvent
+	for _, e := range f.events {
+		if e.PublishedAt == nil && uint64(len(pending)) < limit {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeOutboxRepo) MarkPublished(_ context.Context, ids []int64) error {
+	now := time.Now()
+	for _, id := range ids {
+		f.events[id-1].PublishedAt = &now
+		f.events[id-1].Attempts++
+	}
+	return nil
+}
+
+func (f *fakeOutboxRepo) MarkFailed(_ context.Context, id int64, reason string) error {
+	f.events[id-1].Attempts++
+	f.events[id-1].LastError = reason
+	return nil
+}
+
+type fakeSender struct {
+	disabled bool
+	failKey  string
+	sent     []string
+	headers  []kafkago.Header
+}
+
+func (f *fakeSender) SendMessage(_ context.Context, _ string, key []byte, _ interface{}, opts ...kafka.MessageOption) error {
+	if string(key) == f.failKey {
+		return errors.New("broker unavailable")
+	}
+	f.sent = append(f.sent, string(key))
+
+	var m kafkago.Message
+	for _, opt := range opts {
+		opt(&m)
+	}
+	f.headers = append(f.headers, m.Headers...)
+	return nil
+}
+
+func (f *fakeSender) IsProducerEnabled() bool { return !f.disabled }
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	outbox := &fakeOutboxRepo{}
+	for _, key := range []string{"1", "2", "3", "4", "5"} {
+		require.NoError(t, outbox.Add(ctx, entity.OutboxEvent{Topic: "user-events", Key: key, Payload: []byte(`{}`)}))
+	}
+	outbox.events[0].Headers = map[string]string{kafka.HeaderEventType: "user.created", "x-request-id": "req-1"}
+	sender := &fakeSender{}
+	relay := NewRelay(fakeTransactor{}, outbox, sender, time.Minute, 2, logger.New("error"))
+
+	t.Run("waits while the producer is disabled", func(t *testing.T) {
+		sender.disabled = true
+		defer func() { sender.disabled = false }()
+
+		relay.drain(ctx)
+		assert.Empty(t, sender.sent)
+	})
+
+	t.Run("skips while another relay holds the lock", func(t *testing.T) {
+		outbox.heldElsewhere = true
+		defer func() { outbox.heldElsewhere = false }()
+
+		relay.drain(ctx)
+		assert.Empty(t, sender.sent)
+	})
+
+	t.Run("stops at a failed event", func(t *testing.T) {
+		sender.failKey = "4"
+		relay.drain(ctx)
+
+		assert.Equal(t, []string{"1", "2", "3"}, sender.sent)
+		assert.Equal(t, []kafkago.Header{
+			{Key: kafka.HeaderEventType, Value: []byte("user.created")},
+			{Key: "x-request-id", Value: []byte("req-1")},
+		}, sender.headers, "headers are sent with their event")
+		assert.Nil(t, outbox.events[3].PublishedAt)
+		assert.Equal(t, "broker unavailable", outbox.events[3].LastError)
+		assert.Nil(t, outbox.events[4].PublishedAt, "later events wait for the failed one")
+	})
+
+	t.Run("retries on the next tick", func(t *testing.T) {
+		sender.failKey = ""
+		relay.drain(ctx)
+
+		assert.Equal(t, []string{"1", "2", "3", "4", "5"}, sender.sent)
+		assert.Equal(t, 2, outbox.events[3].Attempts)
+		for _, e := range outbox.events {
+			assert.NotNil(t, e.PublishedAt)
+		}
+	})
+}
@@ -2,14 +2,21 @@ package payment
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/godev-kit/pkg/kafka"
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
 	"github.com/rs/zerolog"
 )
 
+const (
+	// PaymentEventsTopic carries the events of registered payments.
+	PaymentEventsTopic = "payment-events"
+	// DeadLetterTopic receives payment events that keep failing to process.
+	DeadLetterTopic = "payment-events.dlq"
+)
+
 // PaymentConsumer represents payment Kafka consumer
 type PaymentConsumer struct {
 	consumer *kafka.Consumer
@@ -17,22 +24,17 @@ type PaymentConsumer struct {
 	logger   *zerolog.Logger
 }
 
-// NewPaymentConsumer creates new payment consumer
-func NewPaymentConsumer(brokers []string, groupID string, useCase *PaymentUseCase, logger *zerolog.Logger) *PaymentConsumer {
-	handler := func(ctx context.Context, key, value []byte) error {
-		pc := &PaymentConsumer{
-			useCase: useCase,
-			logger:  logger,
-		}
-		return pc.handlePaymentEvent(ctx, key, value)
+// NewPaymentConsumer creates new payment consumer. A payment event is committed
+// once ProcessPayment succeeded; pass kafka.DeadLetter to park events that keep failing.
+func NewPaymentConsumer(brokers []string, groupID string, useCase *PaymentUseCase, logger *zerolog.Logger, opts ...kafka.ConsumerOption) *PaymentConsumer {
+	pc := &PaymentConsumer{
+		useCase: useCase,
+		logger:  logger,
 	}
+	handler := kafka.Subscribe(useCase.serializer, pc.handlePaymentEvent)
 
-	consumer := kafka.NewConsumer(brokers, "payment-events", groupID, handler, *logger)
-	return &PaymentConsumer{
-		consumer: consumer,
-		useCase:  useCase,
-		logger:   logger,
-	}
+	pc.consumer = kafka.NewConsumer(brokers, PaymentEventsTopic, groupID, handler, *logger, opts...)
+	return pc
 }
 
 // Start starts the payment consumer
@@ -41,23 +43,19 @@ func (pc *PaymentConsumer) Start(ctx context.Context) error {
 	return pc.consumer.Start(ctx)
 }
 
-// handlePaymentEvent handles payment events from Kafka
-func (pc *PaymentConsumer) handlePaymentEvent(ctx context.Context, key, value []byte) error {
+// handlePaymentEvent handles payment events from Kafka. Events that do not
+// decode never get here; kafka.Subscribe reports them as permanent errors.
+func (pc *PaymentConsumer) handlePaymentEvent(ctx context.Context, msg kafka.Message, paymentEvent entity.PaymentEvent) error {
 	pc.logger.Info().
-		Str("key", string(key)).
-		Str("value", string(value)).
+		Str("key", string(msg.Key)).
+		Int("partition", msg.Partition).
+		Int64("offset", msg.Offset).
+		Str("request_id", tracectx.RequestID(ctx)).
+		Int64("payment_id", paymentEvent.PaymentID).
 		Msg("Received payment event from Kafka")
 
-	// Parse payment event
-	var paymentEvent entity.PaymentEvent
-	err := json.Unmarshal(value, &paymentEvent)
-	if err != nil {
-		pc.logger.Error().Err(err).Msg("Failed to unmarshal payment event")
-		return fmt.Errorf("failed to unmarshal payment event: %w", err)
-	}
-
 	// Process payment
-	err = pc.useCase.ProcessPayment(ctx, &paymentEvent)
+	err := pc.useCase.ProcessPayment(ctx, &paymentEvent)
 	if err != nil {
 		pc.logger.Error().Err(err).Msg("Failed to process payment")
 		return fmt.Errorf("failed to process payment: %w", err)
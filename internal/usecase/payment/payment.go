@@ -2,29 +2,109 @@ package payment
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ducnpdev/godev-kit/internal/entity"
-	"github.com/ducnpdev/godev-kit/internal/repo/persistent"
+	"github.com/ducnpdev/godev-kit/internal/repo"
 	"github.com/ducnpdev/godev-kit/pkg/kafka"
 	"github.com/rs/zerolog"
 )
 
+var (
+	// ErrVietQRNotConfigured is returned for VietQR payments when no merchant account is configured.
+	ErrVietQRNotConfigured = errors.New("vietqr payments are not configured")
+	// ErrVietQRAmount is returned when a VietQR payment is not a whole, positive VND amount.
+	ErrVietQRAmount = errors.New("vietqr payments require a whole, positive VND amount")
+	// ErrPaymentNotFound is returned when no payment has the requested ID.
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentNotRefundable is returned when refunding a payment that has not completed.
+	ErrPaymentNotRefundable = errors.New("only completed payments can be refunded")
+)
+
+// PaymentRepo is the interface for the payment persistent repository.
+type PaymentRepo interface {
+	Create(ctx context.Context, payment *entity.Payment) error
+	GetByID(ctx context.Context, id int64) (*entity.Payment, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*entity.Payment, error)
+	UpdateStatus(ctx context.Context, id int64, status entity.PaymentStatus) error
+	CreateHistory(ctx context.Context, payment *entity.Payment) error
+	// AdvanceByVietQR moves the payment of a VietQR code to status from one of the from
+	// statuses; nil when no payment qualified.
+	AdvanceByVietQR(ctx context.Context, vietqrID string, from []entity.PaymentStatus, status entity.PaymentStatus) (*entity.Payment, error)
+	// Transition moves a payment to status from one of the from statuses; nil when it did not qualify.
+	Transition(ctx context.Context, id int64, from []entity.PaymentStatus, status entity.PaymentStatus) (*entity.Payment, error)
+}
+
+// QRGenerator generates the VietQR code a customer scans to pay.
+type QRGenerator interface {
+	GenerateQR(ctx context.Context, req entity.VietQRGenerateRequest) (*entity.VietQR, error)
+}
+
+// Merchant is the account VietQR payments are credited to.
+type Merchant struct {
+	BankCode  string
+	AccountNo string
+	Name      string
+}
+
 // PaymentUseCase represents payment use case
 type PaymentUseCase struct {
-	paymentRepo *persistent.PaymentRepo
+	paymentRepo PaymentRepo
+	tx          repo.Transactor
 	kafkaProd   *kafka.Producer
 	logger      *zerolog.Logger
+	qr          QRGenerator
+	merchant    Merchant
+	serializer  *kafka.Serializer
+}
+
+// Option configures the payment use case.
+type Option func(*PaymentUseCase)
+
+// VietQR enables payment_method "vietqr", generating codes credited to merchant.
+func VietQR(qr QRGenerator, merchant Merchant) Option {
+	return func(uc *PaymentUseCase) {
+		uc.qr = qr
+		uc.merchant = merchant
+	}
+}
+
+// Transactor creates a payment and its VietQR code in one transaction, so a failed
+// insert leaves no code that could be paid. The QR generator must store codes with
+// repos that join it.
+func Transactor(tx repo.Transactor) Option {
+	return func(uc *PaymentUseCase) {
+		uc.tx = tx
+	}
+}
+
+// Serializer sets how payment events are encoded; plain JSON by default.
+// The payment consumer decodes with the same serializer.
+func Serializer(s *kafka.Serializer) Option {
+	return func(uc *PaymentUseCase) {
+		if s != nil {
+			uc.serializer = s
+		}
+	}
 }
 
 // NewPaymentUseCase creates new payment use case
-func NewPaymentUseCase(paymentRepo *persistent.PaymentRepo, kafkaProd *kafka.Producer, logger *zerolog.Logger) *PaymentUseCase {
-	return &PaymentUseCase{
+func NewPaymentUseCase(paymentRepo PaymentRepo, kafkaProd *kafka.Producer, logger *zerolog.Logger, opts ...Option) *PaymentUseCase {
+	uc := &PaymentUseCase{
 		paymentRepo: paymentRepo,
 		kafkaProd:   kafkaProd,
 		logger:      logger,
+		serializer:  kafka.NewSerializer(kafka.JSON{}),
+	}
+	for _, opt := range opts {
+		opt(uc)
 	}
+	return uc
 }
 
 // RegisterPayment registers a new payment and sends to Kafka
@@ -42,11 +122,28 @@ func (uc *PaymentUseCase) RegisterPayment(ctx context.Context, req *entity.Payme
 		PaymentMethod: req.PaymentMethod,
 	}
 
-	// Save to database
-	err := uc.paymentRepo.Create(ctx, payment)
+	var qr *entity.VietQR
+	err := uc.inTx(ctx, func(ctx context.Context) error {
+		// Generate the code first so the payment row can reference it
+		if req.PaymentMethod == entity.PaymentMethodVietQR {
+			var err error
+			qr, err = uc.generateVietQR(ctx, req)
+			if err != nil {
+				uc.logger.Error().Err(err).Msg("Failed to generate VietQR for payment")
+				return fmt.Errorf("failed to generate vietqr: %w", err)
+			}
+			payment.VietQRID = qr.ID
+		}
+
+		// Save to database
+		if err := uc.paymentRepo.Create(ctx, payment); err != nil {
+			uc.logger.Error().Err(err).Msg("Failed to create payment in database")
+			return fmt.Errorf("failed to create payment: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		uc.logger.Error().Err(err).Msg("Failed to create payment in database")
-		return nil, fmt.Errorf("failed to create payment: %w", err)
+		return nil, err
 	}
 
 	// Create payment event for Kafka
@@ -69,7 +166,8 @@ func (uc *PaymentUseCase) RegisterPayment(ctx context.Context, req *entity.Payme
 
 	// Send to Kafka if producer is available
 	if uc.kafkaProd != nil {
-		err = uc.kafkaProd.SendMessage(ctx, "payment-events", []byte(paymentEvent.TransactionID), paymentEvent)
+		err = kafka.Publish(ctx, uc.kafkaProd, uc.serializer, PaymentEventsTopic, []byte(paymentEvent.TransactionID), *paymentEvent,
+			kafka.MessageHeader(kafka.HeaderEventType, paymentEvent.EventType))
 		if err != nil {
 			uc.logger.Error().Err(err).Msg("Failed to send payment event to Kafka")
 			// Note: In production, you might want to handle this differently
@@ -99,9 +197,47 @@ func (uc *PaymentUseCase) RegisterPayment(ctx context.Context, req *entity.Payme
 		TransactionID: payment.TransactionID,
 		PaymentMethod: payment.PaymentMethod,
 		CreatedAt:     payment.CreatedAt,
+		VietQRID:      payment.VietQRID,
+		VietQR:        qr,
 	}, nil
 }
 
+// inTx runs fn in the transaction of tx, or directly without one.
+func (uc *PaymentUseCase) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if uc.tx == nil {
+		return fn(ctx)
+	}
+	return uc.tx.InTx(ctx, fn)
+}
+
+// generateVietQR creates the code for a VietQR payment. The customer code goes into the
+// transfer description, shortened so the appended reference still fits in the 25-character field.
+func (uc *PaymentUseCase) generateVietQR(ctx context.Context, req *entity.PaymentRequest) (*entity.VietQR, error) {
+	if uc.qr == nil || uc.merchant.AccountNo == "" {
+		return nil, ErrVietQRNotConfigured
+	}
+	if !strings.EqualFold(req.Currency, "VND") || req.Amount <= 0 || req.Amount != math.Trunc(req.Amount) {
+		return nil, ErrVietQRAmount
+	}
+
+	description := req.CustomerCode
+	if len(description) > 12 {
+		description = description[:12]
+	}
+
+	qr, err := uc.qr.GenerateQR(ctx, entity.VietQRGenerateRequest{
+		BankCode:     uc.merchant.BankCode,
+		AccountNo:    uc.merchant.AccountNo,
+		Amount:       strconv.FormatFloat(req.Amount, 'f', 0, 64),
+		Description:  description,
+		ReceiverName: uc.merchant.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return qr, nil
+}
+
 // ProcessPayment processes payment from Kafka message
 func (uc *PaymentUseCase) ProcessPayment(ctx context.Context, paymentEvent *entity.PaymentEvent) error {
 	uc.logger.Info().
@@ -109,6 +245,12 @@ func (uc *PaymentUseCase) ProcessPayment(ctx context.Context, paymentEvent *enti
 		Str("event_type", paymentEvent.EventType).
 		Msg("Processing payment from Kafka")
 
+	// VietQR payments complete when the customer pays the code, see VietQRLink
+	if paymentEvent.PaymentMethod == entity.PaymentMethodVietQR {
+		uc.logger.Info().Int64("payment_id", paymentEvent.PaymentID).Msg("Skipping VietQR payment, awaiting QR payment")
+		return nil
+	}
+
 	// Update status to processing
 	err := uc.paymentRepo.UpdateStatus(ctx, paymentEvent.PaymentID, entity.PaymentStatusProcessing)
 	if err != nil {
@@ -182,6 +324,8 @@ func (uc *PaymentUseCase) GetPaymentByID(ctx context.Context, id int64) (*entity
 		TransactionID: payment.TransactionID,
 		PaymentMethod: payment.PaymentMethod,
 		CreatedAt:     payment.CreatedAt,
+		VietQRID:      payment.VietQRID,
+		VietQRStatus:  payment.VietQRStatus,
 	}, nil
 }
 
@@ -207,12 +351,55 @@ func (uc *PaymentUseCase) GetPaymentsByUserID(ctx context.Context, userID int64)
 			TransactionID: payment.TransactionID,
 			PaymentMethod: payment.PaymentMethod,
 			CreatedAt:     payment.CreatedAt,
+			VietQRID:      payment.VietQRID,
+			VietQRStatus:  payment.VietQRStatus,
 		}
 	}
 
 	return responses, nil
 }
 
+// RefundPayment marks a completed payment as refunded and records it in the payment history.
+func (uc *PaymentUseCase) RefundPayment(ctx context.Context, id int64) (*entity.PaymentResponse, error) {
+	payment, err := uc.paymentRepo.Transition(ctx, id, []entity.PaymentStatus{entity.PaymentStatusCompleted}, entity.PaymentStatusRefunded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund payment: %w", err)
+	}
+	if payment == nil {
+		existing, err := uc.paymentRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get payment: %w", err)
+		}
+		if existing == nil {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, ErrPaymentNotRefundable
+	}
+
+	if err := uc.paymentRepo.CreateHistory(ctx, payment); err != nil {
+		uc.logger.Error().Err(err).Int64("payment_id", id).Msg("Failed to create payment history for refund")
+	}
+
+	uc.logger.Info().Int64("payment_id", id).Msg("Payment refunded")
+
+	return &entity.PaymentResponse{
+		ID:            payment.ID,
+		UserID:        payment.UserID,
+		Amount:        payment.Amount,
+		Currency:      payment.Currency,
+		PaymentType:   payment.PaymentType,
+		Status:        payment.Status,
+		MeterNumber:   payment.MeterNumber,
+		CustomerCode:  payment.CustomerCode,
+		Description:   payment.Description,
+		TransactionID: payment.TransactionID,
+		PaymentMethod: payment.PaymentMethod,
+		CreatedAt:     payment.CreatedAt,
+		VietQRID:      payment.VietQRID,
+		VietQRStatus:  payment.VietQRStatus,
+	}, nil
+}
+
 // simulatePaymentProcessing simulates payment processing
 // In real implementation, this would call external payment gateway
 func (uc *PaymentUseCase) simulatePaymentProcessing(paymentEvent *entity.PaymentEvent) bool {
@@ -0,0 +1,101 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type txKey struct{}
+
+// fakeTransactor marks the ctx of fn and counts the outcomes.
+type fakeTransactor struct {
+	committed, rolledBack int
+}
+
+func (f *fakeTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		f.rolledBack++
+		return err
+	}
+	f.committed++
+	return nil
+}
+
+// fakeQRGenerator records whether codes were generated in a transaction.
+type fakeQRGenerator struct {
+	inTx []bool
+}
+
+func (f *fakeQRGenerator) GenerateQR(ctx context.Context, req entity.VietQRGenerateRequest) (*entity.VietQR, error) {
+	inTx, _ := ctx.Value(txKey{}).(bool)
+	f.inTx = append(f.inTx, inTx)
+	return &entity.VietQR{ID: "qr-1", Amount: req.Amount, Description: req.Description}, nil
+}
+
+func TestPaymentUseCase_RegisterPayment_VietQR(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	merchant := Merchant{BankCode: "VCB", AccountNo: "1234567890", Name: "GODEV"}
+	req := func() *entity.PaymentRequest {
+		return &entity.PaymentRequest{
+			UserID:        1,
+			Amount:        100000,
+			Currency:      "VND",
+			PaymentType:   "electric",
+			CustomerCode:  "CUST-000123456789",
+			PaymentMethod: entity.PaymentMethodVietQR,
+		}
+	}
+
+	t.Run("links the payment to its code in one transaction", func(t *testing.T) {
+		repo := newFakePaymentRepo()
+		qr := &fakeQRGenerator{}
+		tx := &fakeTransactor{}
+		uc := NewPaymentUseCase(repo, nil, &logger, VietQR(qr, merchant), Transactor(tx))
+
+		resp, err := uc.RegisterPayment(ctx, req())
+		require.NoError(t, err)
+
+		assert.Equal(t, "qr-1", resp.VietQRID)
+		require.NotNil(t, resp.VietQR)
+		assert.Equal(t, "100000", resp.VietQR.Amount)
+		assert.Equal(t, "CUST-0001234", resp.VietQR.Description)
+		assert.Equal(t, "qr-1", repo.payments[resp.ID].VietQRID)
+		assert.Equal(t, []bool{true}, qr.inTx)
+		assert.Equal(t, 1, tx.committed)
+	})
+
+	t.Run("a failed insert rolls the code back", func(t *testing.T) {
+		dbErr := errors.New("connection reset")
+		repo := newFakePaymentRepo()
+		repo.createErr = dbErr
+		qr := &fakeQRGenerator{}
+		tx := &fakeTransactor{}
+		uc := NewPaymentUseCase(repo, nil, &logger, VietQR(qr, merchant), Transactor(tx))
+
+		_, err := uc.RegisterPayment(ctx, req())
+		assert.ErrorIs(t, err, dbErr)
+
+		assert.Equal(t, []bool{true}, qr.inTx, "the code must be stored in the rolled back transaction")
+		assert.Equal(t, 1, tx.rolledBack)
+		assert.Zero(t, tx.committed)
+	})
+
+	t.Run("rejects what a code cannot carry", func(t *testing.T) {
+		uc := NewPaymentUseCase(newFakePaymentRepo(), nil, &logger, VietQR(&fakeQRGenerator{}, merchant))
+
+		r := req()
+		r.Amount = 100.5
+		_, err := uc.RegisterPayment(ctx, r)
+		assert.ErrorIs(t, err, ErrVietQRAmount)
+
+		_, err = NewPaymentUseCase(newFakePaymentRepo(), nil, &logger).RegisterPayment(ctx, req())
+		assert.ErrorIs(t, err, ErrVietQRNotConfigured)
+	})
+}
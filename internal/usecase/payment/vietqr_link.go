@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/rs/zerolog"
+)
+
+// vietqrOutcomes maps a final VietQR status to the status of the payment it pays.
+var vietqrOutcomes = map[entity.VietQRStatus]entity.PaymentStatus{
+	entity.VietQRStatusPaid:    entity.PaymentStatusCompleted,
+	entity.VietQRStatusFail:    entity.PaymentStatusFailed,
+	entity.VietQRStatusTimeout: entity.PaymentStatusCancelled,
+}
+
+// openPaymentStatuses are the statuses a VietQR outcome may advance a payment from.
+var openPaymentStatuses = []entity.PaymentStatus{entity.PaymentStatusPending, entity.PaymentStatusProcessing}
+
+// VietQRLink advances payments when the VietQR code they are paid with reaches a final status.
+// It is a vietqr use case StatusHook, so the payment moves in the transaction that moves the
+// code, and a failure here undoes both.
+type VietQRLink struct {
+	paymentRepo PaymentRepo
+	logger      *zerolog.Logger
+}
+
+// NewVietQRLink creates new VietQR link
+func NewVietQRLink(paymentRepo PaymentRepo, logger *zerolog.Logger) *VietQRLink {
+	return &VietQRLink{
+		paymentRepo: paymentRepo,
+		logger:      logger,
+	}
+}
+
+// StatusChanged advances the linked payment, if any, and records it in the payment history.
+func (l *VietQRLink) StatusChanged(ctx context.Context, event entity.VietQRStatusChangedEvent) error {
+	status, ok := vietqrOutcomes[event.NewStatus]
+	if !ok {
+		return nil
+	}
+
+	payment, err := l.paymentRepo.AdvanceByVietQR(ctx, event.QRID, openPaymentStatuses, status)
+	if err != nil {
+		return fmt.Errorf("failed to advance payment from vietqr: %w", err)
+	}
+	if payment == nil {
+		// Not a payment code, or the payment already finished
+		return nil
+	}
+
+	if err := l.paymentRepo.CreateHistory(ctx, payment); err != nil {
+		return fmt.Errorf("failed to create payment history: %w", err)
+	}
+
+	l.logger.Info().
+		Int64("payment_id", payment.ID).
+		Str("vietqr_id", event.QRID).
+		Str("status", string(status)).
+		Msg("Payment advanced from VietQR status")
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePaymentRepo keeps payments in memory; the err fields fail the matching call.
+type fakePaymentRepo struct {
+	payments   map[int64]*entity.Payment
+	history    []entity.Payment
+	nextID     int64
+	createErr  error
+	advanceErr error
+	historyErr error
+}
+
+func newFakePaymentRepo(payments ...*entity.Payment) *fakePaymentRepo {
+	f := &fakePaymentRepo{payments: map[int64]*entity.Payment{}}
+	for _, p := range payments {
+		f.payments[p.ID] = p
+		f.nextID = max(f.nextID, p.ID)
+	}
+	return f
+}
+
+func (f *fakePaymentRepo) Create(_ context.Context, payment *entity.Payment) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	f.nextID++
+	payment.ID = f.nextID
+	f.payments[payment.ID] = payment
+	return nil
+}
+
+func (f *fakePaymentRepo) GetByID(_ context.Context, id int64) (*entity.Payment, error) {
+	return f.payments[id], nil
+}
+
+func (f *fakePaymentRepo) GetByUserID(_ context.Context, userID int64) ([]*entity.Payment, error) {
+	var payments []*entity.Payment
+	for _, p := range f.payments {
+		if p.UserID == userID {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+func (f *fakePaymentRepo) UpdateStatus(_ context.Context, id int64, status entity.PaymentStatus) error {
+	f.payments[id].Status = status
+	return nil
+}
+
+func (f *fakePaymentRepo) CreateHistory(_ context.Context, payment *entity.Payment) error {
+	if f.historyErr != nil {
+		return f.historyErr
+	}
+	f.history = append(f.history, *payment)
+	return nil
+}
+
+func (f *fakePaymentRepo) AdvanceByVietQR(_ context.Context, vietqrID string, from []entity.PaymentStatus, status entity.PaymentStatus) (*entity.Payment, error) {
+	if f.advanceErr != nil {
+		return nil, f.advanceErr
+	}
+	for _, p := range f.payments {
+		if p.VietQRID == vietqrID {
+			return f.transition(p, from, status), nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakePaymentRepo) Transition(_ context.Context, id int64, from []entity.PaymentStatus, status entity.PaymentStatus) (*entity.Payment, error) {
+	p, ok := f.payments[id]
+	if !ok {
+		return nil, nil
+	}
+	return f.transition(p, from, status), nil
+}
+
+func (f *fakePaymentRepo) transition(p *entity.Payment, from []entity.PaymentStatus, status entity.PaymentStatus) *entity.Payment {
+	for _, s := range from {
+		if p.Status == s {
+			p.Status = status
+			return p
+		}
+	}
+	return nil
+}
+
+var _ PaymentRepo = (*fakePaymentRepo)(nil)
+
+func TestVietQRLink_StatusChanged(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	event := func(status entity.VietQRStatus) entity.VietQRStatusChangedEvent {
+		return entity.VietQRStatusChangedEvent{QRID: "qr-1", OldStatus: entity.VietQRStatusGenerated, NewStatus: status}
+	}
+
+	tests := []struct {
+		name   string
+		status entity.VietQRStatus
+		from   entity.PaymentStatus
+		want   entity.PaymentStatus
+	}{
+		{"paid completes", entity.VietQRStatusPaid, entity.PaymentStatusPending, entity.PaymentStatusCompleted},
+		{"fail fails", entity.VietQRStatusFail, entity.PaymentStatusProcessing, entity.PaymentStatusFailed},
+		{"timeout cancels", entity.VietQRStatusTimeout, entity.PaymentStatusPending, entity.PaymentStatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakePaymentRepo(&entity.Payment{ID: 7, VietQRID: "qr-1", Status: tt.from})
+
+			require.NoError(t, NewVietQRLink(repo, &logger).StatusChanged(ctx, event(tt.status)))
+
+			assert.Equal(t, tt.want, repo.payments[7].Status)
+			require.Len(t, repo.history, 1)
+			assert.Equal(t, tt.want, repo.history[0].Status)
+		})
+	}
+
+	t.Run("open statuses leave the payment alone", func(t *testing.T) {
+		repo := newFakePaymentRepo(&entity.Payment{ID: 7, VietQRID: "qr-1", Status: entity.PaymentStatusPending})
+
+		require.NoError(t, NewVietQRLink(repo, &logger).StatusChanged(ctx, event(entity.VietQRStatusInProcess)))
+
+		assert.Equal(t, entity.PaymentStatusPending, repo.payments[7].Status)
+		assert.Empty(t, repo.history)
+	})
+
+	t.Run("finished payments and other codes are skipped", func(t *testing.T) {
+		repo := newFakePaymentRepo(&entity.Payment{ID: 7, VietQRID: "qr-1", Status: entity.PaymentStatusRefunded})
+		link := NewVietQRLink(repo, &logger)
+
+		require.NoError(t, link.StatusChanged(ctx, event(entity.VietQRStatusPaid)))
+		other := event(entity.VietQRStatusPaid)
+		other.QRID = "qr-2"
+		require.NoError(t, link.StatusChanged(ctx, other))
+
+		assert.Equal(t, entity.PaymentStatusRefunded, repo.payments[7].Status)
+		assert.Empty(t, repo.history)
+	})
+
+	t.Run("failures are returned", func(t *testing.T) {
+		dbErr := errors.New("connection reset")
+
+		repo := newFakePaymentRepo(&entity.Payment{ID: 7, VietQRID: "qr-1", Status: entity.PaymentStatusPending})
+		repo.advanceErr = dbErr
+		assert.ErrorIs(t, NewVietQRLink(repo, &logger).StatusChanged(ctx, event(entity.VietQRStatusPaid)), dbErr)
+
+		repo = newFakePaymentRepo(&entity.Payment{ID: 7, VietQRID: "qr-1", Status: entity.PaymentStatusPending})
+		repo.historyErr = dbErr
+		assert.ErrorIs(t, NewVietQRLink(repo, &logger).StatusChanged(ctx, event(entity.VietQRStatusPaid)), dbErr)
+	})
+}
@@ -0,0 +1,296 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo/persistent/models"
+	"github.com/ducnpdev/godev-kit/pkg/mailer"
+	"github.com/jackc/pgx/v5"
+)
+
+// _mailTimeout bounds a mail sent after the request was answered.
+const _mailTimeout = time.Minute
+
+// Password reset and email verification errors.
+var (
+	ErrMailNotConfigured   = errors.New("account mails are not configured")
+	ErrInvalidUserToken    = errors.New("invalid or expired token")
+	ErrVerificationNotSent = errors.New("verification email not sent")
+)
+
+// MailLinks are the pages linked from account mails.
+type MailLinks struct {
+	ResetPassword string
+	VerifyEmail   string
+}
+
+// ForgotPassword mails a password reset link to email. It does not reveal
+// whether the address belongs to an account: unknown addresses succeed too,
+// and the mail is sent after it returned so the answer takes as long either way.
+// Requesting a new link invalidates earlier ones.
+func (uc *UseCase) ForgotPassword(ctx context.Context, email, clientIP string) error {
+	if uc.mailer == nil {
+		return ErrMailNotConfigured
+	}
+
+	if err := uc.checkMailAllowed(ctx, email, clientIP); err != nil {
+		return fmt.Errorf("UserUseCase - ForgotPassword - %w", err)
+	}
+
+	uc.mailLater(ctx, "ForgotPassword", func(ctx context.Context) error {
+		return uc.sendPasswordReset(ctx, email)
+	})
+
+	return nil
+}
+
+func (uc *UseCase) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := uc.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("uc.repo.GetByEmail: %w", err)
+	}
+
+	token, err := uc.newUserToken(ctx, user.ID, entity.UserTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+
+	link, err := withToken(uc.links.ResetPassword, token)
+	if err != nil {
+		return err
+	}
+
+	err = uc.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account.\n\n"+
+			"Open this link within %s to choose a new password:\n%s\n\n"+
+			"If it was not you, ignore this email; your password stays the same.\n", uc.resetTTL, link),
+	})
+	if err != nil {
+		return fmt.Errorf("uc.mailer.Send: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. The
+// token works once. Every session of the user is revoked, and the email
+// counts as verified since the user received the link. All of it is one
+// transaction, so a failure leaves the token usable again.
+func (uc *UseCase) ResetPassword(ctx context.Context, token, password string) error {
+	if uc.userTokens == nil {
+		return ErrMailNotConfigured
+	}
+
+	var revoked []entity.RefreshToken
+	err := uc.inTx(ctx, func(ctx context.Context) error {
+		stored, err := uc.consumeUserToken(ctx, entity.UserTokenPasswordReset, token)
+		if err != nil {
+			return err
+		}
+
+		user, err := uc.repo.GetByID(ctx, stored.UserID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidUserToken
+		}
+		if err != nil {
+			return fmt.Errorf("uc.repo.GetByID: %w", err)
+		}
+
+		hashedPassword, err := uc.hashPassword(password)
+		if err != nil {
+			return fmt.Errorf("uc.hashPassword: %w", err)
+		}
+
+		if err := uc.repo.Update(ctx, models.UserModel{ID: user.ID, Password: hashedPassword}); err != nil {
+			return fmt.Errorf("uc.repo.Update: %w", err)
+		}
+
+		if err := uc.userTokens.Invalidate(ctx, user.ID, entity.UserTokenPasswordReset); err != nil {
+			return fmt.Errorf("uc.userTokens.Invalidate: %w", err)
+		}
+
+		if err := uc.repo.MarkEmailVerified(ctx, user.ID); err != nil {
+			return fmt.Errorf("uc.repo.MarkEmailVerified: %w", err)
+		}
+
+		if uc.refreshTokens != nil {
+			if revoked, err = uc.refreshTokens.RevokeUser(ctx, user.ID); err != nil {
+				return fmt.Errorf("uc.refreshTokens.RevokeUser: %w", err)
+			}
+		}
+
+		return uc.recordUserEvent(ctx, entity.UserUpdatedEvent, user, map[string][]string{
+			"fields": {"password"},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("UserUseCase - ResetPassword - %w", err)
+	}
+
+	// The denylist is not transactional, so it is written once the reset stands
+	if err := uc.denyAccessTokens(ctx, revoked); err != nil {
+		return fmt.Errorf("UserUseCase - ResetPassword - %w", err)
+	}
+
+	return nil
+}
+
+// VerifyEmail confirms the email address with a token mailed at sign-up or by
+// ResendVerification.
+func (uc *UseCase) VerifyEmail(ctx context.Context, token string) error {
+	if uc.userTokens == nil {
+		return ErrMailNotConfigured
+	}
+
+	stored, err := uc.consumeUserToken(ctx, entity.UserTokenEmailVerification, token)
+	if err != nil {
+		return fmt.Errorf("UserUseCase - VerifyEmail - %w", err)
+	}
+
+	if err := uc.repo.MarkEmailVerified(ctx, stored.UserID); err != nil {
+		return fmt.Errorf("UserUseCase - VerifyEmail - uc.repo.MarkEmailVerified: %w", err)
+	}
+
+	if err := uc.userTokens.Invalidate(ctx, stored.UserID, entity.UserTokenEmailVerification); err != nil {
+		return fmt.Errorf("UserUseCase - VerifyEmail - uc.userTokens.Invalidate: %w", err)
+	}
+
+	return nil
+}
+
+// ResendVerification mails a new verification link. Like ForgotPassword it
+// succeeds for unknown and already verified addresses without sending anything,
+// and sends after it returned.
+func (uc *UseCase) ResendVerification(ctx context.Context, email, clientIP string) error {
+	if uc.mailer == nil {
+		return ErrMailNotConfigured
+	}
+
+	if err := uc.checkMailAllowed(ctx, email, clientIP); err != nil {
+		return fmt.Errorf("UserUseCase - ResendVerification - %w", err)
+	}
+
+	uc.mailLater(ctx, "ResendVerification", func(ctx context.Context) error {
+		user, err := uc.repo.GetByEmail(ctx, email)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return fmt.Errorf("uc.repo.GetByEmail: %w", err)
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+
+		return uc.sendVerification(ctx, user)
+	})
+
+	return nil
+}
+
+// mailLater runs send once the request is answered, so lookups and SMTP do not
+// show in the response time. Failures are logged; the user can ask again.
+func (uc *UseCase) mailLater(ctx context.Context, method string, send func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), _mailTimeout)
+	uc.async(func() {
+		defer cancel()
+		if err := send(ctx); err != nil && uc.l != nil {
+			uc.l.Error(fmt.Errorf("UserUseCase - %s - %w", method, err))
+		}
+	})
+}
+
+func (uc *UseCase) sendVerification(ctx context.Context, user entity.User) error {
+	token, err := uc.newUserToken(ctx, user.ID, entity.UserTokenEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	link, err := withToken(uc.links.VerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	err = uc.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Welcome %s!\n\n"+
+			"Open this link within %s to confirm your email address:\n%s\n", user.Username, uc.verificationTTL, link),
+	})
+	if err != nil {
+		return fmt.Errorf("uc.mailer.Send: %w", err)
+	}
+
+	return nil
+}
+
+// newUserToken invalidates the user's outstanding tokens for purpose and
+// stores a new one, returning the plain token for the mail.
+func (uc *UseCase) newUserToken(ctx context.Context, userID int64, purpose string) (string, error) {
+	if err := uc.userTokens.Invalidate(ctx, userID, purpose); err != nil {
+		return "", fmt.Errorf("uc.userTokens.Invalidate: %w", err)
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := uc.now()
+	_, err = uc.userTokens.Create(ctx, entity.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(uc.tokenTTL(purpose)),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("uc.userTokens.Create: %w", err)
+	}
+
+	return token, nil
+}
+
+func (uc *UseCase) consumeUserToken(ctx context.Context, purpose, token string) (entity.UserToken, error) {
+	stored, err := uc.userTokens.Consume(ctx, purpose, hashToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.UserToken{}, ErrInvalidUserToken
+		}
+		return entity.UserToken{}, fmt.Errorf("uc.userTokens.Consume: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (uc *UseCase) tokenTTL(purpose string) time.Duration {
+	if purpose == entity.UserTokenPasswordReset {
+		return uc.resetTTL
+	}
+
+	return uc.verificationTTL
+}
+
+// withToken adds the token to link as the "token" query parameter.
+func withToken(link, token string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
@@ -0,0 +1,289 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+	"github.com/ducnpdev/godev-kit/pkg/mailer"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeUserTokenRepo struct {
+	mu     sync.Mutex
+	tokens []*entity.UserToken
+}
+
+func (f *fakeUserTokenRepo) Create(_ context.Context, t entity.UserToken) (entity.UserToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t.ID = int64(len(f.tokens) + 1)
+	f.tokens = append(f.tokens, &t)
+	return t, nil
+}
+
+func (f *fakeUserTokenRepo) Consume(_ context.Context, purpose, hash string) (entity.UserToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range f.tokens {
+		if t.TokenHash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			t.UsedAt = &now
+			return *t, nil
+		}
+	}
+	return entity.UserToken{}, pgx.ErrNoRows
+}
+
+func (f *fakeUserTokenRepo) Invalidate(_ context.Context, userID int64, purpose string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range f.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+type fakeMailer struct {
+	sent []mailer.Message
+	err  error
+}
+
+func (f *fakeMailer) Send(_ context.Context, msg mailer.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// blockingMailer holds every mail until release is closed.
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mailer.Message
+}
+
+func (b *blockingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	b.sent <- msg
+	return nil
+}
+
+var _ repo.UserTokenRepo = (*fakeUserTokenRepo)(nil)
+
+var tokenParam = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// lastToken returns the token in the link of the last mail sent to "to".
+func (f *fakeMailer) lastToken(t *testing.T, to string) string {
+	t.Helper()
+
+	for i := len(f.sent) - 1; i >= 0; i-- {
+		if f.sent[i].To == to {
+			m := tokenParam.FindStringSubmatch(f.sent[i].Body)
+			require.NotNil(t, m, "no token in mail to %s", to)
+			return m[1]
+		}
+	}
+	t.Fatalf("no mail sent to %s", to)
+	return ""
+}
+
+func newAccountUseCase(t *testing.T, opts ...Option) (*UseCase, *fakeUserRepo, *fakeMailer) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	verified := time.Now()
+	users := &fakeUserRepo{users: map[int64]entity.User{
+		1: {ID: 1, Email: "alice@example.com", Password: string(hash), EmailVerifiedAt: &verified},
+		2: {ID: 2, Email: "bob@example.com", Password: string(hash)},
+	}}
+	m := &fakeMailer{}
+	links := MailLinks{ResetPassword: "https://app.example.com/reset", VerifyEmail: "https://app.example.com/verify"}
+
+	opts = append([]Option{
+		Tokens(newFakeRefreshTokenRepo(), fakeDenylist{}),
+		Mail(&fakeUserTokenRepo{}, m, links),
+	}, opts...)
+
+	uc := New(users, jwtkeys.NewHMAC("secret"), opts...)
+	// Mails go out before the call returns, so tests can read them right away
+	uc.async = func(fn func()) { fn() }
+
+	return uc, users, m
+}
+
+func TestUseCase_PasswordReset(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("resets once and revokes sessions", func(t *testing.T) {
+		events := &fakeEventPublisher{}
+		uc, _, m := newAccountUseCase(t, Events(events))
+
+		session, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+
+		require.NoError(t, uc.ForgotPassword(ctx, "alice@example.com", ""))
+		token := m.lastToken(t, "alice@example.com")
+		assert.Contains(t, m.sent[0].Body, "https://app.example.com/reset?token="+token)
+
+		require.NoError(t, uc.ResetPassword(ctx, token, "new-password"))
+		assert.ErrorIs(t, uc.ResetPassword(ctx, token, "another-password"), ErrInvalidUserToken)
+		assert.Equal(t, []string{entity.UserUpdatedEvent}, events.events)
+
+		_, _, err = uc.Login(ctx, "alice@example.com", "password123", "")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		_, _, err = uc.Login(ctx, "alice@example.com", "new-password", "")
+		assert.NoError(t, err)
+
+		_, err = uc.Refresh(ctx, session.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("unknown email sends nothing", func(t *testing.T) {
+		uc, _, m := newAccountUseCase(t)
+
+		require.NoError(t, uc.ForgotPassword(ctx, "nobody@example.com", ""))
+		assert.Empty(t, m.sent)
+	})
+
+	t.Run("a new request invalidates the previous link", func(t *testing.T) {
+		uc, _, m := newAccountUseCase(t)
+
+		require.NoError(t, uc.ForgotPassword(ctx, "alice@example.com", ""))
+		first := m.lastToken(t, "alice@example.com")
+		require.NoError(t, uc.ForgotPassword(ctx, "alice@example.com", ""))
+		second := m.lastToken(t, "alice@example.com")
+
+		assert.ErrorIs(t, uc.ResetPassword(ctx, first, "new-password"), ErrInvalidUserToken)
+		assert.NoError(t, uc.ResetPassword(ctx, second, "new-password"))
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		uc, _, m := newAccountUseCase(t, MailTokenTTL(time.Minute, 0))
+		uc.now = func() time.Time { return time.Now().Add(-2 * time.Minute) }
+
+		require.NoError(t, uc.ForgotPassword(ctx, "alice@example.com", ""))
+		assert.ErrorIs(t, uc.ResetPassword(ctx, m.lastToken(t, "alice@example.com"), "new-password"), ErrInvalidUserToken)
+	})
+
+	t.Run("the answer does not wait for the mail", func(t *testing.T) {
+		uc, _, _ := newAccountUseCase(t)
+		m := &blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+		uc.mailer = m
+		uc.async = func(fn func()) { go fn() }
+
+		require.NoError(t, uc.ForgotPassword(ctx, "alice@example.com", ""))
+		close(m.release)
+
+		select {
+		case msg := <-m.sent:
+			assert.Equal(t, "alice@example.com", msg.To)
+		case <-time.After(time.Second):
+			t.Fatal("reset mail not sent")
+		}
+	})
+
+	t.Run("tokens are not interchangeable", func(t *testing.T) {
+		uc, _, m := newAccountUseCase(t)
+
+		require.NoError(t, uc.ResendVerification(ctx, "bob@example.com", ""))
+		assert.ErrorIs(t, uc.ResetPassword(ctx, m.lastToken(t, "bob@example.com"), "new-password"), ErrInvalidUserToken)
+	})
+}
+
+func TestUseCase_EmailVerification(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("login is gated until verified", func(t *testing.T) {
+		uc, _, m := newAccountUseCase(t, RequireEmailVerification(true))
+
+		_, _, err := uc.Login(ctx, "bob@example.com", "password123", "")
+		assert.ErrorIs(t, err, ErrEmailNotVerified)
+		_, _, err = uc.Login(ctx, "bob@example.com", "wrong-password", "")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+		require.NoError(t, uc.ResendVerification(ctx, "bob@example.com", ""))
+		require.NoError(t, uc.VerifyEmail(ctx, m.lastToken(t, "bob@example.com")))
+
+		_, _, err = uc.Login(ctx, "bob@example.com", "password123", "")
+		assert.NoError(t, err)
+
+		// Verified and unknown addresses get no mail
+		sent := len(m.sent)
+		require.NoError(t, uc.ResendVerification(ctx, "bob@example.com", ""))
+		require.NoError(t, uc.ResendVerification(ctx, "nobody@example.com", ""))
+		assert.Len(t, m.sent, sent)
+	})
+
+	t.Run("sign-up sends the verification mail", func(t *testing.T) {
+		uc, users, m := newAccountUseCase(t)
+
+		created, err := uc.Create(ctx, entity.User{Email: "carol@example.com", Username: "carol", Password: "password123"})
+		require.NoError(t, err)
+
+		require.NoError(t, uc.VerifyEmail(ctx, m.lastToken(t, "carol@example.com")))
+		assert.NotNil(t, users.users[created.ID].EmailVerifiedAt)
+	})
+
+	t.Run("sign-up succeeds when the mail fails", func(t *testing.T) {
+		uc, _, m := newAccountUseCase(t)
+		m.err = errors.New("smtp down")
+
+		created, err := uc.Create(ctx, entity.User{Email: "carol@example.com", Username: "carol", Password: "password123"})
+		assert.ErrorIs(t, err, ErrVerificationNotSent)
+		assert.NotZero(t, created.ID)
+	})
+
+	t.Run("changing the email needs a new verification", func(t *testing.T) {
+		uc, users, m := newAccountUseCase(t, RequireEmailVerification(true))
+
+		// A link mailed to the old address must not verify the new one
+		require.NoError(t, uc.ResendVerification(ctx, "bob@example.com", ""))
+		stale := m.lastToken(t, "bob@example.com")
+
+		require.NoError(t, uc.Update(ctx, entity.User{ID: 1, Email: "alice@new.example.com"}))
+		assert.Nil(t, users.users[1].EmailVerifiedAt)
+		_, _, err := uc.Login(ctx, "alice@new.example.com", "password123", "")
+		assert.ErrorIs(t, err, ErrEmailNotVerified)
+
+		require.NoError(t, uc.Update(ctx, entity.User{ID: 2, Email: "bob@new.example.com"}))
+		assert.ErrorIs(t, uc.VerifyEmail(ctx, stale), ErrInvalidUserToken)
+
+		require.NoError(t, uc.VerifyEmail(ctx, m.lastToken(t, "alice@new.example.com")))
+		assert.NotNil(t, users.users[1].EmailVerifiedAt)
+
+		// Saving the same address keeps it verified and sends nothing
+		sent := len(m.sent)
+		require.NoError(t, uc.Update(ctx, entity.User{ID: 1, Email: "alice@new.example.com"}))
+		assert.NotNil(t, users.users[1].EmailVerifiedAt)
+		assert.Len(t, m.sent, sent)
+	})
+
+	t.Run("without mail", func(t *testing.T) {
+		uc, _, _ := newTokenUseCase(t)
+
+		assert.ErrorIs(t, uc.ForgotPassword(ctx, "alice@example.com", ""), ErrMailNotConfigured)
+		assert.ErrorIs(t, uc.VerifyEmail(ctx, "token"), ErrMailNotConfigured)
+	})
+}
@@ -0,0 +1,215 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/internal/usecase"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// _apiKeyIDBytes random bytes, hex encoded, follow entity.APIKeyPrefix to form the stored prefix.
+	_apiKeyIDBytes = 6
+	// _apiKeyTouchInterval bounds how often last_used_at is written for a busy key.
+	_apiKeyTouchInterval = time.Minute
+)
+
+// API key errors.
+var (
+	ErrAPIKeysNotConfigured = usecase.ErrAPIKeysNotConfigured
+	ErrInvalidAPIKey        = usecase.ErrInvalidAPIKey
+	ErrAPIKeyNotFound       = errors.New("api key not found")
+	ErrInvalidScope         = errors.New("invalid scope")
+	ErrInvalidExpiry        = errors.New("expiry must be in the future")
+	ErrAPIKeyNotAllowed     = errors.New("not allowed to create api keys for this user")
+)
+
+// APIKeys enables API keys for machine clients. Keys need Roles, since
+// their scopes are checked against the user's permissions.
+func APIKeys(keys repo.APIKeyRepo) Option {
+	return func(uc *UseCase) {
+		uc.apiKeys = keys
+	}
+}
+
+// CreateAPIKey creates a key acting as userID (the caller when 0) with the
+// given scopes, each of which both the caller and the user must hold, so a
+// key never grants more than its creator has. Only admins create keys for
+// other users. The key is only returned here.
+func (uc *UseCase) CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string, expiresAt *time.Time) (entity.CreatedAPIKey, error) {
+	if uc.apiKeys == nil || uc.roles == nil {
+		return entity.CreatedAPIKey{}, ErrAPIKeysNotConfigured
+	}
+
+	caller, ok := entity.PrincipalFromContext(ctx)
+	if !ok {
+		return entity.CreatedAPIKey{}, ErrNotAuthenticated
+	}
+	if userID == 0 {
+		userID = caller.UserID
+	}
+	if userID != caller.UserID && !caller.IsAdmin() {
+		return entity.CreatedAPIKey{}, ErrAPIKeyNotAllowed
+	}
+
+	if len(scopes) == 0 {
+		return entity.CreatedAPIKey{}, fmt.Errorf("UserUseCase - CreateAPIKey - no scopes: %w", ErrInvalidScope)
+	}
+	if expiresAt != nil && !expiresAt.After(uc.now()) {
+		return entity.CreatedAPIKey{}, ErrInvalidExpiry
+	}
+
+	if _, err := uc.repo.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.CreatedAPIKey{}, ErrUserNotFound
+		}
+		return entity.CreatedAPIKey{}, fmt.Errorf("UserUseCase - CreateAPIKey - uc.repo.GetByID: %w", err)
+	}
+
+	_, permissions, err := uc.roles.UserAccess(ctx, userID)
+	if err != nil {
+		return entity.CreatedAPIKey{}, fmt.Errorf("UserUseCase - CreateAPIKey - uc.roles.UserAccess: %w", err)
+	}
+	for _, scope := range scopes {
+		if !caller.HasPermission(scope) {
+			return entity.CreatedAPIKey{}, fmt.Errorf("UserUseCase - CreateAPIKey - %q is not granted to the caller: %w", scope, ErrInvalidScope)
+		}
+		if !slices.Contains(permissions, scope) {
+			return entity.CreatedAPIKey{}, fmt.Errorf("UserUseCase - CreateAPIKey - %q is not granted to the user: %w", scope, ErrInvalidScope)
+		}
+	}
+
+	prefix, secret, err := newAPIKeySecret()
+	if err != nil {
+		return entity.CreatedAPIKey{}, fmt.Errorf("UserUseCase - CreateAPIKey - newAPIKeySecret: %w", err)
+	}
+	key := prefix + "_" + secret
+
+	stored, err := uc.apiKeys.Create(ctx, entity.APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(key),
+		UserID:    userID,
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedBy: caller.UserID,
+		ExpiresAt: expiresAt,
+		CreatedAt: uc.now(),
+	})
+	if err != nil {
+		return entity.CreatedAPIKey{}, fmt.Errorf("UserUseCase - CreateAPIKey - uc.apiKeys.Create: %w", err)
+	}
+
+	return entity.CreatedAPIKey{APIKey: stored, Key: key}, nil
+}
+
+// ListAPIKeys returns the keys of userID, or every key when userID is 0.
+func (uc *UseCase) ListAPIKeys(ctx context.Context, userID int64) ([]entity.APIKey, error) {
+	if uc.apiKeys == nil {
+		return nil, ErrAPIKeysNotConfigured
+	}
+
+	keys, err := uc.apiKeys.List(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("UserUseCase - ListAPIKeys - uc.apiKeys.List: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes a key; requests using it fail from then on.
+func (uc *UseCase) RevokeAPIKey(ctx context.Context, id int64) error {
+	if uc.apiKeys == nil {
+		return ErrAPIKeysNotConfigured
+	}
+
+	ok, err := uc.apiKeys.Revoke(ctx, id)
+	if err != nil {
+		return fmt.Errorf("UserUseCase - RevokeAPIKey - uc.apiKeys.Revoke: %w", err)
+	}
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey resolves a key to the principal it acts as. The principal
+// has no roles, so role checks such as admin access never pass for a key; it
+// only holds the permissions in the key's scopes that the user still has.
+func (uc *UseCase) AuthenticateAPIKey(ctx context.Context, key string) (entity.Principal, error) {
+	if uc.apiKeys == nil || uc.roles == nil {
+		return entity.Principal{}, ErrAPIKeysNotConfigured
+	}
+
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(key, entity.APIKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(key, entity.APIKeyPrefix) {
+		return entity.Principal{}, ErrInvalidAPIKey
+	}
+
+	stored, err := uc.apiKeys.GetByPrefix(ctx, entity.APIKeyPrefix+prefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Principal{}, ErrInvalidAPIKey
+		}
+		return entity.Principal{}, fmt.Errorf("UserUseCase - AuthenticateAPIKey - uc.apiKeys.GetByPrefix: %w", err)
+	}
+
+	now := uc.now()
+	if subtle.ConstantTimeCompare([]byte(stored.KeyHash), []byte(hashToken(key))) != 1 || !stored.Active(now) {
+		return entity.Principal{}, ErrInvalidAPIKey
+	}
+
+	user, err := uc.repo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.Principal{}, ErrInvalidAPIKey
+		}
+		return entity.Principal{}, fmt.Errorf("UserUseCase - AuthenticateAPIKey - uc.repo.GetByID: %w", err)
+	}
+
+	_, permissions, err := uc.roles.UserAccess(ctx, user.ID)
+	if err != nil {
+		return entity.Principal{}, fmt.Errorf("UserUseCase - AuthenticateAPIKey - uc.roles.UserAccess: %w", err)
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= _apiKeyTouchInterval {
+		// Last use is informational; a failed write must not fail the request
+		_ = uc.apiKeys.TouchLastUsed(ctx, stored.ID, now)
+	}
+
+	return entity.Principal{
+		UserID:   user.ID,
+		Email:    user.Email,
+		APIKeyID: stored.ID,
+		Permissions: slices.DeleteFunc(slices.Clone(stored.Scopes), func(scope string) bool {
+			return !slices.Contains(permissions, scope)
+		}),
+	}, nil
+}
+
+// newAPIKeySecret returns the lookup prefix and the secret part of a new key.
+func newAPIKeySecret() (prefix, secret string, err error) {
+	id := make([]byte, _apiKeyIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", fmt.Errorf("rand.Read: %w", err)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("rand.Read: %w", err)
+	}
+
+	// The prefix is hex, so the first underscore after entity.APIKeyPrefix ends it
+	return entity.APIKeyPrefix + hex.EncodeToString(id), base64.RawURLEncoding.EncodeToString(b), nil
+}
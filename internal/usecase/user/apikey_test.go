@@ -0,0 +1,175 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAPIKeyRepo struct {
+	keys    []entity.APIKey
+	touched int
+}
+
+func (f *fakeAPIKeyRepo) Create(_ context.Context, key entity.APIKey) (entity.APIKey, error) {
+	key.ID = int64(len(f.keys) + 1)
+	f.keys = append(f.keys, key)
+	return key, nil
+}
+
+func (f *fakeAPIKeyRepo) GetByPrefix(_ context.Context, prefix string) (entity.APIKey, error) {
+	for _, k := range f.keys {
+		if k.Prefix == prefix {
+			return k, nil
+		}
+	}
+	return entity.APIKey{}, pgx.ErrNoRows
+}
+
+func (f *fakeAPIKeyRepo) List(_ context.Context, userID int64) ([]entity.APIKey, error) {
+	var keys []entity.APIKey
+	for _, k := range f.keys {
+		if userID == 0 || k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeAPIKeyRepo) Revoke(_ context.Context, id int64) (bool, error) {
+	for i := range f.keys {
+		if f.keys[i].ID == id && f.keys[i].RevokedAt == nil {
+			now := time.Now()
+			f.keys[i].RevokedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeAPIKeyRepo) TouchLastUsed(_ context.Context, id int64, at time.Time) error {
+	f.touched++
+	f.keys[id-1].LastUsedAt = &at
+	return nil
+}
+
+var _ repo.APIKeyRepo = (*fakeAPIKeyRepo)(nil)
+
+func TestUseCase_APIKeys(t *testing.T) {
+	keys := &fakeAPIKeyRepo{}
+	roles := newFakeRoleRepo()
+	roles.users[1] = []string{entity.RoleAdmin}
+	roles.users[2] = []string{entity.RoleUser}
+	uc, _, _ := newAccountUseCase(t, Roles(roles), APIKeys(keys))
+
+	ctx := context.Background()
+	admin := entity.ContextWithPrincipal(ctx, entity.Principal{
+		UserID:      1,
+		Roles:       []string{entity.RoleAdmin},
+		Permissions: []string{entity.PermissionPaymentsRefund, entity.PermissionUsersDelete, entity.PermissionRolesManage, entity.PermissionAPIKeysManage},
+	})
+
+	_, err := uc.CreateAPIKey(ctx, 1, "export", []string{entity.PermissionPaymentsRefund}, nil)
+	assert.ErrorIs(t, err, ErrNotAuthenticated)
+
+	_, err = uc.CreateAPIKey(admin, 1, "export", []string{entity.PermissionRolesManage}, nil)
+	assert.ErrorIs(t, err, ErrInvalidScope, "scopes must be held by the user")
+
+	t.Run("callers cannot grant more than they hold", func(t *testing.T) {
+		// A key manager without the admin's permissions
+		manager := entity.ContextWithPrincipal(ctx, entity.Principal{
+			UserID:      2,
+			Roles:       []string{entity.RoleUser},
+			Permissions: []string{entity.PermissionAPIKeysManage},
+		})
+
+		_, err := uc.CreateAPIKey(manager, 1, "escalate", []string{entity.PermissionPaymentsRefund}, nil)
+		assert.ErrorIs(t, err, ErrAPIKeyNotAllowed, "only admins create keys for other users")
+
+		roles.grants[entity.RoleUser] = []string{entity.PermissionPaymentsRefund}
+		defer func() { roles.grants[entity.RoleUser] = []string{} }()
+
+		_, err = uc.CreateAPIKey(manager, 0, "escalate", []string{entity.PermissionPaymentsRefund}, nil)
+		assert.ErrorIs(t, err, ErrInvalidScope, "the scope is the user's, but not in the caller's token")
+		assert.Empty(t, keys.keys)
+	})
+
+	past := time.Now().Add(-time.Minute)
+	_, err = uc.CreateAPIKey(admin, 1, "export", []string{entity.PermissionPaymentsRefund}, &past)
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+
+	_, err = uc.CreateAPIKey(admin, 99, "export", []string{entity.PermissionPaymentsRefund}, nil)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	created, err := uc.CreateAPIKey(admin, 0, "export", []string{entity.PermissionPaymentsRefund}, nil)
+	require.NoError(t, err)
+	assert.True(t, len(created.Key) > len(created.Prefix))
+	assert.Equal(t, created.Prefix, created.Key[:len(created.Prefix)])
+	assert.Equal(t, hashToken(created.Key), keys.keys[0].KeyHash, "only the hash is stored")
+	assert.Equal(t, int64(1), created.UserID)
+
+	t.Run("authenticates as the user with only the key's scopes", func(t *testing.T) {
+		p, err := uc.AuthenticateAPIKey(ctx, created.Key)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), p.UserID)
+		assert.Equal(t, []string{entity.PermissionPaymentsRefund}, p.Permissions)
+		assert.Equal(t, created.ID, p.APIKeyID)
+
+		// The admin's roles do not come with the key
+		assert.Empty(t, p.Roles)
+		assert.False(t, p.IsAdmin())
+		assert.False(t, p.CanActFor(2))
+		assert.True(t, p.CanActFor(1))
+
+		// last_used_at is written at most once a minute
+		_, err = uc.AuthenticateAPIKey(ctx, created.Key)
+		require.NoError(t, err)
+		assert.Equal(t, 1, keys.touched)
+	})
+
+	t.Run("scopes the user lost are dropped", func(t *testing.T) {
+		roles.grants[entity.RoleAdmin] = []string{entity.PermissionUsersDelete}
+		defer func() {
+			roles.grants[entity.RoleAdmin] = []string{entity.PermissionPaymentsRefund, entity.PermissionUsersDelete}
+		}()
+
+		p, err := uc.AuthenticateAPIKey(ctx, created.Key)
+		require.NoError(t, err)
+		assert.Empty(t, p.Permissions)
+	})
+
+	t.Run("rejects wrong, malformed and revoked keys", func(t *testing.T) {
+		for _, key := range []string{"", "gdk_", "nope", created.Prefix + "_wrong", created.Key + "x"} {
+			_, err := uc.AuthenticateAPIKey(ctx, key)
+			assert.ErrorIs(t, err, ErrInvalidAPIKey, key)
+		}
+
+		require.NoError(t, uc.RevokeAPIKey(ctx, created.ID))
+		assert.ErrorIs(t, uc.RevokeAPIKey(ctx, created.ID), ErrAPIKeyNotFound)
+
+		_, err := uc.AuthenticateAPIKey(ctx, created.Key)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	})
+
+	t.Run("rejects expired keys", func(t *testing.T) {
+		soon := time.Now().Add(time.Minute)
+		key, err := uc.CreateAPIKey(admin, 1, "short lived", []string{entity.PermissionPaymentsRefund}, &soon)
+		require.NoError(t, err)
+
+		uc.now = func() time.Time { return soon.Add(time.Second) }
+		defer func() { uc.now = time.Now }()
+
+		_, err = uc.AuthenticateAPIKey(ctx, key.Key)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	})
+
+	listed, err := uc.ListAPIKeys(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, listed, 2)
+}
@@ -0,0 +1,101 @@
+package user
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+)
+
+const (
+	_defaultUserPageSize = 20
+	_maxUserPageSize     = 100
+)
+
+// User list errors.
+var (
+	ErrInvalidUserQuery = errors.New("invalid user query")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+)
+
+// userCursor is the position after a page. It records the order it was made
+// for, so a cursor cannot continue a list sorted differently.
+type userCursor struct {
+	Sort    string `json:"s"`
+	Desc    bool   `json:"d,omitempty"`
+	Deleted bool   `json:"x,omitempty"`
+	Search  string `json:"q,omitempty"`
+	AfterID int64  `json:"id"`
+}
+
+// List returns a page of users. cursor is the NextCursor of the previous
+// page, empty for the first; it must come with the same query.
+func (uc *UseCase) List(ctx context.Context, query entity.UserQuery, cursor string) (entity.UserHistory, error) {
+	if query.Sort == "" {
+		query.Sort = entity.UserSortID
+	}
+	if !slices.Contains([]string{entity.UserSortID, entity.UserSortCreatedAt, entity.UserSortEmail, entity.UserSortUsername}, query.Sort) {
+		return entity.UserHistory{}, fmt.Errorf("UserUseCase - List - sort %q: %w", query.Sort, ErrInvalidUserQuery)
+	}
+	if query.Limit == 0 {
+		query.Limit = _defaultUserPageSize
+	}
+	query.Limit = min(query.Limit, _maxUserPageSize)
+
+	if cursor != "" {
+		after, err := decodeUserCursor(cursor)
+		if err != nil {
+			return entity.UserHistory{}, fmt.Errorf("UserUseCase - List - %w", err)
+		}
+		if after.Sort != query.Sort || after.Desc != query.Desc || after.Deleted != query.Deleted || after.Search != query.Search {
+			return entity.UserHistory{}, fmt.Errorf("UserUseCase - List - cursor of another query: %w", ErrInvalidCursor)
+		}
+		query.AfterID = after.AfterID
+	}
+
+	// One extra row tells whether there is a next page
+	pageSize := query.Limit
+	query.Limit++
+
+	users, err := uc.repo.List(ctx, query)
+	if err != nil {
+		return entity.UserHistory{}, fmt.Errorf("UserUseCase - List - uc.repo.List: %w", err)
+	}
+
+	page := entity.UserHistory{Users: users}
+	if uint64(len(users)) > pageSize {
+		page.Users = users[:pageSize]
+		page.NextCursor = encodeUserCursor(userCursor{
+			Sort:    query.Sort,
+			Desc:    query.Desc,
+			Deleted: query.Deleted,
+			Search:  query.Search,
+			AfterID: page.Users[pageSize-1].ID,
+		})
+	}
+
+	return page, nil
+}
+
+func encodeUserCursor(c userCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeUserCursor(s string) (userCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return userCursor{}, ErrInvalidCursor
+	}
+
+	var c userCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.AfterID <= 0 {
+		return userCursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
@@ -0,0 +1,136 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUseCase_List(t *testing.T) {
+	users := &fakeUserRepo{users: map[int64]entity.User{}}
+	start := time.Now()
+	for i := int64(1); i <= 7; i++ {
+		users.users[i] = entity.User{
+			ID:        i,
+			Email:     fmt.Sprintf("user%d@example.com", 8-i),
+			Username:  fmt.Sprintf("name%d", i),
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	users.users[8] = entity.User{ID: 8, Email: "admin@example.com", Username: "root", CreatedAt: start}
+	uc := New(users, nil)
+	ctx := context.Background()
+
+	ids := func(page entity.UserHistory) []int64 {
+		var ids []int64
+		for _, u := range page.Users {
+			ids = append(ids, u.ID)
+		}
+		return ids
+	}
+
+	t.Run("pages through every user once", func(t *testing.T) {
+		query := entity.UserQuery{Sort: entity.UserSortEmail, Limit: 3}
+
+		var seen []int64
+		cursor := ""
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 4)
+			page, err := uc.List(ctx, query, cursor)
+			require.NoError(t, err)
+			seen = append(seen, ids(page)...)
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		assert.Equal(t, []int64{8, 7, 6, 5, 4, 3, 2, 1}, seen)
+	})
+
+	t.Run("sorts descending", func(t *testing.T) {
+		page, err := uc.List(ctx, entity.UserQuery{Sort: entity.UserSortCreatedAt, Desc: true, Limit: 2}, "")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{7, 6}, ids(page))
+
+		page, err = uc.List(ctx, entity.UserQuery{Sort: entity.UserSortCreatedAt, Desc: true, Limit: 2}, page.NextCursor)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{5, 4}, ids(page))
+	})
+
+	t.Run("searches email and username prefixes", func(t *testing.T) {
+		page, err := uc.List(ctx, entity.UserQuery{Search: "ADMIN"}, "")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{8}, ids(page))
+
+		page, err = uc.List(ctx, entity.UserQuery{Search: "name"}, "")
+		require.NoError(t, err)
+		assert.Len(t, page.Users, 7)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("rejects bad cursors and sorts", func(t *testing.T) {
+		page, err := uc.List(ctx, entity.UserQuery{Limit: 1}, "")
+		require.NoError(t, err)
+
+		_, err = uc.List(ctx, entity.UserQuery{Limit: 1, Sort: entity.UserSortEmail}, page.NextCursor)
+		assert.ErrorIs(t, err, ErrInvalidCursor, "the cursor belongs to the id order")
+
+		_, err = uc.List(ctx, entity.UserQuery{}, "not a cursor")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		_, err = uc.List(ctx, entity.UserQuery{Sort: "password"}, "")
+		assert.ErrorIs(t, err, ErrInvalidUserQuery)
+	})
+}
+
+func TestUseCase_DeleteRestore(t *testing.T) {
+	refreshTokens := newFakeRefreshTokenRepo()
+	denylist := fakeDenylist{}
+	events := &fakeEventPublisher{}
+	uc, _, _ := newAccountUseCase(t, Tokens(refreshTokens, denylist), Events(events))
+	ctx := context.Background()
+
+	tokens, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+	require.NoError(t, err)
+
+	require.NoError(t, uc.Delete(ctx, 1))
+	assert.ErrorIs(t, uc.Delete(ctx, 1), ErrUserNotFound)
+	assert.Equal(t, []string{entity.UserDeletedEvent}, events.events)
+
+	t.Run("deleted users are gone from login, reads and lists", func(t *testing.T) {
+		_, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+		_, err = uc.Refresh(ctx, tokens.RefreshToken)
+		assert.Error(t, err)
+
+		_, err = uc.GetByID(ctx, 1)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+
+		page, err := uc.List(ctx, entity.UserQuery{}, "")
+		require.NoError(t, err)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, int64(2), page.Users[0].ID)
+
+		page, err = uc.List(ctx, entity.UserQuery{Deleted: true}, "")
+		require.NoError(t, err)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, int64(1), page.Users[0].ID)
+	})
+
+	t.Run("sessions are revoked", func(t *testing.T) {
+		assert.Len(t, denylist, 1)
+	})
+
+	require.NoError(t, uc.Restore(ctx, 1))
+	assert.ErrorIs(t, uc.Restore(ctx, 1), ErrUserNotFound)
+	assert.Equal(t, []string{entity.UserDeletedEvent, entity.UserRestoredEvent}, events.events)
+
+	_, _, err = uc.Login(ctx, "alice@example.com", "password123", "")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,317 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/jackc/pgx/v5"
+)
+
+// Login protection defaults.
+const (
+	_defaultMaxFailures   = 5
+	_defaultMaxIPFailures = 20
+	_defaultFailureWindow = 15 * time.Minute
+	_defaultLockDuration  = 15 * time.Minute
+	_defaultBaseDelay     = 250 * time.Millisecond
+	_defaultMaxDelay      = 4 * time.Second
+
+	_defaultMaxMailRequests = 3
+)
+
+// Login protection errors.
+var (
+	ErrAccountLocked        = errors.New("account is temporarily locked")
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts")
+	ErrLockoutNotConfigured = errors.New("login protection is not configured")
+	ErrTooManyMailRequests  = errors.New("too many email requests")
+)
+
+// LoginPolicy tunes the brute-force protection of Login and the rate limit of
+// the password reset and verification mails. Zero fields keep the defaults.
+type LoginPolicy struct {
+	// MaxFailures failed logins for an email within FailureWindow lock the account for LockDuration.
+	MaxFailures int
+	// MaxIPFailures failed logins from a client IP within FailureWindow block that IP until the window ends.
+	MaxIPFailures int
+	FailureWindow time.Duration
+	LockDuration  time.Duration
+	// BaseDelay slows down the answer to a failed login; it doubles with every further
+	// failure for the email, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxMailRequests mail requests for an email within FailureWindow are allowed;
+	// a client IP gets MaxIPFailures of them.
+	MaxMailRequests int
+}
+
+// EventPublisher sends user events. Delivery is best effort: implementations
+// log failures instead of returning them.
+type EventPublisher interface {
+	PublishUserEvent(ctx context.Context, eventType string, userID int64, email string, data any)
+}
+
+// retryError carries how long a blocked caller should wait.
+type retryError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryError) Error() string { return e.err.Error() }
+
+func (e *retryError) Unwrap() error { return e.err }
+
+// RetryAfter returns how long to wait before trying again after ErrAccountLocked,
+// ErrTooManyLoginAttempts or ErrTooManyMailRequests, zero when err carries no delay.
+func RetryAfter(err error) time.Duration {
+	var re *retryError
+	if errors.As(err, &re) {
+		return re.retryAfter
+	}
+	return 0
+}
+
+// LoginProtection tracks failed logins per email and client IP in attempts,
+// delays the answers to repeated failures and locks accounts after too many.
+// It also limits the password reset and verification mails.
+func LoginProtection(attempts repo.LoginAttemptRepo, policy LoginPolicy) Option {
+	return func(uc *UseCase) {
+		uc.attempts = attempts
+		uc.loginPolicy = policy.withDefaults()
+	}
+}
+
+// Events publishes user.login_failed and user.locked, and the lifecycle
+// events when there is no Outbox.
+func Events(events EventPublisher) Option {
+	return func(uc *UseCase) {
+		uc.events = events
+	}
+}
+
+// UnlockUser lifts the lock of a user's account and forgets its failed logins.
+func (uc *UseCase) UnlockUser(ctx context.Context, id int64) error {
+	if uc.attempts == nil {
+		return ErrLockoutNotConfigured
+	}
+
+	user, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("UserUseCase - UnlockUser - uc.repo.GetByID: %w", err)
+	}
+	if user.ID == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := uc.attempts.Reset(ctx, emailAttemptKey(user.Email)); err != nil {
+		return fmt.Errorf("UserUseCase - UnlockUser - uc.attempts.Reset: %w", err)
+	}
+
+	return nil
+}
+
+// checkLoginAllowed rejects logins for locked emails and blocked client IPs
+// before the password is looked at.
+func (uc *UseCase) checkLoginAllowed(ctx context.Context, email, clientIP string) error {
+	if uc.attempts == nil {
+		return nil
+	}
+
+	locked, err := uc.attempts.LockedFor(ctx, emailAttemptKey(email))
+	if err != nil {
+		return fmt.Errorf("uc.attempts.LockedFor: %w", err)
+	}
+	if locked > 0 {
+		return &retryError{err: ErrAccountLocked, retryAfter: locked}
+	}
+
+	if clientIP == "" {
+		return nil
+	}
+
+	failures, ttl, err := uc.attempts.Failures(ctx, ipAttemptKey(clientIP))
+	if err != nil {
+		return fmt.Errorf("uc.attempts.Failures: %w", err)
+	}
+	if failures >= int64(uc.loginPolicy.MaxIPFailures) {
+		return &retryError{err: ErrTooManyLoginAttempts, retryAfter: ttl}
+	}
+
+	return nil
+}
+
+// loginSucceeded forgets the failed logins of email once the user is fully
+// authenticated. The IP counter is left alone, one good account must not clear it.
+func (uc *UseCase) loginSucceeded(ctx context.Context, email string) error {
+	if uc.attempts == nil {
+		return nil
+	}
+	if err := uc.attempts.Reset(ctx, emailAttemptKey(email)); err != nil {
+		return fmt.Errorf("uc.attempts.Reset: %w", err)
+	}
+	return nil
+}
+
+// loginFailed records a failed login for email and clientIP, locks the account
+// when it reached the limit and waits out the progressive delay. user is the
+// zero User for unknown emails; they are counted all the same so the answers do
+// not reveal which accounts exist.
+func (uc *UseCase) loginFailed(ctx context.Context, user entity.User, email, clientIP string) error {
+	if uc.attempts == nil {
+		return nil
+	}
+
+	policy := uc.loginPolicy
+	key := emailAttemptKey(email)
+
+	failures, err := uc.attempts.AddFailure(ctx, key, policy.FailureWindow)
+	if err != nil {
+		return fmt.Errorf("uc.attempts.AddFailure: %w", err)
+	}
+
+	if clientIP != "" {
+		if _, err := uc.attempts.AddFailure(ctx, ipAttemptKey(clientIP), policy.FailureWindow); err != nil {
+			return fmt.Errorf("uc.attempts.AddFailure: %w", err)
+		}
+	}
+
+	uc.publish(ctx, entity.UserLoginFailedEvent, user.ID, email, map[string]any{
+		"client_ip": clientIP,
+		"failures":  failures,
+	})
+
+	if failures >= int64(policy.MaxFailures) {
+		// The count starts over once the lock ends
+		if err := uc.attempts.Reset(ctx, key); err != nil {
+			return fmt.Errorf("uc.attempts.Reset: %w", err)
+		}
+		if err := uc.attempts.Lock(ctx, key, policy.LockDuration); err != nil {
+			return fmt.Errorf("uc.attempts.Lock: %w", err)
+		}
+
+		if user.ID != 0 {
+			uc.publish(ctx, entity.UserLockedEvent, user.ID, email, map[string]any{
+				"client_ip":    clientIP,
+				"failures":     failures,
+				"locked_until": uc.now().Add(policy.LockDuration),
+			})
+		}
+	}
+
+	return uc.sleep(ctx, policy.delay(failures))
+}
+
+// checkMailAllowed counts a mail request for email and clientIP and rejects it
+// past the limits. Unknown emails count too, so the answers stay the same.
+func (uc *UseCase) checkMailAllowed(ctx context.Context, email, clientIP string) error {
+	if uc.attempts == nil {
+		return nil
+	}
+
+	type limit struct {
+		key string
+		max int
+	}
+	policy := uc.loginPolicy
+	// The IP goes first so requests spread over many emails still count against it
+	limits := []limit{{mailAttemptKey(emailAttemptKey(email)), policy.MaxMailRequests}}
+	if clientIP != "" {
+		limits = append([]limit{{mailAttemptKey(ipAttemptKey(clientIP)), policy.MaxIPFailures}}, limits...)
+	}
+
+	for _, l := range limits {
+		requests, err := uc.attempts.AddFailure(ctx, l.key, policy.FailureWindow)
+		if err != nil {
+			return fmt.Errorf("uc.attempts.AddFailure: %w", err)
+		}
+		if requests <= int64(l.max) {
+			continue
+		}
+
+		_, ttl, err := uc.attempts.Failures(ctx, l.key)
+		if err != nil {
+			return fmt.Errorf("uc.attempts.Failures: %w", err)
+		}
+		return &retryError{err: ErrTooManyMailRequests, retryAfter: ttl}
+	}
+
+	return nil
+}
+
+func (uc *UseCase) publish(ctx context.Context, eventType string, userID int64, email string, data any) {
+	if uc.events != nil {
+		uc.events.PublishUserEvent(ctx, eventType, userID, email, data)
+	}
+}
+
+func (p LoginPolicy) withDefaults() LoginPolicy {
+	if p.MaxFailures <= 0 {
+		p.MaxFailures = _defaultMaxFailures
+	}
+	if p.MaxIPFailures <= 0 {
+		p.MaxIPFailures = _defaultMaxIPFailures
+	}
+	if p.FailureWindow <= 0 {
+		p.FailureWindow = _defaultFailureWindow
+	}
+	if p.LockDuration <= 0 {
+		p.LockDuration = _defaultLockDuration
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = _defaultBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = _defaultMaxDelay
+	}
+	if p.MaxMailRequests <= 0 {
+		p.MaxMailRequests = _defaultMaxMailRequests
+	}
+	return p
+}
+
+// delay is BaseDelay after the first failure, doubled for each further one and capped at MaxDelay.
+func (p LoginPolicy) delay(failures int64) time.Duration {
+	d := p.BaseDelay
+	for i := int64(1); i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+func emailAttemptKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// mailAttemptKey keeps mail requests apart from the failed logins of the same key.
+func mailAttemptKey(key string) string {
+	return "mail:" + key
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,165 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLoginAttemptRepo struct {
+	failures map[string]int64
+	locks    map[string]time.Duration
+}
+
+func (f *fakeLoginAttemptRepo) AddFailure(_ context.Context, key string, _ time.Duration) (int64, error) {
+	f.failures[key]++
+	return f.failures[key], nil
+}
+
+func (f *fakeLoginAttemptRepo) Failures(_ context.Context, key string) (int64, time.Duration, error) {
+	return f.failures[key], time.Minute, nil
+}
+
+func (f *fakeLoginAttemptRepo) Lock(_ context.Context, key string, ttl time.Duration) error {
+	f.locks[key] = ttl
+	return nil
+}
+
+func (f *fakeLoginAttemptRepo) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	return f.locks[key], nil
+}
+
+func (f *fakeLoginAttemptRepo) Reset(_ context.Context, key string) error {
+	delete(f.failures, key)
+	delete(f.locks, key)
+	return nil
+}
+
+type fakeEventPublisher struct {
+	events []string
+}
+
+func (f *fakeEventPublisher) PublishUserEvent(_ context.Context, eventType string, _ int64, _ string, _ any) {
+	f.events = append(f.events, eventType)
+}
+
+var _ repo.LoginAttemptRepo = (*fakeLoginAttemptRepo)(nil)
+
+func newLockoutUseCase(t *testing.T) (*UseCase, *fakeLoginAttemptRepo, *fakeEventPublisher, *[]time.Duration) {
+	t.Helper()
+
+	attempts := &fakeLoginAttemptRepo{failures: map[string]int64{}, locks: map[string]time.Duration{}}
+	events := &fakeEventPublisher{}
+	uc, _, _ := newAccountUseCase(t,
+		LoginProtection(attempts, LoginPolicy{MaxFailures: 3, MaxIPFailures: 5, LockDuration: time.Hour}),
+		Events(events),
+	)
+
+	var delays []time.Duration
+	uc.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	return uc, attempts, events, &delays
+}
+
+func TestUseCase_LoginLockout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("locks the account after too many failures", func(t *testing.T) {
+		uc, _, events, delays := newLockoutUseCase(t)
+
+		for range 3 {
+			_, _, err := uc.Login(ctx, "alice@example.com", "wrong-password", "192.0.2.1")
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		assert.Equal(t, []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second}, *delays)
+		assert.Equal(t, []string{
+			entity.UserLoginFailedEvent, entity.UserLoginFailedEvent, entity.UserLoginFailedEvent, entity.UserLockedEvent,
+		}, events.events)
+
+		// The right password does not help while locked
+		_, _, err := uc.Login(ctx, "Alice@Example.com", "password123", "192.0.2.2")
+		assert.ErrorIs(t, err, ErrAccountLocked)
+		assert.Equal(t, time.Hour, RetryAfter(err))
+
+		require.NoError(t, uc.UnlockUser(ctx, 1))
+		_, _, err = uc.Login(ctx, "alice@example.com", "password123", "192.0.2.2")
+		require.NoError(t, err)
+	})
+
+	t.Run("a successful login clears the email but not the IP", func(t *testing.T) {
+		uc, attempts, _, _ := newLockoutUseCase(t)
+
+		_, _, err := uc.Login(ctx, "alice@example.com", "wrong-password", "192.0.2.1")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		_, _, err = uc.Login(ctx, "alice@example.com", "password123", "192.0.2.1")
+		require.NoError(t, err)
+
+		assert.Zero(t, attempts.failures[emailAttemptKey("alice@example.com")])
+		assert.Equal(t, int64(1), attempts.failures[ipAttemptKey("192.0.2.1")])
+	})
+
+	t.Run("blocks a client IP across emails", func(t *testing.T) {
+		uc, _, events, _ := newLockoutUseCase(t)
+
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+			_, _, err := uc.Login(ctx, email, "password123", "192.0.2.1")
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		// Unknown emails never produce user.locked
+		assert.NotContains(t, events.events, entity.UserLockedEvent)
+
+		_, _, err := uc.Login(ctx, "alice@example.com", "password123", "192.0.2.1")
+		assert.ErrorIs(t, err, ErrTooManyLoginAttempts)
+		assert.Equal(t, time.Minute, RetryAfter(err))
+
+		_, _, err = uc.Login(ctx, "alice@example.com", "password123", "192.0.2.9")
+		require.NoError(t, err)
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		uc, _, _, _ := newLockoutUseCase(t)
+		assert.ErrorIs(t, uc.UnlockUser(ctx, 99), ErrUserNotFound)
+
+		plain, _, _ := newAccountUseCase(t)
+		assert.ErrorIs(t, plain.UnlockUser(ctx, 1), ErrLockoutNotConfigured)
+	})
+}
+
+func TestLoginPolicy_Delay(t *testing.T) {
+	p := LoginPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}.withDefaults()
+
+	assert.Equal(t, time.Second, p.delay(1))
+	assert.Equal(t, 4*time.Second, p.delay(3))
+	assert.Equal(t, 5*time.Second, p.delay(4))
+	assert.Equal(t, 5*time.Second, p.delay(60))
+}
+
+func TestUseCase_MailRateLimit(t *testing.T) {
+	ctx := context.Background()
+	uc, attempts, _, _ := newLockoutUseCase(t)
+
+	for range 3 {
+		require.NoError(t, uc.ForgotPassword(ctx, "alice@example.com", "10.0.0.1"))
+	}
+	err := uc.ForgotPassword(ctx, "alice@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrTooManyMailRequests)
+	assert.Equal(t, time.Minute, RetryAfter(err))
+
+	// Mail requests do not count as failed logins
+	_, _, err = uc.Login(ctx, "alice@example.com", "password123", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Zero(t, attempts.failures["email:alice@example.com"])
+
+	// The IP runs out across emails, unknown ones included: four of its five requests are used
+	require.NoError(t, uc.ResendVerification(ctx, "bob@example.com", "10.0.0.1"))
+	assert.ErrorIs(t, uc.ResendVerification(ctx, "nobody@example.com", "10.0.0.1"), ErrTooManyMailRequests)
+	assert.NoError(t, uc.ResendVerification(ctx, "nobody@example.com", "10.0.0.2"))
+}
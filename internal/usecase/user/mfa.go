@@ -0,0 +1,351 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/qrimage"
+	"github.com/ducnpdev/godev-kit/pkg/totp"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	// _mfaAudience marks challenge tokens so they are never accepted as access tokens
+	_mfaAudience = "godev-kit-mfa"
+
+	_recoveryCodeCount = 10
+)
+
+// Two-factor authentication errors.
+var (
+	ErrMFANotConfigured  = errors.New("two-factor authentication is not configured")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+)
+
+// EnrollTOTP starts enrolling an authenticator app for the caller. The
+// enrollment only takes effect after ConfirmTOTP; enrolling again replaces a
+// pending enrollment.
+func (uc *UseCase) EnrollTOTP(ctx context.Context) (entity.TOTPEnrollment, error) {
+	principal, err := uc.mfaPrincipal(ctx)
+	if err != nil {
+		return entity.TOTPEnrollment{}, err
+	}
+
+	current, err := uc.mfa.GetTOTP(ctx, principal.UserID)
+	if err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("UserUseCase - EnrollTOTP - uc.mfa.GetTOTP: %w", err)
+	}
+	if current.Enabled() {
+		return entity.TOTPEnrollment{}, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("UserUseCase - EnrollTOTP - %w", err)
+	}
+
+	sealed, err := uc.mfaBox.Seal([]byte(secret), totpAdditionalData(principal.UserID))
+	if err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("UserUseCase - EnrollTOTP - uc.mfaBox.Seal: %w", err)
+	}
+
+	if err := uc.mfa.SetTOTPSecret(ctx, principal.UserID, sealed); err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("UserUseCase - EnrollTOTP - uc.mfa.SetTOTPSecret: %w", err)
+	}
+
+	uri := totp.URI(uc.mfaIssuer, principal.Email, secret)
+	qr, err := qrimage.New().Render(uri, qrimage.FormatPNG)
+	if err != nil {
+		return entity.TOTPEnrollment{}, fmt.Errorf("UserUseCase - EnrollTOTP - qrimage.Render: %w", err)
+	}
+
+	return entity.TOTPEnrollment{Secret: secret, URI: uri, QRCode: qr}, nil
+}
+
+// ConfirmTOTP enables the pending enrollment with a first code from the app
+// and returns the recovery codes, which are only shown this once.
+func (uc *UseCase) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	principal, err := uc.mfaPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := uc.mfa.GetTOTP(ctx, principal.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("UserUseCase - ConfirmTOTP - uc.mfa.GetTOTP: %w", err)
+	}
+	if current.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if current.Secret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	if err := uc.checkSecondFactor(ctx, current, code, false); err != nil {
+		return nil, fmt.Errorf("UserUseCase - ConfirmTOTP - %w", err)
+	}
+
+	// Enabled without recovery codes would leave the user no way back in
+	var codes []string
+	err = uc.inTx(ctx, func(ctx context.Context) error {
+		if err := uc.mfa.EnableTOTP(ctx, principal.UserID); err != nil {
+			return fmt.Errorf("uc.mfa.EnableTOTP: %w", err)
+		}
+
+		codes, err = uc.newRecoveryCodes(ctx, principal.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("UserUseCase - ConfirmTOTP - %w", err)
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off; code is a current code or
+// a recovery code.
+func (uc *UseCase) DisableTOTP(ctx context.Context, code string) error {
+	principal, err := uc.mfaPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, err := uc.enabledTOTP(ctx, principal.UserID)
+	if err != nil {
+		return fmt.Errorf("UserUseCase - DisableTOTP - %w", err)
+	}
+
+	if err := uc.checkSecondFactor(ctx, current, code, true); err != nil {
+		return fmt.Errorf("UserUseCase - DisableTOTP - %w", err)
+	}
+
+	// The enrollment and the recovery codes go together
+	err = uc.inTx(ctx, func(ctx context.Context) error {
+		return uc.mfa.DisableTOTP(ctx, principal.UserID)
+	})
+	if err != nil {
+		return fmt.Errorf("UserUseCase - DisableTOTP - uc.mfa.DisableTOTP: %w", err)
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes; code must be
+// a current code from the app.
+func (uc *UseCase) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	principal, err := uc.mfaPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := uc.enabledTOTP(ctx, principal.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("UserUseCase - RegenerateRecoveryCodes - %w", err)
+	}
+
+	if err := uc.checkSecondFactor(ctx, current, code, false); err != nil {
+		return nil, fmt.Errorf("UserUseCase - RegenerateRecoveryCodes - %w", err)
+	}
+
+	codes, err := uc.newRecoveryCodes(ctx, principal.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("UserUseCase - RegenerateRecoveryCodes - %w", err)
+	}
+
+	return codes, nil
+}
+
+// CompleteMFALogin exchanges the challenge returned by Login and a code from
+// the app, or a recovery code, for the token pair. A challenge is single use:
+// a wrong code burns it and the user has to log in again. Wrong codes count
+// as failed logins for the user's email and clientIP, like wrong passwords.
+func (uc *UseCase) CompleteMFALogin(ctx context.Context, mfaToken, code, clientIP string) (entity.AuthTokens, entity.User, error) {
+	// Without the denylist a challenge could be replayed until it expires
+	if uc.mfa == nil || uc.denylist == nil {
+		return entity.AuthTokens{}, entity.User{}, ErrMFANotConfigured
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, err := jwt.ParseWithClaims(mfaToken, &claims, uc.signer.Keyfunc); err != nil || !claims.VerifyAudience(_mfaAudience, true) || claims.ExpiresAt == nil {
+		return entity.AuthTokens{}, entity.User{}, ErrInvalidMFAToken
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return entity.AuthTokens{}, entity.User{}, ErrInvalidMFAToken
+	}
+
+	user, err := uc.repo.GetByID(ctx, userID)
+	if err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - uc.repo.GetByID: %w", err)
+	}
+
+	if err := uc.checkLoginAllowed(ctx, user.Email, clientIP); err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - %w", err)
+	}
+
+	revoked, err := uc.denylist.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - uc.denylist.IsRevoked: %w", err)
+	}
+	if revoked {
+		return entity.AuthTokens{}, entity.User{}, ErrInvalidMFAToken
+	}
+	if err := uc.denylist.Revoke(ctx, claims.ID, claims.ExpiresAt.Sub(uc.now())); err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - uc.denylist.Revoke: %w", err)
+	}
+
+	current, err := uc.enabledTOTP(ctx, userID)
+	if err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - %w", err)
+	}
+
+	if err := uc.checkSecondFactor(ctx, current, code, true); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := uc.loginFailed(ctx, user, user.Email, clientIP); err != nil {
+				return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - %w", err)
+			}
+		}
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - %w", err)
+	}
+
+	if err := uc.loginSucceeded(ctx, user.Email); err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - %w", err)
+	}
+
+	user.Password = ""
+	tokens, err := uc.issueTokens(ctx, user, "")
+	if err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteMFALogin - uc.issueTokens: %w", err)
+	}
+
+	return tokens, user, nil
+}
+
+// issueMFAChallenge signs a short-lived token naming the user that passed the password check.
+func (uc *UseCase) issueMFAChallenge(user entity.User) (entity.AuthTokens, error) {
+	now := uc.now()
+	expiresAt := now.Add(uc.mfaChallengeTTL)
+
+	token, err := uc.signer.Sign(jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Audience:  jwt.ClaimStrings{_mfaAudience},
+		Issuer:    _issuer,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	if err != nil {
+		return entity.AuthTokens{}, fmt.Errorf("failed to sign mfa token: %w", err)
+	}
+
+	return entity.AuthTokens{MFAToken: token, MFAExpiresAt: expiresAt}, nil
+}
+
+// checkSecondFactor accepts a code from the app once per time step and, with
+// allowRecovery, an unused recovery code.
+func (uc *UseCase) checkSecondFactor(ctx context.Context, current entity.TOTP, code string, allowRecovery bool) error {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		secret, err := uc.mfaBox.Open(current.Secret, totpAdditionalData(current.UserID))
+		if err != nil {
+			return fmt.Errorf("uc.mfaBox.Open: %w", err)
+		}
+
+		step, ok := totp.Validate(string(secret), code, uc.now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		used, err := uc.mfa.UseTOTPStep(ctx, current.UserID, step)
+		if err != nil {
+			return fmt.Errorf("uc.mfa.UseTOTPStep: %w", err)
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	if !allowRecovery {
+		return ErrInvalidMFACode
+	}
+
+	used, err := uc.mfa.UseRecoveryCode(ctx, current.UserID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("uc.mfa.UseRecoveryCode: %w", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (uc *UseCase) newRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes := make([]string, 0, _recoveryCodeCount)
+	hashes := make([]string, 0, _recoveryCodeCount)
+	for len(codes) < _recoveryCodeCount {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("rand.Read: %w", err)
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code := raw[:4] + "-" + raw[4:]
+		if slices.Contains(codes, code) {
+			continue
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	if err := uc.mfa.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("uc.mfa.ReplaceRecoveryCodes: %w", err)
+	}
+
+	return codes, nil
+}
+
+func (uc *UseCase) enabledTOTP(ctx context.Context, userID int64) (entity.TOTP, error) {
+	current, err := uc.mfa.GetTOTP(ctx, userID)
+	if err != nil {
+		return entity.TOTP{}, fmt.Errorf("uc.mfa.GetTOTP: %w", err)
+	}
+	if !current.Enabled() {
+		return entity.TOTP{}, ErrMFANotEnrolled
+	}
+
+	return current, nil
+}
+
+func (uc *UseCase) mfaPrincipal(ctx context.Context) (entity.Principal, error) {
+	if uc.mfa == nil {
+		return entity.Principal{}, ErrMFANotConfigured
+	}
+
+	principal, ok := entity.PrincipalFromContext(ctx)
+	if !ok {
+		return entity.Principal{}, ErrNotAuthenticated
+	}
+
+	return principal, nil
+}
+
+// totpAdditionalData binds a sealed secret to its user, so it cannot be copied to another row.
+func totpAdditionalData(userID int64) []byte {
+	return []byte("totp:" + strconv.FormatInt(userID, 10))
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
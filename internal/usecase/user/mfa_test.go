@@ -0,0 +1,298 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+	"github.com/ducnpdev/godev-kit/pkg/secretbox"
+	"github.com/ducnpdev/godev-kit/pkg/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeMFARepo struct {
+	totp     map[int64]entity.TOTP
+	recovery map[int64]map[string]bool
+}
+
+func newFakeMFARepo() *fakeMFARepo {
+	return &fakeMFARepo{totp: map[int64]entity.TOTP{}, recovery: map[int64]map[string]bool{}}
+}
+
+func (f *fakeMFARepo) GetTOTP(_ context.Context, userID int64) (entity.TOTP, error) {
+	t := f.totp[userID]
+	t.UserID = userID
+	return t, nil
+}
+
+func (f *fakeMFARepo) SetTOTPSecret(_ context.Context, userID int64, sealed string) error {
+	f.totp[userID] = entity.TOTP{UserID: userID, Secret: sealed}
+	return nil
+}
+
+func (f *fakeMFARepo) EnableTOTP(_ context.Context, userID int64) error {
+	t := f.totp[userID]
+	now := time.Now()
+	t.EnabledAt = &now
+	f.totp[userID] = t
+	return nil
+}
+
+func (f *fakeMFARepo) DisableTOTP(_ context.Context, userID int64) error {
+	delete(f.totp, userID)
+	delete(f.recovery, userID)
+	return nil
+}
+
+func (f *fakeMFARepo) UseTOTPStep(_ context.Context, userID, step int64) (bool, error) {
+	t := f.totp[userID]
+	if t.LastStep >= step {
+		return false, nil
+	}
+	t.LastStep = step
+	f.totp[userID] = t
+	return true, nil
+}
+
+func (f *fakeMFARepo) ReplaceRecoveryCodes(_ context.Context, userID int64, hashes []string) error {
+	f.recovery[userID] = map[string]bool{}
+	for _, h := range hashes {
+		f.recovery[userID][h] = false
+	}
+	return nil
+}
+
+func (f *fakeMFARepo) UseRecoveryCode(_ context.Context, userID int64, hash string) (bool, error) {
+	used, ok := f.recovery[userID][hash]
+	if !ok || used {
+		return false, nil
+	}
+	f.recovery[userID][hash] = true
+	return true, nil
+}
+
+var _ repo.MFARepo = (*fakeMFARepo)(nil)
+
+func newMFAUseCase(t *testing.T, opts ...Option) (*UseCase, *fakeMFARepo) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := &fakeUserRepo{users: map[int64]entity.User{
+		1: {ID: 1, Email: "alice@example.com", Password: string(hash)},
+	}}
+
+	box, err := secretbox.New(bytes.Repeat([]byte{7}, secretbox.KeySize))
+	require.NoError(t, err)
+	mfa := newFakeMFARepo()
+
+	uc := New(users, jwtkeys.NewHMAC("secret"), append([]Option{
+		Tokens(newFakeRefreshTokenRepo(), fakeDenylist{}),
+		MFA(mfa, box, "Godev Kit"),
+		MFAChallengeTTL(time.Hour),
+	}, opts...)...)
+
+	return uc, mfa
+}
+
+func TestUseCase_TOTP(t *testing.T) {
+	ctx := context.Background()
+	uc, mfa := newMFAUseCase(t)
+	// Each accepted code must come from a later time step, so the clock moves
+	// forward; it starts in the past to keep issued tokens valid for the JWT parser
+	now := time.Now().Add(-10 * time.Minute)
+	uc.now = func() time.Time { return now }
+	nextCode := func(secret string) string {
+		now = now.Add(totp.Period)
+		code, err := totp.Code(secret, now)
+		require.NoError(t, err)
+		return code
+	}
+
+	_, err := uc.EnrollTOTP(ctx)
+	assert.ErrorIs(t, err, ErrNotAuthenticated)
+
+	alice := entity.ContextWithPrincipal(ctx, entity.Principal{UserID: 1, Email: "alice@example.com"})
+
+	enrollment, err := uc.EnrollTOTP(alice)
+	require.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/")
+	assert.Equal(t, []byte("\x89PNG"), enrollment.QRCode[:4])
+	assert.NotContains(t, mfa.totp[1].Secret, enrollment.Secret, "secret must be sealed at rest")
+
+	// Pending enrollments do not affect login
+	tokens, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+
+	_, err = uc.ConfirmTOTP(alice, "000000")
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+
+	recoveryCodes, err := uc.ConfirmTOTP(alice, nextCode(enrollment.Secret))
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, 10)
+
+	_, err = uc.EnrollTOTP(alice)
+	assert.ErrorIs(t, err, ErrMFAAlreadyEnabled)
+
+	t.Run("login returns a challenge", func(t *testing.T) {
+		challenge, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		assert.Empty(t, challenge.AccessToken)
+		assert.NotEmpty(t, challenge.MFAToken)
+
+		_, err = ParseAccessToken(challenge.MFAToken, jwtkeys.NewHMAC("secret").Keyfunc)
+		assert.ErrorIs(t, err, ErrInvalidAccessToken, "a challenge is not an access token")
+
+		tokens, user, err := uc.CompleteMFALogin(ctx, challenge.MFAToken, nextCode(enrollment.Secret), "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.Equal(t, int64(1), user.ID)
+
+		_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, nextCode(enrollment.Secret), "")
+		assert.ErrorIs(t, err, ErrInvalidMFAToken, "challenges are single use")
+
+		_, _, err = uc.CompleteMFALogin(ctx, tokens.AccessToken, nextCode(enrollment.Secret), "")
+		assert.ErrorIs(t, err, ErrInvalidMFAToken, "an access token is not a challenge")
+	})
+
+	t.Run("a code works once", func(t *testing.T) {
+		code := nextCode(enrollment.Secret)
+
+		challenge, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, code, "")
+		require.NoError(t, err)
+
+		challenge, _, err = uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, code, "")
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+	})
+
+	t.Run("recovery codes work once", func(t *testing.T) {
+		challenge, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, recoveryCodes[0], "")
+		require.NoError(t, err)
+
+		challenge, _, err = uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, recoveryCodes[0], "")
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+
+		// Regenerating needs an app code and invalidates the old codes
+		_, err = uc.RegenerateRecoveryCodes(alice, recoveryCodes[1])
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+		fresh, err := uc.RegenerateRecoveryCodes(alice, nextCode(enrollment.Secret))
+		require.NoError(t, err)
+		assert.NotContains(t, fresh, recoveryCodes[1])
+
+		challenge, _, err = uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, recoveryCodes[1], "")
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+		recoveryCodes = fresh
+	})
+
+	t.Run("challenges need the denylist", func(t *testing.T) {
+		challenge, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+
+		noDenylist, _ := newMFAUseCase(t, Tokens(newFakeRefreshTokenRepo(), nil))
+		_, _, err = noDenylist.CompleteMFALogin(ctx, challenge.MFAToken, nextCode(enrollment.Secret), "")
+		assert.ErrorIs(t, err, ErrMFANotConfigured)
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		assert.ErrorIs(t, uc.DisableTOTP(alice, "000000"), ErrInvalidMFACode)
+		require.NoError(t, uc.DisableTOTP(alice, recoveryCodes[0]))
+
+		tokens, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.Empty(t, tokens.MFAToken)
+	})
+}
+
+func TestUseCase_MFALockout(t *testing.T) {
+	ctx := context.Background()
+	alice := entity.ContextWithPrincipal(ctx, entity.Principal{UserID: 1, Email: "alice@example.com"})
+
+	setup := func(t *testing.T) (*UseCase, *fakeLoginAttemptRepo, func() string) {
+		attempts := &fakeLoginAttemptRepo{failures: map[string]int64{}, locks: map[string]time.Duration{}}
+		uc, _ := newMFAUseCase(t, LoginProtection(attempts, LoginPolicy{MaxFailures: 3, MaxIPFailures: 5, LockDuration: time.Hour}))
+		uc.sleep = func(context.Context, time.Duration) error { return nil }
+
+		now := time.Now().Add(-10 * time.Minute)
+		uc.now = func() time.Time { return now }
+		enrollment, err := uc.EnrollTOTP(alice)
+		require.NoError(t, err)
+		nextCode := func() string {
+			now = now.Add(totp.Period)
+			code, err := totp.Code(enrollment.Secret, now)
+			require.NoError(t, err)
+			return code
+		}
+		_, err = uc.ConfirmTOTP(alice, nextCode())
+		require.NoError(t, err)
+
+		return uc, attempts, nextCode
+	}
+
+	t.Run("wrong codes lock the account", func(t *testing.T) {
+		uc, attempts, _ := setup(t)
+
+		_, _, err := uc.Login(ctx, "alice@example.com", "wrong-password", "192.0.2.1")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+		for range 2 {
+			challenge, _, err := uc.Login(ctx, "alice@example.com", "password123", "192.0.2.1")
+			require.NoError(t, err)
+			_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, "000000", "192.0.2.1")
+			assert.ErrorIs(t, err, ErrInvalidMFACode)
+		}
+		assert.Equal(t, int64(3), attempts.failures[ipAttemptKey("192.0.2.1")])
+
+		// The password alone never cleared the count, so the account is locked now
+		_, _, err = uc.Login(ctx, "alice@example.com", "password123", "192.0.2.2")
+		assert.ErrorIs(t, err, ErrAccountLocked)
+	})
+
+	t.Run("a locked account cannot finish a pending challenge", func(t *testing.T) {
+		uc, attempts, nextCode := setup(t)
+
+		challenge, _, err := uc.Login(ctx, "alice@example.com", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		require.NoError(t, attempts.Lock(ctx, emailAttemptKey("alice@example.com"), time.Hour))
+
+		_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, nextCode(), "192.0.2.1")
+		assert.ErrorIs(t, err, ErrAccountLocked)
+	})
+
+	t.Run("only a valid code clears the email", func(t *testing.T) {
+		uc, attempts, nextCode := setup(t)
+
+		challenge, _, err := uc.Login(ctx, "alice@example.com", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		_, _, err = uc.CompleteMFALogin(ctx, challenge.MFAToken, "000000", "192.0.2.1")
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+		assert.Equal(t, int64(1), attempts.failures[emailAttemptKey("alice@example.com")])
+
+		challenge, _, err = uc.Login(ctx, "alice@example.com", "password123", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), attempts.failures[emailAttemptKey("alice@example.com")])
+
+		tokens, _, err := uc.CompleteMFALogin(ctx, challenge.MFAToken, nextCode(), "192.0.2.1")
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.Zero(t, attempts.failures[emailAttemptKey("alice@example.com")])
+		assert.Equal(t, int64(1), attempts.failures[ipAttemptKey("192.0.2.1")])
+	})
+}
@@ -0,0 +1,245 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/internal/repo/persistent/models"
+	"github.com/ducnpdev/godev-kit/pkg/oidc"
+	"github.com/jackc/pgx/v5"
+)
+
+const _defaultOIDCStateTTL = 10 * time.Minute
+
+// OpenID Connect errors.
+var (
+	ErrOIDCNotConfigured     = errors.New("oidc login is not configured")
+	ErrInvalidOIDCState      = errors.New("invalid or expired oidc state")
+	ErrOIDCLoginFailed       = errors.New("oidc login failed")
+	ErrOIDCEmailNotVerified  = errors.New("oidc email address is not verified")
+	ErrOIDCDomainNotAllowed  = errors.New("oidc email domain is not allowed")
+	ErrOIDCUserDeleted       = errors.New("oidc login of a deleted user")
+	ErrOIDCAccountUnverified = errors.New("oidc email belongs to an unverified account")
+)
+
+// IdentityProvider is the OpenID Connect provider users log in with, e.g. an *oidc.Client.
+type IdentityProvider interface {
+	Issuer() string
+	AuthCodeURL(state, nonce, codeChallenge string) string
+	// Authenticate redeems the code and returns the claims of the validated ID token.
+	Authenticate(ctx context.Context, code, codeVerifier, nonce string) (oidc.Claims, error)
+}
+
+// OIDCConfig configures login through an OpenID Connect provider.
+type OIDCConfig struct {
+	// AllowedDomains restricts logins to email addresses of these domains; empty allows any.
+	AllowedDomains []string
+	// StateTTL bounds the time between the redirect and the callback. Zero keeps the default.
+	StateTTL time.Duration
+}
+
+// OIDC enables login through provider. Users are found by their linked
+// identity, linked by verified email or created on their first login.
+func OIDC(provider IdentityProvider, states repo.OIDCStateRepo, identities repo.UserIdentityRepo, cfg OIDCConfig) Option {
+	return func(uc *UseCase) {
+		uc.oidc = provider
+		uc.oidcStates = states
+		uc.identities = identities
+		uc.oidcDomains = make([]string, 0, len(cfg.AllowedDomains))
+		for _, d := range cfg.AllowedDomains {
+			uc.oidcDomains = append(uc.oidcDomains, strings.ToLower(strings.TrimPrefix(d, "@")))
+		}
+		if cfg.StateTTL > 0 {
+			uc.oidcStateTTL = cfg.StateTTL
+		}
+	}
+}
+
+// StartOIDCLogin returns the provider URL to send the browser to. The nonce
+// and PKCE verifier stay on the server until CompleteOIDCLogin; the caller
+// binds the state to the browser.
+func (uc *UseCase) StartOIDCLogin(ctx context.Context) (entity.OIDCLoginRedirect, error) {
+	if uc.oidc == nil {
+		return entity.OIDCLoginRedirect{}, ErrOIDCNotConfigured
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return entity.OIDCLoginRedirect{}, fmt.Errorf("UserUseCase - StartOIDCLogin - %w", err)
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return entity.OIDCLoginRedirect{}, fmt.Errorf("UserUseCase - StartOIDCLogin - %w", err)
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return entity.OIDCLoginRedirect{}, fmt.Errorf("UserUseCase - StartOIDCLogin - %w", err)
+	}
+
+	login := entity.OIDCLoginState{Nonce: nonce, CodeVerifier: verifier}
+	if err := uc.oidcStates.Save(ctx, state, login, uc.oidcStateTTL); err != nil {
+		return entity.OIDCLoginRedirect{}, fmt.Errorf("UserUseCase - StartOIDCLogin - uc.oidcStates.Save: %w", err)
+	}
+
+	return entity.OIDCLoginRedirect{
+		URL:       uc.oidc.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier)),
+		State:     state,
+		ExpiresAt: uc.now().Add(uc.oidcStateTTL),
+	}, nil
+}
+
+// CompleteOIDCLogin handles the provider's callback and issues the token
+// pair for the user behind the ID token. TOTP is not asked for; the provider
+// is trusted to enforce its own second factor.
+func (uc *UseCase) CompleteOIDCLogin(ctx context.Context, state, code string) (entity.AuthTokens, entity.User, error) {
+	if uc.oidc == nil {
+		return entity.AuthTokens{}, entity.User{}, ErrOIDCNotConfigured
+	}
+
+	login, ok, err := uc.oidcStates.Take(ctx, state)
+	if err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteOIDCLogin - uc.oidcStates.Take: %w", err)
+	}
+	if !ok || code == "" {
+		return entity.AuthTokens{}, entity.User{}, ErrInvalidOIDCState
+	}
+
+	claims, err := uc.oidc.Authenticate(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidIDToken) || errors.Is(err, oidc.ErrTokenExchange) {
+			return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteOIDCLogin - %w: %w", ErrOIDCLoginFailed, err)
+		}
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteOIDCLogin - uc.oidc.Authenticate: %w", err)
+	}
+
+	user, err := uc.oidcUser(ctx, claims)
+	if err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteOIDCLogin - %w", err)
+	}
+	user.Password = ""
+
+	tokens, err := uc.issueTokens(ctx, user, "")
+	if err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - CompleteOIDCLogin - uc.issueTokens: %w", err)
+	}
+
+	return tokens, user, nil
+}
+
+// oidcUser returns the user linked to the identity in claims, linking a user
+// with the same email or creating one when there is none.
+func (uc *UseCase) oidcUser(ctx context.Context, claims oidc.Claims) (entity.User, error) {
+	identity, err := uc.identities.GetBySubject(ctx, claims.Issuer, claims.Subject)
+	switch {
+	case err == nil:
+		user, err := uc.repo.GetByID(ctx, identity.UserID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.User{}, ErrOIDCUserDeleted
+		}
+		if err != nil {
+			return entity.User{}, fmt.Errorf("uc.repo.GetByID: %w", err)
+		}
+		return user, nil
+	case !errors.Is(err, pgx.ErrNoRows):
+		return entity.User{}, fmt.Errorf("uc.identities.GetBySubject: %w", err)
+	}
+
+	// Linking by email is only safe when the provider vouches for the address
+	if claims.Email == "" || !claims.EmailVerified {
+		return entity.User{}, ErrOIDCEmailNotVerified
+	}
+	if !uc.oidcDomainAllowed(claims.Email) {
+		return entity.User{}, ErrOIDCDomainNotAllowed
+	}
+
+	user, err := uc.repo.GetByEmail(ctx, claims.Email)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		if user, err = uc.createOIDCUser(ctx, claims); err != nil {
+			return entity.User{}, err
+		}
+	case err != nil:
+		return entity.User{}, fmt.Errorf("uc.repo.GetByEmail: %w", err)
+	case user.EmailVerifiedAt == nil:
+		// Anyone can register an address they don't own; linking it would hand
+		// the squatter's password and sessions the provider's account
+		return entity.User{}, ErrOIDCAccountUnverified
+	}
+
+	_, err = uc.identities.Create(ctx, entity.UserIdentity{
+		UserID:    user.ID,
+		Issuer:    claims.Issuer,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: uc.now(),
+	})
+	if err != nil {
+		return entity.User{}, fmt.Errorf("uc.identities.Create: %w", err)
+	}
+
+	return user, nil
+}
+
+// createOIDCUser creates a user for a first OIDC login. The password is a
+// random secret nobody knows; the user can set one with a password reset.
+func (uc *UseCase) createOIDCUser(ctx context.Context, claims oidc.Claims) (entity.User, error) {
+	secret, err := newOpaqueToken()
+	if err != nil {
+		return entity.User{}, fmt.Errorf("newOpaqueToken: %w", err)
+	}
+	hashedPassword, err := uc.hashPassword(secret)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	var user entity.User
+	err = uc.inTx(ctx, func(ctx context.Context) error {
+		// Usernames are unique and the email is the one value known to be
+		user, err = uc.repo.Create(ctx, models.UserModel{
+			Email:    claims.Email,
+			Username: claims.Email,
+			Password: hashedPassword,
+		})
+		if err != nil {
+			return fmt.Errorf("uc.repo.Create: %w", err)
+		}
+
+		if err := uc.repo.MarkEmailVerified(ctx, user.ID); err != nil {
+			return fmt.Errorf("uc.repo.MarkEmailVerified: %w", err)
+		}
+		now := uc.now()
+		user.EmailVerifiedAt = &now
+
+		if uc.roles != nil {
+			if err := uc.roles.Assign(ctx, user.ID, entity.RoleUser); err != nil {
+				return fmt.Errorf("uc.roles.Assign: %w", err)
+			}
+			user.Roles = []string{entity.RoleUser}
+		}
+
+		return uc.recordUserEvent(ctx, entity.UserCreatedEvent, user, map[string]string{
+			"source": "oidc",
+			"issuer": claims.Issuer,
+		})
+	})
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	return user, nil
+}
+
+func (uc *UseCase) oidcDomainAllowed(email string) bool {
+	if len(uc.oidcDomains) == 0 {
+		return true
+	}
+
+	_, domain, ok := strings.Cut(email, "@")
+	return ok && slices.Contains(uc.oidcDomains, strings.ToLower(domain))
+}
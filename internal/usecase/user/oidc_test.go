@@ -0,0 +1,152 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/oidc"
+	"github.com/ducnpdev/godev-kit/pkg/oidc/oidctest"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOIDCStateRepo map[string]entity.OIDCLoginState
+
+func (f fakeOIDCStateRepo) Save(_ context.Context, state string, login entity.OIDCLoginState, _ time.Duration) error {
+	f[state] = login
+	return nil
+}
+
+func (f fakeOIDCStateRepo) Take(_ context.Context, state string) (entity.OIDCLoginState, bool, error) {
+	login, ok := f[state]
+	delete(f, state)
+	return login, ok, nil
+}
+
+type fakeUserIdentityRepo struct {
+	identities []entity.UserIdentity
+}
+
+func (f *fakeUserIdentityRepo) GetBySubject(_ context.Context, issuer, subject string) (entity.UserIdentity, error) {
+	for _, i := range f.identities {
+		if i.Issuer == issuer && i.Subject == subject {
+			return i, nil
+		}
+	}
+	return entity.UserIdentity{}, pgx.ErrNoRows
+}
+
+func (f *fakeUserIdentityRepo) Create(_ context.Context, identity entity.UserIdentity) (entity.UserIdentity, error) {
+	identity.ID = int64(len(f.identities) + 1)
+	f.identities = append(f.identities, identity)
+	return identity, nil
+}
+
+var (
+	_ repo.OIDCStateRepo    = fakeOIDCStateRepo{}
+	_ repo.UserIdentityRepo = (*fakeUserIdentityRepo)(nil)
+)
+
+func TestUseCase_OIDCLogin(t *testing.T) {
+	srv, err := oidctest.NewServer("godev-kit", "secret")
+	require.NoError(t, err)
+	defer srv.Close()
+
+	ctx := context.Background()
+	provider, err := oidc.New(ctx, srv.Issuer(), "godev-kit", "secret", "http://localhost:8080/v1/auth/oidc/callback")
+	require.NoError(t, err)
+
+	identities := &fakeUserIdentityRepo{}
+	roles := newFakeRoleRepo()
+	events := &fakeEventPublisher{}
+	uc, users, _ := newAccountUseCase(t,
+		Roles(roles),
+		Events(events),
+		OIDC(provider, fakeOIDCStateRepo{}, identities, OIDCConfig{AllowedDomains: []string{"@Example.com"}}),
+	)
+
+	// login runs the browser's part: to the provider and back to the callback
+	login := func(t *testing.T) (entity.AuthTokens, entity.User, error) {
+		t.Helper()
+
+		redirect, err := uc.StartOIDCLogin(ctx)
+		require.NoError(t, err)
+
+		code, state, err := srv.Authorize(redirect.URL)
+		require.NoError(t, err)
+		require.Equal(t, redirect.State, state)
+
+		return uc.CompleteOIDCLogin(ctx, state, code)
+	}
+
+	t.Run("creates a verified user on the first login", func(t *testing.T) {
+		srv.SetUser(oidctest.User{Subject: "staff-1", Email: "carol@example.com", EmailVerified: true})
+
+		tokens, user, err := login(t)
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.NotEmpty(t, tokens.RefreshToken)
+		assert.Equal(t, "carol@example.com", user.Email)
+		assert.NotNil(t, users.users[user.ID].EmailVerifiedAt)
+		assert.Equal(t, []string{entity.RoleUser}, roles.users[user.ID])
+		assert.Equal(t, []string{entity.UserCreatedEvent}, events.events)
+
+		_, again, err := login(t)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, again.ID, "the identity is linked")
+		assert.Len(t, identities.identities, 1)
+	})
+
+	t.Run("links an existing user by verified email", func(t *testing.T) {
+		srv.SetUser(oidctest.User{Subject: "staff-2", Email: "alice@example.com", EmailVerified: true})
+
+		_, user, err := login(t)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), user.ID)
+	})
+
+	t.Run("refuses a pre-registered unverified account", func(t *testing.T) {
+		srv.SetUser(oidctest.User{Subject: "staff-5", Email: "bob@example.com", EmailVerified: true})
+
+		_, _, err := login(t)
+		assert.ErrorIs(t, err, ErrOIDCAccountUnverified)
+		assert.Nil(t, users.users[2].EmailVerifiedAt)
+		for _, identity := range identities.identities {
+			assert.NotEqual(t, int64(2), identity.UserID)
+		}
+	})
+
+	t.Run("rejects unverified emails and other domains", func(t *testing.T) {
+		srv.SetUser(oidctest.User{Subject: "staff-3", Email: "dave@example.com"})
+		_, _, err := login(t)
+		assert.ErrorIs(t, err, ErrOIDCEmailNotVerified)
+
+		srv.SetUser(oidctest.User{Subject: "staff-4", Email: "eve@elsewhere.com", EmailVerified: true})
+		_, _, err = login(t)
+		assert.ErrorIs(t, err, ErrOIDCDomainNotAllowed)
+	})
+
+	t.Run("states are single use", func(t *testing.T) {
+		redirect, err := uc.StartOIDCLogin(ctx)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(_defaultOIDCStateTTL), redirect.ExpiresAt, time.Minute)
+		code, state, err := srv.Authorize(redirect.URL)
+		require.NoError(t, err)
+
+		_, _, err = uc.CompleteOIDCLogin(ctx, state, "wrong-code")
+		assert.ErrorIs(t, err, ErrOIDCLoginFailed)
+
+		_, _, err = uc.CompleteOIDCLogin(ctx, state, code)
+		assert.ErrorIs(t, err, ErrInvalidOIDCState)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		plain, _, _ := newAccountUseCase(t)
+		_, err := plain.StartOIDCLogin(ctx)
+		assert.ErrorIs(t, err, ErrOIDCNotConfigured)
+	})
+}
@@ -0,0 +1,83 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
+)
+
+// Outbox makes the lifecycle events (user.created, user.updated, user.deleted
+// and user.restored) part of the change they report: they are written to the
+// outbox in its transaction and sent to topic by the outbox relay. Without it
+// they go through Events, best effort.
+func Outbox(tx repo.Transactor, outbox repo.OutboxRepo, topic string) Option {
+	return func(uc *UseCase) {
+		uc.tx = tx
+		uc.outbox = outbox
+		uc.outboxTopic = topic
+	}
+}
+
+// inTx runs fn in a transaction when Outbox is configured.
+func (uc *UseCase) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if uc.tx == nil {
+		return fn(ctx)
+	}
+	return uc.tx.InTx(ctx, fn)
+}
+
+// recordUserEvent writes a lifecycle event to the outbox; ctx must carry the
+// transaction of the change. Without an outbox the event is published right away.
+func (uc *UseCase) recordUserEvent(ctx context.Context, eventType string, user entity.User, data any) error {
+	if uc.outbox == nil {
+		if uc.events != nil {
+			uc.events.PublishUserEvent(ctx, eventType, user.ID, user.Email, data)
+		}
+		return nil
+	}
+
+	// The row ID doubles as the event ID, unique and the same on every resend
+	id, err := uc.outbox.NextID(ctx)
+	if err != nil {
+		return fmt.Errorf("uc.outbox.NextID: %w", err)
+	}
+
+	now := uc.now()
+	payload, err := json.Marshal(entity.UserEvent{
+		ID:        id,
+		EventType: eventType,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Data:      data,
+		Timestamp: now,
+	})
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	// The relay sends later; the headers keep the request the event came from
+	headers := map[string]string{kafka.HeaderEventType: eventType}
+	tracectx.Inject(ctx, func(key, value string) { headers[key] = value })
+
+	// Keyed by user so the events of one user keep their order in a partition
+	err = uc.outbox.Add(ctx, entity.OutboxEvent{
+		ID:        id,
+		Topic:     uc.outboxTopic,
+		Key:       strconv.FormatInt(user.ID, 10),
+		EventType: eventType,
+		Payload:   payload,
+		Headers:   headers,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("uc.outbox.Add: %w", err)
+	}
+
+	return nil
+}
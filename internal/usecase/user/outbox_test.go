@@ -0,0 +1,132 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/kafka"
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransactor runs fn and records whether the transaction would commit.
+type fakeTransactor struct {
+	commits, rollbacks int
+}
+
+func (f *fakeTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		f.rollbacks++
+		return err
+	}
+	f.commits++
+	return nil
+}
+
+type fakeOutboxRepo struct {
+	events []entity.OutboxEvent
+	lastID int64
+	err    error
+}
+
+func (f *fakeOutboxRepo) NextID(context.Context) (int64, error) {
+	f.lastID++
+	return f.lastID, nil
+}
+
+func (f *fakeOutboxRepo) Add(_ context.Context, event entity.OutboxEvent) error {
+	if f.err != nil {
+		return f.err
+	}
+	if event.ID == 0 {
+		event.ID, _ = f.NextID(context.Background())
+	}
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeOutboxRepo) TryLock(context.Context) (bool, error) { return true, nil }
+
+func (f *fakeOutboxRepo) Pending(_ context.Context, limit uint64) ([]entity.OutboxEvent, error) {
+	var pending []entity.OutboxEvent
+	for _, e := range f.events {
+		if e.PublishedAt == nil && uint64(len(pending)) < limit {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeOutboxRepo) MarkPublished(context.Context, []int64) error { return nil }
+
+func (f *fakeOutboxRepo) MarkFailed(context.Context, int64, string) error { return nil }
+
+var (
+	_ repo.Transactor = (*fakeTransactor)(nil)
+	_ repo.OutboxRepo = (*fakeOutboxRepo)(nil)
+)
+
+func TestUseCase_Outbox(t *testing.T) {
+	ctx := tracectx.WithRequestID(context.Background(), "req-1")
+	tx := &fakeTransactor{}
+	outbox := &fakeOutboxRepo{}
+	events := &fakeEventPublisher{}
+	uc, _, _ := newAccountUseCase(t, Events(events), Outbox(tx, outbox, "dev.user-events"))
+
+	userEvent := func(t *testing.T, e entity.OutboxEvent) entity.UserEvent {
+		t.Helper()
+
+		var event entity.UserEvent
+		require.NoError(t, json.Unmarshal(e.Payload, &event))
+		assert.Equal(t, "dev.user-events", e.Topic)
+		assert.Equal(t, e.EventType, event.EventType)
+		assert.Equal(t, e.EventType, e.Headers[kafka.HeaderEventType])
+		assert.Equal(t, "req-1", e.Headers[tracectx.HeaderRequestID], "the relay sends the request ID along")
+		return event
+	}
+
+	created, err := uc.Create(ctx, entity.User{Email: "carol@example.com", Username: "carol", Password: "password123"})
+	require.NoError(t, err)
+	require.NoError(t, uc.Update(ctx, entity.User{ID: created.ID, Username: "caroline", Password: "password456"}))
+	require.NoError(t, uc.Delete(ctx, created.ID))
+	require.NoError(t, uc.Restore(ctx, created.ID))
+
+	require.Len(t, outbox.events, 4)
+	assert.Equal(t, 4, tx.commits)
+	assert.Empty(t, events.events, "lifecycle events only go through the outbox")
+
+	event := userEvent(t, outbox.events[0])
+	assert.Equal(t, outbox.events[0].ID, event.ID, "the event carries the ID of its outbox row")
+	assert.Equal(t, entity.UserCreatedEvent, event.EventType)
+	assert.Equal(t, created.ID, event.UserID)
+	assert.Equal(t, "carol@example.com", event.Email)
+	assert.Equal(t, "3", outbox.events[0].Key)
+
+	event = userEvent(t, outbox.events[1])
+	assert.Equal(t, entity.UserUpdatedEvent, event.EventType)
+	assert.Equal(t, map[string]any{"fields": []any{"username", "password"}}, event.Data)
+
+	assert.Equal(t, entity.UserDeletedEvent, userEvent(t, outbox.events[2]).EventType)
+	assert.Equal(t, entity.UserRestoredEvent, userEvent(t, outbox.events[3]).EventType)
+
+	t.Run("unknown users write nothing", func(t *testing.T) {
+		assert.ErrorIs(t, uc.Update(ctx, entity.User{ID: 99, Username: "nobody"}), ErrUserNotFound)
+		assert.ErrorIs(t, uc.Delete(ctx, 99), ErrUserNotFound)
+		assert.Len(t, outbox.events, 4)
+	})
+
+	t.Run("a failed outbox write fails the change", func(t *testing.T) {
+		outbox.err = errors.New("connection reset")
+		defer func() { outbox.err = nil }()
+
+		rollbacks := tx.rollbacks
+		_, err := uc.Create(ctx, entity.User{Email: "dave@example.com", Username: "dave", Password: "password123"})
+		assert.Error(t, err)
+		assert.Equal(t, rollbacks+1, tx.rollbacks)
+	})
+}
@@ -0,0 +1,159 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo/persistent/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// _minAdminPasswordLength is stricter than sign-up, the account holds every permission.
+const _minAdminPasswordLength = 12
+
+// Role management errors.
+var (
+	ErrRolesNotConfigured = errors.New("roles are not configured")
+	ErrUnknownRole        = errors.New("unknown role")
+	ErrRoleNotAssigned    = errors.New("role not assigned")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrWeakAdminPassword  = errors.New("admin password must be at least 12 characters")
+)
+
+// ListRoles returns every role with its permissions.
+func (uc *UseCase) ListRoles(ctx context.Context) ([]entity.Role, error) {
+	if uc.roles == nil {
+		return nil, ErrRolesNotConfigured
+	}
+
+	roles, err := uc.roles.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("UserUseCase - ListRoles - uc.roles.List: %w", err)
+	}
+
+	return roles, nil
+}
+
+// UserRoles returns the roles of a user and the permissions they grant.
+func (uc *UseCase) UserRoles(ctx context.Context, userID int64) (roles, permissions []string, err error) {
+	if uc.roles == nil {
+		return nil, nil, ErrRolesNotConfigured
+	}
+
+	if err := uc.userExists(ctx, userID); err != nil {
+		return nil, nil, fmt.Errorf("UserUseCase - UserRoles: %w", err)
+	}
+
+	roles, permissions, err = uc.roles.UserAccess(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("UserUseCase - UserRoles - uc.roles.UserAccess: %w", err)
+	}
+
+	return roles, permissions, nil
+}
+
+// AssignRole grants role to a user. It takes effect when the user next logs in or refreshes.
+func (uc *UseCase) AssignRole(ctx context.Context, userID int64, role string) error {
+	if uc.roles == nil {
+		return ErrRolesNotConfigured
+	}
+
+	if err := uc.userExists(ctx, userID); err != nil {
+		return fmt.Errorf("UserUseCase - AssignRole: %w", err)
+	}
+
+	if err := uc.roles.Assign(ctx, userID, role); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUnknownRole
+		}
+		return fmt.Errorf("UserUseCase - AssignRole - uc.roles.Assign: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeRole removes role from a user. It takes effect when the user next logs in or refreshes.
+func (uc *UseCase) RevokeRole(ctx context.Context, userID int64, role string) error {
+	if uc.roles == nil {
+		return ErrRolesNotConfigured
+	}
+
+	revoked, err := uc.roles.Revoke(ctx, userID, role)
+	if err != nil {
+		return fmt.Errorf("UserUseCase - RevokeRole - uc.roles.Revoke: %w", err)
+	}
+	if !revoked {
+		return ErrRoleNotAssigned
+	}
+
+	return nil
+}
+
+func (uc *UseCase) userExists(ctx context.Context, userID int64) error {
+	if _, err := uc.repo.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("uc.repo.GetByID: %w", err)
+	}
+
+	return nil
+}
+
+// BootstrapAdmin creates a verified administrator with email and password
+// unless a user with that email exists; an existing account is left untouched.
+// An empty email does nothing.
+func (uc *UseCase) BootstrapAdmin(ctx context.Context, email, password string) error {
+	if email == "" {
+		return nil
+	}
+	if uc.roles == nil {
+		return ErrRolesNotConfigured
+	}
+
+	_, err := uc.repo.GetByEmail(ctx, email)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("UserUseCase - BootstrapAdmin - uc.repo.GetByEmail: %w", err)
+	}
+
+	if len(password) < _minAdminPasswordLength {
+		return ErrWeakAdminPassword
+	}
+	hashedPassword, err := uc.hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("UserUseCase - BootstrapAdmin - uc.hashPassword: %w", err)
+	}
+
+	err = uc.inTx(ctx, func(ctx context.Context) error {
+		admin, err := uc.repo.Create(ctx, models.UserModel{
+			Email:    email,
+			Username: "admin",
+			Password: hashedPassword,
+		})
+		if err != nil {
+			return fmt.Errorf("uc.repo.Create: %w", err)
+		}
+
+		if err := uc.repo.MarkEmailVerified(ctx, admin.ID); err != nil {
+			return fmt.Errorf("uc.repo.MarkEmailVerified: %w", err)
+		}
+		for _, role := range []string{entity.RoleUser, entity.RoleAdmin} {
+			if err := uc.roles.Assign(ctx, admin.ID, role); err != nil {
+				return fmt.Errorf("uc.roles.Assign: %w", err)
+			}
+		}
+		admin.Roles = []string{entity.RoleUser, entity.RoleAdmin}
+
+		return uc.recordUserEvent(ctx, entity.UserCreatedEvent, admin, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("UserUseCase - BootstrapAdmin - %w", err)
+	}
+
+	return nil
+}
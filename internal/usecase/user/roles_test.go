@@ -0,0 +1,133 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRoleRepo struct {
+	grants map[string][]string
+	users  map[int64][]string
+}
+
+func newFakeRoleRepo() *fakeRoleRepo {
+	return &fakeRoleRepo{
+		grants: map[string][]string{
+			entity.RoleAdmin: {entity.PermissionPaymentsRefund, entity.PermissionUsersDelete},
+			entity.RoleUser:  {},
+		},
+		users: map[int64][]string{},
+	}
+}
+
+func (f *fakeRoleRepo) List(context.Context) ([]entity.Role, error) {
+	var roles []entity.Role
+	for name, perms := range f.grants {
+		roles = append(roles, entity.Role{Name: name, Permissions: perms})
+	}
+	return roles, nil
+}
+
+func (f *fakeRoleRepo) UserAccess(_ context.Context, userID int64) (roles, permissions []string, err error) {
+	roles = slices.Clone(f.users[userID])
+	for _, role := range roles {
+		for _, p := range f.grants[role] {
+			if !slices.Contains(permissions, p) {
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	return roles, permissions, nil
+}
+
+func (f *fakeRoleRepo) Assign(_ context.Context, userID int64, role string) error {
+	if _, ok := f.grants[role]; !ok {
+		return fmt.Errorf("RoleRepo - Assign: %w", pgx.ErrNoRows)
+	}
+	if !slices.Contains(f.users[userID], role) {
+		f.users[userID] = append(f.users[userID], role)
+	}
+	return nil
+}
+
+func (f *fakeRoleRepo) Revoke(_ context.Context, userID int64, role string) (bool, error) {
+	i := slices.Index(f.users[userID], role)
+	if i < 0 {
+		return false, nil
+	}
+	f.users[userID] = slices.Delete(f.users[userID], i, i+1)
+	return true, nil
+}
+
+func TestUseCase_RoleManagement(t *testing.T) {
+	ctx := context.Background()
+	uc, _, _ := newTokenUseCase(t)
+	roles := newFakeRoleRepo()
+	Roles(roles)(uc)
+
+	assert.ErrorIs(t, uc.AssignRole(ctx, 1, "superuser"), ErrUnknownRole)
+	assert.ErrorIs(t, uc.AssignRole(ctx, 99, entity.RoleAdmin), ErrUserNotFound)
+	assert.ErrorIs(t, uc.RevokeRole(ctx, 1, entity.RoleAdmin), ErrRoleNotAssigned)
+
+	require.NoError(t, uc.AssignRole(ctx, 1, entity.RoleAdmin))
+
+	got, perms, err := uc.UserRoles(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{entity.RoleAdmin}, got)
+	assert.ElementsMatch(t, []string{entity.PermissionPaymentsRefund, entity.PermissionUsersDelete}, perms)
+
+	// Roles and permissions are read at login and embedded in the access token
+	tokens, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+	require.NoError(t, err)
+
+	principal, err := ParseAccessToken(tokens.AccessToken, jwtkeys.NewHMAC("secret").Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{entity.RoleAdmin}, principal.Roles)
+	assert.Contains(t, principal.Permissions, entity.PermissionPaymentsRefund)
+
+	require.NoError(t, uc.RevokeRole(ctx, 1, entity.RoleAdmin))
+	got, _, err = uc.UserRoles(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestUseCase_BootstrapAdmin(t *testing.T) {
+	ctx := context.Background()
+	uc, _, _ := newTokenUseCase(t)
+
+	assert.NoError(t, uc.BootstrapAdmin(ctx, "", ""), "no email configured")
+	assert.ErrorIs(t, uc.BootstrapAdmin(ctx, "root@example.com", "long-enough-secret"), ErrRolesNotConfigured)
+
+	roles := newFakeRoleRepo()
+	Roles(roles)(uc)
+
+	assert.ErrorIs(t, uc.BootstrapAdmin(ctx, "root@example.com", "short"), ErrWeakAdminPassword)
+
+	require.NoError(t, uc.BootstrapAdmin(ctx, "root@example.com", "long-enough-secret"))
+
+	admin, err := uc.repo.GetByEmail(ctx, "root@example.com")
+	require.NoError(t, err)
+	assert.NotNil(t, admin.EmailVerifiedAt)
+	assert.ElementsMatch(t, []string{entity.RoleUser, entity.RoleAdmin}, roles.users[admin.ID])
+
+	tokens, _, err := uc.Login(ctx, "root@example.com", "long-enough-secret", "")
+	require.NoError(t, err)
+	principal, err := ParseAccessToken(tokens.AccessToken, jwtkeys.NewHMAC("secret").Keyfunc)
+	require.NoError(t, err)
+	assert.True(t, principal.IsAdmin())
+
+	// A later start leaves the existing accounts alone, whatever the password
+	require.NoError(t, uc.BootstrapAdmin(ctx, "root@example.com", "another-long-secret"))
+	require.NoError(t, uc.BootstrapAdmin(ctx, "alice@example.com", ""))
+	_, _, err = uc.Login(ctx, "root@example.com", "long-enough-secret", "")
+	assert.NoError(t, err)
+	assert.Empty(t, roles.users[1])
+}
@@ -0,0 +1,268 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/usecase"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const _issuer = "godev-kit"
+
+// ErrInvalidAccessToken is returned by ParseAccessToken for any token that
+// does not verify or does not name a user.
+var ErrInvalidAccessToken = usecase.ErrInvalidAccessToken
+
+// AccessTokenParser is a usecase.TokenParser verifying with its keyfunc,
+// typically (*jwtkeys.KeySet).Keyfunc.
+type AccessTokenParser jwt.Keyfunc
+
+// ParseAccessToken -.
+func (p AccessTokenParser) ParseAccessToken(token string) (entity.Principal, error) {
+	return ParseAccessToken(token, jwt.Keyfunc(p))
+}
+
+// ParseAccessToken verifies an access token issued by Login or Refresh with
+// keyfunc and returns its principal.
+func ParseAccessToken(token string, keyfunc jwt.Keyfunc) (entity.Principal, error) {
+	var claims JWTClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, keyfunc); err != nil {
+		return entity.Principal{}, fmt.Errorf("%w: %w", ErrInvalidAccessToken, err)
+	}
+	if slices.Contains(claims.Audience, _mfaAudience) {
+		return entity.Principal{}, fmt.Errorf("%w: mfa challenge is not an access token", ErrInvalidAccessToken)
+	}
+
+	// The user ID is signed as a decimal string subject
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || userID <= 0 {
+		return entity.Principal{}, fmt.Errorf("%w: subject is not a user id", ErrInvalidAccessToken)
+	}
+
+	principal := entity.Principal{
+		UserID:      userID,
+		Email:       claims.Email,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		TokenID:     claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		principal.TokenExpiresAt = claims.ExpiresAt.Time
+	}
+
+	return principal, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token
+// is rotated; presenting it again revokes its whole family.
+func (uc *UseCase) Refresh(ctx context.Context, refreshToken string) (entity.AuthTokens, error) {
+	if uc.refreshTokens == nil {
+		return entity.AuthTokens{}, ErrRefreshNotConfigured
+	}
+
+	stored, err := uc.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.AuthTokens{}, ErrInvalidRefreshToken
+		}
+		return entity.AuthTokens{}, fmt.Errorf("UserUseCase - Refresh - uc.refreshTokens.GetByHash: %w", err)
+	}
+
+	if stored.RevokedAt != nil || !uc.now().Before(stored.ExpiresAt) {
+		return entity.AuthTokens{}, ErrInvalidRefreshToken
+	}
+
+	if stored.RotatedAt != nil {
+		return entity.AuthTokens{}, uc.reused(ctx, stored)
+	}
+
+	rotated, err := uc.refreshTokens.Rotate(ctx, stored.ID)
+	if err != nil {
+		return entity.AuthTokens{}, fmt.Errorf("UserUseCase - Refresh - uc.refreshTokens.Rotate: %w", err)
+	}
+	if !rotated {
+		// Someone else redeemed the same token in the meantime
+		return entity.AuthTokens{}, uc.reused(ctx, stored)
+	}
+
+	// Reload the user; issueTokens re-reads roles, so role changes apply from the next refresh
+	user, err := uc.repo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		return entity.AuthTokens{}, fmt.Errorf("UserUseCase - Refresh - uc.repo.GetByID: %w", err)
+	}
+
+	tokens, err := uc.issueTokens(ctx, user, stored.FamilyID)
+	if err != nil {
+		return entity.AuthTokens{}, fmt.Errorf("UserUseCase - Refresh - uc.issueTokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes the access token of the principal in ctx and, when given,
+// the family of refreshToken. A refresh token of another user is rejected.
+func (uc *UseCase) Logout(ctx context.Context, refreshToken string) error {
+	principal, ok := entity.PrincipalFromContext(ctx)
+	if !ok {
+		return ErrNotAuthenticated
+	}
+
+	if uc.denylist != nil && principal.TokenID != "" {
+		if err := uc.denylist.Revoke(ctx, principal.TokenID, principal.TokenExpiresAt.Sub(uc.now())); err != nil {
+			return fmt.Errorf("UserUseCase - Logout - uc.denylist.Revoke: %w", err)
+		}
+	}
+
+	if refreshToken == "" || uc.refreshTokens == nil {
+		return nil
+	}
+
+	stored, err := uc.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		return fmt.Errorf("UserUseCase - Logout - uc.refreshTokens.GetByHash: %w", err)
+	}
+	if stored.UserID != principal.UserID {
+		return ErrInvalidRefreshToken
+	}
+
+	if err := uc.revokeFamily(ctx, stored.FamilyID); err != nil {
+		return fmt.Errorf("UserUseCase - Logout: %w", err)
+	}
+
+	return nil
+}
+
+// reused handles a rotated refresh token being presented again: the token has
+// most likely leaked, so every token issued from the same login is revoked.
+func (uc *UseCase) reused(ctx context.Context, stored entity.RefreshToken) error {
+	if err := uc.revokeFamily(ctx, stored.FamilyID); err != nil {
+		return fmt.Errorf("UserUseCase - Refresh: %w", err)
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// revokeFamily revokes the refresh token family and denies the access tokens issued with it.
+func (uc *UseCase) revokeFamily(ctx context.Context, familyID string) error {
+	revoked, err := uc.refreshTokens.RevokeFamily(ctx, familyID)
+	if err != nil {
+		return fmt.Errorf("uc.refreshTokens.RevokeFamily: %w", err)
+	}
+
+	return uc.denyAccessTokens(ctx, revoked)
+}
+
+// denyAccessTokens puts the access tokens issued with the revoked refresh tokens on the denylist.
+func (uc *UseCase) denyAccessTokens(ctx context.Context, revoked []entity.RefreshToken) error {
+	if uc.denylist == nil {
+		return nil
+	}
+	for _, t := range revoked {
+		if err := uc.denylist.Revoke(ctx, t.AccessJTI, t.AccessExpiresAt.Sub(uc.now())); err != nil {
+			return fmt.Errorf("uc.denylist.Revoke: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// issueTokens signs an access token for user and, when refresh tokens are
+// configured, stores a new refresh token in familyID (a new family if empty).
+func (uc *UseCase) issueTokens(ctx context.Context, user entity.User, familyID string) (entity.AuthTokens, error) {
+	now := uc.now()
+	jti := uuid.NewString()
+
+	var permissions []string
+	if uc.roles != nil {
+		var err error
+		user.Roles, permissions, err = uc.roles.UserAccess(ctx, user.ID)
+		if err != nil {
+			return entity.AuthTokens{}, fmt.Errorf("uc.roles.UserAccess: %w", err)
+		}
+	}
+
+	tokens := entity.AuthTokens{
+		AccessExpiresAt: now.Add(uc.accessTTL),
+	}
+
+	claims := &JWTClaims{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Roles:       user.Roles,
+		Permissions: permissions,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(tokens.AccessExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    _issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+		},
+	}
+
+	var err error
+	tokens.AccessToken, err = uc.signer.Sign(claims)
+	if err != nil {
+		return entity.AuthTokens{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	if uc.refreshTokens == nil {
+		return tokens, nil
+	}
+
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return entity.AuthTokens{}, err
+	}
+	if familyID == "" {
+		familyID = uuid.NewString()
+	}
+
+	stored, err := uc.refreshTokens.Create(ctx, entity.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       hashToken(refreshToken),
+		AccessJTI:       jti,
+		AccessExpiresAt: tokens.AccessExpiresAt,
+		ExpiresAt:       now.Add(uc.refreshTTL),
+		CreatedAt:       now,
+	})
+	if err != nil {
+		return entity.AuthTokens{}, fmt.Errorf("uc.refreshTokens.Create: %w", err)
+	}
+
+	tokens.RefreshToken = refreshToken
+	tokens.RefreshExpiresAt = stored.ExpiresAt
+
+	return tokens, nil
+}
+
+// newOpaqueToken returns 32 random bytes, base64url encoded.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is the lookup key stored for opaque tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,366 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/internal/repo/persistent/models"
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeUserRepo struct {
+	users map[int64]entity.User
+}
+
+func (f *fakeUserRepo) Create(_ context.Context, m models.UserModel) (entity.User, error) {
+	u := entity.User{ID: int64(len(f.users) + 1), Email: m.Email, Username: m.Username, Password: m.Password}
+	f.users[u.ID] = u
+	return u, nil
+}
+
+func (f *fakeUserRepo) GetByID(_ context.Context, id int64) (entity.User, error) {
+	u, ok := f.users[id]
+	if !ok || u.DeletedAt != nil {
+		return entity.User{}, pgx.ErrNoRows
+	}
+	return u, nil
+}
+
+func (f *fakeUserRepo) GetByEmail(_ context.Context, email string) (entity.User, error) {
+	for _, u := range f.users {
+		if u.Email == email && u.DeletedAt == nil {
+			return u, nil
+		}
+	}
+	return entity.User{}, fmt.Errorf("UserRepo - GetByEmail: %w", pgx.ErrNoRows)
+}
+
+func (f *fakeUserRepo) Update(_ context.Context, m models.UserModel) error {
+	u, ok := f.users[m.ID]
+	if !ok || u.DeletedAt != nil {
+		return nil
+	}
+	if m.Email != "" && m.Email != u.Email {
+		u.Email = m.Email
+		u.EmailVerifiedAt = nil
+	}
+	if m.Username != "" {
+		u.Username = m.Username
+	}
+	if m.Password != "" {
+		u.Password = m.Password
+	}
+	f.users[m.ID] = u
+	return nil
+}
+
+func (f *fakeUserRepo) MarkEmailVerified(_ context.Context, id int64) error {
+	u := f.users[id]
+	if u.EmailVerifiedAt == nil {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+	f.users[id] = u
+	return nil
+}
+
+func (f *fakeUserRepo) Delete(_ context.Context, id int64) (bool, error) {
+	u, ok := f.users[id]
+	if !ok || u.DeletedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	u.DeletedAt = &now
+	f.users[id] = u
+	return true, nil
+}
+
+func (f *fakeUserRepo) Restore(_ context.Context, id int64) (bool, error) {
+	u, ok := f.users[id]
+	if !ok || u.DeletedAt == nil {
+		return false, nil
+	}
+	u.DeletedAt = nil
+	f.users[id] = u
+	return true, nil
+}
+
+func (f *fakeUserRepo) List(_ context.Context, q entity.UserQuery) ([]entity.User, error) {
+	key := func(u entity.User) string {
+		switch q.Sort {
+		case entity.UserSortEmail:
+			return u.Email
+		case entity.UserSortUsername:
+			return u.Username
+		case entity.UserSortCreatedAt:
+			return u.CreatedAt.Format(time.RFC3339Nano)
+		}
+		return fmt.Sprintf("%020d", u.ID)
+	}
+	less := func(a, b entity.User) bool {
+		if a.ID == b.ID {
+			return false
+		}
+		if key(a) != key(b) {
+			return (key(a) < key(b)) != q.Desc
+		}
+		return (a.ID < b.ID) != q.Desc
+	}
+
+	var users []entity.User
+	for _, u := range f.users {
+		search := strings.ToLower(q.Search)
+		if (u.DeletedAt != nil) != q.Deleted ||
+			!strings.HasPrefix(strings.ToLower(u.Email), search) && !strings.HasPrefix(strings.ToLower(u.Username), search) {
+			continue
+		}
+		if after, ok := f.users[q.AfterID]; ok && !less(after, u) {
+			continue
+		}
+		users = append(users, u)
+	}
+	slices.SortFunc(users, func(a, b entity.User) int {
+		if less(a, b) {
+			return -1
+		}
+		return 1
+	})
+
+	return users[:min(uint64(len(users)), q.Limit)], nil
+}
+
+func (f *fakeUserRepo) GetBuilder() squirrel.StatementBuilderType { return squirrel.StatementBuilder }
+func (f *fakeUserRepo) GetPool() *pgxpool.Pool                    { return nil }
+
+type fakeRefreshTokenRepo struct {
+	mu     sync.Mutex
+	nextID int64
+	tokens map[int64]*entity.RefreshToken
+}
+
+func newFakeRefreshTokenRepo() *fakeRefreshTokenRepo {
+	return &fakeRefreshTokenRepo{tokens: map[int64]*entity.RefreshToken{}}
+}
+
+func (f *fakeRefreshTokenRepo) Create(_ context.Context, t entity.RefreshToken) (entity.RefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	t.ID = f.nextID
+	f.tokens[t.ID] = &t
+	return t, nil
+}
+
+func (f *fakeRefreshTokenRepo) GetByHash(_ context.Context, hash string) (entity.RefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.tokens {
+		if t.TokenHash == hash {
+			return *t, nil
+		}
+	}
+	return entity.RefreshToken{}, pgx.ErrNoRows
+}
+
+func (f *fakeRefreshTokenRepo) Rotate(_ context.Context, id int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := f.tokens[id]
+	if t == nil || t.RotatedAt != nil || t.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.RotatedAt = &now
+	return true, nil
+}
+
+func (f *fakeRefreshTokenRepo) RevokeFamily(_ context.Context, familyID string) ([]entity.RefreshToken, error) {
+	return f.revoke(func(t *entity.RefreshToken) bool { return t.FamilyID == familyID }), nil
+}
+
+func (f *fakeRefreshTokenRepo) RevokeUser(_ context.Context, userID int64) ([]entity.RefreshToken, error) {
+	return f.revoke(func(t *entity.RefreshToken) bool { return t.UserID == userID }), nil
+}
+
+func (f *fakeRefreshTokenRepo) revoke(match func(*entity.RefreshToken) bool) []entity.RefreshToken {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var revoked []entity.RefreshToken
+	now := time.Now()
+	for _, t := range f.tokens {
+		if match(t) && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			revoked = append(revoked, *t)
+		}
+	}
+	return revoked
+}
+
+type fakeDenylist map[string]time.Duration
+
+func (f fakeDenylist) Revoke(_ context.Context, jti string, ttl time.Duration) error {
+	f[jti] = ttl
+	return nil
+}
+
+func (f fakeDenylist) IsRevoked(_ context.Context, jti string) (bool, error) {
+	_, ok := f[jti]
+	return ok, nil
+}
+
+var (
+	_ repo.RefreshTokenRepo = (*fakeRefreshTokenRepo)(nil)
+	_ repo.TokenDenylist    = fakeDenylist(nil)
+)
+
+func newTokenUseCase(t *testing.T) (*UseCase, *fakeRefreshTokenRepo, fakeDenylist) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	users := &fakeUserRepo{users: map[int64]entity.User{
+		1: {ID: 1, Email: "alice@example.com", Password: string(hash)},
+		2: {ID: 2, Email: "bob@example.com", Password: string(hash)},
+	}}
+	refresh := newFakeRefreshTokenRepo()
+	denylist := fakeDenylist{}
+
+	return New(users, jwtkeys.NewHMAC("secret"), Tokens(refresh, denylist), TokenTTL(time.Minute, time.Hour)), refresh, denylist
+}
+
+func TestUseCase_Login(t *testing.T) {
+	uc, _, _ := newTokenUseCase(t)
+
+	tokens, user, err := uc.Login(context.Background(), "alice@example.com", "password123", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.Empty(t, user.Password)
+
+	_, _, err = uc.Login(context.Background(), "alice@example.com", "wrong-password", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, _, err = uc.Login(context.Background(), "nobody@example.com", "password123", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestParseAccessToken(t *testing.T) {
+	uc, _, _ := newTokenUseCase(t)
+
+	tokens, _, err := uc.Login(context.Background(), "alice@example.com", "password123", "")
+	require.NoError(t, err)
+
+	principal, err := ParseAccessToken(tokens.AccessToken, jwtkeys.NewHMAC("secret").Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), principal.UserID)
+	assert.Equal(t, "alice@example.com", principal.Email)
+	assert.NotEmpty(t, principal.TokenID)
+	assert.WithinDuration(t, tokens.AccessExpiresAt, principal.TokenExpiresAt, time.Second)
+
+	_, err = ParseAccessToken(tokens.AccessToken, jwtkeys.NewHMAC("other").Keyfunc)
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+}
+
+func TestUseCase_Refresh(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rotates within the family", func(t *testing.T) {
+		uc, refresh, _ := newTokenUseCase(t)
+
+		first, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+
+		second, err := uc.Refresh(ctx, first.RefreshToken)
+		require.NoError(t, err)
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		assert.NotEqual(t, first.AccessToken, second.AccessToken)
+
+		a, err := refresh.GetByHash(ctx, hashToken(first.RefreshToken))
+		require.NoError(t, err)
+		b, err := refresh.GetByHash(ctx, hashToken(second.RefreshToken))
+		require.NoError(t, err)
+		assert.Equal(t, a.FamilyID, b.FamilyID)
+		assert.NotNil(t, a.RotatedAt)
+		assert.Nil(t, b.RotatedAt)
+	})
+
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		uc, refresh, denylist := newTokenUseCase(t)
+
+		first, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		second, err := uc.Refresh(ctx, first.RefreshToken)
+		require.NoError(t, err)
+
+		_, err = uc.Refresh(ctx, first.RefreshToken)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		// The legitimate successor is gone too, and so are its access tokens
+		_, err = uc.Refresh(ctx, second.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		b, err := refresh.GetByHash(ctx, hashToken(second.RefreshToken))
+		require.NoError(t, err)
+		assert.Contains(t, denylist, b.AccessJTI)
+		assert.Len(t, denylist, 2)
+	})
+
+	t.Run("unknown and expired tokens", func(t *testing.T) {
+		uc, _, _ := newTokenUseCase(t)
+
+		_, err := uc.Refresh(ctx, "not-a-token")
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		tokens, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+		require.NoError(t, err)
+		uc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		_, err = uc.Refresh(ctx, tokens.RefreshToken)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+}
+
+func TestUseCase_Logout(t *testing.T) {
+	ctx := context.Background()
+	uc, _, denylist := newTokenUseCase(t)
+
+	alice, _, err := uc.Login(ctx, "alice@example.com", "password123", "")
+	require.NoError(t, err)
+	bob, _, err := uc.Login(ctx, "bob@example.com", "password123", "")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, uc.Logout(ctx, alice.RefreshToken), ErrNotAuthenticated)
+
+	aliceCtx := entity.ContextWithPrincipal(ctx, entity.Principal{
+		UserID:         1,
+		TokenID:        "alice-jti",
+		TokenExpiresAt: time.Now().Add(time.Minute),
+	})
+
+	assert.ErrorIs(t, uc.Logout(aliceCtx, bob.RefreshToken), ErrInvalidRefreshToken)
+
+	require.NoError(t, uc.Logout(aliceCtx, alice.RefreshToken))
+	assert.Contains(t, denylist, "alice-jti")
+
+	_, err = uc.Refresh(ctx, alice.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	_, err = uc.Refresh(ctx, bob.RefreshToken)
+	assert.NoError(t, err)
+}
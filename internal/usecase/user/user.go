@@ -9,23 +9,200 @@ import (
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/ducnpdev/godev-kit/internal/repo"
 	"github.com/ducnpdev/godev-kit/internal/repo/persistent/models"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+	"github.com/ducnpdev/godev-kit/pkg/mailer"
+	"github.com/ducnpdev/godev-kit/pkg/secretbox"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	_defaultAccessTTL  = 15 * time.Minute
+	_defaultRefreshTTL = 30 * 24 * time.Hour
+
+	_defaultResetTTL        = 30 * time.Minute
+	_defaultVerificationTTL = 48 * time.Hour
+
+	_defaultMFAChallengeTTL = 5 * time.Minute
+)
+
+// Login and token errors.
+var (
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrInvalidRefreshToken  = errors.New("invalid refresh token")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+	ErrRefreshNotConfigured = errors.New("refresh tokens are not configured")
+	ErrNotAuthenticated     = errors.New("not authenticated")
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+)
+
+// TokenSigner signs and verifies the tokens the use case issues, e.g. a *jwtkeys.KeySet.
+type TokenSigner interface {
+	Sign(claims jwt.Claims) (string, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+}
+
 // UseCase -.
 type UseCase struct {
-	repo      repo.UserRepo
-	jwtSecret []byte
+	repo   repo.UserRepo
+	signer TokenSigner
+
+	roles         repo.RoleRepo
+	refreshTokens repo.RefreshTokenRepo
+	denylist      repo.TokenDenylist
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
+	now           func() time.Time
+
+	userTokens          repo.UserTokenRepo
+	mailer              mailer.Mailer
+	links               MailLinks
+	resetTTL            time.Duration
+	verificationTTL     time.Duration
+	requireVerification bool
+
+	mfa             repo.MFARepo
+	mfaBox          *secretbox.Box
+	mfaIssuer       string
+	mfaChallengeTTL time.Duration
+
+	attempts    repo.LoginAttemptRepo
+	loginPolicy LoginPolicy
+	events      EventPublisher
+	sleep       func(ctx context.Context, d time.Duration) error
+
+	apiKeys repo.APIKeyRepo
+
+	oidc         IdentityProvider
+	oidcStates   repo.OIDCStateRepo
+	identities   repo.UserIdentityRepo
+	oidcDomains  []string
+	oidcStateTTL time.Duration
+
+	tx          repo.Transactor
+	outbox      repo.OutboxRepo
+	outboxTopic string
+
+	l     logger.Interface
+	async func(fn func())
+}
+
+// Option configures the user use case.
+type Option func(*UseCase)
+
+// Roles enables role based access control: new users get the default role and
+// tokens carry the user's roles and permissions.
+func Roles(roles repo.RoleRepo) Option {
+	return func(uc *UseCase) {
+		uc.roles = roles
+	}
+}
+
+// Tokens enables refresh tokens and access token revocation.
+func Tokens(refreshTokens repo.RefreshTokenRepo, denylist repo.TokenDenylist) Option {
+	return func(uc *UseCase) {
+		uc.refreshTokens = refreshTokens
+		uc.denylist = denylist
+	}
+}
+
+// TokenTTL sets the lifetime of access and refresh tokens. Zero keeps the default.
+func TokenTTL(access, refresh time.Duration) Option {
+	return func(uc *UseCase) {
+		if access > 0 {
+			uc.accessTTL = access
+		}
+		if refresh > 0 {
+			uc.refreshTTL = refresh
+		}
+	}
+}
+
+// Mail enables password reset and email verification mails. The links are the
+// pages that receive the token as a "token" query parameter.
+func Mail(userTokens repo.UserTokenRepo, m mailer.Mailer, links MailLinks) Option {
+	return func(uc *UseCase) {
+		uc.userTokens = userTokens
+		uc.mailer = m
+		uc.links = links
+	}
+}
+
+// MailTokenTTL sets the lifetime of password reset and email verification
+// tokens. Zero keeps the default.
+func MailTokenTTL(reset, verification time.Duration) Option {
+	return func(uc *UseCase) {
+		if reset > 0 {
+			uc.resetTTL = reset
+		}
+		if verification > 0 {
+			uc.verificationTTL = verification
+		}
+	}
+}
+
+// RequireEmailVerification makes Login reject users who have not confirmed
+// their email address. It needs Mail, or new users can never log in.
+func RequireEmailVerification(required bool) Option {
+	return func(uc *UseCase) {
+		uc.requireVerification = required
+	}
+}
+
+// MFA enables TOTP two-factor authentication. Secrets are sealed with box;
+// issuer is the account name shown in authenticator apps. Login challenges are
+// made single use with the denylist of Tokens, which completing them requires.
+func MFA(mfa repo.MFARepo, box *secretbox.Box, issuer string) Option {
+	return func(uc *UseCase) {
+		uc.mfa = mfa
+		uc.mfaBox = box
+		uc.mfaIssuer = issuer
+	}
+}
+
+// MFAChallengeTTL sets how long the challenge returned by Login stays valid. Zero keeps the default.
+func MFAChallengeTTL(ttl time.Duration) Option {
+	return func(uc *UseCase) {
+		if ttl > 0 {
+			uc.mfaChallengeTTL = ttl
+		}
+	}
+}
+
+// Logger receives the errors of work done after a request was answered, such
+// as the password reset and verification mails.
+func Logger(l logger.Interface) Option {
+	return func(uc *UseCase) {
+		uc.l = l
+	}
 }
 
 // New -.
-func New(r repo.UserRepo, jwtSecret string) *UseCase {
-	return &UseCase{
-		repo:      r,
-		jwtSecret: []byte(jwtSecret),
+func New(r repo.UserRepo, signer TokenSigner, opts ...Option) *UseCase {
+	uc := &UseCase{
+		repo:       r,
+		signer:     signer,
+		accessTTL:  _defaultAccessTTL,
+		refreshTTL: _defaultRefreshTTL,
+		now:        time.Now,
+
+		resetTTL:        _defaultResetTTL,
+		verificationTTL: _defaultVerificationTTL,
+
+		mfaChallengeTTL: _defaultMFAChallengeTTL,
+
+		oidcStateTTL: _defaultOIDCStateTTL,
+
+		sleep: sleepContext,
+		async: func(fn func()) { go fn() },
 	}
+
+	for _, opt := range opts {
+		opt(uc)
+	}
+
+	return uc
 }
 
 // hashPassword hashes a password using bcrypt
@@ -51,9 +228,32 @@ func (uc *UseCase) Create(ctx context.Context, user entity.User) (entity.User, e
 		Password: hashedPassword,
 	}
 
-	createdUser, err := uc.repo.Create(ctx, userModel)
+	var createdUser entity.User
+	err = uc.inTx(ctx, func(ctx context.Context) error {
+		createdUser, err = uc.repo.Create(ctx, userModel)
+		if err != nil {
+			return fmt.Errorf("uc.repo.Create: %w", err)
+		}
+
+		if uc.roles != nil {
+			if err := uc.roles.Assign(ctx, createdUser.ID, entity.RoleUser); err != nil {
+				return fmt.Errorf("uc.roles.Assign: %w", err)
+			}
+			createdUser.Roles = []string{entity.RoleUser}
+		}
+
+		return uc.recordUserEvent(ctx, entity.UserCreatedEvent, createdUser, nil)
+	})
 	if err != nil {
-		return entity.User{}, fmt.Errorf("UserUseCase - Create - uc.repo.Create: %w", err)
+		return entity.User{}, fmt.Errorf("UserUseCase - Create - %w", err)
+	}
+
+	// Mailed after the commit, the link must not point to a rolled back user
+	if uc.mailer != nil {
+		if err := uc.sendVerification(ctx, createdUser); err != nil {
+			// The account exists; the user can ask for the mail again
+			return createdUser, fmt.Errorf("UserUseCase - Create - %w: %w", ErrVerificationNotSent, err)
+		}
 	}
 
 	return createdUser, nil
@@ -63,9 +263,19 @@ func (uc *UseCase) Create(ctx context.Context, user entity.User) (entity.User, e
 func (uc *UseCase) GetByID(ctx context.Context, id int64) (entity.User, error) {
 	user, err := uc.repo.GetByID(ctx, id)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.User{}, ErrUserNotFound
+		}
 		return entity.User{}, fmt.Errorf("UserUseCase - GetByID - uc.repo.GetByID: %w", err)
 	}
 
+	if uc.roles != nil {
+		user.Roles, _, err = uc.roles.UserAccess(ctx, id)
+		if err != nil {
+			return entity.User{}, fmt.Errorf("UserUseCase - GetByID - uc.roles.UserAccess: %w", err)
+		}
+	}
+
 	return user, nil
 }
 
@@ -86,96 +296,225 @@ func (uc *UseCase) Update(ctx context.Context, user entity.User) error {
 		userModel.Password = hashedPassword
 	}
 
-	err := uc.repo.Update(ctx, userModel)
+	var (
+		updated      entity.User
+		emailChanged bool
+	)
+	err := uc.inTx(ctx, func(ctx context.Context) error {
+		current, err := uc.repo.GetByID(ctx, user.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("uc.repo.GetByID: %w", err)
+		}
+		emailChanged = user.Email != "" && user.Email != current.Email
+
+		if err := uc.repo.Update(ctx, userModel); err != nil {
+			return fmt.Errorf("uc.repo.Update: %w", err)
+		}
+
+		// Links mailed to the old address must not verify the new one
+		if emailChanged && uc.userTokens != nil {
+			if err := uc.userTokens.Invalidate(ctx, user.ID, entity.UserTokenEmailVerification); err != nil {
+				return fmt.Errorf("uc.userTokens.Invalidate: %w", err)
+			}
+		}
+
+		// Read back for the email the event carries; none means no user was updated
+		updated, err = uc.repo.GetByID(ctx, user.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("uc.repo.GetByID: %w", err)
+		}
+
+		return uc.recordUserEvent(ctx, entity.UserUpdatedEvent, updated, map[string][]string{
+			"fields": updatedFields(userModel),
+		})
+	})
 	if err != nil {
-		return fmt.Errorf("UserUseCase - Update - uc.repo.Update: %w", err)
+		return fmt.Errorf("UserUseCase - Update - %w", err)
+	}
+
+	// The new address starts unverified and is confirmed like at sign-up
+	if emailChanged && uc.mailer != nil {
+		uc.mailLater(ctx, "Update", func(ctx context.Context) error {
+			return uc.sendVerification(ctx, updated)
+		})
 	}
 
 	return nil
 }
 
-// Delete -.
+// updatedFields names the fields an update sets, the password included but not its value.
+func updatedFields(user models.UserModel) []string {
+	fields := []string{}
+	if user.Email != "" {
+		fields = append(fields, "email")
+	}
+	if user.Username != "" {
+		fields = append(fields, "username")
+	}
+	if user.Password != "" {
+		fields = append(fields, "password")
+	}
+	return fields
+}
+
+// Delete soft deletes the user and ends their sessions: refresh tokens are
+// revoked and the access tokens issued with them denied.
 func (uc *UseCase) Delete(ctx context.Context, id int64) error {
-	err := uc.repo.Delete(ctx, id)
+	user, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("UserUseCase - Delete - uc.repo.GetByID: %w", err)
+	}
+
+	var revoked []entity.RefreshToken
+	err = uc.inTx(ctx, func(ctx context.Context) error {
+		ok, err := uc.repo.Delete(ctx, id)
+		if err != nil {
+			return fmt.Errorf("uc.repo.Delete: %w", err)
+		}
+		if !ok {
+			return ErrUserNotFound
+		}
+
+		if uc.refreshTokens != nil {
+			if revoked, err = uc.refreshTokens.RevokeUser(ctx, id); err != nil {
+				return fmt.Errorf("uc.refreshTokens.RevokeUser: %w", err)
+			}
+		}
+
+		return uc.recordUserEvent(ctx, entity.UserDeletedEvent, user, nil)
+	})
 	if err != nil {
-		return fmt.Errorf("UserUseCase - Delete - uc.repo.Delete: %w", err)
+		return fmt.Errorf("UserUseCase - Delete - %w", err)
+	}
+
+	// The denylist is not transactional, so it is written once the delete stands
+	if err := uc.denyAccessTokens(ctx, revoked); err != nil {
+		return fmt.Errorf("UserUseCase - Delete - %w", err)
 	}
 
 	return nil
 }
 
-// List -.
-func (uc *UseCase) List(ctx context.Context) (entity.UserHistory, error) {
-	users, err := uc.repo.List(ctx)
+// Restore undoes a soft delete. The user logs in again with the old password.
+func (uc *UseCase) Restore(ctx context.Context, id int64) error {
+	err := uc.inTx(ctx, func(ctx context.Context) error {
+		ok, err := uc.repo.Restore(ctx, id)
+		if err != nil {
+			return fmt.Errorf("uc.repo.Restore: %w", err)
+		}
+		if !ok {
+			return ErrUserNotFound
+		}
+
+		if uc.outbox == nil && uc.events == nil {
+			return nil
+		}
+		user, err := uc.repo.GetByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("uc.repo.GetByID: %w", err)
+		}
+
+		return uc.recordUserEvent(ctx, entity.UserRestoredEvent, user, nil)
+	})
 	if err != nil {
-		return entity.UserHistory{}, fmt.Errorf("UserUseCase - List - uc.repo.List: %w", err)
+		return fmt.Errorf("UserUseCase - Restore - %w", err)
 	}
 
-	return entity.UserHistory{Users: users}, nil
+	return nil
 }
 
 // JWTClaims represents the claims in a JWT token
 type JWTClaims struct {
-	UserID int64  `json:"user_id"`
-	Email  string `json:"email"`
+	UserID      int64    `json:"user_id"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// Login authenticates a user and returns a JWT token
-func (uc *UseCase) Login(ctx context.Context, email, password string) (string, entity.User, error) {
+// Login authenticates a user and returns an access token, plus a refresh
+// token when refresh tokens are configured. clientIP feeds the per-IP failure
+// count of LoginProtection; empty skips it.
+func (uc *UseCase) Login(ctx context.Context, email, password, clientIP string) (entity.AuthTokens, entity.User, error) {
 	// Validate input
 	if email == "" {
-		return "", entity.User{}, fmt.Errorf("UserUseCase - Login - email is required")
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - email is required")
 	}
 	if password == "" {
-		return "", entity.User{}, fmt.Errorf("UserUseCase - Login - password is required")
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - password is required")
+	}
+
+	if err := uc.checkLoginAllowed(ctx, email, clientIP); err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - %w", err)
 	}
 
 	// Get user by email
 	user, err := uc.repo.GetByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return "", entity.User{}, fmt.Errorf("UserUseCase - Login - invalid email or password")
+			return entity.AuthTokens{}, entity.User{}, uc.invalidCredentials(ctx, entity.User{}, email, clientIP)
 		}
-		return "", entity.User{}, fmt.Errorf("UserUseCase - Login - failed to get user: %w", err)
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - failed to get user: %w", err)
 	}
 
 	// Compare passwords
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return "", entity.User{}, fmt.Errorf("UserUseCase - Login - invalid email or password")
+			return entity.AuthTokens{}, entity.User{}, uc.invalidCredentials(ctx, user, email, clientIP)
 		}
-		return "", entity.User{}, fmt.Errorf("UserUseCase - Login - failed to compare passwords: %w", err)
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - failed to compare passwords: %w", err)
 	}
 
-	// Set token expiration (24 hours from now)
-	expirationTime := time.Now().Add(24 * time.Hour)
+	// Checked after the password so the answer does not reveal unverified accounts
+	if uc.requireVerification && user.EmailVerifiedAt == nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login: %w", ErrEmailNotVerified)
+	}
+
+	// Clear password from user entity before returning
+	user.Password = ""
 
-	// Create JWT claims
-	claims := &JWTClaims{
-		UserID: user.ID,
-		Email:  user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "godev-kit",
-			Subject:   fmt.Sprintf("%d", user.ID),
-		},
+	// With TOTP enabled the password only earns a challenge for CompleteMFALogin
+	if uc.mfa != nil {
+		totp, err := uc.mfa.GetTOTP(ctx, user.ID)
+		if err != nil {
+			return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - uc.mfa.GetTOTP: %w", err)
+		}
+		if totp.Enabled() {
+			challenge, err := uc.issueMFAChallenge(user)
+			if err != nil {
+				return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - %w", err)
+			}
+			return challenge, user, nil
+		}
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// The failures are only cleared once no second factor is pending
+	if err := uc.loginSucceeded(ctx, email); err != nil {
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - %w", err)
+	}
 
-	// Generate encoded token
-	tokenString, err := token.SignedString(uc.jwtSecret)
+	tokens, err := uc.issueTokens(ctx, user, "")
 	if err != nil {
-		return "", entity.User{}, fmt.Errorf("UserUseCase - Login - failed to sign token: %w", err)
+		return entity.AuthTokens{}, entity.User{}, fmt.Errorf("UserUseCase - Login - uc.issueTokens: %w", err)
 	}
 
-	// Clear password from user entity before returning
-	user.Password = ""
+	return tokens, user, nil
+}
 
-	return tokenString, user, nil
+// invalidCredentials records the failed login and returns ErrInvalidCredentials.
+func (uc *UseCase) invalidCredentials(ctx context.Context, user entity.User, email, clientIP string) error {
+	if err := uc.loginFailed(ctx, user, email, clientIP); err != nil {
+		return fmt.Errorf("UserUseCase - Login - %w", err)
+	}
+	return fmt.Errorf("UserUseCase - Login: %w", ErrInvalidCredentials)
 }
@@ -0,0 +1,148 @@
+package vietqr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+)
+
+// watchBuffer is how many undelivered events a slow watcher may fall behind before events are dropped.
+const watchBuffer = 8
+
+// StatusPublisher announces status changes to interested parties.
+type StatusPublisher interface {
+	PublishStatusChanged(ctx context.Context, event entity.VietQRStatusChangedEvent)
+}
+
+// StatusHook reacts to a status change in the transaction that makes it. An error rolls the
+// change back and is returned to the caller, so the change is retried with the hook.
+type StatusHook interface {
+	StatusChanged(ctx context.Context, event entity.VietQRStatusChangedEvent) error
+}
+
+// StatusSubject returns the NATS subject carrying events for one code.
+func StatusSubject(qrID string) string {
+	return entity.VietQREventStatusChanged + "." + qrID
+}
+
+// Broker fans status changes out to in-process watchers, such as SSE connections, keyed by code ID.
+type Broker struct {
+	mu       sync.Mutex
+	watchers map[string]map[chan entity.VietQRStatusChangedEvent]struct{}
+}
+
+// NewBroker creates an empty broker.
+func NewBroker() *Broker {
+	return &Broker{watchers: make(map[string]map[chan entity.VietQRStatusChangedEvent]struct{})}
+}
+
+// Watch returns a channel receiving events for the code until cancel is called.
+func (b *Broker) Watch(qrID string) (events <-chan entity.VietQRStatusChangedEvent, cancel func()) {
+	ch := make(chan entity.VietQRStatusChangedEvent, watchBuffer)
+
+	b.mu.Lock()
+	if b.watchers[qrID] == nil {
+		b.watchers[qrID] = make(map[chan entity.VietQRStatusChangedEvent]struct{})
+	}
+	b.watchers[qrID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.watchers[qrID], ch)
+			if len(b.watchers[qrID]) == 0 {
+				delete(b.watchers, qrID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// PublishStatusChanged delivers the event to the code's watchers without blocking.
+func (b *Broker) PublishStatusChanged(_ context.Context, event entity.VietQRStatusChangedEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.watchers[event.QRID] {
+		select {
+		case ch <- event:
+		default:
+			// The watcher re-reads the current status on reconnect, so a dropped event is recoverable
+		}
+	}
+}
+
+// Follow feeds the broker from NATS so watchers see changes made by every instance.
+func (b *Broker) Follow(nats repo.NatsRepo, l logger.Interface) (unsubscribe func() error, err error) {
+	return nats.Subscribe(StatusSubject("*"), func(msg []byte) {
+		var event entity.VietQRStatusChangedEvent
+		if err := json.Unmarshal(msg, &event); err != nil {
+			l.Error(fmt.Errorf("vietqr - Broker - Follow - json.Unmarshal: %w", err))
+			return
+		}
+		b.PublishStatusChanged(context.Background(), event)
+	})
+}
+
+type publishers []StatusPublisher
+
+// Publishers combines publishers; each receives every event in order.
+func Publishers(ps ...StatusPublisher) StatusPublisher {
+	return publishers(ps)
+}
+
+func (ps publishers) PublishStatusChanged(ctx context.Context, event entity.VietQRStatusChangedEvent) {
+	for _, p := range ps {
+		p.PublishStatusChanged(ctx, event)
+	}
+}
+
+// eventPublisher sends status changes to Kafka and NATS. Delivery is best effort: the status
+// update has already been committed, so failures are logged rather than returned.
+type eventPublisher struct {
+	kafka repo.KafkaRepo
+	nats  repo.NatsRepo
+	local *Broker
+	l     logger.Interface
+}
+
+// NewEventPublisher creates a publisher for the given transports; kafka and nats may be nil.
+// Without NATS the broker is fed directly. With NATS it must Follow the subject instead,
+// otherwise local watchers would see every event twice.
+func NewEventPublisher(kafka repo.KafkaRepo, nats repo.NatsRepo, broker *Broker, l logger.Interface) StatusPublisher {
+	p := &eventPublisher{kafka: kafka, nats: nats, l: l}
+	if nats == nil {
+		p.local = broker
+	}
+	return p
+}
+
+func (p *eventPublisher) PublishStatusChanged(ctx context.Context, event entity.VietQRStatusChangedEvent) {
+	if p.kafka != nil && p.kafka.IsProducerEnabled() {
+		// Keying by code keeps each code's transitions ordered within a partition
+		if err := p.kafka.SendMessage(ctx, entity.VietQREventStatusChanged, []byte(event.QRID), event); err != nil {
+			p.l.Error(fmt.Errorf("vietqr - PublishStatusChanged - kafka.SendMessage: %w", err))
+		}
+	}
+
+	if p.nats != nil {
+		data, err := json.Marshal(event)
+		if err != nil {
+			p.l.Error(fmt.Errorf("vietqr - PublishStatusChanged - json.Marshal: %w", err))
+		} else if err := p.nats.Publish(StatusSubject(event.QRID), data); err != nil {
+			p.l.Error(fmt.Errorf("vietqr - PublishStatusChanged - nats.Publish: %w", err))
+		}
+	}
+
+	if p.local != nil {
+		p.local.PublishStatusChanged(ctx, event)
+	}
+}
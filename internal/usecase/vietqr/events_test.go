@@ -0,0 +1,225 @@
+package vietqr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher collects published events
+type recordingPublisher struct {
+	events []entity.VietQRStatusChangedEvent
+}
+
+func (p *recordingPublisher) PublishStatusChanged(_ context.Context, event entity.VietQRStatusChangedEvent) {
+	p.events = append(p.events, event)
+}
+
+func TestVietQRUseCase_UpdateStatus_Events(t *testing.T) {
+	prev := entity.VietQR{
+		ID:        "qr-1",
+		Status:    entity.VietQRStatusGenerated,
+		BankCode:  "VCB",
+		AccountNo: "1234567890",
+		Amount:    "100000",
+		Reference: "GDK7KQ2M9XA",
+	}
+
+	t.Run("publishes old and new status", func(t *testing.T) {
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockPersistent.On("UpdateStatus", mock.Anything, "qr-1", entity.VietQRStatusPaid).Return(prev, nil)
+		publisher := &recordingPublisher{}
+
+		useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent, Events(publisher, nil))
+		require.NoError(t, useCase.UpdateStatus(context.Background(), "qr-1", entity.VietQRStatusPaid))
+
+		require.Len(t, publisher.events, 1)
+		event := publisher.events[0]
+		assert.Equal(t, entity.VietQREventStatusChanged, event.Type)
+		assert.Equal(t, "qr-1", event.QRID)
+		assert.Equal(t, entity.VietQRStatusGenerated, event.OldStatus)
+		assert.Equal(t, entity.VietQRStatusPaid, event.NewStatus)
+		assert.Equal(t, "GDK7KQ2M9XA", event.Reference)
+		assert.NotEmpty(t, event.EventID)
+	})
+
+	t.Run("unchanged status publishes nothing", func(t *testing.T) {
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockPersistent.On("UpdateStatus", mock.Anything, "qr-1", entity.VietQRStatusGenerated).Return(prev, nil)
+		publisher := &recordingPublisher{}
+
+		useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent, Events(publisher, nil))
+		require.NoError(t, useCase.UpdateStatus(context.Background(), "qr-1", entity.VietQRStatusGenerated))
+
+		assert.Empty(t, publisher.events)
+	})
+
+	t.Run("missing code", func(t *testing.T) {
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockPersistent.On("UpdateStatus", mock.Anything, "nope", entity.VietQRStatusPaid).Return(entity.VietQR{}, pgx.ErrNoRows)
+		publisher := &recordingPublisher{}
+
+		useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent, Events(publisher, nil))
+		assert.ErrorIs(t, useCase.UpdateStatus(context.Background(), "nope", entity.VietQRStatusPaid), ErrQRNotFound)
+		assert.Empty(t, publisher.events)
+	})
+}
+
+func TestVietQRUseCase_ExpireOverdue_Events(t *testing.T) {
+	mockPersistent := new(MockVietQRPersistentRepo)
+	mockPersistent.On("ExpireBefore", mock.Anything, mock.Anything).Return([]entity.VietQR{
+		{ID: "qr-1", Status: entity.VietQRStatusGenerated},
+		{ID: "qr-2", Status: entity.VietQRStatusInProcess},
+	}, nil)
+	publisher := &recordingPublisher{}
+
+	useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent, Events(publisher, nil))
+	ids, err := useCase.ExpireOverdue(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"qr-1", "qr-2"}, ids)
+	require.Len(t, publisher.events, 2)
+	assert.Equal(t, entity.VietQRStatusInProcess, publisher.events[1].OldStatus)
+	assert.Equal(t, entity.VietQRStatusTimeout, publisher.events[1].NewStatus)
+}
+
+func TestVietQRUseCase_WatchStatus(t *testing.T) {
+	t.Run("watcher receives changes", func(t *testing.T) {
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockPersistent.On("FindByID", mock.Anything, "qr-1").Return(entity.VietQR{ID: "qr-1", Status: entity.VietQRStatusGenerated}, nil)
+		mockPersistent.On("UpdateStatus", mock.Anything, "qr-1", entity.VietQRStatusPaid).
+			Return(entity.VietQR{ID: "qr-1", Status: entity.VietQRStatusGenerated}, nil)
+
+		useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent)
+		events, cancel, err := useCase.WatchStatus(context.Background(), "qr-1")
+		require.NoError(t, err)
+		defer cancel()
+
+		require.NoError(t, useCase.UpdateStatus(context.Background(), "qr-1", entity.VietQRStatusPaid))
+
+		select {
+		case event := <-events:
+			assert.Equal(t, entity.VietQRStatusPaid, event.NewStatus)
+		case <-time.After(time.Second):
+			t.Fatal("no event received")
+		}
+	})
+
+	t.Run("missing code", func(t *testing.T) {
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockPersistent.On("FindByID", mock.Anything, "nope").Return(entity.VietQR{}, pgx.ErrNoRows)
+
+		useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent)
+		_, _, err := useCase.WatchStatus(context.Background(), "nope")
+		assert.ErrorIs(t, err, ErrQRNotFound)
+	})
+}
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+	events, cancel := broker.Watch("qr-1")
+	other, cancelOther := broker.Watch("qr-2")
+	defer cancelOther()
+
+	broker.PublishStatusChanged(context.Background(), entity.VietQRStatusChangedEvent{QRID: "qr-1", NewStatus: entity.VietQRStatusPaid})
+
+	assert.Equal(t, entity.VietQRStatusPaid, (<-events).NewStatus)
+	assert.Empty(t, other)
+
+	cancel()
+	cancel()
+	_, open := <-events
+	assert.False(t, open)
+
+	// Publishing after cancel must not panic on the closed channel
+	broker.PublishStatusChanged(context.Background(), entity.VietQRStatusChangedEvent{QRID: "qr-1"})
+}
+
+// fakeTransactor records whether the last transaction committed
+type fakeTransactor struct {
+	committed, rolledBack int
+}
+
+func (f *fakeTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		f.rolledBack++
+		return err
+	}
+	f.committed++
+	return nil
+}
+
+// hookFunc adapts a function to StatusHook
+type hookFunc func(ctx context.Context, event entity.VietQRStatusChangedEvent) error
+
+func (f hookFunc) StatusChanged(ctx context.Context, event entity.VietQRStatusChangedEvent) error {
+	return f(ctx, event)
+}
+
+func TestVietQRUseCase_Hooks(t *testing.T) {
+	prev := entity.VietQR{ID: "qr-1", Status: entity.VietQRStatusGenerated}
+
+	t.Run("run before publishing", func(t *testing.T) {
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockPersistent.On("PayOpen", mock.Anything, "qr-1", mock.Anything).Return(prev, nil)
+		publisher := &recordingPublisher{}
+		tx := &fakeTransactor{}
+
+		var hooked []entity.VietQRStatusChangedEvent
+		hook := hookFunc(func(_ context.Context, event entity.VietQRStatusChangedEvent) error {
+			assert.Empty(t, publisher.events, "hooks run before the change is published")
+			hooked = append(hooked, event)
+			return nil
+		})
+
+		useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent, Events(publisher, nil), Hooks(tx, hook))
+		require.NoError(t, useCase.PayOpen(context.Background(), "qr-1"))
+
+		require.Len(t, hooked, 1)
+		assert.Equal(t, entity.VietQRStatusPaid, hooked[0].NewStatus)
+		assert.Equal(t, hooked, publisher.events)
+		assert.Equal(t, 1, tx.committed)
+	})
+
+	t.Run("a failing hook rolls the change back", func(t *testing.T) {
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockPersistent.On("PayOpen", mock.Anything, "qr-1", mock.Anything).Return(prev, nil)
+		mockPersistent.On("ExpireBefore", mock.Anything, mock.Anything).Return([]entity.VietQR{prev}, nil)
+		publisher := &recordingPublisher{}
+		tx := &fakeTransactor{}
+		hookErr := errors.New("payments down")
+		hook := hookFunc(func(context.Context, entity.VietQRStatusChangedEvent) error { return hookErr })
+
+		useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent, Events(publisher, nil), Hooks(tx, hook))
+
+		err := useCase.PayOpen(context.Background(), "qr-1")
+		assert.ErrorIs(t, err, hookErr)
+		assert.NotErrorIs(t, err, ErrQRNotOpen)
+
+		ids, err := useCase.ExpireOverdue(context.Background())
+		assert.ErrorIs(t, err, hookErr)
+		assert.Empty(t, ids)
+
+		assert.Equal(t, 2, tx.rolledBack)
+		assert.Empty(t, publisher.events)
+	})
+
+	t.Run("unchanged codes skip the hooks", func(t *testing.T) {
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockPersistent.On("UpdateStatus", mock.Anything, "qr-1", entity.VietQRStatusGenerated).Return(prev, nil)
+		hook := hookFunc(func(context.Context, entity.VietQRStatusChangedEvent) error {
+			t.Fatal("hook called for an unchanged status")
+			return nil
+		})
+
+		useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent, Hooks(&fakeTransactor{}, hook))
+		require.NoError(t, useCase.UpdateStatus(context.Background(), "qr-1", entity.VietQRStatusGenerated))
+	})
+}
@@ -0,0 +1,53 @@
+package vietqr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+)
+
+// DefaultExpireInterval is how often the expirer sweeps for overdue codes.
+const DefaultExpireInterval = 30 * time.Second
+
+// Expirer periodically moves unpaid codes past their expiry to timeout.
+type Expirer struct {
+	uc       VietQRUseCase
+	interval time.Duration
+	l        logger.Interface
+}
+
+// NewExpirer creates a new expirer. A non-positive interval uses DefaultExpireInterval.
+func NewExpirer(uc VietQRUseCase, interval time.Duration, l logger.Interface) *Expirer {
+	if interval <= 0 {
+		interval = DefaultExpireInterval
+	}
+	return &Expirer{uc: uc, interval: interval, l: l}
+}
+
+// Start sweeps on every tick until ctx is cancelled.
+func (e *Expirer) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.sweep(ctx)
+		}
+	}
+}
+
+func (e *Expirer) sweep(ctx context.Context) {
+	ids, err := e.uc.ExpireOverdue(ctx)
+	if err != nil {
+		e.l.Error(fmt.Errorf("vietqr - Expirer - ExpireOverdue: %w", err))
+		return
+	}
+	if len(ids) > 0 {
+		e.l.Info("vietqr - Expirer - moved %d codes to timeout", len(ids))
+	}
+}
@@ -0,0 +1,86 @@
+package vietqr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"regexp"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/pkg/qrimage"
+	"github.com/jackc/pgx/v5"
+)
+
+// logoName restricts logo names to plain file stems so they cannot escape the logo directory.
+var logoName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+func (uc *vietQRUseCase) RenderQR(ctx context.Context, req entity.VietQRImageRequest) (*entity.VietQRImage, error) {
+	format, err := qrimage.ParseFormat(req.Format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImageRequest, err)
+	}
+
+	levelName := req.Level
+	if levelName == "" {
+		levelName = uc.imageLevel
+	}
+	level, err := qrimage.ParseLevel(levelName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImageRequest, err)
+	}
+
+	opts := []qrimage.Option{qrimage.Recovery(level)}
+	if req.Size > 0 {
+		opts = append(opts, qrimage.Size(req.Size))
+	}
+	if req.Logo != "" {
+		logo, err := uc.loadLogo(req.Logo)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, qrimage.Logo(logo))
+	}
+
+	renderer := qrimage.New(opts...)
+
+	qr, err := uc.persistentRepo.FindByID(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrQRNotFound
+		}
+		return nil, err
+	}
+
+	data, err := renderer.Render(qr.Content, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.VietQRImage{
+		ContentType: format.ContentType(),
+		ETag:        imageETag(qr.Content, format, renderer.Size(), renderer.Level(), req.Logo),
+		Data:        data,
+	}, nil
+}
+
+func (uc *vietQRUseCase) loadLogo(name string) (image.Image, error) {
+	if uc.logoDir == "" || !logoName.MatchString(name) {
+		return nil, fmt.Errorf("%w: unknown logo %q", ErrInvalidImageRequest, name)
+	}
+
+	logo, err := qrimage.LoadLogo(filepath.Join(uc.logoDir, name+".png"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown logo %q", ErrInvalidImageRequest, name)
+	}
+	return logo, nil
+}
+
+// imageETag derives a strong validator from everything that affects the rendered bytes.
+func imageETag(content string, format qrimage.Format, size int, level qrimage.Level, logo string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s|%s", content, format, size, level, logo)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,147 @@
+package vietqr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultUnmatchedLimit caps how many unmatched transactions are listed at once.
+const DefaultUnmatchedLimit = 100
+
+// BankNotificationUseCase reconciles bank balance-change notifications against open codes.
+type BankNotificationUseCase interface {
+	// Ingest matches a credit to an open code and marks it paid, or stores it for manual review.
+	Ingest(ctx context.Context, txn entity.BankTransaction) (entity.BankTransaction, error)
+	ListUnmatched(ctx context.Context, limit uint64) ([]entity.BankTransaction, error)
+}
+
+// BankTransactionRepo is the interface for the bank transaction persistent repository.
+type BankTransactionRepo interface {
+	// Store is idempotent by bank transaction ID and reports whether a new row was written.
+	Store(ctx context.Context, txn *entity.BankTransaction) (bool, error)
+	ListByStatus(ctx context.Context, status entity.BankTransactionStatus, limit uint64) ([]entity.BankTransaction, error)
+}
+
+// errDuplicateNotification rolls back an Ingest whose transaction was stored before.
+var errDuplicateNotification = errors.New("duplicate bank notification")
+
+type bankNotificationUseCase struct {
+	qr             VietQRUseCase
+	persistentRepo VietQRPersistentRepo
+	txnRepo        BankTransactionRepo
+	tx             repo.Transactor
+}
+
+// NewBankNotificationUseCase creates a new bank notification use case. Paying the code and
+// storing the transaction share a transaction of tx; without tx they run one after the other.
+func NewBankNotificationUseCase(qr VietQRUseCase, persistentRepo VietQRPersistentRepo, txnRepo BankTransactionRepo, tx repo.Transactor) BankNotificationUseCase {
+	return &bankNotificationUseCase{qr: qr, persistentRepo: persistentRepo, txnRepo: txnRepo, tx: tx}
+}
+
+func (uc *bankNotificationUseCase) Ingest(ctx context.Context, txn entity.BankTransaction) (entity.BankTransaction, error) {
+	if txn.Direction != entity.BankTransactionCredit {
+		txn.Status = entity.BankTransactionIgnored
+		return txn, nil
+	}
+
+	qr, ok, err := uc.match(ctx, txn)
+	if err != nil {
+		return entity.BankTransaction{}, err
+	}
+
+	err = uc.inTx(ctx, func(ctx context.Context) error {
+		txn.Status = entity.BankTransactionUnmatched
+		if ok {
+			// The write only succeeds while the code is still open, so a concurrent credit for
+			// the same code, or one arriving as it expires, is left unmatched for review
+			err := uc.qr.PayOpen(ctx, qr.ID)
+			switch {
+			case err == nil:
+				txn.Status = entity.BankTransactionMatched
+				txn.VietQRID = qr.ID
+			case !errors.Is(err, ErrQRNotOpen):
+				return fmt.Errorf("PayOpen: %w", err)
+			}
+		}
+
+		stored, err := uc.txnRepo.Store(ctx, &txn)
+		if err != nil {
+			return fmt.Errorf("Store: %w", err)
+		}
+		if !stored {
+			// A redelivery must not pay anything its first delivery did not
+			return errDuplicateNotification
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errDuplicateNotification):
+		// Store replaced txn with the row of the first delivery
+		return txn, nil
+	case err != nil:
+		return entity.BankTransaction{}, fmt.Errorf("vietqr - Ingest - %w", err)
+	}
+
+	return txn, nil
+}
+
+// inTx runs fn in the transaction of tx, or directly without one.
+func (uc *bankNotificationUseCase) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if uc.tx == nil {
+		return fn(ctx)
+	}
+	return uc.tx.InTx(ctx, fn)
+}
+
+// match finds the open, unexpired code the credit pays. Account number and amount must
+// both agree with the code the reference points to.
+func (uc *bankNotificationUseCase) match(ctx context.Context, txn entity.BankTransaction) (entity.VietQR, bool, error) {
+	ref, ok := ExtractReference(txn.Description)
+	if !ok {
+		return entity.VietQR{}, false, nil
+	}
+
+	qr, err := uc.persistentRepo.FindOpenByReference(ctx, ref)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return entity.VietQR{}, false, nil
+		}
+		return entity.VietQR{}, false, fmt.Errorf("vietqr - Ingest - FindOpenByReference: %w", err)
+	}
+
+	if qr.IsExpired(time.Now()) ||
+		strings.TrimSpace(qr.AccountNo) != strings.TrimSpace(txn.AccountNo) ||
+		!sameAmount(qr.Amount, txn.Amount) {
+		return entity.VietQR{}, false, nil
+	}
+
+	return qr, true, nil
+}
+
+func (uc *bankNotificationUseCase) ListUnmatched(ctx context.Context, limit uint64) ([]entity.BankTransaction, error) {
+	if limit == 0 || limit > DefaultUnmatchedLimit {
+		limit = DefaultUnmatchedLimit
+	}
+	return uc.txnRepo.ListByStatus(ctx, entity.BankTransactionUnmatched, limit)
+}
+
+// sameAmount compares decimal amounts so "100000" and "100000.00" agree.
+func sameAmount(a, b string) bool {
+	x, ok := new(big.Rat).SetString(strings.TrimSpace(a))
+	if !ok {
+		return false
+	}
+	y, ok := new(big.Rat).SetString(strings.TrimSpace(b))
+	if !ok {
+		return false
+	}
+	return x.Cmp(y) == 0
+}
@@ -0,0 +1,343 @@
+package vietqr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockBankTransactionRepo is a mock implementation of BankTransactionRepo
+type MockBankTransactionRepo struct {
+	mock.Mock
+}
+
+func (m *MockBankTransactionRepo) Store(ctx context.Context, txn *entity.BankTransaction) (bool, error) {
+	args := m.Called(ctx, txn)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBankTransactionRepo) ListByStatus(ctx context.Context, status entity.BankTransactionStatus, limit uint64) ([]entity.BankTransaction, error) {
+	args := m.Called(ctx, status, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.BankTransaction), args.Error(1)
+}
+
+func TestExtractReference(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+		found       bool
+	}{
+		{"Thanh toan don hang GDK7KQ2M9XA", "GDK7KQ2M9XA", true},
+		{"MBVCB.123456.thanh toan gdk7kq2m9xa.CT tu 0011", "GDK7KQ2M9XA", true},
+		{"THANH TOAN GDK 7KQ2 M9XA", "GDK7KQ2M9XA", true},
+		{"chuyen tien an trua", "", false},
+		{"GDK7KQ2", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			got, found := ExtractReference(tt.description)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewReference(t *testing.T) {
+	ref, err := NewReference()
+	require.NoError(t, err)
+
+	got, found := ExtractReference("payment " + ref)
+	assert.True(t, found)
+	assert.Equal(t, ref, got)
+}
+
+func TestBankNotificationUseCase_Ingest(t *testing.T) {
+	openQR := entity.VietQR{
+		ID:        "qr-1",
+		Status:    entity.VietQRStatusGenerated,
+		AccountNo: "1234567890",
+		Amount:    "100000",
+		Reference: "GDK7KQ2M9XA",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	credit := entity.BankTransaction{
+		BankTransactionID: "FT24001",
+		AccountNo:         "1234567890",
+		Amount:            "100000.00",
+		Description:       "THANH TOAN GDK7KQ2M9XA",
+		Direction:         entity.BankTransactionCredit,
+		TransactedAt:      time.Now(),
+	}
+
+	tests := []struct {
+		name       string
+		txn        func() entity.BankTransaction
+		mockSetup  func(*MockVietQRPersistentRepo, *MockBankTransactionRepo)
+		wantStatus entity.BankTransactionStatus
+		wantQRID   string
+		wantErr    bool
+	}{
+		{
+			name: "matched credit marks the code paid",
+			txn:  func() entity.BankTransaction { return credit },
+			mockSetup: func(qrRepo *MockVietQRPersistentRepo, txnRepo *MockBankTransactionRepo) {
+				qrRepo.On("FindOpenByReference", mock.Anything, "GDK7KQ2M9XA").Return(openQR, nil)
+				qrRepo.On("PayOpen", mock.Anything, "qr-1", mock.Anything).Return(openQR, nil)
+				txnRepo.On("Store", mock.Anything, mock.MatchedBy(func(txn *entity.BankTransaction) bool {
+					return txn.Status == entity.BankTransactionMatched && txn.VietQRID == "qr-1"
+				})).Return(true, nil)
+			},
+			wantStatus: entity.BankTransactionMatched,
+			wantQRID:   "qr-1",
+		},
+		{
+			name: "code paid or expired after the lookup is stored unmatched",
+			txn:  func() entity.BankTransaction { return credit },
+			mockSetup: func(qrRepo *MockVietQRPersistentRepo, txnRepo *MockBankTransactionRepo) {
+				qrRepo.On("FindOpenByReference", mock.Anything, "GDK7KQ2M9XA").Return(openQR, nil)
+				qrRepo.On("PayOpen", mock.Anything, "qr-1", mock.Anything).Return(entity.VietQR{}, pgx.ErrNoRows)
+				txnRepo.On("Store", mock.Anything, mock.MatchedBy(func(txn *entity.BankTransaction) bool {
+					return txn.Status == entity.BankTransactionUnmatched && txn.VietQRID == ""
+				})).Return(true, nil)
+			},
+			wantStatus: entity.BankTransactionUnmatched,
+		},
+		{
+			name: "pay failure is returned",
+			txn:  func() entity.BankTransaction { return credit },
+			mockSetup: func(qrRepo *MockVietQRPersistentRepo, _ *MockBankTransactionRepo) {
+				qrRepo.On("FindOpenByReference", mock.Anything, "GDK7KQ2M9XA").Return(openQR, nil)
+				qrRepo.On("PayOpen", mock.Anything, "qr-1", mock.Anything).Return(entity.VietQR{}, errors.New("connection refused"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "amount mismatch is stored unmatched",
+			txn: func() entity.BankTransaction {
+				txn := credit
+				txn.Amount = "90000"
+				return txn
+			},
+			mockSetup: func(qrRepo *MockVietQRPersistentRepo, txnRepo *MockBankTransactionRepo) {
+				qrRepo.On("FindOpenByReference", mock.Anything, "GDK7KQ2M9XA").Return(openQR, nil)
+				txnRepo.On("Store", mock.Anything, mock.Anything).Return(true, nil)
+			},
+			wantStatus: entity.BankTransactionUnmatched,
+		},
+		{
+			name: "account mismatch is stored unmatched",
+			txn: func() entity.BankTransaction {
+				txn := credit
+				txn.AccountNo = "999999"
+				return txn
+			},
+			mockSetup: func(qrRepo *MockVietQRPersistentRepo, txnRepo *MockBankTransactionRepo) {
+				qrRepo.On("FindOpenByReference", mock.Anything, "GDK7KQ2M9XA").Return(openQR, nil)
+				txnRepo.On("Store", mock.Anything, mock.Anything).Return(true, nil)
+			},
+			wantStatus: entity.BankTransactionUnmatched,
+		},
+		{
+			name: "expired code is stored unmatched",
+			txn:  func() entity.BankTransaction { return credit },
+			mockSetup: func(qrRepo *MockVietQRPersistentRepo, txnRepo *MockBankTransactionRepo) {
+				expired := openQR
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				qrRepo.On("FindOpenByReference", mock.Anything, "GDK7KQ2M9XA").Return(expired, nil)
+				txnRepo.On("Store", mock.Anything, mock.Anything).Return(true, nil)
+			},
+			wantStatus: entity.BankTransactionUnmatched,
+		},
+		{
+			name: "unknown reference is stored unmatched",
+			txn:  func() entity.BankTransaction { return credit },
+			mockSetup: func(qrRepo *MockVietQRPersistentRepo, txnRepo *MockBankTransactionRepo) {
+				qrRepo.On("FindOpenByReference", mock.Anything, "GDK7KQ2M9XA").Return(entity.VietQR{}, pgx.ErrNoRows)
+				txnRepo.On("Store", mock.Anything, mock.Anything).Return(true, nil)
+			},
+			wantStatus: entity.BankTransactionUnmatched,
+		},
+		{
+			name: "no reference is stored unmatched",
+			txn: func() entity.BankTransaction {
+				txn := credit
+				txn.Description = "chuyen tien"
+				return txn
+			},
+			mockSetup: func(_ *MockVietQRPersistentRepo, txnRepo *MockBankTransactionRepo) {
+				txnRepo.On("Store", mock.Anything, mock.Anything).Return(true, nil)
+			},
+			wantStatus: entity.BankTransactionUnmatched,
+		},
+		{
+			name: "debit is ignored",
+			txn: func() entity.BankTransaction {
+				txn := credit
+				txn.Direction = entity.BankTransactionDebit
+				return txn
+			},
+			mockSetup:  func(*MockVietQRPersistentRepo, *MockBankTransactionRepo) {},
+			wantStatus: entity.BankTransactionIgnored,
+		},
+		{
+			name: "lookup failure is returned",
+			txn:  func() entity.BankTransaction { return credit },
+			mockSetup: func(qrRepo *MockVietQRPersistentRepo, _ *MockBankTransactionRepo) {
+				qrRepo.On("FindOpenByReference", mock.Anything, "GDK7KQ2M9XA").Return(entity.VietQR{}, errors.New("connection refused"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qrRepo := new(MockVietQRPersistentRepo)
+			txnRepo := new(MockBankTransactionRepo)
+			tt.mockSetup(qrRepo, txnRepo)
+
+			qrUseCase := NewVietQRUseCase(new(MockVietQRRepo), qrRepo)
+			useCase := NewBankNotificationUseCase(qrUseCase, qrRepo, txnRepo, nil)
+
+			txn, err := useCase.Ingest(context.Background(), tt.txn())
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantStatus, txn.Status)
+				assert.Equal(t, tt.wantQRID, txn.VietQRID)
+			}
+
+			qrRepo.AssertExpectations(t)
+			txnRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// fakeQRStore holds codes in memory; PayOpen is a conditional write like the postgres one.
+type fakeQRStore struct {
+	MockVietQRPersistentRepo
+
+	mu    sync.Mutex
+	codes map[string]entity.VietQR
+}
+
+func (f *fakeQRStore) FindOpenByReference(_ context.Context, reference string) (entity.VietQR, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, qr := range f.codes {
+		if qr.Reference == reference && qr.Status == entity.VietQRStatusGenerated {
+			return qr, nil
+		}
+	}
+	return entity.VietQR{}, pgx.ErrNoRows
+}
+
+func (f *fakeQRStore) PayOpen(_ context.Context, id string, now time.Time) (entity.VietQR, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	qr, ok := f.codes[id]
+	if !ok || qr.Status != entity.VietQRStatusGenerated || qr.IsExpired(now) {
+		return entity.VietQR{}, pgx.ErrNoRows
+	}
+	prev := qr
+	qr.Status = entity.VietQRStatusPaid
+	f.codes[id] = qr
+	return prev, nil
+}
+
+func TestBankNotificationUseCase_IngestConcurrentCredits(t *testing.T) {
+	store := &fakeQRStore{codes: map[string]entity.VietQR{
+		"qr-1": {
+			ID:        "qr-1",
+			Status:    entity.VietQRStatusGenerated,
+			AccountNo: "1234567890",
+			Amount:    "100000",
+			Reference: "GDK7KQ2M9XA",
+			ExpiresAt: time.Now().Add(time.Hour),
+		},
+	}}
+	txnRepo := new(MockBankTransactionRepo)
+	txnRepo.On("Store", mock.Anything, mock.Anything).Return(true, nil)
+	useCase := NewBankNotificationUseCase(NewVietQRUseCase(new(MockVietQRRepo), store), store, txnRepo, nil)
+
+	// Two transfers quoting the same reference both find the open code
+	const credits = 8
+	statuses := make(chan entity.BankTransactionStatus, credits)
+	var wg sync.WaitGroup
+	for range credits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			txn, err := useCase.Ingest(context.Background(), entity.BankTransaction{
+				AccountNo:   "1234567890",
+				Amount:      "100000",
+				Description: "THANH TOAN GDK7KQ2M9XA",
+				Direction:   entity.BankTransactionCredit,
+			})
+			assert.NoError(t, err)
+			statuses <- txn.Status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	matched := 0
+	for status := range statuses {
+		if status == entity.BankTransactionMatched {
+			matched++
+		}
+	}
+	assert.Equal(t, 1, matched, "the code is paid once; the other credits wait for review")
+}
+
+func TestBankNotificationUseCase_IngestRedelivery(t *testing.T) {
+	first := entity.BankTransaction{
+		ID:                7,
+		BankTransactionID: "FT24001",
+		Status:            entity.BankTransactionMatched,
+		VietQRID:          "qr-1",
+	}
+	store := &fakeQRStore{codes: map[string]entity.VietQR{
+		"qr-1": {ID: "qr-1", Status: entity.VietQRStatusGenerated, AccountNo: "1234567890", Amount: "100000", Reference: "GDK7KQ2M9XA"},
+	}}
+	txnRepo := new(MockBankTransactionRepo)
+	txnRepo.On("Store", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.BankTransaction) = first
+	}).Return(false, nil)
+	tx := &fakeTransactor{}
+	useCase := NewBankNotificationUseCase(NewVietQRUseCase(new(MockVietQRRepo), store), store, txnRepo, tx)
+
+	txn, err := useCase.Ingest(context.Background(), entity.BankTransaction{
+		BankTransactionID: "FT24001",
+		AccountNo:         "1234567890",
+		Amount:            "100000",
+		Description:       "THANH TOAN GDK7KQ2M9XA",
+		Direction:         entity.BankTransactionCredit,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, first, txn, "the row of the first delivery is returned")
+	assert.Equal(t, 1, tx.rolledBack, "the payment of the redelivery is rolled back")
+}
+
+func TestVietQRUseCase_PayOpenExpired(t *testing.T) {
+	// The code expired between the lookup and the write
+	store := &fakeQRStore{codes: map[string]entity.VietQR{
+		"qr-1": {ID: "qr-1", Status: entity.VietQRStatusGenerated, ExpiresAt: time.Now().Add(-time.Second)},
+	}}
+	useCase := NewVietQRUseCase(new(MockVietQRRepo), store)
+
+	assert.ErrorIs(t, useCase.PayOpen(context.Background(), "qr-1"), ErrQRNotOpen)
+	assert.Equal(t, entity.VietQRStatusGenerated, store.codes["qr-1"].Status, "not revived to paid")
+}
@@ -0,0 +1,49 @@
+package vietqr
+
+import (
+	"crypto/rand"
+	"regexp"
+	"strings"
+)
+
+const (
+	referencePrefix   = "GDK"
+	referenceLength   = 8
+	referenceAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// referencePattern finds a reference inside a bank transfer description.
+var referencePattern = regexp.MustCompile(`GDK[A-Z0-9]{8}`)
+
+// NewReference returns a random payment reference such as GDK7KQ2M9XA.
+// The alphabet skips 0/O and 1/I so references survive manual retyping.
+func NewReference() (string, error) {
+	buf := make([]byte, referenceLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = referenceAlphabet[int(b)%len(referenceAlphabet)]
+	}
+	return referencePrefix + string(buf), nil
+}
+
+// ExtractReference returns the first reference found in a bank transfer description.
+// Banks often upper-case descriptions or strip separators, so matching ignores both.
+func ExtractReference(description string) (string, bool) {
+	normalized := strings.ToUpper(description)
+	ref := referencePattern.FindString(normalized)
+	if ref == "" {
+		// Some banks drop spaces entirely; punctuation inside the reference is never generated
+		ref = referencePattern.FindString(strings.NewReplacer(" ", "", "-", "", ".", "").Replace(normalized))
+	}
+	return ref, ref != ""
+}
+
+func withReference(description, reference string) string {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return reference
+	}
+	return description + " " + reference
+}
@@ -2,10 +2,29 @@ package vietqr
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/ducnpdev/godev-kit/internal/entity"
+	"github.com/ducnpdev/godev-kit/internal/repo"
 	"github.com/ducnpdev/godev-kit/internal/repo/externalapi/vietqr"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultTTL is how long a generated code stays payable when neither the request nor the use case sets one.
+const DefaultTTL = 15 * time.Minute
+
+var (
+	// ErrQRNotFound is returned when no code exists for the given ID.
+	ErrQRNotFound = errors.New("vietqr - code not found")
+	// ErrInvalidImageRequest is returned for unsupported image formats, levels or logos.
+	ErrInvalidImageRequest = errors.New("vietqr - invalid image request")
+	// ErrUnknownBank is returned when a generate request names a bank missing from the directory.
+	ErrUnknownBank = errors.New("vietqr - unknown bank")
+	// ErrQRNotOpen is returned by PayOpen when the code is paid, expired or cancelled.
+	ErrQRNotOpen = errors.New("vietqr - code is not open")
 )
 
 // VietQRUseCase is the interface for the vietqr use case.
@@ -13,35 +32,162 @@ type VietQRUseCase interface {
 	GenerateQR(ctx context.Context, req entity.VietQRGenerateRequest) (*entity.VietQR, error)
 	InquiryQR(ctx context.Context, id string) (*entity.VietQR, error)
 	UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) error
+	// PayOpen marks the code paid if it is still open and unexpired, in one
+	// conditional write, so a code is paid at most once. ErrQRNotOpen otherwise.
+	PayOpen(ctx context.Context, id string) error
+	// WatchStatus streams status changes of an existing code until cancel is called.
+	WatchStatus(ctx context.Context, id string) (events <-chan entity.VietQRStatusChangedEvent, cancel func(), err error)
+	RenderQR(ctx context.Context, req entity.VietQRImageRequest) (*entity.VietQRImage, error)
+	DecodeQR(ctx context.Context, content string) (*entity.VietQRPayload, error)
+	ListBanks(ctx context.Context, query string) ([]entity.Bank, error)
+	// ExpireOverdue moves open codes past their expiry to timeout and returns their IDs.
+	ExpireOverdue(ctx context.Context) ([]string, error)
 }
 
 // VietQRPersistentRepo is the interface for the vietqr persistent repository.
 type VietQRPersistentRepo interface {
 	Store(ctx context.Context, qr entity.VietQR) error
 	FindByID(ctx context.Context, id string) (entity.VietQR, error)
-	UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) error
+	// UpdateStatus returns the code as it was before the update.
+	UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) (entity.VietQR, error)
+	// ExpireBefore returns the expired codes as they were before the update.
+	ExpireBefore(ctx context.Context, now time.Time) ([]entity.VietQR, error)
+	// FindOpenByReference returns the generated or in-process code carrying the reference.
+	FindOpenByReference(ctx context.Context, reference string) (entity.VietQR, error)
+	// PayOpen moves the code to paid only if it is open and not expired at now, and returns
+	// it as it was before the update; pgx.ErrNoRows when nothing changed.
+	PayOpen(ctx context.Context, id string, now time.Time) (entity.VietQR, error)
 }
 
 type vietQRUseCase struct {
 	repo           vietqr.VietQRRepo
 	persistentRepo VietQRPersistentRepo
+	banks          vietqr.BankDirectory
+	publisher      StatusPublisher
+	broker         *Broker
+	tx             repo.Transactor
+	hooks          []StatusHook
+	defaultTTL     time.Duration
+	imageLevel     string
+	logoDir        string
+}
+
+// Option configures the vietqr use case.
+type Option func(*vietQRUseCase)
+
+// DefaultExpiry sets the TTL used when a generate request does not carry one.
+func DefaultExpiry(ttl time.Duration) Option {
+	return func(uc *vietQRUseCase) {
+		if ttl > 0 {
+			uc.defaultTTL = ttl
+		}
+	}
+}
+
+// ImageErrorCorrection sets the default error-correction level (L, M, Q or H) for rendered images.
+func ImageErrorCorrection(level string) Option {
+	return func(uc *vietQRUseCase) {
+		if level != "" {
+			uc.imageLevel = level
+		}
+	}
+}
+
+// LogoDir sets the directory holding <name>.png logo overlays. Empty disables logos.
+func LogoDir(dir string) Option {
+	return func(uc *vietQRUseCase) {
+		uc.logoDir = dir
+	}
+}
+
+// Banks replaces the embedded NAPAS bank directory.
+func Banks(banks vietqr.BankDirectory) Option {
+	return func(uc *vietQRUseCase) {
+		if banks != nil {
+			uc.banks = banks
+		}
+	}
+}
+
+// Events sends status changes to publisher and serves WatchStatus from broker. Without it
+// changes only reach watchers of this instance.
+func Events(publisher StatusPublisher, broker *Broker) Option {
+	return func(uc *vietQRUseCase) {
+		if publisher != nil {
+			uc.publisher = publisher
+		}
+		if broker != nil {
+			uc.broker = broker
+		}
+	}
+}
+
+// Hooks runs hooks in the transaction of every status change, before the change is
+// published. Without tx the hooks run after the change, which an error does not undo.
+func Hooks(tx repo.Transactor, hooks ...StatusHook) Option {
+	return func(uc *vietQRUseCase) {
+		uc.tx = tx
+		uc.hooks = append(uc.hooks, hooks...)
+	}
 }
 
 // NewVietQRUseCase creates a new vietqr use case.
-func NewVietQRUseCase(repo vietqr.VietQRRepo, persistentRepo VietQRPersistentRepo) VietQRUseCase {
-	return &vietQRUseCase{repo: repo, persistentRepo: persistentRepo}
+func NewVietQRUseCase(repo vietqr.VietQRRepo, persistentRepo VietQRPersistentRepo, opts ...Option) VietQRUseCase {
+	broker := NewBroker()
+	uc := &vietQRUseCase{
+		repo:           repo,
+		persistentRepo: persistentRepo,
+		banks:          vietqr.DefaultBankDirectory(),
+		publisher:      broker,
+		broker:         broker,
+		defaultTTL:     DefaultTTL,
+		imageLevel:     "M",
+	}
+	for _, opt := range opts {
+		opt(uc)
+	}
+	return uc
 }
 
 func (uc *vietQRUseCase) GenerateQR(ctx context.Context, req entity.VietQRGenerateRequest) (*entity.VietQR, error) {
+	bank, ok := uc.banks.Lookup(req.BankCode)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBank, req.BankCode)
+	}
+	req.BankCode = bank.Code
+	req.BankBIN = bank.BIN
+
+	reference, err := NewReference()
+	if err != nil {
+		return nil, err
+	}
+	// The payer's bank copies the description into the transfer, which is how notifications find the code again
+	req.Description = withReference(req.Description, reference)
+
 	content, err := uc.repo.GenerateQR(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = uc.defaultTTL
+	}
+	now := time.Now()
+
 	qrEntity := &entity.VietQR{
-		ID:      uuid.NewString(),
-		Status:  entity.VietQRStatusGenerated,
-		Content: content,
+		ID:               uuid.NewString(),
+		Status:           entity.VietQRStatusGenerated,
+		Content:          content,
+		BankCode:         req.BankCode,
+		BankBIN:          req.BankBIN,
+		AccountNo:        req.AccountNo,
+		Amount:           req.Amount,
+		Description:      req.Description,
+		Reference:        reference,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(ttl),
+		RemainingSeconds: int64(ttl / time.Second),
 	}
 
 	if err := uc.persistentRepo.Store(ctx, *qrEntity); err != nil {
@@ -56,9 +202,147 @@ func (uc *vietQRUseCase) InquiryQR(ctx context.Context, id string) (*entity.Viet
 	if err != nil {
 		return nil, err
 	}
+
+	now := time.Now()
+	if qr.Status.IsOpen() && qr.IsExpired(now) {
+		// The expirer has not swept this code yet; report what it will become
+		qr.Status = entity.VietQRStatusTimeout
+	}
+	if qr.Status.IsOpen() && !qr.ExpiresAt.IsZero() {
+		qr.RemainingSeconds = int64(qr.ExpiresAt.Sub(now) / time.Second)
+	}
+
 	return &qr, nil
 }
 
 func (uc *vietQRUseCase) UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) error {
-	return uc.persistentRepo.UpdateStatus(ctx, id, status)
+	_, err := uc.transition(ctx, status, func(ctx context.Context) ([]entity.VietQR, error) {
+		prev, err := uc.persistentRepo.UpdateStatus(ctx, id, status)
+		if err != nil {
+			return nil, err
+		}
+		return []entity.VietQR{prev}, nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrQRNotFound
+	}
+	return err
+}
+
+func (uc *vietQRUseCase) PayOpen(ctx context.Context, id string) error {
+	_, err := uc.transition(ctx, entity.VietQRStatusPaid, func(ctx context.Context) ([]entity.VietQR, error) {
+		prev, err := uc.persistentRepo.PayOpen(ctx, id, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		return []entity.VietQR{prev}, nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrQRNotOpen
+	}
+	return err
+}
+
+func (uc *vietQRUseCase) WatchStatus(ctx context.Context, id string) (<-chan entity.VietQRStatusChangedEvent, func(), error) {
+	// Subscribe before checking existence so a change in between is not lost
+	events, cancel := uc.broker.Watch(id)
+	if _, err := uc.persistentRepo.FindByID(ctx, id); err != nil {
+		cancel()
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, ErrQRNotFound
+		}
+		return nil, nil, err
+	}
+	return events, cancel, nil
+}
+
+// transition runs change, which moves codes to status and returns them as they were before,
+// together with the hooks, and publishes the changes once they are committed.
+func (uc *vietQRUseCase) transition(ctx context.Context, status entity.VietQRStatus, change func(ctx context.Context) ([]entity.VietQR, error)) ([]entity.VietQR, error) {
+	var (
+		prevs  []entity.VietQR
+		events []entity.VietQRStatusChangedEvent
+	)
+	err := uc.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		prevs, err = change(ctx)
+		if err != nil {
+			return err
+		}
+
+		events = events[:0]
+		for _, prev := range prevs {
+			if prev.Status == status {
+				continue
+			}
+			event := statusChangedEvent(prev, status)
+			for _, hook := range uc.hooks {
+				if err := hook.StatusChanged(ctx, event); err != nil {
+					return fmt.Errorf("vietqr - transition - hook: %w", err)
+				}
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		uc.publisher.PublishStatusChanged(ctx, event)
+	}
+	return prevs, nil
+}
+
+// inTx runs fn in the transaction of tx, or directly without one.
+func (uc *vietQRUseCase) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if uc.tx == nil {
+		return fn(ctx)
+	}
+	return uc.tx.InTx(ctx, fn)
+}
+
+func statusChangedEvent(prev entity.VietQR, status entity.VietQRStatus) entity.VietQRStatusChangedEvent {
+	return entity.VietQRStatusChangedEvent{
+		EventID:    uuid.NewString(),
+		Type:       entity.VietQREventStatusChanged,
+		QRID:       prev.ID,
+		OldStatus:  prev.Status,
+		NewStatus:  status,
+		BankCode:   prev.BankCode,
+		AccountNo:  prev.AccountNo,
+		Amount:     prev.Amount,
+		Reference:  prev.Reference,
+		OccurredAt: time.Now(),
+	}
+}
+
+func (uc *vietQRUseCase) DecodeQR(_ context.Context, content string) (*entity.VietQRPayload, error) {
+	payload, err := vietqr.Decode(content)
+	if err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+func (uc *vietQRUseCase) ListBanks(_ context.Context, query string) ([]entity.Bank, error) {
+	return uc.banks.Search(query), nil
+}
+
+// ExpireOverdue expires all overdue codes in one transaction; a failing hook leaves them
+// open until the next run.
+func (uc *vietQRUseCase) ExpireOverdue(ctx context.Context) ([]string, error) {
+	expired, err := uc.transition(ctx, entity.VietQRStatusTimeout, func(ctx context.Context) ([]entity.VietQR, error) {
+		return uc.persistentRepo.ExpireBefore(ctx, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(expired))
+	for _, prev := range expired {
+		ids = append(ids, prev.ID)
+	}
+	return ids, nil
 }
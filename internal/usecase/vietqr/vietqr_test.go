@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ducnpdev/godev-kit/internal/entity"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // contextKey is a type for context keys to avoid collisions
@@ -44,9 +47,27 @@ func (m *MockVietQRPersistentRepo) FindByID(ctx context.Context, id string) (ent
 	return args.Get(0).(entity.VietQR), args.Error(1)
 }
 
-func (m *MockVietQRPersistentRepo) UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) error {
+func (m *MockVietQRPersistentRepo) UpdateStatus(ctx context.Context, id string, status entity.VietQRStatus) (entity.VietQR, error) {
 	args := m.Called(ctx, id, status)
-	return args.Error(0)
+	return args.Get(0).(entity.VietQR), args.Error(1)
+}
+
+func (m *MockVietQRPersistentRepo) ExpireBefore(ctx context.Context, now time.Time) ([]entity.VietQR, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.VietQR), args.Error(1)
+}
+
+func (m *MockVietQRPersistentRepo) FindOpenByReference(ctx context.Context, reference string) (entity.VietQR, error) {
+	args := m.Called(ctx, reference)
+	return args.Get(0).(entity.VietQR), args.Error(1)
+}
+
+func (m *MockVietQRPersistentRepo) PayOpen(ctx context.Context, id string, now time.Time) (entity.VietQR, error) {
+	args := m.Called(ctx, id, now)
+	return args.Get(0).(entity.VietQR), args.Error(1)
 }
 
 func TestVietQRUseCase_GenerateQR(t *testing.T) {
@@ -61,6 +82,7 @@ func TestVietQRUseCase_GenerateQR(t *testing.T) {
 		{
 			name: "success - generate QR code",
 			request: entity.VietQRGenerateRequest{
+				BankCode:     "VCB",
 				AccountNo:    "1234567890",
 				Amount:       "100000",
 				Description:  "Payment for services",
@@ -92,6 +114,7 @@ func TestVietQRUseCase_GenerateQR(t *testing.T) {
 		{
 			name: "success - generate QR with minimal data",
 			request: entity.VietQRGenerateRequest{
+				BankCode:     "VCB",
 				AccountNo:    "9876543210",
 				Amount:       "50000",
 				Description:  "",
@@ -113,6 +136,7 @@ func TestVietQRUseCase_GenerateQR(t *testing.T) {
 		{
 			name: "error - external API repo error",
 			request: entity.VietQRGenerateRequest{
+				BankCode:     "VCB",
 				AccountNo:    "1234567890",
 				Amount:       "100000",
 				Description:  "Payment",
@@ -131,6 +155,7 @@ func TestVietQRUseCase_GenerateQR(t *testing.T) {
 		{
 			name: "error - persistent repo store error",
 			request: entity.VietQRGenerateRequest{
+				BankCode:     "VCB",
 				AccountNo:    "1234567890",
 				Amount:       "100000",
 				Description:  "Payment",
@@ -150,6 +175,7 @@ func TestVietQRUseCase_GenerateQR(t *testing.T) {
 		{
 			name: "error - context deadline exceeded in external API",
 			request: entity.VietQRGenerateRequest{
+				BankCode:     "VCB",
 				AccountNo:    "1234567890",
 				Amount:       "100000",
 				Description:  "Payment",
@@ -372,6 +398,49 @@ func TestVietQRUseCase_InquiryQR(t *testing.T) {
 	}
 }
 
+func TestVietQRUseCase_GenerateQR_Bank(t *testing.T) {
+	t.Run("resolves the BIN from the bank code", func(t *testing.T) {
+		mockRepo := new(MockVietQRRepo)
+		mockPersistent := new(MockVietQRPersistentRepo)
+		mockRepo.On("GenerateQR", mock.Anything, mock.MatchedBy(func(req entity.VietQRGenerateRequest) bool {
+			return req.BankCode == "VCB" && req.BankBIN == "970436"
+		})).Return("QR_CONTENT", nil)
+		mockPersistent.On("Store", mock.Anything, mock.MatchedBy(func(qr entity.VietQR) bool {
+			return qr.BankCode == "VCB" && qr.BankBIN == "970436"
+		})).Return(nil)
+
+		useCase := NewVietQRUseCase(mockRepo, mockPersistent)
+		result, err := useCase.GenerateQR(context.Background(), entity.VietQRGenerateRequest{
+			BankCode:  "vcb",
+			AccountNo: "1234567890",
+			Amount:    "100000",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "970436", result.BankBIN)
+		mockRepo.AssertExpectations(t)
+		mockPersistent.AssertExpectations(t)
+	})
+
+	for _, code := range []string{"", "NOPE"} {
+		t.Run("rejects bank code "+code, func(t *testing.T) {
+			mockRepo := new(MockVietQRRepo)
+			mockPersistent := new(MockVietQRPersistentRepo)
+
+			useCase := NewVietQRUseCase(mockRepo, mockPersistent)
+			result, err := useCase.GenerateQR(context.Background(), entity.VietQRGenerateRequest{
+				BankCode:  code,
+				AccountNo: "1234567890",
+				Amount:    "100000",
+			})
+
+			assert.ErrorIs(t, err, ErrUnknownBank)
+			assert.Nil(t, result)
+			mockRepo.AssertNotCalled(t, "GenerateQR", mock.Anything, mock.Anything)
+		})
+	}
+}
+
 func TestVietQRUseCase_GenerateQR_Context(t *testing.T) {
 	t.Run("verify context is passed to repositories", func(t *testing.T) {
 		// Setup
@@ -380,6 +449,7 @@ func TestVietQRUseCase_GenerateQR_Context(t *testing.T) {
 		mockPersistent := new(MockVietQRPersistentRepo)
 
 		request := entity.VietQRGenerateRequest{
+			BankCode:     "VCB",
 			AccountNo:    "1234567890",
 			Amount:       "100000",
 			Description:  "Test",
@@ -435,3 +505,190 @@ func TestVietQRUseCase_InquiryQR_Context(t *testing.T) {
 		mockPersistent.AssertExpectations(t)
 	})
 }
+
+func TestVietQRUseCase_GenerateQR_Expiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		useCaseOpts []Option
+		ttl         time.Duration
+		expectedTTL time.Duration
+	}{
+		{
+			name:        "request TTL wins",
+			ttl:         5 * time.Minute,
+			expectedTTL: 5 * time.Minute,
+		},
+		{
+			name:        "falls back to package default",
+			expectedTTL: DefaultTTL,
+		},
+		{
+			name:        "falls back to configured default",
+			useCaseOpts: []Option{DefaultExpiry(time.Hour)},
+			expectedTTL: time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockVietQRRepo)
+			mockPersistent := new(MockVietQRPersistentRepo)
+			mockRepo.On("GenerateQR", mock.Anything, mock.Anything).Return("QR_CONTENT", nil)
+			mockPersistent.On("Store", mock.Anything, mock.MatchedBy(func(qr entity.VietQR) bool {
+				return qr.ExpiresAt.Sub(qr.CreatedAt) == tt.expectedTTL && qr.Amount == "100000"
+			})).Return(nil)
+
+			useCase := NewVietQRUseCase(mockRepo, mockPersistent, tt.useCaseOpts...)
+
+			result, err := useCase.GenerateQR(context.Background(), entity.VietQRGenerateRequest{
+				BankCode:  "VCB",
+				AccountNo: "1234567890",
+				Amount:    "100000",
+				TTL:       tt.ttl,
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, int64(tt.expectedTTL/time.Second), result.RemainingSeconds)
+			mockPersistent.AssertExpectations(t)
+		})
+	}
+}
+
+func TestVietQRUseCase_InquiryQR_Expiry(t *testing.T) {
+	tests := []struct {
+		name              string
+		stored            entity.VietQR
+		expectedStatus    entity.VietQRStatus
+		expectRemainingGt int64
+	}{
+		{
+			name: "open code reports remaining validity",
+			stored: entity.VietQR{
+				ID:        "open",
+				Status:    entity.VietQRStatusGenerated,
+				ExpiresAt: time.Now().Add(10 * time.Minute),
+			},
+			expectedStatus:    entity.VietQRStatusGenerated,
+			expectRemainingGt: 500,
+		},
+		{
+			name: "overdue code not yet swept reports timeout",
+			stored: entity.VietQR{
+				ID:        "overdue",
+				Status:    entity.VietQRStatusInProcess,
+				ExpiresAt: time.Now().Add(-time.Minute),
+			},
+			expectedStatus: entity.VietQRStatusTimeout,
+		},
+		{
+			name: "paid code keeps its status after expiry",
+			stored: entity.VietQR{
+				ID:        "paid",
+				Status:    entity.VietQRStatusPaid,
+				ExpiresAt: time.Now().Add(-time.Minute),
+			},
+			expectedStatus: entity.VietQRStatusPaid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPersistent := new(MockVietQRPersistentRepo)
+			mockPersistent.On("FindByID", mock.Anything, tt.stored.ID).Return(tt.stored, nil)
+
+			useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent)
+
+			result, err := useCase.InquiryQR(context.Background(), tt.stored.ID)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, result.Status)
+			if tt.expectRemainingGt > 0 {
+				assert.Greater(t, result.RemainingSeconds, tt.expectRemainingGt)
+			} else {
+				assert.Zero(t, result.RemainingSeconds)
+			}
+		})
+	}
+}
+
+func TestVietQRUseCase_RenderQR(t *testing.T) {
+	stored := entity.VietQR{ID: "qr-1", Status: entity.VietQRStatusGenerated, Content: "QR_CONTENT"}
+
+	tests := []struct {
+		name          string
+		request       entity.VietQRImageRequest
+		findErr       error
+		expectedType  string
+		expectedError error
+	}{
+		{
+			name:         "success - png",
+			request:      entity.VietQRImageRequest{ID: "qr-1", Format: "png"},
+			expectedType: "image/png",
+		},
+		{
+			name:         "success - svg with explicit level",
+			request:      entity.VietQRImageRequest{ID: "qr-1", Format: "svg", Level: "H", Size: 128},
+			expectedType: "image/svg+xml",
+		},
+		{
+			name:          "error - unsupported format",
+			request:       entity.VietQRImageRequest{ID: "qr-1", Format: "gif"},
+			expectedError: ErrInvalidImageRequest,
+		},
+		{
+			name:          "error - logo without logo directory",
+			request:       entity.VietQRImageRequest{ID: "qr-1", Format: "png", Logo: "napas"},
+			expectedError: ErrInvalidImageRequest,
+		},
+		{
+			name:          "error - code not found",
+			request:       entity.VietQRImageRequest{ID: "missing", Format: "png"},
+			findErr:       pgx.ErrNoRows,
+			expectedError: ErrQRNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPersistent := new(MockVietQRPersistentRepo)
+			mockPersistent.On("FindByID", mock.Anything, tt.request.ID).Return(stored, tt.findErr).Maybe()
+
+			useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent)
+
+			img, err := useCase.RenderQR(context.Background(), tt.request)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, img)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedType, img.ContentType)
+			assert.NotEmpty(t, img.Data)
+			assert.NotEmpty(t, img.ETag)
+
+			again, err := useCase.RenderQR(context.Background(), tt.request)
+			assert.NoError(t, err)
+			assert.Equal(t, img.ETag, again.ETag)
+		})
+	}
+}
+
+func TestVietQRUseCase_RenderQR_ETag(t *testing.T) {
+	stored := entity.VietQR{ID: "qr-1", Status: entity.VietQRStatusGenerated, Content: "QR_CONTENT"}
+	mockPersistent := new(MockVietQRPersistentRepo)
+	mockPersistent.On("FindByID", mock.Anything, stored.ID).Return(stored, nil)
+	useCase := NewVietQRUseCase(new(MockVietQRRepo), mockPersistent)
+
+	etag := func(size int) string {
+		img, err := useCase.RenderQR(context.Background(), entity.VietQRImageRequest{ID: stored.ID, Format: "png", Size: size})
+		require.NoError(t, err)
+		return img.ETag
+	}
+
+	// Sizes outside the allowed range render the same image, so they share a validator
+	assert.Equal(t, etag(64), etag(10))
+	assert.Equal(t, etag(1024), etag(5000))
+	assert.NotEqual(t, etag(64), etag(128))
+}
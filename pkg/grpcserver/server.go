@@ -17,12 +17,13 @@ type Server struct {
 	App     *pbgrpc.Server
 	notify  chan error
 	address string
+
+	serverOptions []pbgrpc.ServerOption
 }
 
 // New -.
 func New(opts ...Option) *Server {
 	s := &Server{
-		App:     pbgrpc.NewServer(),
 		notify:  make(chan error, 1),
 		address: _defaultAddr,
 	}
@@ -32,6 +33,8 @@ func New(opts ...Option) *Server {
 		opt(s)
 	}
 
+	s.App = pbgrpc.NewServer(s.serverOptions...)
+
 	return s
 }
 
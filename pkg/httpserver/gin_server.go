@@ -3,6 +3,7 @@ package httpserver
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"time"
@@ -69,6 +70,8 @@ type Server struct {
 	App    *gin.Engine
 	notify chan error
 	srv    *http.Server
+	// err is a setup error, reported by Start instead of serving
+	err error
 
 	address         string
 	readTimeout     time.Duration
@@ -149,6 +152,14 @@ func New(cfg *config.Config, opts ...Option) *Server {
 	// Create Gin engine with default middleware
 	app := gin.New()
 
+	// ClientIP only reads X-Forwarded-For from trusted proxies; gin trusts
+	// every peer by default, letting clients pick their IP for rate limits.
+	// An invalid list trusts no one and fails Start.
+	if err := app.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		s.err = fmt.Errorf("httpserver - New - app.SetTrustedProxies: %w", err)
+		_ = app.SetTrustedProxies(nil)
+	}
+
 	// Add default middleware
 	app.Use(gin.Recovery())
 	if cfg.App.MODE == "debug" {
@@ -171,6 +182,12 @@ func New(cfg *config.Config, opts ...Option) *Server {
 
 // Start -.
 func (s *Server) Start() {
+	if s.err != nil {
+		s.notify <- s.err
+		close(s.notify)
+		return
+	}
+
 	go func() {
 		s.notify <- s.srv.ListenAndServe()
 		close(s.notify)
@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ducnpdev/godev-kit/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_ClientIP(t *testing.T) {
+	clientIP := func(t *testing.T, trusted []string) string {
+		t.Helper()
+
+		cfg := &config.Config{}
+		cfg.HTTP.TrustedProxies = trusted
+		s := New(cfg)
+		s.App.GET("/ip", func(c *gin.Context) {
+			c.String(http.StatusOK, c.ClientIP())
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = "203.0.113.7:40000"
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		rec := httptest.NewRecorder()
+		s.App.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		return rec.Body.String()
+	}
+
+	t.Run("ignores a spoofed header by default", func(t *testing.T) {
+		assert.Equal(t, "203.0.113.7", clientIP(t, nil))
+	})
+
+	t.Run("ignores the header from untrusted peers", func(t *testing.T) {
+		assert.Equal(t, "203.0.113.7", clientIP(t, []string{"10.0.0.0/8"}))
+	})
+
+	t.Run("reads the header from trusted proxies", func(t *testing.T) {
+		assert.Equal(t, "198.51.100.1", clientIP(t, []string{"203.0.113.0/24"}))
+	})
+}
+
+func TestNew_InvalidTrustedProxies(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.HTTP.TrustedProxies = []string{"not-an-ip"}
+
+	s := New(cfg)
+	s.Start()
+
+	assert.Error(t, <-s.Notify())
+}
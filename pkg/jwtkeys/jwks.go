@@ -0,0 +1,115 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JWK is a public key in JSON Web Key format (RFC 7517, RFC 7518 for EC, RFC 8037 for Ed25519).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP (Ed25519); Y is only set for EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range ks.Public() {
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Algorithm()}
+		switch pub := k.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// Key converts the JWK back into a verification key.
+func (j JWK) Key() (Key, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q - n: %w", j.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q - e: %w", j.Kid, err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 2 || exp.Int64() > 1<<31-1 {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q: invalid exponent", j.Kid)
+		}
+		return NewPublicKey(j.Kid, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())})
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q - curve %s: %w", j.Kid, j.Crv, ErrUnsupportedKey)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q - x: %w", j.Kid, err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q - y: %w", j.Kid, err)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q: point is not on curve %s", j.Kid, j.Crv)
+		}
+		return NewPublicKey(j.Kid, pub)
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q - curve %s: %w", j.Kid, j.Crv, ErrUnsupportedKey)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q - x: %w", j.Kid, err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return Key{}, fmt.Errorf("jwtkeys - JWK %q: invalid Ed25519 key size", j.Kid)
+		}
+		return NewPublicKey(j.Kid, ed25519.PublicKey(x))
+	default:
+		return Key{}, fmt.Errorf("jwtkeys - JWK %q - kty %s: %w", j.Kid, j.Kty, ErrUnsupportedKey)
+	}
+}
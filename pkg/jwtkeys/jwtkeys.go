@@ -0,0 +1,254 @@
+// Package jwtkeys signs and verifies JWTs with rotating keys and publishes
+// the public keys as a JSON Web Key Set.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	// ErrUnknownKey is returned when a token names a key that is not in the set.
+	ErrUnknownKey = errors.New("jwtkeys: unknown key id")
+	// ErrAlgorithmMismatch is returned when a token's alg does not match its key.
+	ErrAlgorithmMismatch = errors.New("jwtkeys: algorithm does not match key")
+	// ErrNoSigningKey is returned by Sign on a verification-only set.
+	ErrNoSigningKey = errors.New("jwtkeys: no signing key")
+	// ErrUnsupportedKey is returned for keys other than RSA, ECDSA P-256/P-384 and Ed25519.
+	ErrUnsupportedKey = errors.New("jwtkeys: unsupported key type")
+)
+
+// Key is a signing or verification key identified by its kid.
+type Key struct {
+	ID     string
+	method jwt.SigningMethod
+	// sign is the private key or HMAC secret; nil for verification-only keys
+	sign interface{}
+	// verify is the public key or HMAC secret
+	verify interface{}
+}
+
+// Algorithm returns the JWS algorithm of the key, e.g. RS256 or EdDSA.
+func (k Key) Algorithm() string {
+	return k.method.Alg()
+}
+
+// KeySet signs with one key and verifies with any key in the set.
+type KeySet struct {
+	signing Key
+	keys    map[string]Key
+}
+
+// NewHMAC returns a set that signs and verifies HS256 with a shared secret.
+// It has no public keys to publish.
+func NewHMAC(secret string) *KeySet {
+	key := Key{method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}
+
+	return &KeySet{signing: key, keys: map[string]Key{"": key}}
+}
+
+// New returns a set that signs with signing and also accepts tokens signed by
+// any of verification, typically the keys it replaced.
+func New(signing Key, verification ...Key) (*KeySet, error) {
+	if signing.sign == nil {
+		return nil, fmt.Errorf("jwtkeys - New: signing key %q has no private key", signing.ID)
+	}
+
+	ks := &KeySet{signing: signing, keys: map[string]Key{signing.ID: signing}}
+	for _, k := range verification {
+		if _, ok := ks.keys[k.ID]; ok {
+			return nil, fmt.Errorf("jwtkeys - New: duplicate key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+
+	return ks, nil
+}
+
+// NewVerifier returns a set that only verifies, e.g. for a service that trusts
+// the keys published by another service's JWKS endpoint.
+func NewVerifier(keys ...Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]Key, len(keys))}
+	for _, k := range keys {
+		if _, ok := ks.keys[k.ID]; ok {
+			return nil, fmt.Errorf("jwtkeys - NewVerifier: duplicate key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+
+	return ks, nil
+}
+
+// LoadPrivateKey reads a PEM encoded RSA, ECDSA or Ed25519 private key (PKCS#8,
+// PKCS#1 for RSA or SEC 1 for ECDSA).
+func LoadPrivateKey(kid, path string) (Key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return Key{}, err
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("jwtkeys - LoadPrivateKey - %s: %w", path, err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return Key{}, fmt.Errorf("jwtkeys - LoadPrivateKey - %s: %w", path, ErrUnsupportedKey)
+	}
+
+	key, err := NewPublicKey(kid, signer.Public())
+	if err != nil {
+		return Key{}, fmt.Errorf("jwtkeys - LoadPrivateKey - %s: %w", path, err)
+	}
+	key.sign = signer
+
+	return key, nil
+}
+
+// LoadPublicKey reads a PEM encoded PKIX public key or certificate.
+func LoadPublicKey(kid, path string) (Key, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return Key{}, err
+	}
+
+	var pub interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			pub = cert.PublicKey
+		}
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("jwtkeys - LoadPublicKey - %s: %w", path, err)
+	}
+
+	key, err := NewPublicKey(kid, pub)
+	if err != nil {
+		return Key{}, fmt.Errorf("jwtkeys - LoadPublicKey - %s: %w", path, err)
+	}
+
+	return key, nil
+}
+
+// NewPrivateKey wraps an *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey.
+func NewPrivateKey(kid string, priv crypto.Signer) (Key, error) {
+	key, err := NewPublicKey(kid, priv.Public())
+	if err != nil {
+		return Key{}, err
+	}
+	key.sign = priv
+
+	return key, nil
+}
+
+// NewPublicKey wraps an *rsa.PublicKey (RS256), *ecdsa.PublicKey on P-256 (ES256)
+// or P-384 (ES384), or ed25519.PublicKey (EdDSA).
+func NewPublicKey(kid string, pub crypto.PublicKey) (Key, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return Key{ID: kid, method: jwt.SigningMethodRS256, verify: pub}, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return Key{ID: kid, method: jwt.SigningMethodES256, verify: pub}, nil
+		case elliptic.P384():
+			return Key{ID: kid, method: jwt.SigningMethodES384, verify: pub}, nil
+		}
+		return Key{}, ErrUnsupportedKey
+	case ed25519.PublicKey:
+		return Key{ID: kid, method: jwt.SigningMethodEdDSA, verify: pub}, nil
+	default:
+		return Key{}, ErrUnsupportedKey
+	}
+}
+
+// Sign signs claims with the signing key and sets the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.signing.sign == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
+	}
+
+	return token.SignedString(ks.signing.sign)
+}
+
+// Keyfunc resolves the verification key of a token by its kid header and
+// rejects tokens whose alg differs from the key's, so a public key can never
+// be used as an HMAC secret.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+
+	return verifyKey(token, key.method, key.verify)
+}
+
+// Public returns the keys published in the JWKS; HMAC keys are never included.
+func (ks *KeySet) Public() []Key {
+	keys := make([]Key, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		if k.method == jwt.SigningMethodHS256 {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return keys
+}
+
+func verifyKey(token *jwt.Token, method jwt.SigningMethod, key interface{}) (interface{}, error) {
+	if token.Method.Alg() != method.Alg() {
+		return nil, fmt.Errorf("%w: token %s, key %s", ErrAlgorithmMismatch, token.Method.Alg(), method.Alg())
+	}
+
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwtkeys - readPEM: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwtkeys - readPEM - %s: no PEM block found", path)
+	}
+
+	return block, nil
+}
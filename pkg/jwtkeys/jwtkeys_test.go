@@ -0,0 +1,238 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func newRSAKey(t *testing.T, kid string) Key {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := NewPrivateKey(kid, priv)
+	require.NoError(t, err)
+
+	return key
+}
+
+func newEd25519Key(t *testing.T, kid string) Key {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := NewPrivateKey(kid, priv)
+	require.NoError(t, err)
+
+	return key
+}
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+
+	return path
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	for _, key := range []Key{newRSAKey(t, "rsa-1"), newEd25519Key(t, "ed-1")} {
+		t.Run(key.Algorithm(), func(t *testing.T) {
+			ks, err := New(key)
+			require.NoError(t, err)
+
+			signed, err := ks.Sign(newClaims())
+			require.NoError(t, err)
+
+			var claims jwt.RegisteredClaims
+			token, err := jwt.ParseWithClaims(signed, &claims, ks.Keyfunc)
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, token.Header["kid"])
+			assert.Equal(t, key.Algorithm(), token.Method.Alg())
+			assert.Equal(t, "42", claims.Subject)
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	oldKey := newRSAKey(t, "2025-01")
+	newKey := newEd25519Key(t, "2025-06")
+
+	old, err := New(oldKey)
+	require.NoError(t, err)
+	oldToken, err := old.Sign(newClaims())
+	require.NoError(t, err)
+
+	// The retired key is kept for verification only
+	retired, err := NewPublicKey(oldKey.ID, oldKey.verify)
+	require.NoError(t, err)
+	rotated, err := New(newKey, retired)
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(oldToken, rotated.Keyfunc)
+	assert.NoError(t, err)
+
+	// Once dropped from the set, the old key no longer verifies
+	current, err := New(newKey)
+	require.NoError(t, err)
+	_, err = jwt.Parse(oldToken, current.Keyfunc)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = New(newKey, newKey)
+	assert.Error(t, err)
+	_, err = New(retired)
+	assert.Error(t, err)
+}
+
+func TestKeySet_RejectsAlgorithmConfusion(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	ks, err := New(key)
+	require.NoError(t, err)
+
+	// An HS256 token "signed" with the public key must not verify
+	der, err := x509.MarshalPKIXPublicKey(key.verify)
+	require.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims())
+	token.Header["kid"] = key.ID
+	forged, err := token.SignedString(der)
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(forged, ks.Keyfunc)
+	assert.ErrorIs(t, err, ErrAlgorithmMismatch)
+}
+
+func TestNewHMAC(t *testing.T) {
+	ks := NewHMAC("secret")
+
+	signed, err := ks.Sign(newClaims())
+	require.NoError(t, err)
+	_, err = jwt.Parse(signed, ks.Keyfunc)
+	assert.NoError(t, err)
+
+	_, err = jwt.Parse(signed, NewHMAC("other").Keyfunc)
+	assert.Error(t, err)
+
+	assert.Empty(t, ks.JWKS().Keys)
+}
+
+func TestLoadKeys(t *testing.T) {
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edPriv)
+	require.NoError(t, err)
+	pkix, err := x509.MarshalPKIXPublicKey(edPub)
+	require.NoError(t, err)
+
+	signing, err := LoadPrivateKey("ed-1", writePEM(t, "PRIVATE KEY", pkcs8))
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", signing.Algorithm())
+
+	rsaKey, err := LoadPrivateKey("rsa-1", writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPriv)))
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", rsaKey.Algorithm())
+
+	verification, err := LoadPublicKey("ed-1", writePEM(t, "PUBLIC KEY", pkix))
+	require.NoError(t, err)
+
+	ks, err := New(signing)
+	require.NoError(t, err)
+	signed, err := ks.Sign(newClaims())
+	require.NoError(t, err)
+
+	verifier, err := NewVerifier(verification)
+	require.NoError(t, err)
+	_, err = jwt.Parse(signed, verifier.Keyfunc)
+	assert.NoError(t, err)
+	_, err = verifier.Sign(newClaims())
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+
+	_, err = LoadPrivateKey("missing", filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	ks, err := New(newRSAKey(t, "rsa-1"), newEd25519Key(t, "ed-1"))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(ks.JWKS())
+	require.NoError(t, err)
+
+	var set JWKS
+	require.NoError(t, json.Unmarshal(data, &set))
+	require.Len(t, set.Keys, 2)
+	assert.Equal(t, "ed-1", set.Keys[0].Kid)
+	assert.Equal(t, "OKP", set.Keys[0].Kty)
+	assert.Equal(t, "RSA", set.Keys[1].Kty)
+	assert.Equal(t, "AQAB", set.Keys[1].E)
+
+	// A verifier rebuilt from the published keys accepts the set's tokens
+	keys := make([]Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.Key()
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	verifier, err := NewVerifier(keys...)
+	require.NoError(t, err)
+	signed, err := ks.Sign(newClaims())
+	require.NoError(t, err)
+	_, err = jwt.Parse(signed, verifier.Keyfunc)
+	assert.NoError(t, err)
+}
+
+func TestJWK_ECDSA(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384()} {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+			require.NoError(t, err)
+			key, err := NewPrivateKey("ec-1", priv)
+			require.NoError(t, err)
+			ks, err := New(key)
+			require.NoError(t, err)
+
+			set := ks.JWKS()
+			require.Len(t, set.Keys, 1)
+			assert.Equal(t, "EC", set.Keys[0].Kty)
+			assert.Equal(t, curve.Params().Name, set.Keys[0].Crv)
+
+			pub, err := set.Keys[0].Key()
+			require.NoError(t, err)
+			assert.Equal(t, key.Algorithm(), pub.Algorithm())
+
+			verifier, err := NewVerifier(pub)
+			require.NoError(t, err)
+			signed, err := ks.Sign(newClaims())
+			require.NoError(t, err)
+			_, err = jwt.Parse(signed, verifier.Keyfunc)
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err := JWK{Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"}.Key()
+	assert.Error(t, err, "point not on the curve")
+}
@@ -0,0 +1,741 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Avro encodes Go structs in the Avro binary format. The schema is derived
+// from the struct: fields are named by their "avro" tag, else their "json"
+// tag; pointers become unions with null and time.Time a timestamp-millis long.
+//
+// Decoding follows the schema the message was written with, so fields the
+// writer added are skipped and fields it dropped keep their zero value.
+type Avro struct{}
+
+var _ Codec = Avro{}
+
+var _avroSchemas sync.Map // reflect.Type or schema text -> *avroType
+
+// Schema -.
+func (Avro) Schema(v any) (Schema, error) {
+	t, err := avroTypeOf(indirectType(reflect.TypeOf(v)))
+	if err != nil {
+		return Schema{}, err
+	}
+
+	b, err := json.Marshal(t.json(map[string]bool{}))
+	if err != nil {
+		return Schema{}, fmt.Errorf("kafka - Avro - Schema: %w", err)
+	}
+	return Schema{Type: SchemaTypeAvro, Schema: string(b)}, nil
+}
+
+// Marshal -.
+func (Avro) Marshal(v any) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return nil, errors.New("kafka - Avro - Marshal: nil value")
+	}
+	t, err := avroTypeOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	var e avroEncoder
+	if err := e.encode(t, rv); err != nil {
+		return nil, fmt.Errorf("kafka - Avro - Marshal: %w", err)
+	}
+	return e.buf, nil
+}
+
+// Unmarshal -.
+func (Avro) Unmarshal(data []byte, writer *Schema, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("kafka - Avro - Unmarshal: target must be a non-nil pointer")
+	}
+
+	var (
+		t   *avroType
+		err error
+	)
+	if writer == nil {
+		t, err = avroTypeOf(rv.Type().Elem())
+	} else {
+		t, err = parseAvroSchema(writer.Schema)
+	}
+	if err != nil {
+		return err
+	}
+
+	d := avroDecoder{buf: data}
+	if err := d.decode(t, rv.Elem()); err != nil {
+		return fmt.Errorf("kafka - Avro - Unmarshal: %w", err)
+	}
+	return nil
+}
+
+// avroType is a parsed or derived Avro schema.
+type avroType struct {
+	kind    string // a primitive type, "record", "enum", "fixed", "array", "map" or "union"
+	logical string
+	name    string
+
+	fields   []avroField // record
+	symbols  []string    // enum
+	size     int         // fixed
+	items    *avroType   // array, map values
+	branches []*avroType // union
+}
+
+type avroField struct {
+	name string
+	typ  *avroType
+	// index is the Go field of a derived record
+	index []int
+	// nullDefault marks unions with null, which default to null
+	nullDefault bool
+}
+
+var _avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+func avroTypeOf(t reflect.Type) (*avroType, error) {
+	if t == nil {
+		return nil, errors.New("kafka - Avro: nil value")
+	}
+	if cached, ok := _avroSchemas.Load(t); ok {
+		return cached.(*avroType), nil
+	}
+
+	at, err := deriveAvroType(t, map[reflect.Type]*avroType{})
+	if err != nil {
+		return nil, fmt.Errorf("kafka - Avro - %s: %w", t, err)
+	}
+	_avroSchemas.Store(t, at)
+	return at, nil
+}
+
+func deriveAvroType(t reflect.Type, records map[reflect.Type]*avroType) (*avroType, error) {
+	if t == _timeType {
+		return &avroType{kind: "long", logical: "timestamp-millis"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &avroType{kind: "boolean"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &avroType{kind: "int"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return &avroType{kind: "long"}, nil
+	case reflect.Float32:
+		return &avroType{kind: "float"}, nil
+	case reflect.Float64:
+		return &avroType{kind: "double"}, nil
+	case reflect.String:
+		return &avroType{kind: "string"}, nil
+	case reflect.Pointer:
+		elem, err := deriveAvroType(t.Elem(), records)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: "union", branches: []*avroType{{kind: "null"}, elem}}, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &avroType{kind: "bytes"}, nil
+		}
+		items, err := deriveAvroType(t.Elem(), records)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: "array", items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key %s is not a string", t.Key())
+		}
+		values, err := deriveAvroType(t.Elem(), records)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: "map", items: values}, nil
+	case reflect.Struct:
+		if record, ok := records[t]; ok {
+			return record, nil
+		}
+		if t.Name() == "" {
+			return nil, errors.New("anonymous structs have no record name")
+		}
+
+		record := &avroType{kind: "record", name: t.Name()}
+		records[t] = record
+		for _, f := range structFields(t, "avro", "json") {
+			ft, err := deriveAvroType(f.field.Type, records)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.field.Name, err)
+			}
+			record.fields = append(record.fields, avroField{
+				name:        f.name,
+				typ:         ft,
+				index:       f.index,
+				nullDefault: ft.kind == "union",
+			})
+		}
+		return record, nil
+	default:
+		return nil, fmt.Errorf("%s has no avro type", t)
+	}
+}
+
+// json renders t as schema JSON. Records after their first use are referenced by name.
+func (t *avroType) json(defined map[string]bool) any {
+	switch t.kind {
+	case "record":
+		if defined[t.name] {
+			return t.name
+		}
+		defined[t.name] = true
+
+		fields := make([]map[string]any, 0, len(t.fields))
+		for _, f := range t.fields {
+			field := map[string]any{"name": f.name, "type": f.typ.json(defined)}
+			if f.nullDefault {
+				field["default"] = nil
+			}
+			fields = append(fields, field)
+		}
+		return map[string]any{"type": "record", "name": t.name, "fields": fields}
+	case "enum":
+		if defined[t.name] {
+			return t.name
+		}
+		defined[t.name] = true
+		return map[string]any{"type": "enum", "name": t.name, "symbols": t.symbols}
+	case "fixed":
+		if defined[t.name] {
+			return t.name
+		}
+		defined[t.name] = true
+		return map[string]any{"type": "fixed", "name": t.name, "size": t.size}
+	case "array":
+		return map[string]any{"type": "array", "items": t.items.json(defined)}
+	case "map":
+		return map[string]any{"type": "map", "values": t.items.json(defined)}
+	case "union":
+		branches := make([]any, 0, len(t.branches))
+		for _, b := range t.branches {
+			branches = append(branches, b.json(defined))
+		}
+		return branches
+	default:
+		if t.logical != "" {
+			return map[string]any{"type": t.kind, "logicalType": t.logical}
+		}
+		return t.kind
+	}
+}
+
+func parseAvroSchema(text string) (*avroType, error) {
+	if cached, ok := _avroSchemas.Load(text); ok {
+		return cached.(*avroType), nil
+	}
+
+	var raw any
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil, fmt.Errorf("kafka - Avro - parse schema: %w", err)
+	}
+	t, err := parseAvroType(raw, map[string]*avroType{}, "")
+	if err != nil {
+		return nil, fmt.Errorf("kafka - Avro - parse schema: %w", err)
+	}
+
+	_avroSchemas.Store(text, t)
+	return t, nil
+}
+
+func parseAvroType(raw any, named map[string]*avroType, namespace string) (*avroType, error) {
+	switch v := raw.(type) {
+	case string:
+		if _avroPrimitives[v] {
+			return &avroType{kind: v}, nil
+		}
+		if t, ok := named[v]; ok {
+			return t, nil
+		}
+		if t, ok := named[namespace+"."+v]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", v)
+	case []any:
+		union := &avroType{kind: "union"}
+		for _, b := range v {
+			bt, err := parseAvroType(b, named, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, bt)
+		}
+		return union, nil
+	case map[string]any:
+		return parseAvroComplex(v, named, namespace)
+	default:
+		return nil, fmt.Errorf("invalid schema %v", raw)
+	}
+}
+
+func parseAvroComplex(v map[string]any, named map[string]*avroType, namespace string) (*avroType, error) {
+	kind, _ := v["type"].(string)
+	name, _ := v["name"].(string)
+	if ns, ok := v["namespace"].(string); ok {
+		namespace = ns
+	}
+	// Names are registered before the fields so records can refer to themselves
+	register := func(t *avroType) {
+		named[name] = t
+		if namespace != "" {
+			named[namespace+"."+name] = t
+		}
+	}
+
+	switch kind {
+	case "record", "error":
+		record := &avroType{kind: "record", name: name}
+		register(record)
+		fields, _ := v["fields"].([]any)
+		for _, raw := range fields {
+			f, ok := raw.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("record %s: invalid field", name)
+			}
+			ft, err := parseAvroType(f["type"], named, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("record %s: %w", name, err)
+			}
+			fieldName, _ := f["name"].(string)
+			record.fields = append(record.fields, avroField{name: fieldName, typ: ft})
+		}
+		return record, nil
+	case "enum":
+		enum := &avroType{kind: "enum", name: name}
+		register(enum)
+		symbols, _ := v["symbols"].([]any)
+		for _, s := range symbols {
+			symbol, _ := s.(string)
+			enum.symbols = append(enum.symbols, symbol)
+		}
+		return enum, nil
+	case "fixed":
+		size, _ := v["size"].(float64)
+		fixed := &avroType{kind: "fixed", name: name, size: int(size)}
+		register(fixed)
+		return fixed, nil
+	case "array":
+		items, err := parseAvroType(v["items"], named, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: "array", items: items}, nil
+	case "map":
+		values, err := parseAvroType(v["values"], named, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: "map", items: values}, nil
+	default:
+		t, err := parseAvroType(v["type"], named, namespace)
+		if err != nil {
+			return nil, err
+		}
+		logical, _ := v["logicalType"].(string)
+		return &avroType{kind: t.kind, logical: logical, name: t.name, fields: t.fields,
+			symbols: t.symbols, size: t.size, items: t.items, branches: t.branches}, nil
+	}
+}
+
+type avroEncoder struct {
+	buf []byte
+}
+
+func (e *avroEncoder) long(n int64) {
+	e.buf = binary.AppendVarint(e.buf, n)
+}
+
+func (e *avroEncoder) bytes(b []byte) {
+	e.long(int64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *avroEncoder) encode(t *avroType, v reflect.Value) error {
+	switch t.kind {
+	case "null":
+		return nil
+	case "boolean":
+		if v.Bool() {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+	case "int", "long":
+		switch {
+		case t.logical == "timestamp-millis" && v.Type() == _timeType:
+			e.long(v.Interface().(time.Time).UnixMilli())
+		case v.CanInt():
+			e.long(v.Int())
+		default:
+			e.long(int64(v.Uint()))
+		}
+	case "float":
+		e.buf = binary.LittleEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case "double":
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case "bytes":
+		e.bytes(v.Bytes())
+	case "string":
+		e.bytes([]byte(v.String()))
+	case "record":
+		for _, f := range t.fields {
+			if err := e.encode(f.typ, v.FieldByIndex(f.index)); err != nil {
+				return fmt.Errorf("%s.%s: %w", t.name, f.name, err)
+			}
+		}
+	case "array":
+		if v.Len() > 0 {
+			e.long(int64(v.Len()))
+			for i := range v.Len() {
+				if err := e.encode(t.items, v.Index(i)); err != nil {
+					return err
+				}
+			}
+		}
+		e.long(0)
+	case "map":
+		if v.Len() > 0 {
+			e.long(int64(v.Len()))
+			iter := v.MapRange()
+			for iter.Next() {
+				e.bytes([]byte(iter.Key().String()))
+				if err := e.encode(t.items, iter.Value()); err != nil {
+					return err
+				}
+			}
+		}
+		e.long(0)
+	case "union":
+		// Derived unions are [null, T] for pointers
+		if v.IsNil() {
+			e.long(0)
+			return nil
+		}
+		e.long(1)
+		return e.encode(t.branches[1], v.Elem())
+	default:
+		return fmt.Errorf("cannot encode avro %s", t.kind)
+	}
+	return nil
+}
+
+// _avroMaxZeroWidthItems caps the items of an array whose items take no bytes,
+// such as nulls or empty records; their count is the only bound on the work.
+const _avroMaxZeroWidthItems = 1 << 10
+
+type avroDecoder struct {
+	buf []byte
+}
+
+func (d *avroDecoder) long() (int64, error) {
+	n, size := binary.Varint(d.buf)
+	if size <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	d.buf = d.buf[size:]
+	return n, nil
+}
+
+func (d *avroDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+func (d *avroDecoder) bytes() ([]byte, error) {
+	n, err := d.long()
+	if err != nil {
+		return nil, err
+	}
+	return d.next(int(n))
+}
+
+// decode reads a value written as t into v. An invalid v skips the value.
+func (d *avroDecoder) decode(t *avroType, v reflect.Value) error {
+	switch t.kind {
+	case "null":
+		if v.IsValid() {
+			v.SetZero()
+		}
+		return nil
+	case "boolean":
+		b, err := d.next(1)
+		if err != nil {
+			return err
+		}
+		return setAvro(v, b[0] != 0)
+	case "int", "long":
+		n, err := d.long()
+		if err != nil {
+			return err
+		}
+		if t.logical == "timestamp-millis" && v.IsValid() && v.Type() == _timeType {
+			v.Set(reflect.ValueOf(time.UnixMilli(n).UTC()))
+			return nil
+		}
+		return setAvro(v, n)
+	case "float":
+		b, err := d.next(4)
+		if err != nil {
+			return err
+		}
+		return setAvro(v, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+	case "double":
+		b, err := d.next(8)
+		if err != nil {
+			return err
+		}
+		return setAvro(v, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	case "bytes", "string":
+		b, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		return setAvro(v, b)
+	case "enum":
+		n, err := d.long()
+		if err != nil {
+			return err
+		}
+		if n < 0 || int(n) >= len(t.symbols) {
+			return fmt.Errorf("enum %s has no symbol %d", t.name, n)
+		}
+		return setAvro(v, []byte(t.symbols[n]))
+	case "fixed":
+		b, err := d.next(t.size)
+		if err != nil {
+			return err
+		}
+		return setAvro(v, b)
+	case "record":
+		return d.record(t, v)
+	case "array", "map":
+		return d.blocks(t, v)
+	case "union":
+		n, err := d.long()
+		if err != nil {
+			return err
+		}
+		if n < 0 || int(n) >= len(t.branches) {
+			return fmt.Errorf("union has no branch %d", n)
+		}
+		branch := t.branches[n]
+		if v.IsValid() && v.Kind() == reflect.Pointer {
+			if branch.kind == "null" {
+				v.SetZero()
+				return nil
+			}
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		return d.decode(branch, v)
+	default:
+		return fmt.Errorf("cannot decode avro %s", t.kind)
+	}
+}
+
+func (d *avroDecoder) record(t *avroType, v reflect.Value) error {
+	var fields map[string][]int
+	if v.IsValid() {
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("cannot decode record %s into %s", t.name, v.Type())
+		}
+		fields = map[string][]int{}
+		for _, f := range structFields(v.Type(), "avro", "json") {
+			fields[f.name] = f.index
+		}
+	}
+
+	for _, f := range t.fields {
+		// Fields the reader does not know are read and dropped
+		var fv reflect.Value
+		if index, ok := fields[f.name]; ok {
+			fv = v.FieldByIndex(index)
+		}
+		if err := d.decode(f.typ, fv); err != nil {
+			return fmt.Errorf("%s.%s: %w", t.name, f.name, err)
+		}
+	}
+	return nil
+}
+
+func (d *avroDecoder) blocks(t *avroType, v reflect.Value) error {
+	if v.IsValid() {
+		switch {
+		case t.kind == "array" && v.Kind() == reflect.Slice:
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		case t.kind == "map" && v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			v.Set(reflect.MakeMap(v.Type()))
+		default:
+			return fmt.Errorf("cannot decode %s into %s", t.kind, v.Type())
+		}
+	}
+
+	// Every item takes at least width bytes, so a count the rest of the
+	// message cannot hold is rejected before looping over it
+	width := 1
+	if t.kind == "array" {
+		width = t.items.minSize(map[*avroType]bool{})
+	}
+	var zeroWidthItems int64
+
+	for {
+		n, err := d.long()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if n < 0 {
+			// A negative count is followed by the block's size in bytes
+			n = -n
+			if _, err := d.long(); err != nil {
+				return err
+			}
+		}
+		if width == 0 {
+			zeroWidthItems += n
+			if n < 0 || zeroWidthItems > _avroMaxZeroWidthItems {
+				return fmt.Errorf("%s of more than %d empty items", t.kind, _avroMaxZeroWidthItems)
+			}
+		} else if n < 0 || n > int64(len(d.buf)/width) {
+			return fmt.Errorf("%s block of %d items: %w", t.kind, n, io.ErrUnexpectedEOF)
+		}
+
+		for range n {
+			var key []byte
+			if t.kind == "map" {
+				if key, err = d.bytes(); err != nil {
+					return err
+				}
+			}
+
+			if !v.IsValid() {
+				if err := d.decode(t.items, reflect.Value{}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.decode(t.items, elem); err != nil {
+				return err
+			}
+			if t.kind == "map" {
+				v.SetMapIndex(reflect.ValueOf(string(key)).Convert(v.Type().Key()), elem)
+			} else {
+				v.Set(reflect.Append(v, elem))
+			}
+		}
+	}
+}
+
+// minSize returns the fewest bytes a value of t is written in. Records being
+// sized further up count as zero. That can only underestimate, so the bound on
+// block counts gets looser but never rejects a valid message.
+func (t *avroType) minSize(sizing map[*avroType]bool) int {
+	switch t.kind {
+	case "null":
+		return 0
+	case "float":
+		return 4
+	case "double":
+		return 8
+	case "fixed":
+		return t.size
+	case "record":
+		if sizing[t] {
+			return 0
+		}
+		sizing[t] = true
+		defer delete(sizing, t)
+
+		size := 0
+		for _, f := range t.fields {
+			size += f.typ.minSize(sizing)
+		}
+		return size
+	default:
+		// Booleans, varints, length-prefixed values, block counts and union branches
+		return 1
+	}
+}
+
+// setAvro stores a decoded primitive in v, converting between the Go kinds
+// an Avro type may be read into.
+func setAvro(v reflect.Value, x any) error {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch x := x.(type) {
+	case bool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(x)
+			return nil
+		}
+	case int64:
+		switch {
+		case v.CanInt():
+			if v.OverflowInt(x) {
+				return fmt.Errorf("%d overflows %s", x, v.Type())
+			}
+			v.SetInt(x)
+			return nil
+		case v.CanUint():
+			if x < 0 || v.OverflowUint(uint64(x)) {
+				return fmt.Errorf("%d overflows %s", x, v.Type())
+			}
+			v.SetUint(uint64(x))
+			return nil
+		case v.CanFloat():
+			v.SetFloat(float64(x))
+			return nil
+		}
+	case float64:
+		if v.CanFloat() {
+			v.SetFloat(x)
+			return nil
+		}
+	case []byte:
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(string(x))
+			return nil
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes(append([]byte(nil), x...))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot decode %T into %s", x, v.Type())
+}
@@ -0,0 +1,168 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema types known to the schema registry.
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+	SchemaTypeJSON     = "JSON"
+)
+
+// Schema is a schema as the registry stores it.
+type Schema struct {
+	Type       string            `json:"schemaType,omitempty"`
+	Schema     string            `json:"schema"`
+	References []SchemaReference `json:"references,omitempty"`
+}
+
+// SchemaReference points a schema at another one it imports, such as a
+// .proto file. Schema is the imported schema, registered before the referrer.
+type SchemaReference struct {
+	Name    string  `json:"name"`
+	Subject string  `json:"subject"`
+	Version int     `json:"version"`
+	Schema  *Schema `json:"-"`
+}
+
+// Codec turns values into message bytes and back.
+type Codec interface {
+	// Schema describes the values of v's type for the registry.
+	Schema(v any) (Schema, error)
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into v. writer is the schema data was written
+	// with, nil when the message did not name one.
+	Unmarshal(data []byte, writer *Schema, v any) error
+}
+
+// JSON encodes values with encoding/json. Its schemas are JSON Schemas
+// derived from the Go types, so the registry sees when a field is renamed.
+type JSON struct{}
+
+var _ Codec = JSON{}
+
+// Schema -.
+func (JSON) Schema(v any) (Schema, error) {
+	b, err := json.Marshal(jsonSchema(indirectType(reflect.TypeOf(v)), map[reflect.Type]bool{}))
+	if err != nil {
+		return Schema{}, fmt.Errorf("kafka - JSON - Schema: %w", err)
+	}
+	return Schema{Type: SchemaTypeJSON, Schema: string(b)}, nil
+}
+
+// Marshal -.
+func (JSON) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal -.
+func (JSON) Unmarshal(data []byte, _ *Schema, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+var (
+	_timeType    = reflect.TypeOf(time.Time{})
+	_rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// jsonSchema describes t the way encoding/json writes it. Types that are
+// already being described, i.e. recursive ones, are left open.
+func jsonSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	if t == nil || t == _rawJSONType {
+		return map[string]any{}
+	}
+
+	switch {
+	case t == _timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()):
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Pointer:
+		return map[string]any{"oneOf": []any{map[string]any{"type": "null"}, jsonSchema(t.Elem(), seen)}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]any{}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]any{}
+		for _, f := range structFields(t, "json") {
+			properties[f.name] = jsonSchema(f.field.Type, seen)
+		}
+		return map[string]any{"type": "object", "title": t.Name(), "properties": properties}
+	default:
+		// interface{} and anything else encoding/json takes at runtime
+		return map[string]any{}
+	}
+}
+
+// indirectType is t, or what t points to; the top level value of a message is never null.
+func indirectType(t reflect.Type) reflect.Type {
+	if t != nil && t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+type namedField struct {
+	name  string
+	field reflect.StructField
+	index []int
+}
+
+// structFields lists the exported fields of t under the name given by the
+// first of tags they have, or their Go name, skipping fields tagged "-".
+// Embedded structs are not flattened.
+func structFields(t reflect.Type, tags ...string) []namedField {
+	var fields []namedField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		for _, tag := range tags {
+			value, ok := f.Tag.Lookup(tag)
+			if !ok {
+				continue
+			}
+			value, _, _ = strings.Cut(value, ",")
+			if value != "" {
+				name = value
+			}
+			break
+		}
+		if name == "-" {
+			continue
+		}
+		fields = append(fields, namedField{name: name, field: f, index: f.Index})
+	}
+	return fields
+}
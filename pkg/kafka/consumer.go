@@ -2,71 +2,228 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
 	"github.com/rs/zerolog"
 	"github.com/segmentio/kafka-go"
 )
 
-// MessageHandler -.
-type MessageHandler func(ctx context.Context, key, value []byte) error
+// Consumer defaults.
+const (
+	DefaultCommitInterval = time.Second
+	DefaultMaxRetries     = 3
+	DefaultRetryBackoff   = 500 * time.Millisecond
 
-// Consumer -.
+	_maxRetryBackoff = 30 * time.Second
+)
+
+// Headers of messages routed to a dead letter topic.
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+)
+
+// MessageHandler handles a consumed message. ctx carries the trace context
+// and request ID of the message's headers, see tracectx.
+type MessageHandler func(ctx context.Context, msg Message) error
+
+// MessageWriter writes messages to the topics they name, e.g. a *Producer.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// messageReader is the part of *kafka.Reader the consumer uses.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Config() kafka.ReaderConfig
+	Close() error
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error that retrying cannot fix, such as a message
+// that does not decode. The message goes to the dead letter topic right away,
+// or is skipped when there is none.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Consumer reads a topic as part of a consumer group. Offsets are committed
+// once the handler succeeded or the message was routed to the dead letter
+// topic, so a message is handled at least once. Commits are batched every
+// commit interval; closing the consumer, or a rebalance, flushes them.
 type Consumer struct {
-	reader  *kafka.Reader
+	reader  messageReader
 	logger  zerolog.Logger
 	handler MessageHandler
+
+	commitInterval time.Duration
+	maxRetries     int
+	retryBackoff   time.Duration
+	deadLetter     MessageWriter
+	deadLetterTo   string
+	workers        int
+	workerBuffer   int
+	minBytes       int
+	maxBytes       int
+	maxWait        time.Duration
+	security       Security
+	sleep          func(ctx context.Context, d time.Duration) error
+	state          *consumerState
+}
+
+// ConsumerOption configures a consumer.
+type ConsumerOption func(*Consumer)
+
+// CommitInterval sets how often handled offsets are committed. Zero keeps the default.
+func CommitInterval(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		if d > 0 {
+			c.commitInterval = d
+		}
+	}
+}
+
+// Retry sets how often a failing message is retried before it goes to the
+// dead letter topic, and the first wait; the wait doubles up to 30s. Without
+// a dead letter topic the message is retried until it succeeds, holding up
+// its partition. Negative values keep the defaults.
+func Retry(maxRetries int, backoff time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		if maxRetries >= 0 {
+			c.maxRetries = maxRetries
+		}
+		if backoff > 0 {
+			c.retryBackoff = backoff
+		}
+	}
+}
+
+// DeadLetter routes messages that still fail after the retries to topic,
+// with the original position and the error in headers.
+func DeadLetter(w MessageWriter, topic string) ConsumerOption {
+	return func(c *Consumer) {
+		c.deadLetter = w
+		c.deadLetterTo = topic
+	}
+}
+
+// FetchBytes sets how many bytes a fetch waits for and returns at most;
+// 10KB and 10MB by default. Zero keeps the default.
+func FetchBytes(minBytes, maxBytes int) ConsumerOption {
+	return func(c *Consumer) {
+		if minBytes > 0 {
+			c.minBytes = minBytes
+		}
+		if maxBytes > 0 {
+			c.maxBytes = maxBytes
+		}
+	}
+}
+
+// MaxWait sets how long a fetch waits for its minimum bytes; 10s by default.
+func MaxWait(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		if d > 0 {
+			c.maxWait = d
+		}
+	}
+}
+
+// ConsumerSecurity connects the consumer with TLS and SASL.
+func ConsumerSecurity(s Security) ConsumerOption {
+	return func(c *Consumer) {
+		c.security = s
+	}
 }
 
 // NewConsumer -.
-func NewConsumer(brokers []string, topic, groupID string, handler MessageHandler, logger zerolog.Logger) *Consumer {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  brokers,
-		Topic:    topic,
-		GroupID:  groupID,
-		MinBytes: 10e3, // 10KB
-		MaxBytes: 10e6, // 10MB
-		Logger:   kafka.LoggerFunc(logger.Printf),
-	})
+func NewConsumer(brokers []string, topic, groupID string, handler MessageHandler, logger zerolog.Logger, opts ...ConsumerOption) *Consumer {
+	c := newConsumer(handler, logger, opts...)
+
+	config := kafka.ReaderConfig{
+		Brokers:        brokers,
+		Topic:          topic,
+		GroupID:        groupID,
+		MinBytes:       c.minBytes,
+		MaxBytes:       c.maxBytes,
+		MaxWait:        c.maxWait,
+		CommitInterval: c.commitInterval,
+		Logger:         kafka.LoggerFunc(logger.Printf),
+	}
+	if !c.security.isZero() {
+		config.Dialer = c.security.dialer()
+	}
+	c.reader = kafka.NewReader(config)
+
+	return c
+}
+
+func newConsumer(handler MessageHandler, logger zerolog.Logger, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		logger:         logger,
+		handler:        handler,
+		commitInterval: DefaultCommitInterval,
+		maxRetries:     DefaultMaxRetries,
+		retryBackoff:   DefaultRetryBackoff,
+		minBytes:       10e3, // 10KB
+		maxBytes:       10e6, // 10MB
+		sleep:          sleepContext,
+		state:          &consumerState{},
+	}
 
-	return &Consumer{
-		reader:  reader,
-		logger:  logger,
-		handler: handler,
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
-// Start -.
+// Start consumes until ctx is cancelled, then closes the reader, which
+// commits the offsets handled so far. A message whose handling was cut
+// short is not committed and is read again by the next consumer.
 func (c *Consumer) Start(ctx context.Context) error {
 	c.logger.Info().
 		Str("topic", c.reader.Config().Topic).
 		Str("group_id", c.reader.Config().GroupID).
 		Msg("starting kafka consumer")
+	c.state.started()
 
-	for {
-		select {
-		case <-ctx.Done():
-			c.logger.Info().Msg("stopping kafka consumer")
-			return c.reader.Close()
-		default:
-			m, err := c.reader.ReadMessage(ctx)
-			if err != nil {
-				c.logger.Error().Err(err).Msg("failed to read message")
-				continue
-			}
-
-			c.logger.Debug().
-				Str("topic", m.Topic).
-				Int("partition", m.Partition).
-				Int64("offset", m.Offset).
-				Str("key", string(m.Key)).
-				Msg("received message")
+	err := c.consume(ctx, c.handler)
 
-			if err := c.handler(ctx, m.Key, m.Value); err != nil {
-				c.logger.Error().Err(err).Msg("failed to handle message")
-			}
-		}
+	c.logger.Info().Msg("stopping kafka consumer")
+	if closeErr := c.reader.Close(); closeErr != nil {
+		c.state.stopped(closeErr)
+		return closeErr
+	}
+	if errors.Is(err, context.Canceled) {
+		err = nil
 	}
+	c.state.stopped(err)
+
+	return err
 }
 
 // Close -.
@@ -74,21 +231,144 @@ func (c *Consumer) Close() error {
 	return c.reader.Close()
 }
 
-// ConsumeMessages -.
+// ConsumeMessages consumes with handler instead of the consumer's own until
+// ctx is cancelled, committing the same way as Start.
 func (c *Consumer) ConsumeMessages(ctx context.Context, handler func(key, value []byte) error) error {
+	err := c.consume(ctx, func(_ context.Context, msg Message) error {
+		return handler(msg.Key, msg.Value)
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+func (c *Consumer) consume(ctx context.Context, handler MessageHandler) error {
+	if c.workers > 1 {
+		return c.consumePool(ctx, handler)
+	}
+
 	for {
-		select {
-		case <-ctx.Done():
+		m, err := c.fetch(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := c.handle(ctx, handler, m); err != nil {
+			return err
+		}
+
+		// Queued for the next interval commit; a cancelled ctx must not drop it
+		if err := c.reader.CommitMessages(context.WithoutCancel(ctx), m); err != nil {
+			return fmt.Errorf("failed to commit message: %w", err)
+		}
+	}
+}
+
+func (c *Consumer) fetch(ctx context.Context) (kafka.Message, error) {
+	m, err := c.reader.FetchMessage(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return kafka.Message{}, ctx.Err()
+		}
+		return kafka.Message{}, fmt.Errorf("failed to fetch message: %w", err)
+	}
+	// A paused consumer holds the message until it is resumed
+	if err := c.state.waitResumed(ctx); err != nil {
+		return kafka.Message{}, err
+	}
+	c.state.received()
+
+	c.logger.Debug().
+		Str("topic", m.Topic).
+		Int("partition", m.Partition).
+		Int64("offset", m.Offset).
+		Str("key", string(m.Key)).
+		Msg("received message")
+
+	return m, nil
+}
+
+// handle runs handler until it succeeds or the message is dealt with
+// otherwise. An error means the message must not be committed.
+func (c *Consumer) handle(ctx context.Context, handler MessageHandler, m kafka.Message) error {
+	backoff := c.retryBackoff
+	msg := newMessage(m)
+	msgCtx := tracectx.Extract(ctx, msg.Headers.Get)
+	span, _ := tracectx.Span(msgCtx)
+
+	for attempt := 0; ; attempt++ {
+		err := handler(msgCtx, msg)
+		if ctx.Err() != nil && err != nil {
+			return ctx.Err()
+		}
+		c.state.handled(err)
+		if err == nil {
 			return nil
-		default:
-			m, err := c.reader.ReadMessage(ctx)
-			if err != nil {
-				return fmt.Errorf("failed to read message: %w", err)
-			}
+		}
+
+		log := func() *zerolog.Event {
+			return c.logger.Error().Err(err).
+				Str("topic", m.Topic).
+				Int("partition", m.Partition).
+				Int64("offset", m.Offset).
+				Int("attempt", attempt+1).
+				Str("trace_id", span.TraceIDString()).
+				Str("request_id", tracectx.RequestID(msgCtx))
+		}
 
-			if err := handler(m.Key, m.Value); err != nil {
-				c.logger.Error().Err(err).Msg("failed to process message")
+		permanent := IsPermanent(err)
+		if permanent || attempt >= c.maxRetries {
+			if c.deadLetter != nil {
+				dlqErr := c.sendToDeadLetter(ctx, m, err)
+				if dlqErr == nil {
+					log().Str("dead_letter_topic", c.deadLetterTo).Msg("message routed to dead letter topic")
+					c.state.handled(nil)
+					return nil
+				}
+				log().AnErr("dead_letter_error", dlqErr).Msg("failed to route message to dead letter topic")
+			} else if permanent {
+				log().Msg("skipping message that cannot be handled")
+				c.state.handled(nil)
+				return nil
 			}
 		}
+		log().Dur("retry_in", backoff).Msg("failed to handle message")
+
+		if err := c.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = min(2*backoff, _maxRetryBackoff)
+	}
+}
+
+func (c *Consumer) sendToDeadLetter(ctx context.Context, m kafka.Message, cause error) error {
+	headers := append([]kafka.Header{}, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderError, Value: []byte(cause.Error())},
+	)
+
+	return c.deadLetter.WriteMessages(ctx, kafka.Message{
+		Topic:   c.deadLetterTo,
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+		Time:    time.Now(),
+	})
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
 }
@@ -0,0 +1,329 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReader hands out its messages, then waits for more until ctx is cancelled.
+type fakeReader struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	committed []int64
+	closed    bool
+}
+
+func (f *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		f.mu.Lock()
+		if len(f.messages) > 0 {
+			m := f.messages[0]
+			f.messages = f.messages[1:]
+			f.mu.Unlock()
+			return m, nil
+		}
+		f.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (f *fakeReader) push(m kafka.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, m)
+}
+
+func (f *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range msgs {
+		f.committed = append(f.committed, m.Offset)
+	}
+	return nil
+}
+
+func (f *fakeReader) Config() kafka.ReaderConfig {
+	return kafka.ReaderConfig{Topic: "payment-events", GroupID: "test"}
+}
+
+func (f *fakeReader) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeReader) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *fakeReader) commits() []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int64(nil), f.committed...)
+}
+
+type fakeWriter struct {
+	messages []kafka.Message
+	err      error
+}
+
+func (f *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, msgs...)
+	return nil
+}
+
+func newTestConsumer(handler MessageHandler, offsets int, opts ...ConsumerOption) (*Consumer, *fakeReader) {
+	reader := &fakeReader{}
+	for i := range offsets {
+		reader.messages = append(reader.messages, kafka.Message{
+			Topic:     "payment-events",
+			Partition: 2,
+			Offset:    int64(i),
+			Key:       []byte("key"),
+			Value:     []byte{byte('a' + i)},
+		})
+	}
+
+	c := newConsumer(handler, zerolog.Nop(), opts...)
+	c.reader = reader
+	c.sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+
+	return c, reader
+}
+
+// run starts c and stops it once want offsets are committed.
+func run(t *testing.T, c *Consumer, reader *fakeReader, want int) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	require.Eventually(t, func() bool { return len(reader.commits()) >= want }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	assert.True(t, reader.closed)
+}
+
+func TestConsumer_CommitsAfterHandling(t *testing.T) {
+	failures := map[string]int{"b": 2}
+	var handled []string
+	c, reader := newTestConsumer(func(_ context.Context, msg Message) error {
+		if failures[string(msg.Value)] > 0 {
+			failures[string(msg.Value)]--
+			return errors.New("database unavailable")
+		}
+		handled = append(handled, string(msg.Value))
+		return nil
+	}, 3)
+
+	run(t, c, reader, 3)
+
+	assert.Equal(t, []string{"a", "b", "c"}, handled, "b is retried until it succeeds")
+	assert.Equal(t, []int64{0, 1, 2}, reader.commits())
+}
+
+func TestConsumer_DeadLetter(t *testing.T) {
+	dlq := &fakeWriter{}
+	c, reader := newTestConsumer(func(_ context.Context, msg Message) error {
+		if string(msg.Value) == "a" {
+			return errors.New("payment rejected")
+		}
+		return nil
+	}, 2, Retry(2, time.Millisecond), DeadLetter(dlq, "payment-events.dlq"))
+
+	run(t, c, reader, 2)
+
+	require.Len(t, dlq.messages, 1)
+	m := dlq.messages[0]
+	assert.Equal(t, "payment-events.dlq", m.Topic)
+	assert.Equal(t, []byte("a"), m.Value)
+	assert.Contains(t, m.Headers, kafka.Header{Key: HeaderOriginalTopic, Value: []byte("payment-events")})
+	assert.Contains(t, m.Headers, kafka.Header{Key: HeaderOriginalPartition, Value: []byte("2")})
+	assert.Contains(t, m.Headers, kafka.Header{Key: HeaderOriginalOffset, Value: []byte("0")})
+	assert.Contains(t, m.Headers, kafka.Header{Key: HeaderError, Value: []byte("payment rejected")})
+	assert.Equal(t, []int64{0, 1}, reader.commits())
+}
+
+func TestConsumer_PermanentErrors(t *testing.T) {
+	attempts := 0
+	c, reader := newTestConsumer(func(context.Context, Message) error {
+		attempts++
+		return Permanent(errors.New("invalid json"))
+	}, 1)
+
+	run(t, c, reader, 1)
+
+	assert.Equal(t, 1, attempts, "permanent errors are not retried")
+	assert.Equal(t, []int64{0}, reader.commits(), "and skipped without a dead letter topic")
+}
+
+func TestConsumer_ShutdownDoesNotCommitFailedMessages(t *testing.T) {
+	dlq := &fakeWriter{err: errors.New("broker unavailable")}
+	started := make(chan struct{}, 1)
+	c, reader := newTestConsumer(func(context.Context, Message) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		return errors.New("payment rejected")
+	}, 1, Retry(0, time.Millisecond), DeadLetter(dlq, "payment-events.dlq"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	<-started
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Empty(t, reader.commits())
+	assert.True(t, reader.closed)
+}
+
+// keysOnDifferentWorkers returns two keys that go to different workers.
+func keysOnDifferentWorkers(t *testing.T, workers int) (string, string) {
+	t.Helper()
+
+	first := workerFor(kafka.Message{Key: []byte("key-0")}, workers)
+	for i := 1; i < 100; i++ {
+		key := "key-" + strconv.Itoa(i)
+		if workerFor(kafka.Message{Key: []byte(key)}, workers) != first {
+			return "key-0", key
+		}
+	}
+	t.Fatal("no keys on different workers")
+	return "", ""
+}
+
+func TestConsumer_Workers(t *testing.T) {
+	slow, fast := keysOnDifferentWorkers(t, 4)
+
+	release := make(chan struct{})
+	var (
+		mu      sync.Mutex
+		handled = map[string][]byte{}
+	)
+	c, reader := newTestConsumer(func(_ context.Context, msg Message) error {
+		if string(msg.Key) == slow && msg.Value[0] == 'a' {
+			<-release
+		}
+		mu.Lock()
+		handled[string(msg.Key)] = append(handled[string(msg.Key)], msg.Value[0])
+		mu.Unlock()
+		return nil
+	}, 0, Workers(4, 2))
+
+	// a and d have the slow key; b, c and e the fast one
+	for i, key := range []string{slow, fast, fast, slow, fast} {
+		reader.messages = append(reader.messages, kafka.Message{
+			Topic: "payment-events", Partition: 0, Offset: int64(i), Key: []byte(key), Value: []byte{byte('a' + i)},
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	// The fast key runs while the slow one is stuck
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled[fast]) == 3
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, reader.commits(), "offset 0 is not handled yet")
+
+	close(release)
+	require.Eventually(t, func() bool {
+		commits := reader.commits()
+		return len(commits) > 0 && commits[len(commits)-1] == 4
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []byte("ad"), handled[slow], "one key is handled in order")
+	assert.Equal(t, []byte("bce"), handled[fast])
+	commits := reader.commits()
+	assert.IsIncreasing(t, commits)
+}
+
+func TestConsumer_WorkersBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	c, reader := newTestConsumer(func(context.Context, Message) error {
+		<-release
+		return nil
+	}, 10, Workers(2, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	// One message handled, one queued and one waiting to be queued
+	require.Eventually(t, func() bool {
+		reader.mu.Lock()
+		defer reader.mu.Unlock()
+		return len(reader.messages) == 7
+	}, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	reader.mu.Lock()
+	assert.Len(t, reader.messages, 7, "fetching waits for the full worker")
+	reader.mu.Unlock()
+
+	close(release)
+	require.Eventually(t, func() bool {
+		commits := reader.commits()
+		return len(commits) > 0 && commits[len(commits)-1] == 9
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestConsumer_TraceContext(t *testing.T) {
+	ctx := tracectx.WithRequestID(tracectx.WithSpan(context.Background(), tracectx.New()), "req-1")
+	sent := newProducerMessage(ctx, "payment-events", []byte("key"), []byte("a"), MessageHeader(HeaderEventType, "payment.created"))
+
+	var (
+		got       Message
+		requestID string
+		span      tracectx.SpanContext
+	)
+	c, reader := newTestConsumer(func(ctx context.Context, msg Message) error {
+		got = msg
+		requestID = tracectx.RequestID(ctx)
+		span, _ = tracectx.Span(ctx)
+		return nil
+	}, 0)
+	sent.Partition, sent.Offset = 1, 7
+	reader.messages = append(reader.messages, sent)
+
+	run(t, c, reader, 1)
+
+	assert.Equal(t, "payment.created", got.Headers.Get(HeaderEventType))
+	assert.Equal(t, 1, got.Partition)
+	assert.Equal(t, int64(7), got.Offset)
+	assert.Equal(t, "req-1", requestID)
+	sentSpan, _ := tracectx.Span(ctx)
+	assert.Equal(t, sentSpan.TraceID, span.TraceID, "the handler continues the producer's trace")
+	assert.NotEqual(t, sentSpan.SpanID, span.SpanID)
+}
@@ -1,53 +1,117 @@
 package kafka
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 
 	"github.com/rs/zerolog"
 )
 
-// Manager -.
+// Consumer control errors.
+var (
+	ErrConsumerNotFound   = errors.New("kafka: consumer not found")
+	ErrConsumerExists     = errors.New("kafka: consumer already exists")
+	ErrConsumerRunning    = errors.New("kafka: consumer is already running")
+	ErrConsumerNotStarted = errors.New("kafka: consumer was never started")
+	ErrConsumerDisabled   = errors.New("kafka: consumer is disabled")
+)
+
+// Manager runs a producer and consumers, which are told apart by topic and
+// group. Each consumer can be paused, resumed, stopped and restarted.
 type Manager struct {
 	producer  *Producer
-	consumers map[string]*Consumer
+	consumers map[consumerKey]*managedConsumer
 	logger    zerolog.Logger
 	mu        sync.RWMutex
 	brokers   []string
 
+	producerOpts []ProducerOption
+	consumerOpts []ConsumerOption
+
 	// Control flags
 	producerEnabled bool
 	consumerEnabled bool
 	controlMu       sync.RWMutex
+
+	newConsumer func(topic, groupID string, handler MessageHandler, opts ...ConsumerOption) *Consumer
 }
 
-// NewManager -.
-func NewManager(brokers []string, logger zerolog.Logger) *Manager {
-	return &Manager{
-		producer:        NewProducer(brokers, logger),
-		consumers:       make(map[string]*Consumer),
-		logger:          logger,
-		brokers:         brokers,
-		producerEnabled: true, // Producer enabled by default
-		consumerEnabled: true, // Consumer enabled by default
+type consumerKey struct {
+	topic   string
+	groupID string
+}
+
+// managedConsumer is a consumer and what it takes to start it again; a
+// reader cannot be reopened, so each run gets a new consumer.
+type managedConsumer struct {
+	handler MessageHandler
+	opts    []ConsumerOption
+	state   *consumerState
+
+	// ctx is what it was last started with; cancel and done are set while it runs
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	// disabled is set when DisableConsumer stopped it
+	disabled bool
+}
+
+func (mc *managedConsumer) running() bool {
+	return mc.cancel != nil
+}
+
+// ManagerOption configures a manager.
+type ManagerOption func(*Manager)
+
+// ProducerOptions configures the manager's producer.
+func ProducerOptions(opts ...ProducerOption) ManagerOption {
+	return func(m *Manager) {
+		m.producerOpts = append(m.producerOpts, opts...)
 	}
 }
 
+// ConsumerOptions configures every consumer the manager adds, before the
+// options passed to AddConsumer.
+func ConsumerOptions(opts ...ConsumerOption) ManagerOption {
+	return func(m *Manager) {
+		m.consumerOpts = append(m.consumerOpts, opts...)
+	}
+}
+
+// NewManager -.
+func NewManager(brokers []string, logger zerolog.Logger, opts ...ManagerOption) *Manager {
+	return NewManagerWithConfig(brokers, logger, true, true, opts...)
+}
+
 // NewManagerWithConfig creates a new manager with configuration
-func NewManagerWithConfig(brokers []string, logger zerolog.Logger, producerEnabled, consumerEnabled bool) *Manager {
-	return &Manager{
-		producer:        NewProducer(brokers, logger),
-		consumers:       make(map[string]*Consumer),
+func NewManagerWithConfig(brokers []string, logger zerolog.Logger, producerEnabled, consumerEnabled bool, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		consumers:       make(map[consumerKey]*managedConsumer),
 		logger:          logger,
 		brokers:         brokers,
 		producerEnabled: producerEnabled,
 		consumerEnabled: consumerEnabled,
 	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.producer = NewProducer(brokers, logger, m.producerOpts...)
+	m.newConsumer = func(topic, groupID string, handler MessageHandler, opts ...ConsumerOption) *Consumer {
+		return NewConsumer(m.brokers, topic, groupID, handler, m.logger, opts...)
+	}
+
+	return m
 }
 
 // SendMessage -.
-func (m *Manager) SendMessage(ctx context.Context, topic string, key []byte, value interface{}) error {
+func (m *Manager) SendMessage(ctx context.Context, topic string, key []byte, value interface{}, opts ...MessageOption) error {
 	m.controlMu.RLock()
 	defer m.controlMu.RUnlock()
 
@@ -55,85 +119,258 @@ func (m *Manager) SendMessage(ctx context.Context, topic string, key []byte, val
 		return fmt.Errorf("kafka producer is disabled")
 	}
 
-	return m.producer.SendMessage(ctx, topic, key, value)
+	return m.producer.SendMessage(ctx, topic, key, value, opts...)
 }
 
-// AddConsumer -.
-func (m *Manager) AddConsumer(topic, groupID string, handler MessageHandler) error {
+// AddConsumer adds a consumer of topic in group groupID. It joins the group
+// when it is started.
+func (m *Manager) AddConsumer(topic, groupID string, handler MessageHandler, opts ...ConsumerOption) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.consumers[topic]; exists {
-		return fmt.Errorf("consumer for topic %s already exists", topic)
+	key := consumerKey{topic: topic, groupID: groupID}
+	if _, exists := m.consumers[key]; exists {
+		return fmt.Errorf("%w: topic %s, group %s", ErrConsumerExists, topic, groupID)
 	}
 
-	consumer := NewConsumer(
-		m.brokers,
-		topic,
-		groupID,
-		handler,
-		m.logger,
-	)
-
-	m.consumers[topic] = consumer
+	m.consumers[key] = &managedConsumer{
+		handler: handler,
+		opts:    append(slices.Clone(m.consumerOpts), opts...),
+		state:   &consumerState{},
+	}
 	return nil
 }
 
-// StartConsumer -.
-func (m *Manager) StartConsumer(ctx context.Context, topic string) error {
-	m.controlMu.RLock()
-	defer m.controlMu.RUnlock()
-
-	if !m.consumerEnabled {
-		return fmt.Errorf("kafka consumer is disabled")
+// StartConsumer runs the consumer of topic in group groupID until ctx is
+// cancelled or it is stopped.
+func (m *Manager) StartConsumer(ctx context.Context, topic, groupID string) error {
+	if !m.IsConsumerEnabled() {
+		return ErrConsumerDisabled
 	}
 
-	m.mu.RLock()
-	consumer, exists := m.consumers[topic]
-	m.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("consumer for topic %s not found", topic)
+	m.mu.Lock()
+	mc, err := m.consumer(topic, groupID)
+	var stopped <-chan error
+	if err == nil {
+		stopped, err = m.run(ctx, topic, groupID, mc)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
 	}
 
-	return consumer.Start(ctx)
+	return <-stopped
 }
 
-// StartAllConsumers -.
+// StartAllConsumers starts the consumers that are not running in the
+// background, until ctx is cancelled or they are stopped.
 func (m *Manager) StartAllConsumers(ctx context.Context) {
-	m.controlMu.RLock()
-	defer m.controlMu.RUnlock()
-
-	if !m.consumerEnabled {
+	if !m.IsConsumerEnabled() {
 		m.logger.Warn().Msg("kafka consumer is disabled, skipping start all consumers")
 		return
 	}
 
-	for topic, consumer := range m.consumers {
-		go func(t string, c *Consumer) {
-			if err := c.Start(ctx); err != nil {
-				m.logger.Error().Err(err).Str("topic", t).Msg("consumer stopped with error")
-			}
-		}(topic, consumer)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, mc := range m.consumers {
+		if mc.running() {
+			continue
+		}
+		m.runBackground(ctx, key.topic, key.groupID, mc)
 	}
 }
 
-// Close -.
-func (m *Manager) Close() error {
+// PauseConsumer stops the consumer of topic in group groupID from handling
+// messages until it is resumed. It keeps its partitions, so no other member
+// of the group takes them over. A stopped consumer starts paused.
+func (m *Manager) PauseConsumer(topic, groupID string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Close all consumers
-	for topic, consumer := range m.consumers {
-		if err := consumer.Close(); err != nil {
-			m.logger.Error().Err(err).Str("topic", topic).Msg("failed to close consumer")
-		}
+	mc, err := m.consumer(topic, groupID)
+	if err != nil {
+		return err
+	}
+	mc.state.pause()
+
+	m.logger.Info().Str("topic", topic).Str("group_id", groupID).Msg("kafka consumer paused")
+	return nil
+}
+
+// ResumeConsumer -.
+func (m *Manager) ResumeConsumer(topic, groupID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mc, err := m.consumer(topic, groupID)
+	if err != nil {
+		return err
+	}
+	mc.state.resume()
+
+	m.logger.Info().Str("topic", topic).Str("group_id", groupID).Msg("kafka consumer resumed")
+	return nil
+}
+
+// StopConsumer stops the consumer of topic in group groupID and waits until
+// it committed its offsets and left the group, which hands its partitions to
+// the other members.
+func (m *Manager) StopConsumer(topic, groupID string) error {
+	m.mu.Lock()
+	mc, err := m.consumer(topic, groupID)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.stop(mc)
+
+	m.logger.Info().Str("topic", topic).Str("group_id", groupID).Msg("kafka consumer stopped")
+	return nil
+}
+
+// RestartConsumer stops the consumer of topic in group groupID if it runs,
+// and starts it in the background with the context it was last started
+// with, so it still stops with the application.
+func (m *Manager) RestartConsumer(topic, groupID string) error {
+	if !m.IsConsumerEnabled() {
+		return ErrConsumerDisabled
+	}
+
+	m.mu.Lock()
+	mc, err := m.consumer(topic, groupID)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.stop(mc)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mc.ctx == nil {
+		return fmt.Errorf("%w: topic %s, group %s", ErrConsumerNotStarted, topic, groupID)
+	}
+	if err := mc.ctx.Err(); err != nil {
+		return fmt.Errorf("kafka - Manager - RestartConsumer: %w", err)
+	}
+	m.runBackground(mc.ctx, topic, groupID, mc)
+
+	m.logger.Info().Str("topic", topic).Str("group_id", groupID).Msg("kafka consumer restarted")
+	return nil
+}
+
+// ConsumerStatus -.
+func (m *Manager) ConsumerStatus(topic, groupID string) (ConsumerStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mc, err := m.consumer(topic, groupID)
+	if err != nil {
+		return ConsumerStatus{}, err
+	}
+	return mc.state.status(topic, groupID), nil
+}
+
+// ConsumerStatuses returns the status of every consumer, by topic and group.
+func (m *Manager) ConsumerStatuses() []ConsumerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]ConsumerStatus, 0, len(m.consumers))
+	for key, mc := range m.consumers {
+		statuses = append(statuses, mc.state.status(key.topic, key.groupID))
+	}
+	slices.SortFunc(statuses, func(a, b ConsumerStatus) int {
+		return cmp.Or(cmp.Compare(a.Topic, b.Topic), cmp.Compare(a.GroupID, b.GroupID))
+	})
+
+	return statuses
+}
+
+// Close stops all consumers, then closes the producer.
+func (m *Manager) Close() error {
+	m.mu.RLock()
+	consumers := slices.Collect(maps.Values(m.consumers))
+	m.mu.RUnlock()
+
+	for _, mc := range consumers {
+		m.stop(mc)
 	}
 
-	// Close producer
 	return m.producer.Close()
 }
 
+// consumer must be called with m.mu held.
+func (m *Manager) consumer(topic, groupID string) (*managedConsumer, error) {
+	mc, ok := m.consumers[consumerKey{topic: topic, groupID: groupID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: topic %s, group %s", ErrConsumerNotFound, topic, groupID)
+	}
+	return mc, nil
+}
+
+// run starts mc with a new reader; the channel gets the error it stopped
+// with. It must be called with m.mu held.
+func (m *Manager) run(ctx context.Context, topic, groupID string, mc *managedConsumer) (<-chan error, error) {
+	if mc.running() {
+		return nil, fmt.Errorf("%w: topic %s, group %s", ErrConsumerRunning, topic, groupID)
+	}
+
+	c := m.newConsumer(topic, groupID, mc.handler, mc.opts...)
+	c.state = mc.state
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	mc.ctx, mc.cancel, mc.done = ctx, cancel, done
+
+	stopped := make(chan error, 1)
+	go func() {
+		err := c.Start(runCtx)
+		cancel()
+
+		m.mu.Lock()
+		mc.cancel, mc.done = nil, nil
+		m.mu.Unlock()
+
+		close(done)
+		stopped <- err
+	}()
+
+	return stopped, nil
+}
+
+// runBackground runs mc and logs the error it stops with. It must be called
+// with m.mu held.
+func (m *Manager) runBackground(ctx context.Context, topic, groupID string, mc *managedConsumer) {
+	stopped, err := m.run(ctx, topic, groupID, mc)
+	if err != nil {
+		m.logger.Error().Err(err).Str("topic", topic).Str("group_id", groupID).Msg("failed to start consumer")
+		return
+	}
+
+	go func() {
+		if err := <-stopped; err != nil {
+			m.logger.Error().Err(err).Str("topic", topic).Str("group_id", groupID).Msg("consumer stopped with error")
+		}
+	}()
+}
+
+// stop cancels mc and waits until it stopped. It must be called without m.mu held.
+func (m *Manager) stop(mc *managedConsumer) {
+	m.mu.RLock()
+	cancel, done := mc.cancel, mc.done
+	m.mu.RUnlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
 // EnableProducer enables the Kafka producer
 func (m *Manager) EnableProducer() {
 	m.controlMu.Lock()
@@ -157,19 +394,49 @@ func (m *Manager) IsProducerEnabled() bool {
 	return m.producerEnabled
 }
 
-// EnableConsumer enables the Kafka consumer
+// EnableConsumer enables the Kafka consumer and restarts the consumers
+// DisableConsumer stopped.
 func (m *Manager) EnableConsumer() {
 	m.controlMu.Lock()
-	defer m.controlMu.Unlock()
 	m.consumerEnabled = true
+	m.controlMu.Unlock()
+
+	m.mu.Lock()
+	for key, mc := range m.consumers {
+		if !mc.disabled {
+			continue
+		}
+		mc.disabled = false
+		if mc.ctx.Err() == nil && !mc.running() {
+			m.runBackground(mc.ctx, key.topic, key.groupID, mc)
+		}
+	}
+	m.mu.Unlock()
+
 	m.logger.Info().Msg("kafka consumer enabled")
 }
 
-// DisableConsumer disables the Kafka consumer
+// DisableConsumer disables the Kafka consumer and stops the running
+// consumers, which hands their partitions to the other members of their groups.
 func (m *Manager) DisableConsumer() {
 	m.controlMu.Lock()
-	defer m.controlMu.Unlock()
 	m.consumerEnabled = false
+	m.controlMu.Unlock()
+
+	var running []*managedConsumer
+	m.mu.Lock()
+	for _, mc := range m.consumers {
+		if mc.running() {
+			mc.disabled = true
+			running = append(running, mc)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, mc := range running {
+		m.stop(mc)
+	}
+
 	m.logger.Info().Msg("kafka consumer disabled")
 }
 
@@ -182,17 +449,16 @@ func (m *Manager) IsConsumerEnabled() bool {
 
 // GetStatus returns the current status of both producer and consumer
 func (m *Manager) GetStatus() map[string]interface{} {
+	consumers := m.ConsumerStatuses()
+
 	m.controlMu.RLock()
 	defer m.controlMu.RUnlock()
 
-	m.mu.RLock()
-	consumerCount := len(m.consumers)
-	m.mu.RUnlock()
-
 	return map[string]interface{}{
 		"producer_enabled": m.producerEnabled,
 		"consumer_enabled": m.consumerEnabled,
-		"consumer_count":   consumerCount,
+		"consumer_count":   len(consumers),
+		"consumers":        consumers,
 		"brokers":          m.brokers,
 	}
 }
@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestManager runs consumers on fake readers, the latest of each run last.
+func newTestManager(t *testing.T) (*Manager, func() []*fakeReader) {
+	t.Helper()
+
+	var (
+		mu      sync.Mutex
+		readers []*fakeReader
+	)
+	m := NewManager([]string{"localhost:9092"}, zerolog.Nop())
+	m.newConsumer = func(_, _ string, handler MessageHandler, opts ...ConsumerOption) *Consumer {
+		reader := &fakeReader{}
+		c := newConsumer(handler, zerolog.Nop(), opts...)
+		c.reader = reader
+		c.sleep = func(ctx context.Context, _ time.Duration) error {
+			return sleepContext(ctx, time.Millisecond)
+		}
+
+		mu.Lock()
+		readers = append(readers, reader)
+		mu.Unlock()
+		return c
+	}
+	t.Cleanup(func() { _ = m.Close() })
+
+	return m, func() []*fakeReader {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*fakeReader(nil), readers...)
+	}
+}
+
+func requireState(t *testing.T, m *Manager, want ConsumerState) ConsumerStatus {
+	t.Helper()
+
+	var status ConsumerStatus
+	require.Eventually(t, func() bool {
+		var err error
+		status, err = m.ConsumerStatus("orders", "billing")
+		require.NoError(t, err)
+		return status.State == want
+	}, time.Second, time.Millisecond, "want %s", want)
+
+	return status
+}
+
+func TestManager_ConsumersByTopicAndGroup(t *testing.T) {
+	m, _ := newTestManager(t)
+	handler := func(context.Context, Message) error { return nil }
+
+	require.NoError(t, m.AddConsumer("orders", "billing", handler))
+	require.NoError(t, m.AddConsumer("orders", "shipping", handler), "a topic can have several groups")
+	assert.ErrorIs(t, m.AddConsumer("orders", "billing", handler), ErrConsumerExists)
+
+	assert.ErrorIs(t, m.PauseConsumer("orders", "audit"), ErrConsumerNotFound)
+	assert.ErrorIs(t, m.RestartConsumer("orders", "billing"), ErrConsumerNotStarted)
+
+	statuses := m.ConsumerStatuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "billing", statuses[0].GroupID)
+	assert.Equal(t, "shipping", statuses[1].GroupID)
+	assert.Equal(t, ConsumerStopped, statuses[0].State)
+	assert.Nil(t, statuses[0].LastMessageAt)
+}
+
+func TestManager_Lifecycle(t *testing.T) {
+	m, readers := newTestManager(t)
+
+	var handled atomic.Int64
+	require.NoError(t, m.AddConsumer("orders", "billing", func(_ context.Context, msg Message) error {
+		if string(msg.Value) == "bad" {
+			return errors.New("database unavailable")
+		}
+		handled.Add(1)
+		return nil
+	}))
+
+	m.StartAllConsumers(context.Background())
+	requireState(t, m, ConsumerRunning)
+
+	readers()[0].push(kafka.Message{Topic: "orders", Offset: 0, Value: []byte("a")})
+	require.Eventually(t, func() bool { return handled.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Paused consumers hold their messages
+	require.NoError(t, m.PauseConsumer("orders", "billing"))
+	requireState(t, m, ConsumerPaused)
+	readers()[0].push(kafka.Message{Topic: "orders", Offset: 1, Value: []byte("b")})
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int64(1), handled.Load())
+
+	require.NoError(t, m.ResumeConsumer("orders", "billing"))
+	require.Eventually(t, func() bool { return handled.Load() == 2 }, time.Second, time.Millisecond)
+
+	// Stopping closes the reader, which leaves the group
+	require.NoError(t, m.StopConsumer("orders", "billing"))
+	status := requireState(t, m, ConsumerStopped)
+	assert.True(t, readers()[0].isClosed())
+	assert.Equal(t, int64(2), status.Messages)
+	assert.NotNil(t, status.LastMessageAt)
+
+	// Restarts read with a new reader and keep the counts
+	require.NoError(t, m.RestartConsumer("orders", "billing"))
+	status = requireState(t, m, ConsumerRunning)
+	require.Len(t, readers(), 2)
+	assert.Equal(t, int64(2), status.Messages)
+
+	readers()[1].push(kafka.Message{Topic: "orders", Offset: 2, Value: []byte("bad")})
+	status = requireState(t, m, ConsumerErroring)
+	assert.Positive(t, status.Errors)
+	assert.Equal(t, "database unavailable", status.LastError)
+	assert.ErrorIs(t, m.StartConsumer(context.Background(), "orders", "billing"), ErrConsumerRunning)
+}
+
+func TestManager_DisableConsumer(t *testing.T) {
+	m, readers := newTestManager(t)
+	require.NoError(t, m.AddConsumer("orders", "billing", func(context.Context, Message) error { return nil }))
+	require.NoError(t, m.AddConsumer("orders", "shipping", func(context.Context, Message) error { return nil }))
+
+	m.StartAllConsumers(context.Background())
+	requireState(t, m, ConsumerRunning)
+	require.NoError(t, m.StopConsumer("orders", "shipping"))
+
+	m.DisableConsumer()
+	requireState(t, m, ConsumerStopped)
+	assert.ErrorIs(t, m.RestartConsumer("orders", "billing"), ErrConsumerDisabled)
+
+	// Only the consumers disabling stopped start again
+	m.EnableConsumer()
+	requireState(t, m, ConsumerRunning)
+	assert.Len(t, readers(), 3)
+
+	status, err := m.ConsumerStatus("orders", "shipping")
+	require.NoError(t, err)
+	assert.Equal(t, ConsumerStopped, status.State)
+}
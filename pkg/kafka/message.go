@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/pkg/tracectx"
+	"github.com/segmentio/kafka-go"
+)
+
+// HeaderEventType names the kind of event a message carries.
+const HeaderEventType = "x-event-type"
+
+// Header -.
+type Header = kafka.Header
+
+// Headers are the headers of a message, in order.
+type Headers []Header
+
+// Get returns the value of the last header named key, ignoring case, or "".
+func (h Headers) Get(key string) string {
+	for i := len(h) - 1; i >= 0; i-- {
+		if strings.EqualFold(h[i].Key, key) {
+			return string(h[i].Value)
+		}
+	}
+	return ""
+}
+
+// Message is a consumed message and its position.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   Headers
+	Time      time.Time
+}
+
+func newMessage(m kafka.Message) Message {
+	return Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Headers:   m.Headers,
+		Time:      m.Time,
+	}
+}
+
+// MessageOption configures a produced message.
+type MessageOption func(*kafka.Message)
+
+// MessageHeader adds a header to the message.
+func MessageHeader(key, value string) MessageOption {
+	return func(m *kafka.Message) {
+		m.Headers = append(m.Headers, Header{Key: key, Value: []byte(value)})
+	}
+}
+
+// newProducerMessage builds a message and adds the trace context and
+// request ID of ctx, unless opts set them.
+func newProducerMessage(ctx context.Context, topic string, key, value []byte, opts ...MessageOption) kafka.Message {
+	m := kafka.Message{
+		Topic: topic,
+		Key:   key,
+		Value: value,
+		Time:  time.Now(),
+	}
+
+	for _, opt := range opts {
+		opt(&m)
+	}
+
+	headers := Headers(m.Headers)
+	tracectx.Inject(ctx, func(key, value string) {
+		if headers.Get(key) == "" {
+			m.Headers = append(m.Headers, Header{Key: key, Value: []byte(value)})
+		}
+	})
+
+	return m
+}
@@ -0,0 +1,156 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultWorkerBuffer is how many messages may wait for each worker.
+const DefaultWorkerBuffer = 16
+
+// Workers handles messages on n workers. Messages with the same key, or
+// without a key on the same partition, go to the same worker, so they are
+// handled in order while other keys run in parallel. Each worker queues up to
+// buffer messages; when its queue is full fetching waits. An offset is only
+// committed once it and every offset before it on the partition are handled.
+// n below 2 handles one message at a time; zero buffer uses DefaultWorkerBuffer.
+func Workers(n, buffer int) ConsumerOption {
+	return func(c *Consumer) {
+		c.workers = n
+		c.workerBuffer = buffer
+		if c.workerBuffer <= 0 {
+			c.workerBuffer = DefaultWorkerBuffer
+		}
+	}
+}
+
+// consumePool fetches messages and fans them out to the workers by key.
+func (c *Consumer) consumePool(ctx context.Context, handler MessageHandler) error {
+	tracker := newOffsetTracker(func(m kafka.Message) error {
+		// Queued for the next interval commit; a cancelled ctx must not drop it
+		return c.reader.CommitMessages(context.WithoutCancel(ctx), m)
+	})
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	queues := make([]chan kafka.Message, c.workers)
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, c.workerBuffer)
+		wg.Add(1)
+		go func(queue <-chan kafka.Message) {
+			defer wg.Done()
+			for m := range queue {
+				// Left uncommitted: they are read again after a restart
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := c.handle(ctx, handler, m); err != nil {
+					continue
+				}
+				if err := tracker.done(m); err != nil {
+					cancel(err)
+				}
+			}
+		}(queues[i])
+	}
+
+	err := c.dispatch(ctx, tracker, queues)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+
+	// A failed commit stopped the workers
+	if cause := context.Cause(ctx); ctx.Err() != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return err
+}
+
+func (c *Consumer) dispatch(ctx context.Context, tracker *offsetTracker, queues []chan kafka.Message) error {
+	for {
+		m, err := c.fetch(ctx)
+		if err != nil {
+			return err
+		}
+
+		tracker.add(m)
+
+		select {
+		case queues[workerFor(m, len(queues))] <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// workerFor picks the worker of m's key, or of its partition when it has none.
+func workerFor(m kafka.Message, workers int) int {
+	h := fnv.New32a()
+	if len(m.Key) > 0 {
+		h.Write(m.Key)
+	} else {
+		h.Write([]byte(strconv.Itoa(m.Partition)))
+	}
+	return int(h.Sum32() % uint32(workers))
+}
+
+// offsetTracker commits the highest offset of each partition below which
+// every fetched message is handled.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int]*partitionOffsets
+	commit     func(kafka.Message) error
+}
+
+type partitionOffsets struct {
+	// pending are the fetched messages not committed yet, in fetch order
+	pending []kafka.Message
+	handled map[int64]bool
+}
+
+func newOffsetTracker(commit func(kafka.Message) error) *offsetTracker {
+	return &offsetTracker{partitions: make(map[int]*partitionOffsets), commit: commit}
+}
+
+func (t *offsetTracker) add(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[m.Partition]
+	if !ok {
+		p = &partitionOffsets{handled: make(map[int64]bool)}
+		t.partitions[m.Partition] = p
+	}
+	p.pending = append(p.pending, m)
+}
+
+// done marks m handled and commits as far as the partition is contiguous.
+// Commits happen under the lock so they never go backwards.
+func (t *offsetTracker) done(m kafka.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partitions[m.Partition]
+	p.handled[m.Offset] = true
+
+	var last *kafka.Message
+	for len(p.pending) > 0 && p.handled[p.pending[0].Offset] {
+		last = &p.pending[0]
+		delete(p.handled, last.Offset)
+		p.pending = p.pending[1:]
+	}
+	if last == nil {
+		return nil
+	}
+
+	return t.commit(*last)
+}
@@ -17,8 +17,84 @@ type Producer struct {
 	logger zerolog.Logger
 }
 
+// ProducerOption configures a producer.
+type ProducerOption func(*Producer)
+
+// Batch sets how many messages, or how long, the producer collects per
+// partition before sending them. Zero keeps the kafka-go defaults, 100 and 1s;
+// a synchronous send waits for its batch, so keep the timeout short.
+func Batch(size int, timeout time.Duration) ProducerOption {
+	return func(p *Producer) {
+		if size > 0 {
+			p.writer.BatchSize = size
+		}
+		if timeout > 0 {
+			p.writer.BatchTimeout = timeout
+		}
+	}
+}
+
+// Compression compresses the batches with codec, e.g. kafka.Snappy.
+func Compression(codec kafka.Compression) ProducerOption {
+	return func(p *Producer) {
+		p.writer.Compression = codec
+	}
+}
+
+// RequiredAcks sets how many replicas must have a message before it counts
+// as written; kafka.RequireOne by default.
+func RequiredAcks(acks kafka.RequiredAcks) ProducerOption {
+	return func(p *Producer) {
+		p.writer.RequiredAcks = acks
+	}
+}
+
+// Balancer picks the partition of each message; kafka.LeastBytes by default,
+// which spreads one key over partitions. Hash balancers such as kafka.Hash
+// keep a key on one partition, and so in order.
+func Balancer(b kafka.Balancer) ProducerOption {
+	return func(p *Producer) {
+		if b != nil {
+			p.writer.Balancer = b
+		}
+	}
+}
+
+// Async makes sends return once the message is queued. Delivery errors
+// only reach onError, or the log when it is nil, so use it only where a
+// lost message is acceptable.
+func Async(onError func(msgs []Message, err error)) ProducerOption {
+	return func(p *Producer) {
+		p.writer.Async = true
+		p.writer.Completion = func(msgs []kafka.Message, err error) {
+			if err == nil {
+				return
+			}
+			if onError == nil {
+				p.logger.Error().Err(err).Int("messages", len(msgs)).Msg("failed to deliver messages")
+				return
+			}
+
+			delivered := make([]Message, 0, len(msgs))
+			for _, m := range msgs {
+				delivered = append(delivered, newMessage(m))
+			}
+			onError(delivered, err)
+		}
+	}
+}
+
+// ProducerSecurity connects the producer with TLS and SASL.
+func ProducerSecurity(s Security) ProducerOption {
+	return func(p *Producer) {
+		if !s.isZero() {
+			p.writer.Transport = s.transport()
+		}
+	}
+}
+
 // NewProducer -.
-func NewProducer(brokers []string, logger zerolog.Logger) *Producer {
+func NewProducer(brokers []string, logger zerolog.Logger, opts ...ProducerOption) *Producer {
 	writer := &kafka.Writer{
 		Addr:         kafka.TCP(brokers...),
 		Balancer:     &kafka.LeastBytes{},
@@ -27,32 +103,42 @@ func NewProducer(brokers []string, logger zerolog.Logger) *Producer {
 		Logger:       kafka.LoggerFunc(logger.Printf),
 	}
 
-	return &Producer{
+	p := &Producer{
 		writer: writer,
 		logger: logger,
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
-// SendMessage -.
+// SendMessage encodes value as JSON and writes it to topic. The trace
+// context and request ID of ctx go along as headers.
 func (p *Producer) SendMessage(ctx context.Context, topic string,
-	key []byte, value interface{}) error {
+	key []byte, value interface{}, opts ...MessageOption) error {
 	valueBytes, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
 
-	msg := kafka.Message{
-		Topic: topic,
-		Key:   key,
-		Value: valueBytes,
-		Time:  time.Now(),
-	}
+	msg := newProducerMessage(ctx, topic, key, valueBytes, opts...)
 
 	err = p.writer.WriteMessages(ctx, msg)
 	if err != nil {
 		return fmt.Errorf("failed to write message: %w", err)
 	}
 
+	if p.writer.Async {
+		p.logger.Debug().
+			Str("topic", topic).
+			Str("key", string(key)).
+			Msg("message queued")
+		return nil
+	}
+
 	p.logger.Info().
 		Str("topic", topic).
 		Str("key", string(key)).
@@ -61,6 +147,14 @@ func (p *Producer) SendMessage(ctx context.Context, topic string,
 	return nil
 }
 
+// WriteMessages writes already encoded messages, each to the topic it names.
+func (p *Producer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := p.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to write messages: %w", err)
+	}
+	return nil
+}
+
 // Close -.
 func (p *Producer) Close() error {
 	return p.writer.Close()
@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProducer_Options(t *testing.T) {
+	p := NewProducer([]string{"localhost:9092"}, zerolog.Nop())
+	assert.Equal(t, &kafka.LeastBytes{}, p.writer.Balancer)
+	assert.Nil(t, p.writer.Transport, "plain text by default")
+
+	p = NewProducer([]string{"localhost:9092"}, zerolog.Nop(),
+		Batch(500, 5*time.Millisecond),
+		Compression(kafka.Lz4),
+		RequiredAcks(kafka.RequireAll),
+		Balancer(&kafka.Hash{}),
+		ProducerSecurity(Security{TLS: &tls.Config{MinVersion: tls.VersionTLS12}}),
+	)
+	assert.Equal(t, 500, p.writer.BatchSize)
+	assert.Equal(t, 5*time.Millisecond, p.writer.BatchTimeout)
+	assert.Equal(t, kafka.Lz4, p.writer.Compression)
+	assert.Equal(t, kafka.RequireAll, p.writer.RequiredAcks)
+	assert.Equal(t, &kafka.Hash{}, p.writer.Balancer)
+	require.IsType(t, &kafka.Transport{}, p.writer.Transport)
+	assert.NotNil(t, p.writer.Transport.(*kafka.Transport).TLS)
+	assert.False(t, p.writer.Async)
+
+	// Zero batch settings leave the kafka-go defaults
+	p = NewProducer([]string{"localhost:9092"}, zerolog.Nop(), Batch(0, 0))
+	assert.Zero(t, p.writer.BatchSize)
+	assert.Zero(t, p.writer.BatchTimeout)
+}
+
+func TestAsync(t *testing.T) {
+	var (
+		failed []Message
+		got    error
+	)
+	p := NewProducer([]string{"localhost:9092"}, zerolog.Nop(), Async(func(msgs []Message, err error) {
+		failed, got = msgs, err
+	}))
+	require.True(t, p.writer.Async)
+
+	p.writer.Completion([]kafka.Message{{Topic: "events", Key: []byte("k")}}, nil)
+	assert.Nil(t, failed, "delivered messages are not reported")
+
+	errDelivery := errors.New("broker down")
+	p.writer.Completion([]kafka.Message{{Topic: "events", Key: []byte("k")}}, errDelivery)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "events", failed[0].Topic)
+	assert.Equal(t, []byte("k"), failed[0].Key)
+	assert.ErrorIs(t, got, errDelivery)
+}
+
+func TestSASLMechanism(t *testing.T) {
+	m, err := SASLMechanism("none", "user", "secret")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	m, err = SASLMechanism("plain", "user", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "PLAIN", m.Name())
+
+	m, err = SASLMechanism("SCRAM-SHA-512", "user", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, scram.SHA512.Name(), m.Name())
+
+	_, err = SASLMechanism("gssapi", "user", "secret")
+	assert.Error(t, err)
+}
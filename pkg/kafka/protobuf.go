@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Protobuf encodes generated protobuf messages. Schemas are the message's
+// file descriptor, base64 encoded, with its imports as references. Imports of
+// google/protobuf are known to the registry and not referenced.
+type Protobuf struct{}
+
+var _ Codec = Protobuf{}
+
+// Schema -.
+func (Protobuf) Schema(v any) (Schema, error) {
+	m, err := protoMessage(v)
+	if err != nil {
+		return Schema{}, err
+	}
+	return protoFileSchema(m.ProtoReflect().Descriptor().ParentFile()), nil
+}
+
+// Marshal -.
+func (Protobuf) Marshal(v any) ([]byte, error) {
+	m, err := protoMessage(v)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal -. The generated type already knows its schema, so writer is unused.
+func (Protobuf) Unmarshal(data []byte, _ *Schema, v any) error {
+	m, err := protoMessage(v)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// messageIndexes is the path of v's message in its file: its index among the
+// top level messages, then among the nested ones. The wire format carries it
+// after the schema ID.
+func (Protobuf) messageIndexes(v any) []int {
+	m, err := protoMessage(v)
+	if err != nil {
+		return nil
+	}
+
+	var indexes []int
+	var d protoreflect.Descriptor = m.ProtoReflect().Descriptor()
+	for {
+		md, ok := d.(protoreflect.MessageDescriptor)
+		if !ok {
+			break
+		}
+		indexes = append([]int{md.Index()}, indexes...)
+		d = md.Parent()
+	}
+	return indexes
+}
+
+func protoMessage(v any) (proto.Message, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("kafka - Protobuf: %T is not a proto.Message", v)
+	}
+	return m, nil
+}
+
+func protoFileSchema(fd protoreflect.FileDescriptor) Schema {
+	b, _ := proto.Marshal(protodesc.ToFileDescriptorProto(fd))
+	s := Schema{Type: SchemaTypeProtobuf, Schema: base64.StdEncoding.EncodeToString(b)}
+
+	imports := fd.Imports()
+	for i := range imports.Len() {
+		dep := imports.Get(i).FileDescriptor
+		if strings.HasPrefix(dep.Path(), "google/protobuf/") {
+			continue
+		}
+		depSchema := protoFileSchema(dep)
+		s.References = append(s.References, SchemaReference{
+			Name:    dep.Path(),
+			Subject: dep.Path(),
+			Schema:  &depSchema,
+		})
+	}
+	return s
+}
@@ -0,0 +1,198 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	_registryTimeout     = 10 * time.Second
+	_registryContentType = "application/vnd.schemaregistry.v1+json"
+	// _maxRegistryResponse bounds the registry responses read into memory.
+	_maxRegistryResponse = 4 << 20
+
+	// Registry error codes for a subject or version that does not exist.
+	_registrySubjectNotFound = 40401
+	_registryVersionNotFound = 40402
+)
+
+// RegistryError is an error response of the schema registry.
+type RegistryError struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"error_code"`
+	Message string `json:"message"`
+}
+
+func (e *RegistryError) Error() string {
+	return fmt.Sprintf("schema registry: status %d, error %d: %s", e.Status, e.Code, e.Message)
+}
+
+// Registry is a client of a Confluent compatible schema registry.
+// Schemas fetched by ID are cached; IDs never change their schema.
+type Registry struct {
+	url      string
+	username string
+	password string
+	http     *http.Client
+
+	mu  sync.RWMutex
+	ids map[int]Schema
+}
+
+// RegistryOption configures a registry client.
+type RegistryOption func(*Registry)
+
+// RegistryAuth sets the basic auth credentials of the registry.
+func RegistryAuth(username, password string) RegistryOption {
+	return func(r *Registry) {
+		r.username = username
+		r.password = password
+	}
+}
+
+// RegistryHTTPClient sets the client used to call the registry.
+func RegistryHTTPClient(hc *http.Client) RegistryOption {
+	return func(r *Registry) {
+		if hc != nil {
+			r.http = hc
+		}
+	}
+}
+
+// NewRegistry -.
+func NewRegistry(registryURL string, opts ...RegistryOption) *Registry {
+	r := &Registry{
+		url:  strings.TrimSuffix(registryURL, "/"),
+		http: &http.Client{Timeout: _registryTimeout},
+		ids:  make(map[int]Schema),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Register adds s to subject, unless the subject already has it, and returns its ID.
+func (r *Registry) Register(ctx context.Context, subject string, s Schema) (int, error) {
+	var resp struct {
+		ID int `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", s, &resp); err != nil {
+		return 0, fmt.Errorf("kafka - Registry - Register: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// Version returns the version of s in subject.
+func (r *Registry) Version(ctx context.Context, subject string, s Schema) (int, error) {
+	var resp struct {
+		Version int `json:"version"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject), s, &resp); err != nil {
+		return 0, fmt.Errorf("kafka - Registry - Version: %w", err)
+	}
+	return resp.Version, nil
+}
+
+// CheckCompatibility reports whether s may be added to subject under the
+// subject's compatibility level, and why not. A subject without versions
+// accepts any schema.
+func (r *Registry) CheckCompatibility(ctx context.Context, subject string, s Schema) (bool, []string, error) {
+	var resp struct {
+		IsCompatible bool     `json:"is_compatible"`
+		Messages     []string `json:"messages"`
+	}
+
+	path := "/compatibility/subjects/" + url.PathEscape(subject) + "/versions/latest?verbose=true"
+	err := r.do(ctx, http.MethodPost, path, s, &resp)
+
+	var regErr *RegistryError
+	if errors.As(err, &regErr) && (regErr.Code == _registrySubjectNotFound || regErr.Code == _registryVersionNotFound) {
+		return true, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("kafka - Registry - CheckCompatibility: %w", err)
+	}
+
+	return resp.IsCompatible, resp.Messages, nil
+}
+
+// SchemaByID returns the schema registered under id.
+func (r *Registry) SchemaByID(ctx context.Context, id int) (Schema, error) {
+	r.mu.RLock()
+	s, ok := r.ids[id]
+	r.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+
+	if err := r.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &s); err != nil {
+		return Schema{}, fmt.Errorf("kafka - Registry - SchemaByID: %w", err)
+	}
+	// The registry leaves out the type of Avro schemas
+	if s.Type == "" {
+		s.Type = SchemaTypeAvro
+	}
+
+	r.mu.Lock()
+	r.ids[id] = s
+	r.mu.Unlock()
+
+	return s, nil
+}
+
+func (r *Registry) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.url+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", _registryContentType)
+	if in != nil {
+		req.Header.Set("Content-Type", _registryContentType)
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, _maxRegistryResponse))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		regErr := &RegistryError{Status: resp.StatusCode}
+		if json.Unmarshal(b, regErr) != nil || regErr.Message == "" {
+			regErr.Message = strings.TrimSpace(string(b))
+		}
+		return regErr
+	}
+
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,61 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// _dialTimeout matches the default dialer of kafka-go.
+const _dialTimeout = 10 * time.Second
+
+// Security is how clients connect to the brokers. The zero value connects
+// in plain text without authentication.
+type Security struct {
+	TLS  *tls.Config
+	SASL sasl.Mechanism
+}
+
+// SASLMechanism returns the mechanism named "plain", "scram-sha-256" or
+// "scram-sha-512", or nil for "" and "none".
+func SASLMechanism(name, username, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, username, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, username, password)
+	default:
+		return nil, fmt.Errorf("kafka: unknown sasl mechanism %q", name)
+	}
+}
+
+func (s Security) isZero() bool {
+	return s.TLS == nil && s.SASL == nil
+}
+
+func (s Security) transport() *kafka.Transport {
+	return &kafka.Transport{
+		DialTimeout: _dialTimeout,
+		TLS:         s.TLS,
+		SASL:        s.SASL,
+	}
+}
+
+func (s Security) dialer() *kafka.Dialer {
+	return &kafka.Dialer{
+		Timeout:       _dialTimeout,
+		DualStack:     true,
+		TLS:           s.TLS,
+		SASLMechanism: s.SASL,
+	}
+}
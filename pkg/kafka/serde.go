@@ -0,0 +1,269 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// _magicByte starts every message in the schema registry wire format,
+// followed by the 4 byte big endian schema ID.
+const _magicByte = 0
+
+var (
+	// ErrIncompatibleSchema is returned when the registry rejects the schema
+	// of a value, e.g. after a field was renamed.
+	ErrIncompatibleSchema = errors.New("kafka: incompatible schema")
+	// ErrUnknownWireFormat is returned for registry framed messages that are too short.
+	ErrUnknownWireFormat = errors.New("kafka: message is not in the schema registry wire format")
+)
+
+// messageIndexer is a codec whose messages name their type after the
+// schema ID, like Protobuf.
+type messageIndexer interface {
+	messageIndexes(v any) []int
+}
+
+// Serializer encodes message values with a codec. With a schema registry
+// each value's schema is checked for compatibility and registered before it
+// is first produced, and the schema ID is written in front of the payload.
+// Decode reads both framed and plain messages.
+type Serializer struct {
+	codec    Codec
+	registry *Registry
+	subject  func(topic string) string
+
+	mu  sync.RWMutex
+	ids map[subjectType]int
+}
+
+type subjectType struct {
+	subject string
+	typ     reflect.Type
+}
+
+// SerializerOption configures a serializer.
+type SerializerOption func(*Serializer)
+
+// SchemaRegistry registers schemas with r and frames messages with their IDs.
+func SchemaRegistry(r *Registry) SerializerOption {
+	return func(s *Serializer) {
+		s.registry = r
+	}
+}
+
+// SubjectNames sets the registry subject of a topic's values; topic-value by default.
+func SubjectNames(subject func(topic string) string) SerializerOption {
+	return func(s *Serializer) {
+		if subject != nil {
+			s.subject = subject
+		}
+	}
+}
+
+// NewSerializer -.
+func NewSerializer(codec Codec, opts ...SerializerOption) *Serializer {
+	s := &Serializer{
+		codec:   codec,
+		subject: func(topic string) string { return topic + "-value" },
+		ids:     make(map[subjectType]int),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Encode encodes v as a value of topic.
+func (s *Serializer) Encode(ctx context.Context, topic string, v any) ([]byte, error) {
+	payload, err := s.codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("kafka - Serializer - Encode - codec.Marshal: %w", err)
+	}
+	if s.registry == nil {
+		return payload, nil
+	}
+
+	id, err := s.schemaID(ctx, s.subject(topic), v)
+	if err != nil {
+		return nil, fmt.Errorf("kafka - Serializer - Encode: %w", err)
+	}
+
+	b := make([]byte, 5, 5+len(payload)+4)
+	b[0] = _magicByte
+	binary.BigEndian.PutUint32(b[1:], uint32(id))
+	if indexer, ok := s.codec.(messageIndexer); ok {
+		b = appendMessageIndexes(b, indexer.messageIndexes(v))
+	}
+
+	return append(b, payload...), nil
+}
+
+// Decode decodes data into v, which must be a pointer.
+func (s *Serializer) Decode(ctx context.Context, data []byte, v any) error {
+	// Plain messages, e.g. produced before the registry was turned on
+	if s.registry == nil || len(data) == 0 || data[0] != _magicByte {
+		if err := s.codec.Unmarshal(data, nil, v); err != nil {
+			return fmt.Errorf("kafka - Serializer - Decode - codec.Unmarshal: %w", err)
+		}
+		return nil
+	}
+
+	if len(data) < 5 {
+		return ErrUnknownWireFormat
+	}
+	id := int(binary.BigEndian.Uint32(data[1:5]))
+	data = data[5:]
+
+	if _, ok := s.codec.(messageIndexer); ok {
+		var err error
+		if data, err = skipMessageIndexes(data); err != nil {
+			return err
+		}
+	}
+
+	writer, err := s.registry.SchemaByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("kafka - Serializer - Decode: %w", err)
+	}
+
+	if err := s.codec.Unmarshal(data, &writer, v); err != nil {
+		return fmt.Errorf("kafka - Serializer - Decode - codec.Unmarshal: %w", err)
+	}
+	return nil
+}
+
+// schemaID registers the schema of v's type under subject once.
+func (s *Serializer) schemaID(ctx context.Context, subject string, v any) (int, error) {
+	key := subjectType{subject: subject, typ: reflect.TypeOf(v)}
+
+	s.mu.RLock()
+	id, ok := s.ids[key]
+	s.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	schema, err := s.codec.Schema(v)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.registerReferences(ctx, schema.References); err != nil {
+		return 0, err
+	}
+
+	compatible, messages, err := s.registry.CheckCompatibility(ctx, subject, schema)
+	if err != nil {
+		return 0, err
+	}
+	if !compatible {
+		return 0, fmt.Errorf("%w: subject %s: %v", ErrIncompatibleSchema, subject, messages)
+	}
+
+	id, err = s.registry.Register(ctx, subject, schema)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	s.ids[key] = id
+	s.mu.Unlock()
+
+	return id, nil
+}
+
+// registerReferences registers imported schemas under their own subjects,
+// filling in the versions the referrer points at.
+func (s *Serializer) registerReferences(ctx context.Context, refs []SchemaReference) error {
+	for i := range refs {
+		ref := &refs[i]
+		if ref.Schema == nil {
+			continue
+		}
+		if err := s.registerReferences(ctx, ref.Schema.References); err != nil {
+			return err
+		}
+		if _, err := s.registry.Register(ctx, ref.Subject, *ref.Schema); err != nil {
+			return err
+		}
+
+		version, err := s.registry.Version(ctx, ref.Subject, *ref.Schema)
+		if err != nil {
+			return err
+		}
+		ref.Version = version
+	}
+	return nil
+}
+
+// appendMessageIndexes writes the message indexes as zigzag varints, with
+// the common [0] shortened to a single 0.
+func appendMessageIndexes(b []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(b, 0)
+	}
+
+	b = binary.AppendVarint(b, int64(len(indexes)))
+	for _, i := range indexes {
+		b = binary.AppendVarint(b, int64(i))
+	}
+	return b
+}
+
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	n, size := binary.Varint(data)
+	if size <= 0 || n < 0 {
+		return nil, ErrUnknownWireFormat
+	}
+	data = data[size:]
+
+	for range n {
+		if _, size = binary.Varint(data); size <= 0 {
+			return nil, ErrUnknownWireFormat
+		}
+		data = data[size:]
+	}
+	return data, nil
+}
+
+// Publish encodes v with s and writes it to topic, with the trace context
+// and request ID of ctx as headers.
+func Publish[T any](ctx context.Context, w MessageWriter, s *Serializer, topic string, key []byte, v T, opts ...MessageOption) error {
+	value, err := s.Encode(ctx, topic, v)
+	if err != nil {
+		return err
+	}
+
+	return w.WriteMessages(ctx, newProducerMessage(ctx, topic, key, value, opts...))
+}
+
+// Subscribe turns a handler of decoded values into a MessageHandler.
+// Messages that do not decode into T are permanent errors. T may be a
+// pointer, e.g. to a generated protobuf message.
+func Subscribe[T any](s *Serializer, handle func(ctx context.Context, msg Message, v T) error) MessageHandler {
+	return func(ctx context.Context, msg Message) error {
+		v, err := decodeAs[T](ctx, s, msg.Value)
+		if err != nil {
+			return Permanent(err)
+		}
+		return handle(ctx, msg, v)
+	}
+}
+
+func decodeAs[T any](ctx context.Context, s *Serializer, data []byte) (T, error) {
+	var v T
+	target := any(&v)
+	if t := reflect.TypeFor[T](); t.Kind() == reflect.Pointer {
+		p := reflect.New(t.Elem())
+		reflect.ValueOf(&v).Elem().Set(p)
+		target = p.Interface()
+	}
+
+	err := s.Decode(ctx, data, target)
+	return v, err
+}
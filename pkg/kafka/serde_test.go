@@ -0,0 +1,294 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeRegistry keeps schemas per subject and accepts a new version only if
+// it lists the fields of the latest one.
+type fakeRegistry struct {
+	mu       sync.Mutex
+	schemas  []Schema
+	subjects map[string][]int
+}
+
+func newFakeRegistry(t *testing.T) (*fakeRegistry, *Registry) {
+	t.Helper()
+
+	f := &fakeRegistry{subjects: map[string][]int{}}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+
+	return f, NewRegistry(srv.URL)
+}
+
+func (f *fakeRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var s Schema
+	if r.Method == http.MethodPost {
+		_ = json.NewDecoder(r.Body).Decode(&s)
+	}
+	reply := func(status int, v any) {
+		w.Header().Set("Content-Type", _registryContentType)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	path := r.URL.Path
+
+	switch {
+	case strings.HasPrefix(path, "/compatibility/subjects/"):
+		subject := strings.TrimSuffix(strings.TrimPrefix(path, "/compatibility/subjects/"), "/versions/latest")
+		versions := f.subjects[subject]
+		if len(versions) == 0 {
+			reply(http.StatusNotFound, map[string]any{"error_code": _registrySubjectNotFound, "message": "Subject not found"})
+			return
+		}
+		latest := f.schemas[versions[len(versions)-1]-1]
+		var missing []string
+		for _, field := range avroFieldNames(latest.Schema) {
+			if !strings.Contains(s.Schema, `"`+field+`"`) {
+				missing = append(missing, "missing field "+field)
+			}
+		}
+		reply(http.StatusOK, map[string]any{"is_compatible": len(missing) == 0, "messages": missing})
+	case strings.HasPrefix(path, "/subjects/") && strings.HasSuffix(path, "/versions"):
+		subject := strings.TrimSuffix(strings.TrimPrefix(path, "/subjects/"), "/versions")
+		for _, id := range f.subjects[subject] {
+			if f.schemas[id-1].Schema == s.Schema {
+				reply(http.StatusOK, map[string]int{"id": id})
+				return
+			}
+		}
+		f.schemas = append(f.schemas, s)
+		f.subjects[subject] = append(f.subjects[subject], len(f.schemas))
+		reply(http.StatusOK, map[string]int{"id": len(f.schemas)})
+	case strings.HasPrefix(path, "/schemas/ids/"):
+		var id int
+		_ = json.Unmarshal([]byte(strings.TrimPrefix(path, "/schemas/ids/")), &id)
+		if id < 1 || id > len(f.schemas) {
+			reply(http.StatusNotFound, map[string]any{"error_code": 40403, "message": "Schema not found"})
+			return
+		}
+		reply(http.StatusOK, f.schemas[id-1])
+	default:
+		reply(http.StatusNotFound, map[string]any{"error_code": 404, "message": "not found"})
+	}
+}
+
+func avroFieldNames(schema string) []string {
+	var record struct {
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	_ = json.Unmarshal([]byte(schema), &record)
+
+	names := make([]string, 0, len(record.Fields))
+	for _, f := range record.Fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+type paymentV1 struct {
+	ID       int64             `json:"id"`
+	Amount   float64           `json:"amount"`
+	Status   string            `json:"status"`
+	Note     *string           `json:"note"`
+	Tags     []string          `json:"tags"`
+	Metadata map[string]int32  `json:"metadata"`
+	Raw      []byte            `json:"raw"`
+	Created  time.Time         `json:"created"`
+	Items    []paymentV1Item   `json:"items"`
+	Extra    map[string]string `json:"-"`
+}
+
+type paymentV1Item struct {
+	Name  string `json:"name"`
+	Price float32
+}
+
+func TestAvro_RoundTrip(t *testing.T) {
+	note := "first payment"
+	in := paymentV1{
+		ID:       42,
+		Amount:   -12.5,
+		Status:   "pending",
+		Note:     &note,
+		Tags:     []string{"water", "monthly"},
+		Metadata: map[string]int32{"retries": 3},
+		Raw:      []byte{0, 1, 2},
+		Created:  time.UnixMilli(1_700_000_000_123).UTC(),
+		Items:    []paymentV1Item{{Name: "meter", Price: 1.5}},
+	}
+
+	b, err := Avro{}.Marshal(in)
+	require.NoError(t, err)
+
+	var out paymentV1
+	require.NoError(t, Avro{}.Unmarshal(b, nil, &out))
+	assert.Equal(t, in, out)
+
+	s, err := Avro{}.Schema(in)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaTypeAvro, s.Type)
+	assert.JSONEq(t, `{"type":"record","name":"paymentV1","fields":[
+		{"name":"id","type":"long"},
+		{"name":"amount","type":"double"},
+		{"name":"status","type":"string"},
+		{"name":"note","type":["null","string"],"default":null},
+		{"name":"tags","type":{"type":"array","items":"string"}},
+		{"name":"metadata","type":{"type":"map","values":"int"}},
+		{"name":"raw","type":"bytes"},
+		{"name":"created","type":{"type":"long","logicalType":"timestamp-millis"}},
+		{"name":"items","type":{"type":"array","items":{"type":"record","name":"paymentV1Item","fields":[
+			{"name":"name","type":"string"},
+			{"name":"Price","type":"float"}]}}}]}`, s.Schema)
+}
+
+func TestAvro_ReadsWithWriterSchema(t *testing.T) {
+	type paymentV2 struct {
+		ID       int64   `json:"id"`
+		Amount   float64 `json:"amount"`
+		Currency string  `json:"currency"`
+	}
+
+	writer, err := Avro{}.Schema(paymentV1{})
+	require.NoError(t, err)
+	b, err := Avro{}.Marshal(paymentV1{ID: 7, Amount: 10, Status: "done", Tags: []string{"x"}})
+	require.NoError(t, err)
+
+	var out paymentV2
+	require.NoError(t, Avro{}.Unmarshal(b, &writer, &out))
+	assert.Equal(t, paymentV2{ID: 7, Amount: 10}, out, "fields the reader lacks are skipped")
+}
+
+func TestAvro_BoundsBlockCounts(t *testing.T) {
+	schema := func(items string) *Schema {
+		return &Schema{Type: SchemaTypeAvro, Schema: `{"type":"record","name":"r","fields":[{"name":"xs","type":` + items + `}]}`}
+	}
+	block := func(count int64, items ...byte) []byte {
+		b := binary.AppendVarint(nil, count)
+		return append(append(b, items...), 0)
+	}
+
+	tests := []struct {
+		name    string
+		items   string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "nulls", items: `{"type":"array","items":"null"}`, data: block(10)},
+		{name: "empty records", items: `{"type":"array","items":{"type":"record","name":"e","fields":[]}}`, data: block(3)},
+		{name: "longs", items: `{"type":"array","items":"long"}`, data: block(2, 2, 4)},
+		{name: "huge count of nulls", items: `{"type":"array","items":"null"}`, data: block(1 << 62), wantErr: true},
+		{name: "nulls over many blocks", items: `{"type":"array","items":"null"}`, data: append(binary.AppendVarint(nil, 1000), block(1000)...), wantErr: true},
+		{name: "count beyond the data", items: `{"type":"array","items":"long"}`, data: block(1000, 2, 4), wantErr: true},
+		{name: "overflowing negative count", items: `{"type":"array","items":"long"}`, data: block(math.MinInt64), wantErr: true},
+		{name: "map count beyond the data", items: `{"type":"map","values":"null"}`, data: block(1 << 40), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out struct{}
+			err := Avro{}.Unmarshal(tt.data, schema(tt.items), &out)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSerializer_Registry(t *testing.T) {
+	fake, registry := newFakeRegistry(t)
+	s := NewSerializer(Avro{}, SchemaRegistry(registry))
+	ctx := context.Background()
+
+	b, err := s.Encode(ctx, "payment-events", paymentV1{ID: 1, Status: "pending"})
+	require.NoError(t, err)
+	require.Greater(t, len(b), 5)
+	assert.Equal(t, byte(0), b[0])
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(b[1:5]))
+	assert.Equal(t, []int{1}, fake.subjects["payment-events-value"])
+
+	var out paymentV1
+	require.NoError(t, s.Decode(ctx, b, &out))
+	assert.Equal(t, int64(1), out.ID)
+	assert.Equal(t, "pending", out.Status)
+
+	// Renaming status breaks the consumers of the old schema
+	type renamed struct {
+		ID    int64  `json:"id"`
+		State string `json:"state"`
+	}
+	_, err = s.Encode(ctx, "payment-events", renamed{ID: 2})
+	require.ErrorIs(t, err, ErrIncompatibleSchema)
+	assert.Contains(t, err.Error(), "missing field status")
+}
+
+func TestSerializer_PlainMessages(t *testing.T) {
+	_, registry := newFakeRegistry(t)
+	s := NewSerializer(JSON{}, SchemaRegistry(registry))
+
+	var out paymentV1
+	require.NoError(t, s.Decode(context.Background(), []byte(`{"id":3}`), &out))
+	assert.Equal(t, int64(3), out.ID)
+}
+
+func TestSerializer_Protobuf(t *testing.T) {
+	fake, registry := newFakeRegistry(t)
+	s := NewSerializer(Protobuf{}, SchemaRegistry(registry))
+	ctx := context.Background()
+
+	b, err := s.Encode(ctx, "timeouts", durationpb.New(90*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, byte(0), b[5], "the first message of the file is index [0]")
+	assert.Equal(t, SchemaTypeProtobuf, fake.schemas[0].Type)
+
+	handled := make(chan time.Duration, 1)
+	handler := Subscribe(s, func(_ context.Context, _ Message, d *durationpb.Duration) error {
+		handled <- d.AsDuration()
+		return nil
+	})
+	require.NoError(t, handler(ctx, Message{Value: b}))
+	assert.Equal(t, 90*time.Second, <-handled)
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	s := NewSerializer(JSON{})
+	w := &fakeWriter{}
+	ctx := context.Background()
+
+	require.NoError(t, Publish(ctx, w, s, "payment-events", []byte("tx-1"), paymentV1{ID: 5}))
+	require.Len(t, w.messages, 1)
+	assert.Equal(t, "payment-events", w.messages[0].Topic)
+	assert.Equal(t, []byte("tx-1"), w.messages[0].Key)
+
+	var got paymentV1
+	handler := Subscribe(s, func(_ context.Context, _ Message, p paymentV1) error {
+		got = p
+		return nil
+	})
+	require.NoError(t, handler(ctx, newMessage(w.messages[0])))
+	assert.Equal(t, int64(5), got.ID)
+
+	err := handler(ctx, Message{Value: []byte("not json")})
+	assert.True(t, IsPermanent(err), "undecodable messages are not retried")
+}
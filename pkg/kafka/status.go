@@ -0,0 +1,175 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ConsumerState -.
+type ConsumerState string
+
+// Consumer states.
+const (
+	ConsumerRunning ConsumerState = "running"
+	// ConsumerPaused consumers keep their partitions but handle no messages.
+	ConsumerPaused  ConsumerState = "paused"
+	ConsumerStopped ConsumerState = "stopped"
+	// ConsumerErroring consumers are retrying a message, or stopped with an error.
+	ConsumerErroring ConsumerState = "erroring"
+)
+
+// ConsumerStatus is a snapshot of a consumer. Messages counts the messages
+// done with, including dead letters and skipped ones; Errors counts failed
+// attempts and runs that stopped with an error. A Manager keeps the counts
+// across restarts.
+type ConsumerStatus struct {
+	Topic         string        `json:"topic"`
+	GroupID       string        `json:"group_id"`
+	State         ConsumerState `json:"state"`
+	LastMessageAt *time.Time    `json:"last_message_at"`
+	Messages      int64         `json:"messages"`
+	Errors        int64         `json:"errors"`
+	LastError     string        `json:"last_error,omitempty"`
+}
+
+// consumerState is what a consumer reports, and its pause switch.
+type consumerState struct {
+	mu sync.Mutex
+
+	running bool
+	// resumed is closed on resume; nil when not paused
+	resumed chan struct{}
+	// failing is set while the current message fails
+	failing bool
+	// stopErr is why the last run stopped, if not cancelled
+	stopErr error
+
+	lastMessageAt time.Time
+	messages      int64
+	errors        int64
+	lastErr       error
+}
+
+func (s *consumerState) pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resumed == nil {
+		s.resumed = make(chan struct{})
+	}
+}
+
+func (s *consumerState) resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resumed != nil {
+		close(s.resumed)
+		s.resumed = nil
+	}
+}
+
+// waitResumed blocks while the consumer is paused.
+func (s *consumerState) waitResumed(ctx context.Context) error {
+	s.mu.Lock()
+	resumed := s.resumed
+	s.mu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *consumerState) started() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = true
+	s.failing = false
+	s.stopErr = nil
+}
+
+func (s *consumerState) stopped(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+	s.failing = false
+	if err != nil {
+		s.stopErr = err
+		s.errors++
+		s.lastErr = err
+	}
+}
+
+func (s *consumerState) received() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastMessageAt = time.Now()
+}
+
+// handled records the outcome of one attempt at a message.
+func (s *consumerState) handled(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failing = true
+		s.errors++
+		s.lastErr = err
+		return
+	}
+	s.failing = false
+	s.messages++
+}
+
+func (s *consumerState) status(topic, groupID string) ConsumerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := ConsumerStatus{
+		Topic:    topic,
+		GroupID:  groupID,
+		Messages: s.messages,
+		Errors:   s.errors,
+	}
+	if !s.lastMessageAt.IsZero() {
+		t := s.lastMessageAt
+		status.LastMessageAt = &t
+	}
+	if s.lastErr != nil {
+		status.LastError = s.lastErr.Error()
+	}
+
+	switch {
+	case s.failing || (!s.running && s.stopErr != nil):
+		status.State = ConsumerErroring
+	case !s.running:
+		status.State = ConsumerStopped
+	case s.resumed != nil:
+		status.State = ConsumerPaused
+	default:
+		status.State = ConsumerRunning
+	}
+
+	return status
+}
+
+// Pause stops handling messages until Resume; messages already being handled
+// finish. The consumer stays in its group and keeps its partitions.
+func (c *Consumer) Pause() {
+	c.state.pause()
+}
+
+// Resume -.
+func (c *Consumer) Resume() {
+	c.state.resume()
+}
+
+// Status -.
+func (c *Consumer) Status() ConsumerStatus {
+	config := c.reader.Config()
+	return c.state.status(config.Topic, config.GroupID)
+}
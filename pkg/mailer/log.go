@@ -0,0 +1,30 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/ducnpdev/godev-kit/pkg/logger"
+)
+
+// Log writes messages to the logger instead of sending them. Links in the
+// body (reset and verification tokens) end up in the log, so use it in
+// development only.
+type Log struct {
+	l logger.Interface
+}
+
+// NewLog -.
+func NewLog(l logger.Interface) *Log {
+	return &Log{l: l}
+}
+
+// Send -.
+func (m *Log) Send(_ context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	m.l.Info("mailer - Log - Send: to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+
+	return nil
+}
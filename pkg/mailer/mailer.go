@@ -0,0 +1,32 @@
+// Package mailer sends transactional email.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidMessage is returned for messages without a recipient or with
+// line breaks in a header field.
+var ErrInvalidMessage = errors.New("mailer: invalid message")
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer -.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+
+	return nil
+}
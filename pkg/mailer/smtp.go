@@ -0,0 +1,167 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const _defaultTimeout = 10 * time.Second
+
+// SMTP sends messages through an SMTP server, upgrading to TLS with STARTTLS
+// when the server offers it.
+type SMTP struct {
+	addr    string
+	host    string
+	from    mail.Address
+	auth    smtp.Auth
+	timeout time.Duration
+	tlsConf *tls.Config
+}
+
+// SMTPOption -.
+type SMTPOption func(*SMTP)
+
+// Auth authenticates with PLAIN. net/smtp refuses to send the password over
+// an unencrypted connection to anything but localhost.
+func Auth(username, password string) SMTPOption {
+	return func(m *SMTP) {
+		if username != "" {
+			m.auth = smtp.PlainAuth("", username, password, m.host)
+		}
+	}
+}
+
+// Timeout bounds a whole delivery.
+func Timeout(timeout time.Duration) SMTPOption {
+	return func(m *SMTP) {
+		if timeout > 0 {
+			m.timeout = timeout
+		}
+	}
+}
+
+// TLSConfig overrides the STARTTLS configuration, e.g. to trust a private CA.
+func TLSConfig(conf *tls.Config) SMTPOption {
+	return func(m *SMTP) {
+		m.tlsConf = conf
+	}
+}
+
+// NewSMTP -.
+func NewSMTP(host string, port int, from string, opts ...SMTPOption) (*SMTP, error) {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("mailer - NewSMTP - mail.ParseAddress: %w", err)
+	}
+
+	m := &SMTP{
+		addr:    net.JoinHostPort(host, fmt.Sprint(port)),
+		host:    host,
+		from:    *addr,
+		timeout: _defaultTimeout,
+		tlsConf: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m, nil
+}
+
+// Send -.
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("mailer - SMTP - Send - mail.ParseAddress: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("mailer - SMTP - Send - dial: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return fmt.Errorf("mailer - SMTP - Send - smtp.NewClient: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(m.tlsConf); err != nil {
+			return fmt.Errorf("mailer - SMTP - Send - c.StartTLS: %w", err)
+		}
+	}
+
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return fmt.Errorf("mailer - SMTP - Send - c.Auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("mailer - SMTP - Send - c.Mail: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("mailer - SMTP - Send - c.Rcpt: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("mailer - SMTP - Send - c.Data: %w", err)
+	}
+	if _, err := w.Write(m.compose(to, msg)); err != nil {
+		return fmt.Errorf("mailer - SMTP - Send - write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mailer - SMTP - Send - close data: %w", err)
+	}
+
+	return c.Quit()
+}
+
+func (m *SMTP) compose(to *mail.Address, msg Message) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", m.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", to.String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", messageID(), m.host)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return b.Bytes()
+}
+
+func messageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
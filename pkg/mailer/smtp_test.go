@@ -0,0 +1,132 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpStandIn is a minimal SMTP server that records one delivery.
+type smtpStandIn struct {
+	ln       net.Listener
+	from     string
+	rcpt     []string
+	data     string
+	authLine string
+	done     chan struct{}
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpStandIn{ln: ln, done: make(chan struct{})}
+	go s.serve()
+
+	return s
+}
+
+func (s *smtpStandIn) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) serve() {
+	defer close(s.done)
+
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH"):
+			s.authLine = line
+			reply("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from = line[len("MAIL FROM:"):]
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.rcpt = append(s.rcpt, line[len("RCPT TO:"):])
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+			reply("250 OK queued")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTP_Send(t *testing.T) {
+	server := newSMTPStandIn(t)
+
+	m, err := NewSMTP("localhost", server.port(), "Godev Kit <no-reply@example.com>",
+		Auth("mailer", "secret"), Timeout(5*time.Second))
+	require.NoError(t, err)
+
+	err = m.Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Đặt lại mật khẩu",
+		Body:    "Hello\nReset: https://example.com/reset?token=abc",
+	})
+	require.NoError(t, err)
+	<-server.done
+
+	assert.Equal(t, "<no-reply@example.com>", server.from)
+	assert.Equal(t, []string{"<alice@example.com>"}, server.rcpt)
+	assert.True(t, strings.HasPrefix(server.authLine, "AUTH PLAIN"))
+	assert.Contains(t, server.data, "To: <alice@example.com>\r\n")
+	assert.Contains(t, server.data, "Subject: =?utf-8?q?")
+	assert.Contains(t, server.data, "\r\n\r\nHello\r\nReset: https://example.com/reset?token=abc")
+}
+
+func TestSMTP_RejectsHeaderInjection(t *testing.T) {
+	m, err := NewSMTP("localhost", 25, "no-reply@example.com")
+	require.NoError(t, err)
+
+	err = m.Send(context.Background(), Message{To: "alice@example.com\r\nBcc: eve@example.com", Subject: "hi"})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	err = m.Send(context.Background(), Message{To: "alice@example.com", Subject: "hi\r\nBcc: eve@example.com"})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
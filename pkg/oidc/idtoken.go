@@ -0,0 +1,171 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// _minKeyRefresh rate limits JWKS downloads triggered by unknown key IDs.
+const _minKeyRefresh = time.Minute
+
+// Claims are the validated ID token claims.
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string   `json:"nonce"`
+	AuthorizedParty   string   `json:"azp"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// flexBool accepts true as well as "true"; some providers send email_verified as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = v == "true"
+	}
+
+	return nil
+}
+
+// VerifyIDToken checks the signature of an ID token against the provider's
+// JWKS and validates iss, aud, azp, exp, iat and nonce (OpenID Connect Core
+// 1.0, section 3.1.3.7).
+func (c *Client) VerifyIDToken(ctx context.Context, raw, nonce string) (Claims, error) {
+	var claims idTokenClaims
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "ES256", "ES384", "EdDSA"}),
+		// Time claims are checked below, with leeway
+		jwt.WithoutClaimsValidation(),
+	)
+	if _, err := parser.ParseWithClaims(raw, &claims, c.keys.keyfunc(ctx)); err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	now := c.now()
+	switch {
+	case claims.Issuer != c.meta.Issuer:
+		return Claims{}, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !claims.VerifyAudience(c.clientID, true):
+		return Claims{}, fmt.Errorf("%w: audience %v", ErrInvalidIDToken, claims.Audience)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != c.clientID:
+		return Claims{}, fmt.Errorf("%w: authorized party %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	case claims.ExpiresAt == nil || !now.Before(claims.ExpiresAt.Add(c.leeway)):
+		return Claims{}, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case claims.IssuedAt != nil && now.Add(c.leeway).Before(claims.IssuedAt.Time):
+		return Claims{}, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	case nonce != "" && claims.Nonce != nonce:
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return Claims{
+		Issuer:            claims.Issuer,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// keyCache holds the provider's signing keys and downloads the JWKS again
+// when a token names a key it does not know, as providers rotate keys.
+type keyCache struct {
+	client *Client
+	uri    string
+
+	mu        sync.Mutex
+	verifier  *jwtkeys.KeySet
+	fetchedAt time.Time
+}
+
+func newKeyCache(c *Client, uri string) *keyCache {
+	return &keyCache{client: c, uri: uri}
+}
+
+func (kc *keyCache) keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		verifier, err := kc.get(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := verifier.Keyfunc(token)
+		if !errors.Is(err, jwtkeys.ErrUnknownKey) {
+			return key, err
+		}
+
+		verifier, err = kc.get(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return verifier.Keyfunc(token)
+	}
+}
+
+func (kc *keyCache) get(ctx context.Context, refresh bool) (*jwtkeys.KeySet, error) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if kc.verifier != nil && (!refresh || kc.client.now().Sub(kc.fetchedAt) < _minKeyRefresh) {
+		return kc.verifier, nil
+	}
+
+	var set jwtkeys.JWKS
+	if err := kc.client.getJSON(ctx, kc.uri, &set); err != nil {
+		return nil, fmt.Errorf("oidc - jwks: %w", err)
+	}
+
+	keys := make([]jwtkeys.Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.Key()
+		if err != nil {
+			// Skip key types we cannot use instead of failing on the whole set
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	verifier, err := jwtkeys.NewVerifier(keys...)
+	if err != nil {
+		return nil, fmt.Errorf("oidc - jwks: %w", err)
+	}
+
+	kc.verifier = verifier
+	kc.fetchedAt = kc.client.now()
+
+	return verifier, nil
+}
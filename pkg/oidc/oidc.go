@@ -0,0 +1,265 @@
+// Package oidc is a minimal OpenID Connect relying party: discovery, the
+// authorization code flow with PKCE (RFC 7636) and ID token validation.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	_defaultTimeout = 10 * time.Second
+	// _maxResponseSize bounds the provider responses read into memory.
+	_maxResponseSize = 1 << 20
+)
+
+var (
+	// ErrInvalidIDToken is returned when an ID token fails validation.
+	ErrInvalidIDToken = errors.New("oidc: invalid id token")
+	// ErrTokenExchange is returned when the token endpoint rejects the code.
+	ErrTokenExchange = errors.New("oidc: token exchange failed")
+)
+
+// Metadata is the part of the provider's discovery document the client uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint,omitempty"`
+}
+
+// Token is the token endpoint response.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in,omitempty"`
+}
+
+// Client is an OpenID Connect client registered with one provider.
+type Client struct {
+	meta         Metadata
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	http         *http.Client
+	leeway       time.Duration
+	now          func() time.Time
+	keys         *keyCache
+}
+
+// Option -.
+type Option func(*Client)
+
+// Scopes replaces the requested scopes; "openid" is always added.
+func Scopes(scopes ...string) Option {
+	return func(c *Client) {
+		if len(scopes) > 0 {
+			c.scopes = scopes
+		}
+	}
+}
+
+// HTTPClient sets the client used for discovery, the token endpoint and the JWKS.
+func HTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.http = hc
+		}
+	}
+}
+
+// Leeway tolerates clock skew when checking the ID token's exp and iat.
+func Leeway(d time.Duration) Option {
+	return func(c *Client) {
+		c.leeway = d
+	}
+}
+
+// New discovers the provider at issuer and returns a client for it.
+func New(ctx context.Context, issuer, clientID, clientSecret, redirectURL string, opts ...Option) (*Client, error) {
+	c := &Client{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       []string{"openid", "email", "profile"},
+		http:         &http.Client{Timeout: _defaultTimeout},
+		leeway:       time.Minute,
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if !slices.Contains(c.scopes, "openid") {
+		c.scopes = append([]string{"openid"}, c.scopes...)
+	}
+
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := c.getJSON(ctx, wellKnown, &c.meta); err != nil {
+		return nil, fmt.Errorf("oidc - New - discovery: %w", err)
+	}
+
+	// OpenID Connect Discovery 1.0, section 4.3
+	if c.meta.Issuer != issuer {
+		return nil, fmt.Errorf("oidc - New: discovery issuer %q does not match %q", c.meta.Issuer, issuer)
+	}
+	if c.meta.AuthorizationEndpoint == "" || c.meta.TokenEndpoint == "" || c.meta.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc - New: discovery document of %q is incomplete", issuer)
+	}
+
+	c.keys = newKeyCache(c, c.meta.JWKSURI)
+
+	return c, nil
+}
+
+// Issuer returns the provider's issuer identifier.
+func (c *Client) Issuer() string {
+	return c.meta.Issuer
+}
+
+// Metadata returns the discovered provider metadata.
+func (c *Client) Metadata() Metadata {
+	return c.meta
+}
+
+// AuthCodeURL returns the provider URL that starts a login. state and nonce
+// are echoed back in the callback and the ID token; codeChallenge is
+// CodeChallenge of the verifier later passed to Exchange.
+func (c *Client) AuthCodeURL(state, nonce, codeChallenge string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.clientID},
+		"redirect_uri":          {c.redirectURL},
+		"scope":                 {strings.Join(c.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(c.meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return c.meta.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems an authorization code at the token endpoint.
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier string) (Token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.redirectURL},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, fmt.Errorf("oidc - Exchange - http.NewRequest: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// client_secret_basic, RFC 6749 section 2.3.1
+	req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("oidc - Exchange - c.http.Do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, _maxResponseSize))
+	if err != nil {
+		return Token{}, fmt.Errorf("oidc - Exchange - io.ReadAll: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &oauthErr)
+		return Token{}, fmt.Errorf("%w: status %d %s %s", ErrTokenExchange, resp.StatusCode, oauthErr.Error, oauthErr.Description)
+	}
+
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return Token{}, fmt.Errorf("oidc - Exchange - json.Unmarshal: %w", err)
+	}
+	if token.IDToken == "" {
+		return Token{}, fmt.Errorf("%w: no id_token in the response", ErrTokenExchange)
+	}
+
+	return token, nil
+}
+
+// Authenticate exchanges the code and returns the claims of the validated ID token.
+func (c *Client) Authenticate(ctx context.Context, code, codeVerifier, nonce string) (Claims, error) {
+	token, err := c.Exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	return c.VerifyIDToken(ctx, token.IDToken, nonce)
+}
+
+func (c *Client) getJSON(ctx context.Context, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("c.http.Do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", target, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, _maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", target, err)
+	}
+
+	return nil
+}
+
+// RandomString returns n random bytes, base64url encoded; use it for state and nonce.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("oidc - RandomString - rand.Read: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewCodeVerifier returns a PKCE code verifier with 256 bits of entropy.
+func NewCodeVerifier() (string, error) {
+	return RandomString(32)
+}
+
+// CodeChallenge returns the S256 challenge of a PKCE code verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,132 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/pkg/oidc"
+	"github.com/ducnpdev/godev-kit/pkg/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const _redirectURL = "http://localhost:8080/v1/auth/oidc/callback"
+
+func newProvider(t *testing.T) (*oidctest.Server, *oidc.Client) {
+	t.Helper()
+
+	srv, err := oidctest.NewServer("godev-kit", "s3cret/+")
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+
+	client, err := oidc.New(context.Background(), srv.Issuer(), "godev-kit", "s3cret/+", _redirectURL)
+	require.NoError(t, err)
+
+	return srv, client
+}
+
+// login runs the flow up to the callback and returns the code and verifier.
+func login(t *testing.T, srv *oidctest.Server, client *oidc.Client, nonce string) (code, verifier string) {
+	t.Helper()
+
+	verifier, err := oidc.NewCodeVerifier()
+	require.NoError(t, err)
+
+	code, state, err := srv.Authorize(client.AuthCodeURL("state-1", nonce, oidc.CodeChallenge(verifier)))
+	require.NoError(t, err)
+	require.Equal(t, "state-1", state)
+
+	return code, verifier
+}
+
+func TestClient_Authenticate(t *testing.T) {
+	srv, client := newProvider(t)
+	ctx := context.Background()
+
+	t.Run("discovery", func(t *testing.T) {
+		assert.Equal(t, srv.Issuer(), client.Issuer())
+		assert.Equal(t, srv.Issuer()+"/token", client.Metadata().TokenEndpoint)
+
+		u, err := url.Parse(client.AuthCodeURL("s", "n", "c"))
+		require.NoError(t, err)
+		assert.Equal(t, "openid email profile", u.Query().Get("scope"))
+		assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+		assert.Equal(t, _redirectURL, u.Query().Get("redirect_uri"))
+	})
+
+	t.Run("returns the verified claims", func(t *testing.T) {
+		code, verifier := login(t, srv, client, "nonce-1")
+
+		claims, err := client.Authenticate(ctx, code, verifier, "nonce-1")
+		require.NoError(t, err)
+		assert.Equal(t, srv.Issuer(), claims.Issuer)
+		assert.Equal(t, "user-1", claims.Subject)
+		assert.Equal(t, "user@example.com", claims.Email)
+		assert.True(t, claims.EmailVerified)
+
+		_, err = client.Authenticate(ctx, code, verifier, "nonce-1")
+		assert.ErrorIs(t, err, oidc.ErrTokenExchange, "codes are single use")
+	})
+
+	t.Run("rejects a wrong PKCE verifier", func(t *testing.T) {
+		code, _ := login(t, srv, client, "nonce-1")
+
+		other, err := oidc.NewCodeVerifier()
+		require.NoError(t, err)
+
+		_, err = client.Authenticate(ctx, code, other, "nonce-1")
+		assert.ErrorIs(t, err, oidc.ErrTokenExchange)
+	})
+
+	t.Run("rejects a nonce mismatch", func(t *testing.T) {
+		code, verifier := login(t, srv, client, "nonce-1")
+
+		_, err := client.Authenticate(ctx, code, verifier, "nonce-2")
+		assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+	})
+
+	t.Run("accepts email_verified sent as a string", func(t *testing.T) {
+		srv.SetClaims(func(c jwt.MapClaims) { c["email_verified"] = "true" })
+		defer srv.SetClaims(nil)
+
+		code, verifier := login(t, srv, client, "n")
+		claims, err := client.Authenticate(ctx, code, verifier, "n")
+		require.NoError(t, err)
+		assert.True(t, claims.EmailVerified)
+	})
+
+	for name, mutate := range map[string]func(jwt.MapClaims){
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"foreign azp":    func(c jwt.MapClaims) { c["aud"] = []string{"godev-kit", "other"}; c["azp"] = "other" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() },
+		"future iat":     func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() },
+		"no subject":     func(c jwt.MapClaims) { delete(c, "sub") },
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			srv.SetClaims(mutate)
+			defer srv.SetClaims(nil)
+
+			code, verifier := login(t, srv, client, "n")
+			_, err := client.Authenticate(ctx, code, verifier, "n")
+			assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+		})
+	}
+}
+
+func TestNew_IssuerMismatch(t *testing.T) {
+	srv, err := oidctest.NewServer("godev-kit", "secret")
+	require.NoError(t, err)
+	defer srv.Close()
+
+	_, err = oidc.New(context.Background(), srv.Issuer()+"/", "godev-kit", "secret", _redirectURL)
+	assert.Error(t, err)
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636, appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
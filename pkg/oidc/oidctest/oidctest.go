@@ -0,0 +1,264 @@
+// Package oidctest runs an in-memory OpenID Connect provider for tests. It
+// implements discovery, the authorization endpoint (logging in User without a
+// prompt), the token endpoint with PKCE and client_secret_basic, and the JWKS.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ducnpdev/godev-kit/pkg/jwtkeys"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// User is the account the provider logs in.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Server is a mock provider. Change User and Claims between logins as needed.
+type Server struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+
+	mu   sync.Mutex
+	user User
+	// claims, when set, edits the ID token claims before signing
+	claims func(jwt.MapClaims)
+
+	keys  *jwtkeys.KeySet
+	codes map[string]grant
+}
+
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        User
+}
+
+// NewServer starts a provider with a fresh RSA signing key. Close it when done.
+func NewServer(clientID, clientSecret string) (*Server, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("oidctest - rsa.GenerateKey: %w", err)
+	}
+
+	key, err := jwtkeys.NewPrivateKey("oidctest", priv)
+	if err != nil {
+		return nil, fmt.Errorf("oidctest - jwtkeys.NewPrivateKey: %w", err)
+	}
+
+	keys, err := jwtkeys.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("oidctest - jwtkeys.New: %w", err)
+	}
+
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		user:         User{Subject: "user-1", Email: "user@example.com", EmailVerified: true, Name: "Test User"},
+		keys:         keys,
+		codes:        make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	s.Server = httptest.NewServer(mux)
+
+	return s, nil
+}
+
+// Issuer returns the provider's issuer identifier.
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// SetUser sets the account logged in by the next authorization request.
+func (s *Server) SetUser(u User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = u
+}
+
+// SetClaims sets a function that edits the ID token claims before signing,
+// e.g. to test how a client handles a wrong audience.
+func (s *Server) SetClaims(fn func(jwt.MapClaims)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims = fn
+}
+
+// Authorize requests authURL like a browser would and returns the code and
+// state the provider redirects back with, without following the redirect.
+func (s *Server) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("oidctest - Authorize: status %d", resp.StatusCode)
+	}
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	if e := loc.Query().Get("error"); e != "" {
+		return "", "", errors.New("oidctest - Authorize: " + e)
+	}
+
+	return loc.Query().Get("code"), loc.Query().Get("state"), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	reply := redirect.Query()
+	reply.Set("state", q.Get("state"))
+
+	switch {
+	case q.Get("client_id") != s.ClientID:
+		reply.Set("error", "unauthorized_client")
+	case q.Get("response_type") != "code":
+		reply.Set("error", "unsupported_response_type")
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		reply.Set("error", "invalid_request")
+	default:
+		code := randomString()
+
+		s.mu.Lock()
+		s.codes[code] = grant{
+			redirectURI: q.Get("redirect_uri"),
+			challenge:   q.Get("code_challenge"),
+			nonce:       q.Get("nonce"),
+			user:        s.user,
+		}
+		s.mu.Unlock()
+
+		reply.Set("code", code)
+	}
+
+	redirect.RawQuery = reply.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	}
+	if !ok || id != s.ClientID || secret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.mu.Lock()
+	code := r.PostFormValue("code")
+	g, ok := s.codes[code]
+	// Codes are single use
+	delete(s.codes, code)
+	mutate := s.claims
+	s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || g.redirectURI != r.PostFormValue("redirect_uri") || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.URL,
+		"sub":            g.user.Subject,
+		"aud":            s.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          g.nonce,
+		"email":          g.user.Email,
+		"email_verified": g.user.EmailVerified,
+		"name":           g.user.Name,
+	}
+	if mutate != nil {
+		mutate(claims)
+	}
+
+	idToken, err := s.keys.Sign(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+		"expires_in":   300,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.keys.JWKS())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
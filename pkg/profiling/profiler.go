@@ -317,7 +317,8 @@ func (p *Profiler) GetAllProfiles() map[string]*FunctionProfile {
 }
 
 // SetupRoutes sets up profiling and monitoring routes
-func (p *Profiler) SetupRoutes(router *gin.Engine) {
+// guards run before the endpoints that change process state (POST /gc).
+func (p *Profiler) SetupRoutes(router *gin.Engine, guards ...gin.HandlerFunc) {
 	if !p.enabled {
 		return
 	}
@@ -342,7 +343,7 @@ func (p *Profiler) SetupRoutes(router *gin.Engine) {
 		debugGroup.GET("/stats", p.getStatsHandler)
 		debugGroup.GET("/memory", p.getMemoryHandler)
 		debugGroup.GET("/goroutines", p.getGoroutinesHandler)
-		debugGroup.POST("/gc", p.triggerGCHandler)
+		debugGroup.POST("/gc", append(guards, p.triggerGCHandler)...)
 	}
 }
 
@@ -0,0 +1,27 @@
+package qrimage
+
+import "image"
+
+// Option -.
+type Option func(*Renderer)
+
+// Size sets the output width and height in pixels.
+func Size(size int) Option {
+	return func(r *Renderer) {
+		r.size = size
+	}
+}
+
+// Recovery sets the error-correction level.
+func Recovery(level Level) Option {
+	return func(r *Renderer) {
+		r.level = level
+	}
+}
+
+// Logo overlays img in the centre of the code.
+func Logo(img image.Image) Option {
+	return func(r *Renderer) {
+		r.logo = img
+	}
+}
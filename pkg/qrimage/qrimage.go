@@ -0,0 +1,236 @@
+// Package qrimage renders QR code content as PNG or SVG images.
+package qrimage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	_defaultSize = 256
+	_minSize     = 64
+	_maxSize     = 1024
+
+	// _logoRatio is the share of the image width covered by a logo.
+	_logoRatio = 5
+)
+
+// Format is an output image format.
+type Format string
+
+const (
+	FormatPNG Format = "png"
+	FormatSVG Format = "svg"
+)
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// Level is a QR error-correction level.
+type Level string
+
+const (
+	LevelLow     Level = "L"
+	LevelMedium  Level = "M"
+	LevelQuart   Level = "Q"
+	LevelHighest Level = "H"
+)
+
+// ParseFormat validates a format name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatPNG, FormatSVG:
+		return f, nil
+	default:
+		return "", fmt.Errorf("qrimage - unsupported format %q", s)
+	}
+}
+
+// ParseLevel validates an error-correction level name.
+func ParseLevel(s string) (Level, error) {
+	switch l := Level(strings.ToUpper(s)); l {
+	case LevelLow, LevelMedium, LevelQuart, LevelHighest:
+		return l, nil
+	default:
+		return "", fmt.Errorf("qrimage - unsupported error-correction level %q", s)
+	}
+}
+
+func (l Level) recovery() qrcode.RecoveryLevel {
+	switch l {
+	case LevelLow:
+		return qrcode.Low
+	case LevelQuart:
+		return qrcode.High
+	case LevelHighest:
+		return qrcode.Highest
+	default:
+		return qrcode.Medium
+	}
+}
+
+// Renderer -.
+type Renderer struct {
+	size  int
+	level Level
+	logo  image.Image
+}
+
+// New -.
+func New(opts ...Option) *Renderer {
+	r := &Renderer{
+		size:  _defaultSize,
+		level: LevelMedium,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.size < _minSize {
+		r.size = _minSize
+	}
+	if r.size > _maxSize {
+		r.size = _maxSize
+	}
+	// A logo hides modules in the centre, so it needs the highest redundancy to stay scannable
+	if r.logo != nil {
+		r.level = LevelHighest
+	}
+
+	return r
+}
+
+// Size returns the clamped output size in pixels.
+func (r *Renderer) Size() int {
+	return r.size
+}
+
+// Level returns the error-correction level used, which a logo raises to LevelHighest.
+func (r *Renderer) Level() Level {
+	return r.level
+}
+
+// Render encodes content in the given format.
+func (r *Renderer) Render(content string, format Format) ([]byte, error) {
+	q, err := qrcode.New(content, r.level.recovery())
+	if err != nil {
+		return nil, fmt.Errorf("qrimage - Render - qrcode.New: %w", err)
+	}
+
+	switch format {
+	case FormatPNG:
+		return r.png(q)
+	case FormatSVG:
+		return r.svg(q)
+	default:
+		return nil, fmt.Errorf("qrimage - Render - unsupported format %q", format)
+	}
+}
+
+func (r *Renderer) png(q *qrcode.QRCode) ([]byte, error) {
+	if r.logo == nil {
+		return q.PNG(r.size)
+	}
+
+	code := q.Image(r.size)
+	canvas := image.NewRGBA(code.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), code, image.Point{}, draw.Src)
+
+	side := r.size / _logoRatio
+	offset := (r.size - side) / 2
+	area := image.Rect(offset, offset, offset+side, offset+side)
+
+	// White pad behind the logo keeps its edges readable against the modules
+	pad := side / 10
+	draw.Draw(canvas, area.Inset(-pad), image.NewUniform(color.White), image.Point{}, draw.Src)
+	drawScaled(canvas, area, r.logo)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("qrimage - png - png.Encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *Renderer) svg(q *qrcode.QRCode) ([]byte, error) {
+	bitmap := q.Bitmap()
+	modules := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		r.size, r.size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#ffffff"/><path fill="#000000" d="`, modules, modules)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/>`)
+
+	if r.logo != nil {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, r.logo); err != nil {
+			return nil, fmt.Errorf("qrimage - svg - png.Encode: %w", err)
+		}
+
+		side := float64(modules) / _logoRatio
+		offset := (float64(modules) - side) / 2
+		pad := side / 10
+		fmt.Fprintf(&buf, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="#ffffff"/>`,
+			offset-pad, offset-pad, side+2*pad, side+2*pad)
+		fmt.Fprintf(&buf, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" href="data:image/png;base64,%s"/>`,
+			offset, offset, side, side, base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+// drawScaled draws src into dst's area using nearest-neighbour sampling.
+func drawScaled(dst draw.Image, area image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	w, h := area.Dx(), area.Dy()
+	for y := 0; y < h; y++ {
+		sy := sb.Min.Y + y*sb.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := sb.Min.X + x*sb.Dx()/w
+			c := src.At(sx, sy)
+			if _, _, _, a := c.RGBA(); a == 0 {
+				continue
+			}
+			dst.Set(area.Min.X+x, area.Min.Y+y, c)
+		}
+	}
+}
+
+// LoadLogo reads a PNG logo from path.
+func LoadLogo(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("qrimage - LoadLogo - os.Open: %w", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("qrimage - LoadLogo - png.Decode: %w", err)
+	}
+	return img, nil
+}
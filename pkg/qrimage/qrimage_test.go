@@ -0,0 +1,130 @@
+package qrimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Size(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected int
+	}{
+		{name: "default", expected: _defaultSize},
+		{name: "in range", opts: []Option{Size(300)}, expected: 300},
+		{name: "below minimum", opts: []Option{Size(10)}, expected: _minSize},
+		{name: "above maximum", opts: []Option{Size(5000)}, expected: _maxSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.opts...)
+			assert.Equal(t, tt.expected, r.Size())
+
+			data, err := r.Render("hello", FormatPNG)
+			require.NoError(t, err)
+			img, err := png.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, img.Bounds().Dx())
+			assert.Equal(t, tt.expected, img.Bounds().Dy())
+
+			data, err = r.Render("hello", FormatSVG)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), fmt.Sprintf(`width="%d" height="%d"`, tt.expected, tt.expected))
+		})
+	}
+}
+
+func TestNew_LogoRaisesLevel(t *testing.T) {
+	assert.Equal(t, LevelLow, New(Recovery(LevelLow)).Level())
+
+	logo := image.NewUniform(color.Black)
+	assert.Equal(t, LevelHighest, New(Recovery(LevelLow), Logo(logo)).Level())
+}
+
+func TestRenderer_Render(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		format Format
+		check  func(t *testing.T, data []byte)
+	}{
+		{
+			name:   "png",
+			format: FormatPNG,
+			check: func(t *testing.T, data []byte) {
+				_, err := png.Decode(bytes.NewReader(data))
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:   "png with logo",
+			opts:   []Option{Logo(logo)},
+			format: FormatPNG,
+			check: func(t *testing.T, data []byte) {
+				_, err := png.Decode(bytes.NewReader(data))
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:   "svg",
+			format: FormatSVG,
+			check: func(t *testing.T, data []byte) {
+				assert.True(t, bytes.HasPrefix(data, []byte("<svg ")))
+				assert.True(t, bytes.HasSuffix(data, []byte("</svg>")))
+				assert.NotContains(t, string(data), "<image")
+			},
+		},
+		{
+			name:   "svg with logo",
+			opts:   []Option{Logo(logo)},
+			format: FormatSVG,
+			check: func(t *testing.T, data []byte) {
+				assert.Contains(t, string(data), `href="data:image/png;base64,`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := New(tt.opts...).Render("hello", tt.format)
+			require.NoError(t, err)
+			tt.check(t, data)
+		})
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		_, err := New().Render("hello", Format("gif"))
+		assert.Error(t, err)
+	})
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("SVG")
+	require.NoError(t, err)
+	assert.Equal(t, FormatSVG, f)
+	assert.Equal(t, "image/svg+xml", f.ContentType())
+	assert.Equal(t, "image/png", FormatPNG.ContentType())
+
+	_, err = ParseFormat("gif")
+	assert.Error(t, err)
+}
+
+func TestParseLevel(t *testing.T) {
+	l, err := ParseLevel("q")
+	require.NoError(t, err)
+	assert.Equal(t, LevelQuart, l)
+
+	_, err = ParseLevel("X")
+	assert.Error(t, err)
+}
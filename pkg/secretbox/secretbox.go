@@ -0,0 +1,79 @@
+// Package secretbox encrypts small secrets for storage with AES-256-GCM.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the key length in bytes.
+const KeySize = 32
+
+// ErrDecrypt is returned when a sealed value was tampered with or sealed with another key.
+var ErrDecrypt = errors.New("secretbox: cannot decrypt")
+
+// Box seals and opens values with one key.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a box for a 32 byte key.
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secretbox - New: key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secretbox - New - aes.NewCipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("secretbox - New - cipher.NewGCM: %w", err)
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+// NewFromBase64 is New with a standard base64 encoded key, as kept in config.
+func NewFromBase64(key string) (*Box, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("secretbox - NewFromBase64: %w", err)
+	}
+
+	return New(raw)
+}
+
+// Seal encrypts plaintext with a random nonce and returns nonce and
+// ciphertext, base64 encoded. additionalData, e.g. the owning row's ID, must
+// be given again to Open, so a value copied to another row does not open.
+func (b *Box) Seal(plaintext, additionalData []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("secretbox - Seal - rand.Read: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(b.aead.Seal(nonce, nonce, plaintext, additionalData)), nil
+}
+
+// Open decrypts a value returned by Seal.
+func (b *Box) Open(sealed string, additionalData []byte) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
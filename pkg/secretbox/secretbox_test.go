@@ -0,0 +1,42 @@
+package secretbox
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBox(t *testing.T) {
+	box, err := New(bytes.Repeat([]byte{1}, KeySize))
+	require.NoError(t, err)
+
+	sealed, err := box.Seal([]byte("JBSWY3DPEHPK3PXP"), []byte("user:1"))
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+
+	again, err := box.Seal([]byte("JBSWY3DPEHPK3PXP"), []byte("user:1"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "nonce must be random")
+
+	plain, err := box.Open(sealed, []byte("user:1"))
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", string(plain))
+
+	_, err = box.Open(sealed, []byte("user:2"))
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	other, err := New(bytes.Repeat([]byte{2}, KeySize))
+	require.NoError(t, err)
+	_, err = other.Open(sealed, []byte("user:1"))
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = box.Open("not base64!", nil)
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = New([]byte("short"))
+	assert.Error(t, err)
+	_, err = NewFromBase64("AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=")
+	assert.NoError(t, err)
+}
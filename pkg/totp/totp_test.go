@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 seed of RFC 6238 appendix B.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; the last six digits are the 6-digit code
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "t=%d", tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Unix(1_700_000_000, 0)
+	current, err := Code(secret, now)
+	require.NoError(t, err)
+	previous, err := Code(secret, now.Add(-Period))
+	require.NoError(t, err)
+	old, err := Code(secret, now.Add(-3*Period))
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, current, now)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	step, ok = Validate(secret, previous, now)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+
+	_, ok = Validate(secret, old, now)
+	assert.False(t, ok)
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok)
+	_, ok = Validate("not base32!", current, now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Godev Kit", "alice@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Godev Kit:alice@example.com", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "Godev Kit", u.Query().Get("issuer"))
+}
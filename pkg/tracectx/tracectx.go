@@ -0,0 +1,161 @@
+// Package tracectx carries W3C Trace Context (traceparent and tracestate)
+// and request IDs through a context, and across HTTP requests and messages.
+package tracectx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Header names. Lower case, as Kafka headers are case sensitive; HTTP
+// headers match them regardless.
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+	HeaderRequestID   = "x-request-id"
+)
+
+// _maxRequestID bounds request IDs taken from callers.
+const _maxRequestID = 128
+
+// ErrInvalidTraceparent is returned for traceparent values Parse rejects.
+var ErrInvalidTraceparent = errors.New("tracectx: invalid traceparent")
+
+// SpanContext is the position in a trace a traceparent header describes.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+	State   string
+}
+
+// New starts a trace.
+func New() SpanContext {
+	var s SpanContext
+	_, _ = rand.Read(s.TraceID[:])
+	_, _ = rand.Read(s.SpanID[:])
+	return s
+}
+
+// Parse reads a traceparent header, and the tracestate that goes with it.
+// Versions after 00 are read as 00, as the specification asks.
+func Parse(traceparent, tracestate string) (SpanContext, error) {
+	// version-traceid-spanid-flags
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var (
+		s     SpanContext
+		flags [1]byte
+	)
+	if !decodeHex(s.TraceID[:], parts[1]) || !decodeHex(s.SpanID[:], parts[2]) ||
+		!decodeHex(flags[:], parts[3]) || !s.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	s.Flags = flags[0]
+	s.State = strings.TrimSpace(tracestate)
+
+	return s, nil
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// IsValid reports whether neither ID is all zeros.
+func (s SpanContext) IsValid() bool {
+	return s.TraceID != [16]byte{} && s.SpanID != [8]byte{}
+}
+
+// Child is a new span in the same trace.
+func (s SpanContext) Child() SpanContext {
+	_, _ = rand.Read(s.SpanID[:])
+	return s
+}
+
+// TraceIDString -.
+func (s SpanContext) TraceIDString() string {
+	return hex.EncodeToString(s.TraceID[:])
+}
+
+// Traceparent formats s as a version 00 traceparent header.
+func (s SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(s.TraceID[:]) + "-" + hex.EncodeToString(s.SpanID[:]) + "-" + hex.EncodeToString([]byte{s.Flags})
+}
+
+type (
+	spanKey      struct{}
+	requestIDKey struct{}
+)
+
+// WithSpan returns ctx carrying s.
+func WithSpan(ctx context.Context, s SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// Span returns the span ctx carries.
+func Span(ctx context.Context) (SpanContext, bool) {
+	s, ok := ctx.Value(spanKey{}).(SpanContext)
+	return s, ok
+}
+
+// WithRequestID returns ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID ctx carries, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Inject passes the trace context and request ID of ctx to set, e.g. to
+// write them as outgoing headers.
+func Inject(ctx context.Context, set func(key, value string)) {
+	if s, ok := Span(ctx); ok && s.IsValid() {
+		set(HeaderTraceparent, s.Traceparent())
+		if s.State != "" {
+			set(HeaderTracestate, s.State)
+		}
+	}
+	if id := RequestID(ctx); id != "" {
+		set(HeaderRequestID, id)
+	}
+}
+
+// Extract returns ctx carrying the trace context and request ID get returns
+// for incoming headers. The trace continues in a new span, or starts when
+// there is no valid traceparent; a missing request ID is generated.
+func Extract(ctx context.Context, get func(key string) string) context.Context {
+	s, err := Parse(get(HeaderTraceparent), get(HeaderTracestate))
+	if err != nil {
+		s = New()
+	} else {
+		s = s.Child()
+	}
+
+	id := get(HeaderRequestID)
+	if id == "" || len(id) > _maxRequestID {
+		id = NewRequestID()
+	}
+
+	return WithRequestID(WithSpan(ctx, s), id)
+}
@@ -0,0 +1,63 @@
+package tracectx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	// Example of the W3C Trace Context specification
+	s, err := Parse("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "congo=t61rcWkgMzE")
+	require.NoError(t, err)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", s.TraceIDString())
+	assert.Equal(t, byte(1), s.Flags)
+	assert.Equal(t, "congo=t61rcWkgMzE", s.State)
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", s.Traceparent())
+
+	// Later versions may append fields
+	_, err = Parse("01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", "")
+	require.NoError(t, err)
+
+	for _, invalid := range []string{
+		"",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319-b7ad6b7169203331-01",
+	} {
+		_, err := Parse(invalid, "")
+		assert.ErrorIs(t, err, ErrInvalidTraceparent, invalid)
+	}
+}
+
+func TestExtractInject(t *testing.T) {
+	in := map[string]string{
+		HeaderTraceparent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		HeaderRequestID:   "req-1",
+	}
+	ctx := Extract(context.Background(), func(key string) string { return in[key] })
+
+	out := map[string]string{}
+	Inject(ctx, func(key, value string) { out[key] = value })
+
+	assert.Equal(t, "req-1", out[HeaderRequestID])
+	s, err := Parse(out[HeaderTraceparent], "")
+	require.NoError(t, err)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", s.TraceIDString(), "the trace continues")
+	assert.NotEqual(t, in[HeaderTraceparent], out[HeaderTraceparent], "in a new span")
+}
+
+func TestExtract_StartsTrace(t *testing.T) {
+	ctx := Extract(context.Background(), func(string) string { return "" })
+
+	s, ok := Span(ctx)
+	require.True(t, ok)
+	assert.True(t, s.IsValid())
+	assert.Len(t, RequestID(ctx), 32)
+}